@@ -0,0 +1,286 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/logger"
+)
+
+// Event is the event_type of an entry. The names and the fields of Entry are stable, new events and fields may be
+// added but existing ones are not renamed or removed, so that external tools can rely on them.
+type Event string
+
+const (
+	Login        Event = "login"
+	LoginFailed  Event = "login_failed"
+	Logout       Event = "logout"
+	LogoutAll    Event = "logout_all"
+	RevokeAll    Event = "revoke_all"
+	RoomCreate   Event = "room_create"
+	RoomClose    Event = "room_close"
+	RoomJoin     Event = "room_join"
+	RoomLeave    Event = "room_leave"
+	Kick         Event = "kick"
+	Ban          Event = "ban"
+	InviteCreate Event = "invite_create"
+	Broadcast    Event = "broadcast"
+	UsersReload  Event = "users_reload"
+	UserDelete   Event = "user_delete"
+	ConfigRead   Event = "config_read"
+)
+
+// Outcomes of the entries, only login_failed and failed users_reload entries are failures.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Reasons of the login_failed and room_close entries.
+const (
+	ReasonInvalidCredentials = "invalid_credentials"
+	ReasonLockedOut          = "locked_out"
+	ReasonInvalidTOTP        = "invalid_totp"
+	ReasonTOTPNotEnrolled    = "totp_not_enrolled"
+	ReasonEmpty              = "empty"
+	ReasonOwnerLeft          = "owner_left"
+	ReasonExpired            = "expired"
+	ReasonShutdown           = "shutdown"
+)
+
+// Entry is a single line of the audit log. The actor of login_failed is the user name of the attempt, the actor of
+// room_close is the creator of the room and it has no source ip and session.
+type Entry struct {
+	Timestamp      time.Time `json:"timestamp"`
+	EventType      Event     `json:"event_type"`
+	ActorUsername  string    `json:"actor_username"`
+	TargetUsername string    `json:"target_username,omitempty"`
+	RoomID         string    `json:"room_id,omitempty"`
+	SourceIP       string    `json:"source_ip"`
+	SessionID      string    `json:"session_id"`
+	Reason         string    `json:"reason,omitempty"`
+	// Outcome is set by Write if it is empty, to failure for login_failed and to success otherwise.
+	Outcome string `json:"outcome"`
+}
+
+// Log writes newline delimited json entries to a file or to the main log. A nil Log discards all entries, so callers
+// don't have to check if the audit log is enabled.
+type Log struct {
+	// Pseudonyms replaces the user names, ips and session ids of the entries in privacy mode.
+	Pseudonyms *logger.Pseudonyms
+
+	lock sync.Mutex
+	path string
+	file *os.File
+}
+
+// Open opens the audit log in append mode. Every entry is synced to disk before Write returns.
+func Open(path string) (*Log, error) {
+	l := &Log{path: path}
+	if err := l.Reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Main returns a Log that writes the entries to the main log in the audit field. It is not affected by the log level,
+// the entry stays json with the console output.
+func Main() *Log {
+	return &Log{}
+}
+
+// Reopen closes and opens the file again, so that it can be rotated by external tools like logrotate.
+func (l *Log) Reopen() error {
+	if l.path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_SYNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.file != nil {
+		_ = l.file.Close()
+	}
+	l.file = file
+	return nil
+}
+
+// ReopenOnSignal reopens the file on SIGHUP.
+func (l *Log) ReopenOnSignal() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			if err := l.Reopen(); err != nil {
+				log.Error().Err(err).Str("file", l.path).Msg("Could not reopen audit log")
+				continue
+			}
+			log.Info().Str("file", l.path).Msg("Reopened audit log")
+		}
+	}()
+}
+
+// Write appends the entry, the timestamp is set if it is empty.
+func (l *Log) Write(entry Entry) {
+	if l == nil {
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now().UTC()
+	}
+	entry.ActorUsername = l.Pseudonyms.User(entry.ActorUsername)
+	entry.TargetUsername = l.Pseudonyms.User(entry.TargetUsername)
+	entry.SourceIP = l.Pseudonyms.IP(entry.SourceIP)
+	entry.SessionID = l.Pseudonyms.Session(entry.SessionID)
+	if entry.Outcome == "" {
+		entry.Outcome = OutcomeSuccess
+		if entry.EventType == LoginFailed {
+			entry.Outcome = OutcomeFailure
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Error().Err(err).Msg("Audit log encode")
+		return
+	}
+
+	if l.path == "" {
+		log.Log().RawJSON("audit", line).Msg("Audit")
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		log.Error().Err(err).Str("file", l.path).Str("event", string(entry.EventType)).Msg("Could not write audit log")
+	}
+}
+
+// UserEntries returns the entries of the file where the user is the actor or the target. In privacy mode only the
+// entries of the current pseudonyms are found. Entries of the main log cannot be read, none are returned then.
+func (l *Log) UserEntries(user string) ([]Entry, error) {
+	entries := []Entry{}
+	if l == nil || l.path == "" {
+		return entries, nil
+	}
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	user = l.Pseudonyms.User(user)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		if entry.ActorUsername == user || entry.TargetUsername == user {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// DeleteUserEntries removes the entries of the file where the user is the actor or the target and returns how many
+// were removed. The file is replaced by renaming a temporary file, rotated files aren't changed.
+func (l *Log) DeleteUserEntries(user string) (int, error) {
+	if l == nil || l.path == "" {
+		return 0, nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	content, err := os.ReadFile(l.path)
+	if err != nil {
+		return 0, err
+	}
+	user = l.Pseudonyms.User(user)
+	kept := []byte{}
+	removed := 0
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return 0, err
+		}
+		if entry.ActorUsername == user || entry.TargetUsername == user {
+			removed++
+			continue
+		}
+		kept = append(kept, line...)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), "."+filepath.Base(l.path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(kept); err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return 0, err
+	}
+	// the old file handle points to the replaced file.
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_SYNC, 0o600)
+	if err != nil {
+		return removed, err
+	}
+	_ = l.file.Close()
+	l.file = file
+	return removed, nil
+}
+
+func (l *Log) Close() error {
+	if l == nil || l.path == "" {
+		return nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.file.Close()
+}
+
+// ClientIP returns the ip of the X-Real-IP header if the proxy headers are trusted, otherwise the RemoteIP.
+func ClientIP(r *http.Request, trustProxyHeaders bool) string {
+	if realIP := r.Header.Get("X-Real-IP"); trustProxyHeaders && realIP != "" {
+		return realIP
+	}
+	return RemoteIP(r)
+}
+
+// RemoteIP returns the ip of the http client.
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
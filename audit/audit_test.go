@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+	"github.com/screego/server/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var allEvents = []Event{Login, LoginFailed, Logout, RoomCreate, RoomClose, RoomJoin, RoomLeave, Kick, Ban, InviteCreate, UsersReload}
+
+func readEntries(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var entries []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), scanner.Text())
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestLog_schema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+
+	for _, event := range allEvents {
+		log.Write(Entry{
+			EventType:      event,
+			ActorUsername:  "alice",
+			TargetUsername: "bob",
+			RoomID:         "room",
+			SourceIP:       "127.0.0.1",
+			SessionID:      "session",
+		})
+	}
+
+	entries := readEntries(t, path)
+	require.Len(t, entries, len(allEvents))
+	for i, entry := range entries {
+		assert.Len(t, entry, 8)
+		assert.Equal(t, string(allEvents[i]), entry["event_type"])
+		if allEvents[i] == LoginFailed {
+			assert.Equal(t, OutcomeFailure, entry["outcome"])
+		} else {
+			assert.Equal(t, OutcomeSuccess, entry["outcome"])
+		}
+		assert.Equal(t, "alice", entry["actor_username"])
+		assert.Equal(t, "bob", entry["target_username"])
+		assert.Equal(t, "room", entry["room_id"])
+		assert.Equal(t, "127.0.0.1", entry["source_ip"])
+		assert.Equal(t, "session", entry["session_id"])
+
+		timestamp, err := time.Parse(time.RFC3339Nano, entry["timestamp"].(string))
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), timestamp, time.Minute)
+	}
+}
+
+func TestLog_appendAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path)
+	require.NoError(t, err)
+	log.Write(Entry{EventType: Login, ActorUsername: "alice"})
+	require.NoError(t, log.Close())
+
+	log, err = Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+	log.Write(Entry{EventType: Logout, ActorUsername: "alice"})
+	assert.Len(t, readEntries(t, path), 2)
+
+	rotated := path + ".1"
+	require.NoError(t, os.Rename(path, rotated))
+	require.NoError(t, log.Reopen())
+	log.Write(Entry{EventType: Login, ActorUsername: "bob"})
+
+	assert.Len(t, readEntries(t, rotated), 2)
+	entries := readEntries(t, path)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "bob", entries[0]["actor_username"])
+}
+
+func TestLog_nil(t *testing.T) {
+	var log *Log
+	log.Write(Entry{EventType: Login})
+	assert.NoError(t, log.Close())
+}
+
+func TestLog_pseudonyms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+	log.Pseudonyms = logger.NewPseudonyms()
+
+	log.Write(Entry{EventType: Kick, ActorUsername: "alice", TargetUsername: "bob", RoomID: "room", SourceIP: "127.0.0.1", SessionID: "session"})
+
+	entries := readEntries(t, path)
+	require.Len(t, entries, 1)
+	assert.Equal(t, log.Pseudonyms.User("alice"), entries[0]["actor_username"])
+	assert.Equal(t, log.Pseudonyms.User("bob"), entries[0]["target_username"])
+	assert.Equal(t, log.Pseudonyms.IP("127.0.0.1"), entries[0]["source_ip"])
+	assert.Equal(t, log.Pseudonyms.Session("session"), entries[0]["session_id"])
+	assert.Equal(t, "room", entries[0]["room_id"])
+}
+
+func TestLog_main(t *testing.T) {
+	old := zlog.Logger
+	defer func() { zlog.Logger = old }()
+	var output bytes.Buffer
+	zlog.Logger = zerolog.New(&output).Level(zerolog.ErrorLevel)
+
+	log := Main()
+	log.Write(Entry{EventType: UsersReload, Outcome: OutcomeFailure})
+	require.NoError(t, log.Reopen())
+	require.NoError(t, log.Close())
+
+	var line struct {
+		Audit map[string]interface{} `json:"audit"`
+	}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &line), "the entry is written regardless of the level")
+	assert.Equal(t, "users_reload", line.Audit["event_type"])
+	assert.Equal(t, OutcomeFailure, line.Audit["outcome"])
+}
+
+func TestLog_deleteUserEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	require.NoError(t, err)
+	defer l.Close()
+
+	l.Write(Entry{EventType: Login, ActorUsername: "alice"})
+	l.Write(Entry{EventType: Kick, ActorUsername: "bob", TargetUsername: "alice"})
+	l.Write(Entry{EventType: Login, ActorUsername: "bob"})
+
+	removed, err := l.DeleteUserEntries("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	l.Write(Entry{EventType: Logout, ActorUsername: "bob"})
+
+	entries, err := l.UserEntries("bob")
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "entries are appended to the new file")
+	assert.Equal(t, Login, entries[0].EventType)
+	assert.Equal(t, Logout, entries[1].EventType)
+	entries, err = l.UserEntries("alice")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
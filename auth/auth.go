@@ -1,27 +1,117 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/sessions"
+	"github.com/rs/xid"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/config"
+)
+
+// Login providers stored in the session.
+const (
+	providerPassword = "password"
+	providerOIDC     = "oidc"
+	providerLDAP     = "ldap"
+	providerWebhook  = "webhook"
+	providerProxy    = "proxy"
+)
+
+// Roles of the users, the users file may set the role in an optional third field.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Permissions of the users, the users file may set them in an optional fourth field like create_rooms=false.
+const (
+	PermissionCreateRooms = "create_rooms"
 )
 
 type Users struct {
-	Lookup         map[string]string
+	Audit *audit.Log
+	// PasswordLoginDisabled rejects logins with the users file, it is still used for basic auth.
+	PasswordLoginDisabled bool
+	// LDAP checks the password of logins instead of the users file if set. Users of the users file and directory
+	// users of the admin group may use the admin endpoints.
+	LDAP *LDAP
+	// Webhook checks the password of logins instead of the users file if set, like LDAP. Users with the admin role of the
+	// webhook may use the admin endpoints.
+	Webhook *AuthWebhook
+	// Proxy takes the user of requests from trusted reverse proxies from a header if set.
+	Proxy *ProxyAuth
+	// Tokens are the API tokens for the admin endpoints.
+	Tokens *Tokens
+	// Limiter locks out addresses and users after too many failed logins if set.
+	Limiter *LoginLimiter
+	// Cookie are the attributes of the session cookie.
+	Cookie SessionCookie
+	// SessionIdleTimeout makes sessions expire after inactivity if set, RenewSessions extends them up to the session
+	// timeout.
+	SessionIdleTimeout time.Duration
+	// RestrictRoomCreation denies the creation of rooms to users without the create_rooms permission and guests.
+	RestrictRoomCreation bool
+	// RequireTOTPForAdmins rejects the password login of admins of the users file that have no TOTP secret.
+	RequireTOTPForAdmins bool
+
 	store          sessions.Store
 	sessionTimeout int
+	// sources are the files and directories of the users file setting, they are read again on every reload.
+	sources          []string
+	rejectDuplicates bool
+	secret           []byte
+
+	// deleteLock serializes the rewrites of the users files.
+	deleteLock sync.Mutex
+
+	lock     sync.RWMutex
+	lookup   map[string]account
+	loadedAt time.Time
+	// hasAdmins is whether the users file has an admin, without admins all users may use the admin endpoints.
+	hasAdmins bool
+	// revoked is when the sessions of a user were revoked by LogoutAll, sessions created before are invalid.
+	revoked map[string]time.Time
+	// totpUsed is the period of the last accepted TOTP code of a user, see verifyTOTP.
+	totpUsed map[string]int64
+}
+
+type account struct {
+	hash        string
+	role        string
+	permissions map[string]bool
+	totpSecret  string
 }
 
 type UserPW struct {
 	Name string
 	Pass string
+	// Role is RoleUser if the entry has no role.
+	Role string
+	// Permissions that are set explicitly, the others have the instance default.
+	Permissions map[string]bool
+	// TOTPSecret is the base32 secret of the second factor, users without it login with the password only.
+	TOTPSecret string
+	// Line of the user in the users file.
+	Line int
 }
 
 func read(r io.Reader) ([]UserPW, error) {
@@ -29,66 +119,425 @@ func read(r io.Reader) ([]UserPW, error) {
 	reader.Comma = ':'
 	reader.Comment = '#'
 	reader.TrimLeadingSpace = true
-
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
-	}
+	reader.FieldsPerRecord = -1
 
 	result := []UserPW{}
-	for _, record := range records {
-		if len(record) != 2 {
-			return nil, errors.New("malformed users file")
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) < 2 || len(record) > 5 {
+			return nil, fmt.Errorf("malformed users file in line %d", line)
+		}
+		role := RoleUser
+		if len(record) >= 3 && record[2] != "" {
+			role = record[2]
 		}
-		result = append(result, UserPW{Name: record[0], Pass: record[1]})
+		permissions := map[string]bool{}
+		if len(record) >= 4 && record[3] != "" {
+			for _, permission := range strings.Split(record[3], ",") {
+				name, value, _ := strings.Cut(strings.TrimSpace(permission), "=")
+				allowed, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("malformed permission %q in line %d, expected name=true or name=false", permission, line)
+				}
+				permissions[name] = allowed
+			}
+		}
+		totpSecret := ""
+		if len(record) == 5 {
+			totpSecret = strings.TrimSpace(record[4])
+		}
+		result = append(result, UserPW{Name: record[0], Pass: record[1], Role: role, Permissions: permissions, TOTPSecret: totpSecret, Line: line})
 	}
-	return result, nil
 }
 
 func ReadPasswordsFile(path string, secret []byte, sessionTimeout int) (*Users, error) {
+	return ReadUsersFiles(path, config.UsersDuplicatesError, secret, sessionTimeout)
+}
+
+// ReadUsersFiles reads a comma separated list of users files and directories, the *.htpasswd files of a directory are
+// read in alphabetical order. duplicates decides about users that are in more than one file, it is an error or the
+// last file wins.
+func ReadUsersFiles(path, duplicates string, secret []byte, sessionTimeout int) (*Users, error) {
 	users := &Users{
-		Lookup:         map[string]string{},
-		sessionTimeout: sessionTimeout,
-		store:          sessions.NewCookieStore(secret),
+		lookup:           map[string]account{},
+		sessionTimeout:   sessionTimeout,
+		store:            sessions.NewCookieStore(secret),
+		secret:           secret,
+		sources:          splitSources(path),
+		rejectDuplicates: duplicates == config.UsersDuplicatesError,
 	}
-	if path == "" {
+	if len(users.sources) == 0 {
 		log.Info().Msg("Users file not specified")
 		return users, nil
 	}
 
-	file, err := os.Open(path)
+	lookup, err := readSources(users.sources, users.rejectDuplicates)
 	if err != nil {
 		return users, err
 	}
+	users.lookup = lookup
+	users.loadedAt = time.Now()
+	users.hasAdmins = hasAdmins(lookup)
+	log.Info().Int("amount", len(lookup)).Msg("Loaded Users")
+	if !users.hasAdmins && len(lookup) > 0 {
+		log.Warn().Str("file", path).Msg("The users file has no admin, all users may use the admin endpoints. Add :admin to the entries of the admins")
+	}
+	return users, nil
+}
+
+func hasAdmins(lookup map[string]account) bool {
+	for _, account := range lookup {
+		if account.role == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+func splitSources(path string) []string {
+	var sources []string
+	for _, source := range strings.Split(path, ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// readSources merges the users of all files, every source must be readable.
+func readSources(sources []string, rejectDuplicates bool) (map[string]account, error) {
+	lookup := map[string]account{}
+	fileOf := map[string]string{}
+	for _, source := range sources {
+		paths, err := sourceFiles(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			accounts, err := readFile(path)
+			if err != nil {
+				return nil, err
+			}
+			// sorted, the error names the same user on every start.
+			names := make([]string, 0, len(accounts))
+			for name := range accounts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				account := accounts[name]
+				if previous, ok := fileOf[name]; ok {
+					if rejectDuplicates {
+						return nil, fmt.Errorf("user %q is in %s and %s", name, previous, path)
+					}
+					log.Debug().Str("user", name).Str("file", path).Str("previous", previous).Msg("User is in more than one users file, using the last one")
+				}
+				lookup[name] = account
+				fileOf[name] = path
+			}
+		}
+	}
+	return lookup, nil
+}
+
+// sourceFiles returns the *.htpasswd files if the source is a directory and the source otherwise.
+func sourceFiles(source string) ([]string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{source}, nil
+	}
+	return filepath.Glob(filepath.Join(source, "*.htpasswd"))
+}
+
+func readFile(path string) (map[string]account, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 	userPws, err := read(file)
 	if err != nil {
-		return users, err
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
 
+	lookup := map[string]account{}
 	for _, record := range userPws {
-		users.Lookup[record.Name] = record.Pass
+		if weak := weakHash(record.Pass); weak != "" {
+			log.Warn().Str("file", path).Int("line", record.Line).Str("user", record.Name).Str("hash", weak).
+				Msg("Weak password hash, only bcrypt and argon2id are supported, rehash the password with `screego hash`. Skipping user")
+			continue
+		}
+		if hashAlgorithm(record.Pass) == "" {
+			log.Warn().Str("file", path).Int("line", record.Line).Str("user", record.Name).
+				Msg("Unknown password hash format, expected bcrypt or argon2id. Skipping user")
+			continue
+		}
+		if record.Role != RoleUser && record.Role != RoleAdmin {
+			log.Warn().Str("file", path).Int("line", record.Line).Str("user", record.Name).Str("role", record.Role).
+				Msg("Unknown role, expected user or admin. Skipping user")
+			continue
+		}
+		if unknown := unknownPermission(record.Permissions); unknown != "" {
+			log.Warn().Str("file", path).Int("line", record.Line).Str("user", record.Name).Str("permission", unknown).
+				Msg("Unknown permission, expected create_rooms. Skipping user")
+			continue
+		}
+		if _, err := decodeTOTPSecret(record.TOTPSecret); record.TOTPSecret != "" && err != nil {
+			log.Warn().Str("file", path).Int("line", record.Line).Str("user", record.Name).
+				Msg("Invalid TOTP secret, expected base32 like `screego totp` creates it. Skipping user")
+			continue
+		}
+		lookup[record.Name] = account{hash: record.Pass, role: record.Role, permissions: record.Permissions, totpSecret: record.TOTPSecret}
 	}
-	log.Info().Int("amount", len(users.Lookup)).Msg("Loaded Users")
-	return users, nil
+	return lookup, nil
+}
+
+// Reload reads the users file again and replaces all users at once. The current users stay active if the file cannot
+// be read. Sessions of removed users are invalid on their next request.
+func (u *Users) Reload() error {
+	if len(u.sources) == 0 {
+		return nil
+	}
+	lookup, err := readSources(u.sources, u.rejectDuplicates)
+	if err != nil {
+		u.Audit.Write(audit.Entry{EventType: audit.UsersReload, Outcome: audit.OutcomeFailure})
+		return err
+	}
+
+	u.lock.Lock()
+	previous := u.lookup
+	u.lookup = lookup
+	u.loadedAt = time.Now()
+	u.hasAdmins = hasAdmins(lookup)
+	u.lock.Unlock()
+
+	added, removed := 0, 0
+	for name := range lookup {
+		if _, ok := previous[name]; !ok {
+			added++
+		}
+	}
+	for name := range previous {
+		if _, ok := lookup[name]; !ok {
+			removed++
+		}
+	}
+	log.Info().Int("amount", len(lookup)).Int("added", added).Int("removed", removed).Msg("Reloaded Users")
+	u.Audit.Write(audit.Entry{EventType: audit.UsersReload})
+	return nil
+}
+
+// ReloadOnSignal reloads the users file on SIGHUP.
+func (u *Users) ReloadOnSignal() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			if err := u.Reload(); err != nil {
+				log.Error().Err(err).Strs("files", u.sources).Msg("Could not reload users file, keeping the previous users")
+			}
+		}
+	}()
+}
+
+// LoadedAt returns when the users file was last loaded successfully, it is zero without users file.
+func (u *Users) LoadedAt() time.Time {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	return u.loadedAt
+}
+
+func (u *Users) exists(user string) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	_, ok := u.lookup[user]
+	return ok
+}
+
+// IsAdmin returns whether the user has the admin role in the users file.
+func (u *Users) IsAdmin(user string) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	return u.lookup[user].role == RoleAdmin
+}
+
+func unknownPermission(permissions map[string]bool) string {
+	for name := range permissions {
+		if name != PermissionCreateRooms {
+			return name
+		}
+	}
+	return ""
+}
+
+// CanCreateRooms returns whether the user may create rooms, fileUser is the user of FileUser. Users of the users file
+// may have the create_rooms permission, other users and guests may create rooms unless RestrictRoomCreation is set.
+func (u *Users) CanCreateRooms(fileUser string) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	if allowed, ok := u.lookup[fileUser].permissions[PermissionCreateRooms]; ok {
+		return allowed
+	}
+	return !u.RestrictRoomCreation
+}
+
+// FileUser returns the user if it logged in with a password of the users file, it is empty for guests and other
+// logins, so that a name chosen at the OIDC provider doesn't get the permissions of a user of the users file.
+func (u *Users) FileUser(r *http.Request) string {
+	user, loggedIn := u.CurrentUser(r)
+	if !loggedIn {
+		return ""
+	}
+	s := u.session(r)
+	if sessionUser, _ := s.Values["user"].(string); sessionUser != user {
+		return ""
+	}
+	if provider, _ := s.Values["provider"].(string); provider != providerPassword {
+		return ""
+	}
+	return user
+}
+
+// OwnAccount returns whether the current user is the account name. Names of the users file are only owned by password
+// logins, so that a name chosen at the OIDC provider or sent by the proxy doesn't get access to the file user.
+func (u *Users) OwnAccount(r *http.Request, name string) bool {
+	if u.exists(name) {
+		return u.FileUser(r) == name
+	}
+	user, loggedIn := u.CurrentUser(r)
+	return loggedIn && user == name
+}
+
+// CurrentRole returns the role of the current user, it is empty for guests. Sessions of the users file have the
+// current role of the file, LDAP sessions are admins if the admin group filter matched at the login. Other logins
+// are users, so that a name chosen at the OIDC provider doesn't get the role of an admin of the users file.
+func (u *Users) CurrentRole(r *http.Request) string {
+	user, loggedIn := u.CurrentUser(r)
+	if !loggedIn {
+		return ""
+	}
+	s := u.session(r)
+	if sessionUser, _ := s.Values["user"].(string); sessionUser != user {
+		// the user of a trusted proxy header without session.
+		return RoleUser
+	}
+	if provider, _ := s.Values["provider"].(string); provider == providerPassword {
+		if u.IsAdmin(user) {
+			return RoleAdmin
+		}
+		return RoleUser
+	}
+	if role, _ := s.Values["role"].(string); role == RoleAdmin {
+		return RoleAdmin
+	}
+	return RoleUser
 }
 
 type Response struct {
 	Message string `json:"message"`
+	// TOTPRequired tells the login form to ask for the code of the authenticator app.
+	TOTPRequired bool `json:"totpRequired,omitempty"`
 }
 
 func (u *Users) CurrentUser(r *http.Request) (string, bool) {
-	s, _ := u.store.Get(r, "user")
+	// the header of a trusted proxy takes precedence over the session, it may not have been saved yet.
+	if u.Proxy != nil {
+		if user, trusted := u.Proxy.user(r); trusted {
+			if user == "" {
+				return "guest", false
+			}
+			return user, true
+		}
+	}
+	user := u.sessionUser(r)
+	if user == "" {
+		return "guest", false
+	}
+	return user, true
+}
+
+// sessionUser returns the user of the session, it is empty without valid session.
+func (u *Users) sessionUser(r *http.Request) string {
+	s := u.session(r)
 	user, ok := s.Values["user"].(string)
 	if !ok {
-		return "guest", ok
+		return ""
+	}
+	// the user may have been removed from the users file since the login, other users aren't in the users file.
+	if provider, _ := s.Values["provider"].(string); provider == providerPassword && !u.exists(user) {
+		return ""
+	}
+	// sessions of older versions have no creation time, they count as revoked once the user logged out everywhere.
+	created, _ := s.Values["created"].(int64)
+	if u.isRevoked(user, created) {
+		return ""
+	}
+	// the sliding expiry of the idle timeout, the cookie may be sent after it by a manipulated client.
+	if expires, ok := s.Values["expires"].(int64); ok && time.Now().UnixNano() >= expires {
+		return ""
+	}
+	return user
+}
+
+// SessionID returns the id of the login session, it is empty for users that aren't logged in.
+func (u *Users) SessionID(r *http.Request) string {
+	if u.sessionUser(r) == "" {
+		return ""
+	}
+	s := u.session(r)
+	sid, _ := s.Values["sid"].(string)
+	return sid
+}
+
+func (u *Users) isRevoked(user string, created int64) bool {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+	revoked, ok := u.revoked[user]
+	return ok && created <= revoked.UnixNano()
+}
+
+// LogoutAll revokes all sessions of the user that exist now, they are rejected on their next request. Sessions of the
+// session backend are deleted, the revocation of cookie sessions is only kept in memory and they are valid again
+// after a restart.
+func (u *Users) LogoutAll(user string) error {
+	u.lock.Lock()
+	if u.revoked == nil {
+		u.revoked = map[string]time.Time{}
 	}
-	return user, ok
+	u.revoked[user] = time.Now()
+	u.lock.Unlock()
+
+	if store, ok := u.store.(*persistentStore); ok {
+		return store.backend.DeleteUser(user)
+	}
+	return nil
+}
+
+// Sessions returns the stored sessions of the user, it is empty without session backend because the sessions are
+// then only in the cookies.
+func (u *Users) Sessions(user string) ([]StoredSession, error) {
+	if store, ok := u.store.(*persistentStore); ok {
+		return store.backend.ListUser(user)
+	}
+	return []StoredSession{}, nil
 }
 
 func (u *Users) Logout(w http.ResponseWriter, r *http.Request) {
-	session := sessions.NewSession(u.store, "user")
-	session.IsNew = true
+	if user, loggedIn := u.CurrentUser(r); loggedIn {
+		u.Audit.Write(audit.Entry{EventType: audit.Logout, ActorUsername: user, SourceIP: audit.RemoteIP(r), SessionID: u.SessionID(r)})
+	}
+
+	session := u.newSession(r)
 	if err := u.store.Save(r, w, session); err != nil {
 		w.WriteHeader(500)
 		_ = json.NewEncoder(w).Encode(&Response{
@@ -100,22 +549,76 @@ func (u *Users) Logout(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *Users) Authenticate(w http.ResponseWriter, r *http.Request) {
+	if u.PasswordLoginDisabled {
+		w.WriteHeader(403)
+		_ = json.NewEncoder(w).Encode(&Response{
+			Message: "password login is disabled",
+		})
+		return
+	}
+
 	user := r.FormValue("user")
 	pass := r.FormValue("pass")
 
-	if !u.Validate(user, pass) {
+	if u.Limiter != nil {
+		if wait := u.Limiter.locked(r, user); wait > 0 {
+			u.Audit.Write(audit.Entry{EventType: audit.LoginFailed, ActorUsername: user, SourceIP: audit.RemoteIP(r), Reason: audit.ReasonLockedOut})
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			w.WriteHeader(429)
+			_ = json.NewEncoder(w).Encode(&Response{
+				Message: "too many failed logins, try again later",
+			})
+			return
+		}
+	}
+
+	provider, ok, admin := providerPassword, false, false
+	var err error
+	switch {
+	case u.LDAP != nil:
+		provider = providerLDAP
+		ok, admin, err = u.LDAP.Authenticate(user, pass)
+	case u.Webhook != nil:
+		provider = providerWebhook
+		ok, admin, err = u.Webhook.Authenticate(user, pass)
+	default:
+		ok = u.validateFile(user, pass)
+	}
+	if err != nil {
+		status := 502
+		if errors.Is(err, ErrWebhookCircuitOpen) {
+			status = 503
+		}
+		log.Error().Err(err).Str("user", user).Str("provider", provider).Msg("Login failed")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(&Response{
+			Message: "the login is currently unavailable, try again later",
+		})
+		return
+	}
+	if !ok {
+		if u.Limiter != nil {
+			u.Limiter.failed(r, user)
+		}
+		u.Audit.Write(audit.Entry{EventType: audit.LoginFailed, ActorUsername: user, SourceIP: audit.RemoteIP(r), Reason: audit.ReasonInvalidCredentials})
 		w.WriteHeader(401)
 		_ = json.NewEncoder(w).Encode(&Response{
 			Message: "could not authenticate",
 		})
 		return
 	}
+	if provider == providerPassword && !u.checkTOTP(w, r, user) {
+		return
+	}
+	if u.Limiter != nil {
+		u.Limiter.succeeded(user)
+	}
 
-	session := sessions.NewSession(u.store, "user")
-	session.IsNew = true
-	session.Options.MaxAge = u.sessionTimeout
-	session.Values["user"] = user
-	if err := u.store.Save(r, w, session); err != nil {
+	role := RoleUser
+	if admin {
+		role = RoleAdmin
+	}
+	if err := u.startSession(w, r, user, provider, role); err != nil {
 		w.WriteHeader(500)
 		_ = json.NewEncoder(w).Encode(&Response{
 			Message: err.Error(),
@@ -128,7 +631,127 @@ func (u *Users) Authenticate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (u Users) Validate(user, password string) bool {
-	realPassword, exists := u.Lookup[user]
-	return exists && bcrypt.CompareHashAndPassword([]byte(realPassword), []byte(password)) == nil
+// checkTOTP asks for the second factor of users with a TOTP secret and verifies it, it writes the response and returns
+// false if the login must not continue.
+func (u *Users) checkTOTP(w http.ResponseWriter, r *http.Request, user string) bool {
+	u.lock.RLock()
+	account := u.lookup[user]
+	u.lock.RUnlock()
+	if account.totpSecret == "" {
+		if u.RequireTOTPForAdmins && account.role == RoleAdmin {
+			u.Audit.Write(audit.Entry{EventType: audit.LoginFailed, ActorUsername: user, SourceIP: audit.RemoteIP(r), Reason: audit.ReasonTOTPNotEnrolled})
+			w.WriteHeader(403)
+			_ = json.NewEncoder(w).Encode(&Response{
+				Message: "admins need two-factor authentication, ask the operator to enroll you",
+			})
+			return false
+		}
+		return true
+	}
+
+	code := strings.TrimSpace(r.FormValue("code"))
+	if code == "" {
+		w.WriteHeader(401)
+		_ = json.NewEncoder(w).Encode(&Response{
+			Message:      "enter the code of your authenticator app",
+			TOTPRequired: true,
+		})
+		return false
+	}
+	if !u.verifyTOTP(user, account.totpSecret, code) {
+		if u.Limiter != nil {
+			u.Limiter.failed(r, user)
+		}
+		u.Audit.Write(audit.Entry{EventType: audit.LoginFailed, ActorUsername: user, SourceIP: audit.RemoteIP(r), Reason: audit.ReasonInvalidTOTP})
+		w.WriteHeader(401)
+		_ = json.NewEncoder(w).Encode(&Response{
+			Message:      "invalid code",
+			TOTPRequired: true,
+		})
+		return false
+	}
+	return true
+}
+
+// startSession logs in the user, provider is how the user authenticated. The role of users file accounts is looked
+// up on every request, so that changes of the users file apply to existing sessions.
+func (u *Users) startSession(w http.ResponseWriter, r *http.Request, user, provider, role string) error {
+	session := u.newSession(r)
+	session.Options.MaxAge = u.sessionTimeout
+	session.Values["user"] = user
+	session.Values["provider"] = provider
+	session.Values["role"] = role
+	session.Values["sid"] = xid.New().String()
+	now := time.Now()
+	session.Values["created"] = now.UnixNano()
+	if u.SessionIdleTimeout > 0 {
+		u.slide(session, now)
+	}
+	if err := u.store.Save(r, w, session); err != nil {
+		return err
+	}
+	u.Audit.Write(audit.Entry{EventType: audit.Login, ActorUsername: user, SourceIP: audit.RemoteIP(r), SessionID: session.Values["sid"].(string)})
+	return nil
+}
+
+// Validate checks the basic auth of the admin endpoints, it accepts admins of the users file or all users if the file
+// has no admin. With LDAP it also accepts users of the admin group, with the auth webhook users with the admin role.
+// Basic auth has no second factor, users with a TOTP secret and admins that require one must use their session or an
+// API token.
+func (u *Users) Validate(user, password string) bool {
+	return u.validate(user, password, false)
+}
+
+// ValidateAdmin is Validate without the fallback for users files without admins, for endpoints that only admins may
+// use.
+func (u *Users) ValidateAdmin(user, password string) bool {
+	return u.validate(user, password, true)
+}
+
+func (u *Users) validate(user, password string, strict bool) bool {
+	if u.validateFile(user, password) {
+		u.lock.RLock()
+		defer u.lock.RUnlock()
+		account := u.lookup[user]
+		if account.totpSecret != "" || (u.RequireTOTPForAdmins && account.role == RoleAdmin) {
+			return false
+		}
+		return (!u.hasAdmins && !strict) || account.role == RoleAdmin
+	}
+	if u.Webhook != nil {
+		ok, admin, err := u.Webhook.Authenticate(user, password)
+		if err != nil {
+			log.Error().Err(err).Str("user", user).Msg("Auth webhook admin login failed")
+		}
+		return ok && admin
+	}
+	if u.LDAP == nil || u.LDAP.conf.LDAPAdminGroupFilter == "" {
+		return false
+	}
+	ok, admin, err := u.LDAP.Authenticate(user, password)
+	if err != nil {
+		log.Error().Err(err).Str("user", user).Msg("LDAP admin login failed")
+	}
+	return ok && admin
+}
+
+func (u *Users) validateFile(user, password string) bool {
+	u.lock.RLock()
+	account, exists := u.lookup[user]
+	u.lock.RUnlock()
+	return exists && VerifyPassword(account.hash, []byte(password))
+}
+
+// TURNPassword returns the TURN password of the user. It is derived from the password hash, so it changes with the
+// password, and from the secret, so the hash can't be recovered from it.
+func (u *Users) TURNPassword(user string) (string, bool) {
+	u.lock.RLock()
+	account, exists := u.lookup[user]
+	u.lock.RUnlock()
+	if !exists {
+		return "", false
+	}
+	mac := hmac.New(sha256.New, u.secret)
+	_, _ = mac.Write([]byte(user + ":" + account.hash))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), true
 }
@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeUsersFile writes the users with the password <name>-pw, users may have a role like alice:admin.
+func writeUsersFile(t *testing.T, path string, users ...string) {
+	t.Helper()
+	var lines []string
+	for _, user := range users {
+		name, role, _ := strings.Cut(user, ":")
+		hash, err := bcrypt.GenerateFromPassword([]byte(name+"-pw"), bcrypt.MinCost)
+		require.NoError(t, err)
+		line := name + ":" + string(hash)
+		if role != "" {
+			line += ":" + role
+		}
+		lines = append(lines, line)
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+}
+
+func login(t *testing.T, users *Users, user string) *http.Cookie {
+	t.Helper()
+	form := url.Values{"user": {user}, "pass": {user + "-pw"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	users.Authenticate(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	return cookies[0]
+}
+
+func TestUsers_reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice", "bob")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	loadedAt := users.LoadedAt()
+	require.False(t, loadedAt.IsZero())
+
+	cookie := login(t, users, "bob")
+	req := httptest.NewRequest("GET", "/config", nil)
+	req.AddCookie(cookie)
+	user, loggedIn := users.CurrentUser(req)
+	assert.True(t, loggedIn)
+	assert.Equal(t, "bob", user)
+
+	writeUsersFile(t, path, "alice", "carol")
+	require.NoError(t, users.Reload())
+
+	assert.True(t, users.Validate("alice", "alice-pw"))
+	assert.True(t, users.Validate("carol", "carol-pw"))
+	assert.False(t, users.Validate("bob", "bob-pw"))
+	assert.False(t, users.LoadedAt().Before(loadedAt))
+
+	user, loggedIn = users.CurrentUser(req)
+	assert.False(t, loggedIn, "sessions of removed users are invalid")
+	assert.Equal(t, "guest", user)
+}
+
+func TestUsers_reloadKeepsUsersOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	loadedAt := users.LoadedAt()
+
+	require.NoError(t, os.WriteFile(path, []byte("alice:hash:admin:extra\n"), 0o600))
+	assert.Error(t, users.Reload())
+	assert.True(t, users.Validate("alice", "alice-pw"))
+	assert.Equal(t, loadedAt, users.LoadedAt())
+
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, users.Reload())
+	assert.True(t, users.Validate("alice", "alice-pw"))
+}
+
+func TestUsers_reloadAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	auditPath := filepath.Join(t.TempDir(), "audit.log")
+	users.Audit, err = audit.Open(auditPath)
+	require.NoError(t, err)
+	defer users.Audit.Close()
+
+	require.NoError(t, users.Reload())
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, users.Reload())
+
+	content, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"event_type":"users_reload"`)
+	assert.Contains(t, lines[0], `"outcome":"success"`)
+	assert.Contains(t, lines[1], `"outcome":"failure"`)
+}
+
+func TestReadUsersFiles_sources(t *testing.T) {
+	dir := t.TempDir()
+	usersDir := filepath.Join(dir, "users.d")
+	require.NoError(t, os.Mkdir(usersDir, 0o700))
+	writeUsersFile(t, filepath.Join(dir, "users"), "alice", "bob")
+	writeUsersFile(t, filepath.Join(usersDir, "a.htpasswd"), "carol", "bob:admin")
+	writeUsersFile(t, filepath.Join(usersDir, "b.htpasswd"), "dave")
+	writeUsersFile(t, filepath.Join(usersDir, "ignored"), "erin")
+
+	path := filepath.Join(dir, "users") + ", " + usersDir
+	users, err := ReadUsersFiles(path, config.UsersDuplicatesLastWins, []byte("secret"), 0)
+	require.NoError(t, err)
+	for _, name := range []string{"alice", "bob", "carol", "dave"} {
+		assert.True(t, users.exists(name), name)
+	}
+	assert.False(t, users.exists("erin"), "only *.htpasswd files of directories are read")
+	assert.True(t, users.IsAdmin("bob"), "the last file wins")
+
+	writeUsersFile(t, filepath.Join(usersDir, "c.htpasswd"), "frank")
+	require.NoError(t, users.Reload())
+	assert.True(t, users.exists("frank"), "directories are scanned again on reload")
+
+	_, err = ReadPasswordsFile(path, []byte("secret"), 0)
+	assert.EqualError(t, err, fmt.Sprintf("user %q is in %s and %s", "bob", filepath.Join(dir, "users"),
+		filepath.Join(usersDir, "a.htpasswd")), "duplicates are rejected by default")
+
+	require.NoError(t, os.WriteFile(filepath.Join(usersDir, "b.htpasswd"), []byte("dave\n"), 0o600))
+	_, err = ReadUsersFiles(path, config.UsersDuplicatesLastWins, []byte("secret"), 0)
+	assert.ErrorContains(t, err, filepath.Join(usersDir, "b.htpasswd"), "the error names the file")
+
+	_, err = ReadUsersFiles(path+","+filepath.Join(dir, "missing"), config.UsersDuplicatesLastWins, []byte("secret"), 0)
+	assert.Error(t, err)
+}
+
+func TestReadPasswordsFile_roles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice:admin", "bob", "carol:user", "dave:root")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+
+	assert.True(t, users.IsAdmin("alice"))
+	assert.False(t, users.IsAdmin("bob"))
+	assert.False(t, users.IsAdmin("carol"))
+	assert.False(t, users.exists("dave"), "unknown roles are skipped")
+
+	assert.True(t, users.Validate("alice", "alice-pw"))
+	assert.False(t, users.Validate("bob", "bob-pw"), "only admins may use the admin endpoints")
+
+	for user, role := range map[string]string{"alice": RoleAdmin, "bob": RoleUser} {
+		req := httptest.NewRequest("GET", "/config", nil)
+		req.AddCookie(login(t, users, user))
+		assert.Equal(t, role, users.CurrentRole(req), user)
+	}
+	assert.Equal(t, "", users.CurrentRole(httptest.NewRequest("GET", "/config", nil)))
+}
+
+func TestReadPasswordsFile_withoutRoles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice", "bob")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+
+	for _, user := range []string{"alice", "bob"} {
+		assert.False(t, users.IsAdmin(user))
+		assert.True(t, users.Validate(user, user+"-pw"), "all users may use the admin endpoints without admin")
+		req := httptest.NewRequest("GET", "/config", nil)
+		req.AddCookie(login(t, users, user))
+		assert.Equal(t, RoleUser, users.CurrentRole(req))
+	}
+}
+
+func TestReadPasswordsFile_permissions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice:admin:create_rooms=true", "bob::create_rooms=false", "carol", "dave:user:kick=true")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	assert.True(t, users.IsAdmin("alice"))
+	assert.False(t, users.exists("dave"), "unknown permissions are skipped")
+
+	fileUser := func(user string) string {
+		return users.FileUser(requestWith(login(t, users, user)))
+	}
+	assert.Equal(t, "bob", fileUser("bob"))
+	assert.Equal(t, "", users.FileUser(httptest.NewRequest("GET", "/config", nil)))
+
+	assert.True(t, users.CanCreateRooms(fileUser("alice")))
+	assert.False(t, users.CanCreateRooms(fileUser("bob")))
+	assert.True(t, users.CanCreateRooms(fileUser("carol")), "the default applies without permission")
+	assert.True(t, users.CanCreateRooms(""), "guests have the default")
+
+	users.RestrictRoomCreation = true
+	assert.True(t, users.CanCreateRooms("alice"))
+	assert.False(t, users.CanCreateRooms("carol"))
+	assert.False(t, users.CanCreateRooms(""))
+
+	writeUsersFile(t, path, "bob::create_rooms=true", "carol")
+	require.NoError(t, users.Reload())
+	assert.True(t, users.CanCreateRooms("bob"), "the permissions of the reloaded file apply")
+}
+
+func TestReadPasswordsFile_malformedPermission(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	require.NoError(t, os.WriteFile(path, []byte("alice:hash::create_rooms\n"), 0o600))
+	_, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	assert.ErrorContains(t, err, "malformed permission")
+}
+
+func TestReadPasswordsFile_htpasswd(t *testing.T) {
+	var logs bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&logs)
+	defer func() { log.Logger = previous }()
+
+	users, err := ReadPasswordsFile("testdata/htpasswd", []byte("secret"), 0)
+	require.NoError(t, err)
+
+	assert.True(t, users.Validate("alice", "alice-pw"))
+	assert.True(t, users.Validate("bob", "bob-pw"))
+	assert.False(t, users.Validate("alice", "bob-pw"))
+	for _, name := range []string{"carol", "dave", "erin"} {
+		assert.False(t, users.exists(name), name)
+	}
+	login(t, users, "alice")
+
+	assert.Contains(t, logs.String(), `"line":5,"user":"carol","hash":"md5-crypt","message":"Weak password hash`)
+	assert.Contains(t, logs.String(), `"line":6,"user":"dave","hash":"sha1"`)
+	assert.Contains(t, logs.String(), `"line":8,"user":"erin","hash":"md5-crypt"`)
+}
+
+func TestUsers_turnPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice", "bob")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+
+	alice, ok := users.TURNPassword("alice")
+	require.True(t, ok)
+	bob, _ := users.TURNPassword("bob")
+	assert.NotEqual(t, alice, bob)
+	again, _ := users.TURNPassword("alice")
+	assert.Equal(t, alice, again)
+	_, ok = users.TURNPassword("carol")
+	assert.False(t, ok)
+
+	other, err := ReadPasswordsFile(path, []byte("other secret"), 0)
+	require.NoError(t, err)
+	otherAlice, _ := other.TURNPassword("alice")
+	assert.NotEqual(t, alice, otherAlice, "depends on the secret")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			users.TURNPassword("alice")
+		}
+	}()
+	writeUsersFile(t, path, "alice")
+	require.NoError(t, users.Reload())
+	wg.Wait()
+
+	changed, ok := users.TURNPassword("alice")
+	assert.True(t, ok)
+	assert.NotEqual(t, alice, changed, "changes with the password hash")
+	_, ok = users.TURNPassword("bob")
+	assert.False(t, ok, "removed users")
+}
+
+func TestUsers_deleteUser(t *testing.T) {
+	dir := t.TempDir()
+	usersDir := filepath.Join(dir, "users.d")
+	require.NoError(t, os.Mkdir(usersDir, 0o700))
+	writeUsersFile(t, filepath.Join(dir, "users"), "alice:admin", "bob")
+	writeUsersFile(t, filepath.Join(usersDir, "a.htpasswd"), "carol")
+	content, err := os.ReadFile(filepath.Join(dir, "users"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "users"), append([]byte("# bob: the team lead\n"), content...), 0o640))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "users"), 0o640))
+
+	users, err := ReadPasswordsFile(filepath.Join(dir, "users")+","+usersDir, []byte("secret"), 0)
+	require.NoError(t, err)
+	files, err := users.DeleteUser("bob")
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "users")}, files)
+	assert.False(t, users.exists("bob"))
+
+	content, err = os.ReadFile(filepath.Join(dir, "users"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "# bob: the team lead\nalice:"), "comments are kept")
+	assert.NotContains(t, string(content), "\nbob:")
+	info, err := os.Stat(filepath.Join(dir, "users"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	require.NoError(t, users.Reload())
+	assert.False(t, users.exists("bob"), "the user stays deleted after a reload")
+	assert.True(t, users.exists("carol"))
+	files, err = users.DeleteUser("bob")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+	"github.com/screego/server/config"
+)
+
+// oidcSession is the name of the session of the OIDC login in progress.
+const oidcSession = "oidc"
+
+// SessionCookie are the attributes of the login session cookie. The zero value is the cookie of older versions, it is
+// named user and has no attributes, so that browsers use the path of the login.
+type SessionCookie struct {
+	Name string
+	// Secure is config.CookieSecureAuto, config.CookieSecureAlways or config.CookieSecureNever, the zero value is never.
+	Secure   string
+	SameSite http.SameSite
+	Domain   string
+	Path     string
+	// trustProxy detects TLS from the X-Forwarded-Proto header of the proxy for config.CookieSecureAuto.
+	trustProxy bool
+}
+
+// NewSessionCookie returns the session cookie of SCREEGO_SESSION_COOKIE_*, it has the base path by default.
+func NewSessionCookie(conf config.Config) SessionCookie {
+	cookie := SessionCookie{
+		Name:       conf.SessionCookieName,
+		Secure:     conf.SessionCookieSecure,
+		Domain:     conf.SessionCookieDomain,
+		Path:       conf.SessionCookiePath,
+		trustProxy: conf.TrustProxyHeaders,
+	}
+	if cookie.Path == "" {
+		cookie.Path = conf.BasePath
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+	}
+	switch strings.ToLower(conf.SessionCookieSameSite) {
+	case config.CookieSameSiteLax:
+		cookie.SameSite = http.SameSiteLaxMode
+	case config.CookieSameSiteStrict:
+		cookie.SameSite = http.SameSiteStrictMode
+	case config.CookieSameSiteNone:
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	return cookie
+}
+
+func (c SessionCookie) name() string {
+	if c.Name == "" {
+		return "user"
+	}
+	return c.Name
+}
+
+// apply sets the attributes on the options of a session that is saved in response to the request.
+func (c SessionCookie) apply(options *sessions.Options, r *http.Request) {
+	options.Path = c.Path
+	options.Domain = c.Domain
+	options.SameSite = c.SameSite
+	switch c.Secure {
+	case config.CookieSecureAlways:
+		options.Secure = true
+	case config.CookieSecureAuto:
+		options.Secure = r.TLS != nil || (c.trustProxy && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"))
+	default:
+		options.Secure = false
+	}
+}
+
+// newSession returns an empty login session with the cookie attributes for the request.
+func (u *Users) newSession(r *http.Request) *sessions.Session {
+	session := sessions.NewSession(u.store, u.Cookie.name())
+	session.IsNew = true
+	u.Cookie.apply(session.Options, r)
+	return session
+}
+
+// session returns the login session of the request.
+func (u *Users) session(r *http.Request) *sessions.Session {
+	s, _ := u.store.Get(r, u.Cookie.name())
+	return s
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DeleteUser removes the user from all users files and revokes the login sessions. A file is replaced by renaming a
+// temporary file, readers and reloads see the old or the new file. It returns the changed files.
+func (u *Users) DeleteUser(name string) ([]string, error) {
+	u.deleteLock.Lock()
+	defer u.deleteLock.Unlock()
+
+	changed := []string{}
+	for _, source := range u.sources {
+		paths, err := sourceFiles(source)
+		if err != nil {
+			return changed, err
+		}
+		for _, path := range paths {
+			removed, err := removeUser(path, name)
+			if err != nil {
+				return changed, err
+			}
+			if removed {
+				changed = append(changed, path)
+			}
+		}
+	}
+
+	u.lock.Lock()
+	delete(u.lookup, name)
+	u.hasAdmins = hasAdmins(u.lookup)
+	u.lock.Unlock()
+	return changed, u.LogoutAll(name)
+}
+
+// removeUser removes the lines of the user from the file, comments and other users are kept as they are.
+func removeUser(path, name string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	kept := make([][]byte, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.TrimLeft(string(line), " \t")
+		if user, _, ok := strings.Cut(fields, ":"); ok && !strings.HasPrefix(fields, "#") && user == name {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if len(kept) == len(lines) {
+		return false, nil
+	}
+	return true, replaceFile(path, bytes.Join(kept, nil))
+}
+
+// replaceFile writes the content to a temporary file in the same directory and renames it over path.
+func replaceFile(path string, content []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
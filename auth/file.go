@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ValidateUserName checks the name by parsing an entry with the users file reader, so that entries of valid names can
+// always be read back.
+func ValidateUserName(name string) error {
+	if name == "" {
+		return fmt.Errorf("user name must not be empty")
+	}
+	records, err := read(strings.NewReader(name + ":hash"))
+	if err != nil || len(records) != 1 || records[0].Name != name || strings.IndexFunc(name, unicode.IsControl) != -1 {
+		return fmt.Errorf("invalid user name %q, it must not contain ':', quotes or control characters and must not start with '#' or whitespace", name)
+	}
+	return nil
+}
+
+// UserLine returns the users file entry of the user, the role is omitted for RoleUser.
+func UserLine(name, hash, role string) (string, error) {
+	if err := ValidateUserName(name); err != nil {
+		return "", err
+	}
+	if hashAlgorithm(hash) == "" {
+		return "", fmt.Errorf("unknown password hash format")
+	}
+	switch role {
+	case "", RoleUser:
+		return name + ":" + hash, nil
+	case RoleAdmin:
+		return name + ":" + hash + ":" + role, nil
+	default:
+		return "", fmt.Errorf("unknown role %q, it must be user or admin", role)
+	}
+}
+
+// AppendUser adds the user to the users file, the file is created if it doesn't exist. The file is locked while
+// writing, so that concurrent calls don't corrupt it.
+func AppendUser(path, name, hash, role string) error {
+	line, err := UserLine(name, hash, role)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return fmt.Errorf("could not lock %s: %w", path, err)
+	}
+	defer unlockFile(file)
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	records, err := read(strings.NewReader(string(content)))
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.Name == name {
+			return fmt.Errorf("user %s does already exist in line %d", name, record.Line)
+		}
+	}
+
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		line = "\n" + line
+	}
+	_, err = file.WriteString(line + "\n")
+	return err
+}
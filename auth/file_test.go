@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateUserName(t *testing.T) {
+	for _, name := range []string{"alice", "alice smith", "alice@example.org", "ä"} {
+		assert.NoError(t, ValidateUserName(name), name)
+	}
+	for _, name := range []string{"", "al:ice", "#alice", " alice", "al\"ice", "\"alice\"", "al\nice", "al\rice", "al\tice"} {
+		assert.Error(t, ValidateUserName(name), "%q", name)
+	}
+}
+
+func TestUserLine_roundTrip(t *testing.T) {
+	for _, params := range []HashParams{
+		{Algorithm: HashBcrypt, BcryptCost: 4},
+		{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1},
+	} {
+		t.Run(params.Algorithm, func(t *testing.T) {
+			hash, err := HashPassword([]byte("alice-pw"), params)
+			require.NoError(t, err)
+			line, err := UserLine("alice", hash, RoleUser)
+			require.NoError(t, err)
+
+			path := filepath.Join(t.TempDir(), "users")
+			require.NoError(t, os.WriteFile(path, []byte(line+"\n"), 0o600))
+			users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+			require.NoError(t, err)
+			assert.True(t, users.Validate("alice", "alice-pw"))
+			assert.False(t, users.ValidateAdmin("alice", "alice-pw"), "the file has no admins")
+			login(t, users, "alice")
+		})
+	}
+}
+
+func TestAppendUser(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	require.NoError(t, os.WriteFile(path, []byte("# users\nalice:$2a$10$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga"), 0o600))
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"bob", "carol", "dave"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			hash, err := HashPassword([]byte(name+"-pw"), HashParams{Algorithm: HashBcrypt, BcryptCost: 4})
+			assert.NoError(t, err)
+			assert.NoError(t, AppendUser(path, name, hash, RoleUser))
+		}(name)
+	}
+	wg.Wait()
+
+	hash, err := HashPassword([]byte("pw"), HashParams{Algorithm: HashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	assert.EqualError(t, AppendUser(path, "alice", hash, RoleUser), "user alice does already exist in line 2")
+	assert.Error(t, AppendUser(path, "#eve", hash, RoleUser))
+
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	assert.True(t, users.Validate("alice", "allmine"))
+	for _, name := range []string{"bob", "carol", "dave"} {
+		assert.True(t, users.Validate(name, name+"-pw"), name)
+	}
+}
+
+func TestAppendUser_createsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	hash, err := HashPassword([]byte("alice-pw"), HashParams{Algorithm: HashBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	require.NoError(t, AppendUser(path, "alice", hash, RoleUser))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	assert.True(t, users.Validate("alice", "alice-pw"))
+}
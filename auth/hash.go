@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/screego/server/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms.
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// HashParams configure how new password hashes are created. Existing hashes are verified with the parameters encoded
+// in the hash.
+type HashParams struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+// HashParamsFrom returns the hash parameters of the config.
+func HashParamsFrom(conf config.Config) HashParams {
+	return HashParams{
+		Algorithm:         conf.PasswordHash,
+		BcryptCost:        conf.BcryptCost,
+		Argon2Memory:      conf.Argon2Memory,
+		Argon2Iterations:  conf.Argon2Iterations,
+		Argon2Parallelism: conf.Argon2Parallelism,
+	}
+}
+
+// HashPassword hashes the password with the configured algorithm.
+func HashPassword(password []byte, params HashParams) (string, error) {
+	switch params.Algorithm {
+	case HashBcrypt:
+		hash, err := bcrypt.GenerateFromPassword(password, params.BcryptCost)
+		return string(hash), err
+	case HashArgon2id:
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey(password, salt, params.Argon2Iterations, params.Argon2Memory, params.Argon2Parallelism, argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, params.Argon2Memory,
+			params.Argon2Iterations, params.Argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	default:
+		return "", fmt.Errorf("unknown password hash algorithm %q", params.Algorithm)
+	}
+}
+
+// hashAlgorithm detects the algorithm of the hash from its prefix, it is empty for unknown hashes.
+func hashAlgorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return HashBcrypt
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return HashArgon2id
+	default:
+		return ""
+	}
+}
+
+// weakHash returns the name of htpasswd hash formats that are too weak to be supported, it is empty for other hashes.
+func weakHash(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$apr1$"), strings.HasPrefix(hash, "$1$"):
+		return "md5-crypt"
+	case strings.HasPrefix(hash, "{SHA}"):
+		return "sha1"
+	case strings.HasPrefix(hash, "$5$"), strings.HasPrefix(hash, "$6$"):
+		return "sha-crypt"
+	default:
+		return ""
+	}
+}
+
+// VerifyPassword returns whether the password matches the bcrypt or argon2id hash.
+func VerifyPassword(hash string, password []byte) bool {
+	switch hashAlgorithm(hash) {
+	case HashBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(hash), password) == nil
+	case HashArgon2id:
+		ok, err := verifyArgon2id(hash, password)
+		return err == nil && ok
+	default:
+		return false
+	}
+}
+
+func verifyArgon2id(hash string, password []byte) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, errors.New("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errors.New("unsupported argon2 version")
+	}
+	var memory, iterations uint32
+	var parallelism uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &parallelism); err != nil {
+		return false, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+	if iterations == 0 || parallelism == 0 {
+		return false, errors.New("invalid argon2id parameters")
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, errors.New("malformed argon2id key")
+	}
+
+	actual := argon2.IDKey(password, salt, iterations, memory, parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(actual, key) == 1, nil
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		valid    bool
+	}{
+		{
+			name:     "bcrypt",
+			hash:     "$2a$10$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga",
+			password: "allmine",
+			valid:    true,
+		},
+		{
+			name:     "bcrypt 2y",
+			hash:     "$2y$10$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga",
+			password: "allmine",
+			valid:    true,
+		},
+		{
+			name:     "bcrypt wrong password",
+			hash:     "$2a$10$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga",
+			password: "yours",
+			valid:    false,
+		},
+		{
+			name:     "argon2id",
+			hash:     "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7",
+			password: "password",
+			valid:    true,
+		},
+		{
+			name:     "argon2id wrong password",
+			hash:     "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7",
+			password: "passwort",
+			valid:    false,
+		},
+		{
+			name:     "argon2id unsupported version",
+			hash:     "$argon2id$v=16$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7",
+			password: "password",
+			valid:    false,
+		},
+		{
+			name:     "argon2id malformed",
+			hash:     "$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ",
+			password: "password",
+			valid:    false,
+		},
+		{
+			name:     "unknown",
+			hash:     "5f4dcc3b5aa765d61d8327deb882cf99",
+			password: "password",
+			valid:    false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.valid, VerifyPassword(test.hash, []byte(test.password)))
+		})
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	for _, params := range []HashParams{
+		{Algorithm: HashBcrypt, BcryptCost: 4},
+		{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Iterations: 1, Argon2Parallelism: 1},
+	} {
+		t.Run(params.Algorithm, func(t *testing.T) {
+			hash, err := HashPassword([]byte("secret"), params)
+			require.NoError(t, err)
+			assert.Equal(t, params.Algorithm, hashAlgorithm(hash))
+			assert.True(t, VerifyPassword(hash, []byte("secret")))
+			assert.False(t, VerifyPassword(hash, []byte("public")))
+		})
+	}
+
+	_, err := HashPassword([]byte("secret"), HashParams{Algorithm: "md5"})
+	assert.Error(t, err)
+}
+
+func TestReadPasswordsFile_skipsUnknownHashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	lines := []string{
+		"alice:$2a$10$XajjQvNhvvRt5GSeFk1xFeyqRrsxkhBkUiQeg0dt.wU1qD4aFDcga",
+		"bob:5f4dcc3b5aa765d61d8327deb882cf99",
+		"carol:$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ$ZVrRXqxlLcWfcXCnMyv0m4Rpvh/bnCi7",
+	}
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600))
+
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	assert.True(t, users.Validate("alice", "allmine"))
+	assert.True(t, users.Validate("carol", "password"))
+	assert.False(t, users.Validate("bob", "password"))
+	assert.False(t, users.exists("bob"))
+}
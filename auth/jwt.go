@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/gorilla/sessions"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/config"
+	"github.com/screego/server/store"
+)
+
+// jwtIssuer is the issuer of the session tokens, so that other tokens signed with SCREEGO_SECRET aren't accepted.
+const jwtIssuer = "screego"
+
+// revocationRefresh is how often the revocation of all sessions is read again from the store, so that not every
+// request has to read it.
+const revocationRefresh = 10 * time.Second
+
+// jwtClaims are the values of the session besides the registered claims. created and expires are unix nanoseconds
+// like the values of the other sessions, expires is only set with SCREEGO_SESSION_IDLE_TIMEOUT_SECONDS.
+type jwtClaims struct {
+	Provider string `json:"provider,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Created  int64  `json:"created"`
+	Expires  int64  `json:"expires,omitempty"`
+}
+
+// JWTSessions keeps the login session in a signed JWT in the cookie, every instance with the same key accepts it
+// without shared state. A logout deletes the cookie, but the token stays valid until it expires. RevokeAll
+// invalidates all tokens that were issued before. Other sessions, like the short lived OIDC login, stay in the
+// fallback store.
+type JWTSessions struct {
+	algorithm   jose.SignatureAlgorithm
+	signer      jose.Signer
+	key         interface{}
+	skew        time.Duration
+	revocations store.Revocations
+	fallback    sessions.Store
+	now         func() time.Time
+
+	lock      sync.Mutex
+	notBefore time.Time
+	loadedAt  time.Time
+}
+
+// NewJWTSessions returns the sessions of SCREEGO_SESSION_JWT_*, HS256 tokens are signed with SCREEGO_SECRET. The
+// revocations should be shared by all instances of the deployment.
+func NewJWTSessions(conf config.Config, revocations store.Revocations) (*JWTSessions, error) {
+	j := &JWTSessions{
+		skew:        time.Duration(conf.SessionJWTClockSkewSeconds) * time.Second,
+		revocations: revocations,
+		now:         time.Now,
+	}
+	var signingKey interface{}
+	switch conf.SessionJWTAlgorithm {
+	case config.JWTAlgorithmHS256:
+		j.algorithm, signingKey, j.key = jose.HS256, conf.Secret, conf.Secret
+	case config.JWTAlgorithmRS256:
+		key, err := readRSAKey(conf.SessionJWTPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		j.algorithm, signingKey, j.key = jose.RS256, key, &key.PublicKey
+	default:
+		return nil, fmt.Errorf("unknown jwt algorithm %s", conf.SessionJWTAlgorithm)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: j.algorithm, Key: signingKey}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return nil, err
+	}
+	j.signer = signer
+	return j, nil
+}
+
+// readRSAKey reads a PEM encoded PKCS#1 or PKCS#8 RSA private key.
+func readRSAKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not PEM encoded", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA key", path)
+	}
+	return rsaKey, nil
+}
+
+// UseJWTSessions keeps the login sessions in signed tokens instead of the cookie store, it replaces a session backend.
+func (u *Users) UseJWTSessions(j *JWTSessions) {
+	j.fallback = u.store
+	if store, ok := u.store.(*persistentStore); ok {
+		j.fallback = store.fallback
+	}
+	u.store = j
+}
+
+// RevokeAllSessions invalidates all sessions that were created until now. It is only supported by the jwt sessions,
+// the sessions of the other stores can be revoked per user with LogoutAll.
+func (u *Users) RevokeAllSessions() (time.Time, error) {
+	j, ok := u.store.(*JWTSessions)
+	if !ok {
+		return time.Time{}, errors.New("only jwt sessions can be revoked at once")
+	}
+	return j.RevokeAll()
+}
+
+// RevokeAll invalidates all tokens of sessions that were created until now.
+func (j *JWTSessions) RevokeAll() (time.Time, error) {
+	now := j.now()
+	if err := j.revocations.SetSessionsNotBefore(now); err != nil {
+		return time.Time{}, err
+	}
+	j.lock.Lock()
+	j.notBefore, j.loadedAt = now, now
+	j.lock.Unlock()
+	return now, nil
+}
+
+// revokedBefore returns the time before which the sessions are revoked, the previous time is kept if the store
+// fails.
+func (j *JWTSessions) revokedBefore() time.Time {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	now := j.now()
+	if now.Sub(j.loadedAt) < revocationRefresh {
+		return j.notBefore
+	}
+	notBefore, err := j.revocations.SessionsNotBefore()
+	if err != nil {
+		log.Warn().Err(err).Msg("Could not read the revocation of the sessions")
+		return j.notBefore
+	}
+	j.notBefore, j.loadedAt = notBefore, now
+	return notBefore
+}
+
+func (j *JWTSessions) Get(r *http.Request, name string) (*sessions.Session, error) {
+	if name == oidcSession {
+		return j.fallback.Get(r, name)
+	}
+	return sessions.GetRegistry(r).Get(j, name)
+}
+
+// New returns the session of the token in the cookie, invalid tokens result in a new session without error.
+func (j *JWTSessions) New(r *http.Request, name string) (*sessions.Session, error) {
+	if name == oidcSession {
+		return j.fallback.New(r, name)
+	}
+	session := sessions.NewSession(j, name)
+	session.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	claims, values, err := j.parse(cookie.Value)
+	if err != nil {
+		log.Debug().Err(err).Msg("Invalid session token")
+		return session, nil
+	}
+	session.IsNew = false
+	session.Values["user"] = claims.Subject
+	session.Values["provider"] = values.Provider
+	session.Values["role"] = values.Role
+	session.Values["sid"] = claims.ID
+	session.Values["created"] = values.Created
+	if values.Expires != 0 {
+		session.Values["expires"] = values.Expires
+	}
+	return session, nil
+}
+
+func (j *JWTSessions) parse(token string) (jwt.Claims, jwtClaims, error) {
+	var claims jwt.Claims
+	var values jwtClaims
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return claims, values, err
+	}
+	// the algorithm of the header must not choose how the key is used.
+	if len(parsed.Headers) != 1 || parsed.Headers[0].Algorithm != string(j.algorithm) {
+		return claims, values, errors.New("unexpected signature algorithm")
+	}
+	if err := parsed.Claims(j.key, &claims, &values); err != nil {
+		return claims, values, err
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{Issuer: jwtIssuer, Time: j.now()}, j.skew); err != nil {
+		return claims, values, err
+	}
+	if claims.Subject == "" || claims.Expiry == nil {
+		return claims, values, errors.New("token without user or expiry")
+	}
+	if notBefore := j.revokedBefore(); !notBefore.IsZero() && values.Created <= notBefore.UnixNano() {
+		return claims, values, errors.New("token was revoked")
+	}
+	return claims, values, nil
+}
+
+// Save signs a new token with the values of the session. Sessions without user, like the one of the logout, delete the
+// cookie.
+func (j *JWTSessions) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Name() == oidcSession {
+		return j.fallback.Save(r, w, session)
+	}
+	user, _ := session.Values["user"].(string)
+	if user == "" || session.Options.MaxAge < 0 {
+		options := *session.Options
+		options.MaxAge = -1
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", &options))
+		return nil
+	}
+
+	now := j.now()
+	expires := now.Add(sessionLifetime)
+	if session.Options.MaxAge > 0 {
+		expires = now.Add(time.Duration(session.Options.MaxAge) * time.Second)
+	}
+	values := jwtClaims{Created: now.UnixNano()}
+	// renewed sessions keep their creation time, the expiry of the idle timeout is set by the caller.
+	if created, ok := session.Values["created"].(int64); ok {
+		values.Created = created
+	}
+	if idle, ok := session.Values["expires"].(int64); ok {
+		values.Expires = idle
+		expires = time.Unix(0, idle)
+	}
+	values.Provider, _ = session.Values["provider"].(string)
+	values.Role, _ = session.Values["role"].(string)
+	sid, _ := session.Values["sid"].(string)
+
+	token, err := jwt.Signed(j.signer).Claims(jwt.Claims{
+		Issuer:   jwtIssuer,
+		Subject:  user,
+		ID:       sid,
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(expires),
+	}).Claims(values).CompactSerialize()
+	if err != nil {
+		return err
+	}
+	session.ID = token
+	http.SetCookie(w, sessions.NewCookie(session.Name(), token, session.Options))
+	return nil
+}
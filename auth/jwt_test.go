@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/screego/server/config"
+	"github.com/screego/server/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newJWTUsers returns users of the same users file with jwt sessions, like the instances of a deployment.
+func newJWTUsers(t *testing.T, dir string, conf config.Config, revocations store.Revocations) *Users {
+	t.Helper()
+	users, err := ReadPasswordsFile(filepath.Join(dir, "users"), conf.Secret, conf.SessionTimeoutSeconds)
+	require.NoError(t, err)
+	sessions, err := NewJWTSessions(conf, revocations)
+	require.NoError(t, err)
+	users.UseJWTSessions(sessions)
+	return users
+}
+
+func jwtConfig(algorithm string) config.Config {
+	return config.Config{Secret: []byte("0123456789abcdef0123456789abcdef"), SessionTimeoutSeconds: 3600,
+		SessionJWTAlgorithm: algorithm, SessionJWTClockSkewSeconds: 30}
+}
+
+func writeRSAKey(t *testing.T, path string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	data, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), 0o600))
+}
+
+func TestJWTSessions(t *testing.T) {
+	for _, algorithm := range []string{config.JWTAlgorithmHS256, config.JWTAlgorithmRS256} {
+		t.Run(algorithm, func(t *testing.T) {
+			dir := t.TempDir()
+			writeUsersFile(t, filepath.Join(dir, "users"), "alice:admin")
+			conf := jwtConfig(algorithm)
+			if algorithm == config.JWTAlgorithmRS256 {
+				conf.SessionJWTPrivateKeyFile = filepath.Join(dir, "key.pem")
+				writeRSAKey(t, conf.SessionJWTPrivateKeyFile)
+			}
+			revocations := store.NewMemoryStore()
+			first := newJWTUsers(t, dir, conf, revocations)
+			second := newJWTUsers(t, dir, conf, revocations)
+
+			cookie := login(t, first, "alice")
+			assert.Equal(t, 3600, cookie.MaxAge)
+			assert.Len(t, strings.Split(cookie.Value, "."), 3, "the cookie is a JWT")
+
+			req := requestWith(cookie)
+			user, loggedIn := second.CurrentUser(req)
+			assert.True(t, loggedIn, "all instances accept the token")
+			assert.Equal(t, "alice", user)
+			assert.Equal(t, RoleAdmin, second.CurrentRole(req))
+			assert.Equal(t, first.SessionID(requestWith(cookie)), second.SessionID(req))
+		})
+	}
+}
+
+func TestJWTSessions_invalid(t *testing.T) {
+	dir := t.TempDir()
+	writeUsersFile(t, filepath.Join(dir, "users"), "alice")
+	conf := jwtConfig(config.JWTAlgorithmHS256)
+	users := newJWTUsers(t, dir, conf, store.NewMemoryStore())
+	cookie := login(t, users, "alice")
+
+	otherSecret := conf
+	otherSecret.Secret = []byte("another secret of the other deployment")
+	other := newJWTUsers(t, dir, otherSecret, store.NewMemoryStore())
+	_, loggedIn := other.CurrentUser(requestWith(cookie))
+	assert.False(t, loggedIn, "the signature doesn't match")
+
+	sign := func(claims jwt.Claims, algorithm jose.SignatureAlgorithm) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: algorithm, Key: conf.Secret}, nil)
+		require.NoError(t, err)
+		token, err := jwt.Signed(signer).Claims(claims).Claims(jwtClaims{Created: time.Now().UnixNano()}).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+	now := time.Now()
+	valid := jwt.Claims{Issuer: jwtIssuer, Subject: "alice", Expiry: jwt.NewNumericDate(now.Add(time.Hour))}
+	expired := valid
+	expired.Expiry = jwt.NewNumericDate(now.Add(-time.Minute))
+	skewed := valid
+	skewed.Expiry = jwt.NewNumericDate(now.Add(-10 * time.Second))
+	future := valid
+	future.IssuedAt = jwt.NewNumericDate(now.Add(time.Hour))
+	foreign := valid
+	foreign.Issuer = "other"
+
+	for name, test := range map[string]struct {
+		token    string
+		loggedIn bool
+	}{
+		"valid":            {token: sign(valid, jose.HS256), loggedIn: true},
+		"within the skew":  {token: sign(skewed, jose.HS256), loggedIn: true},
+		"expired":          {token: sign(expired, jose.HS256)},
+		"issued in future": {token: sign(future, jose.HS256)},
+		"other issuer":     {token: sign(foreign, jose.HS256)},
+		"other algorithm":  {token: sign(valid, jose.HS512)},
+		"opaque session":   {token: "dGhlIHRva2VuIG9mIGEgc2Vzc2lvbiBmaWxl"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cookie.Value = test.token
+			_, loggedIn := users.CurrentUser(requestWith(cookie))
+			assert.Equal(t, test.loggedIn, loggedIn)
+		})
+	}
+}
+
+func TestJWTSessions_revokeAll(t *testing.T) {
+	dir := t.TempDir()
+	writeUsersFile(t, filepath.Join(dir, "users"), "alice", "bob")
+	conf := jwtConfig(config.JWTAlgorithmHS256)
+	revocations := store.NewMemoryStore()
+	first := newJWTUsers(t, dir, conf, revocations)
+	second := newJWTUsers(t, dir, conf, revocations)
+	alice, bob := login(t, first, "alice"), login(t, second, "bob")
+	_, loggedIn := second.CurrentUser(requestWith(alice))
+	require.True(t, loggedIn)
+
+	_, err := first.RevokeAllSessions()
+	require.NoError(t, err)
+	_, loggedIn = first.CurrentUser(requestWith(alice))
+	assert.False(t, loggedIn)
+	_, loggedIn = first.CurrentUser(requestWith(bob))
+	assert.False(t, loggedIn)
+
+	_, loggedIn = second.CurrentUser(requestWith(alice))
+	assert.True(t, loggedIn, "the other instance reads the revocation again after a while")
+	jwtSessions := second.store.(*JWTSessions)
+	jwtSessions.now = func() time.Time { return time.Now().Add(revocationRefresh) }
+	_, loggedIn = second.CurrentUser(requestWith(alice))
+	assert.False(t, loggedIn)
+
+	_, loggedIn = second.CurrentUser(requestWith(login(t, second, "alice")))
+	assert.True(t, loggedIn, "new logins are valid")
+}
+
+func TestRevokeAllSessions_cookie(t *testing.T) {
+	users, err := ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	_, err = users.RevokeAllSessions()
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/screego/server/config"
+)
+
+// ErrDirectoryUnavailable is returned if the LDAP server cannot be reached or fails, it is distinct from wrong
+// credentials, so that the user isn't told to check the password during an outage.
+var ErrDirectoryUnavailable = errors.New("the directory is unavailable")
+
+// LDAP checks passwords by binding as the user at a LDAP server or Active Directory.
+type LDAP struct {
+	conf config.Config
+}
+
+// NewLDAP returns the LDAP backend, it doesn't connect to the server.
+func NewLDAP(conf config.Config) *LDAP {
+	return &LDAP{conf: conf}
+}
+
+// Check connects to the server and binds with the service account, or anonymously without one.
+func (l *LDAP) Check() error {
+	conn, err := l.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return l.bindServiceAccount(conn)
+}
+
+// Authenticate returns whether the password of the user is correct and the user matches SCREEGO_LDAP_GROUP_FILTER,
+// admin is whether the user also matches SCREEGO_LDAP_ADMIN_GROUP_FILTER. The error wraps ErrDirectoryUnavailable.
+func (l *LDAP) Authenticate(user, password string) (ok, admin bool, err error) {
+	// the server accepts a bind without password as anonymous bind.
+	if user == "" || password == "" {
+		return false, false, nil
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return false, false, err
+	}
+	defer conn.Close()
+
+	dn, err := l.userDN(conn, user)
+	if err != nil || dn == "" {
+		return false, false, err
+	}
+	if err := conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return false, false, nil
+		}
+		return false, false, unavailable(err)
+	}
+
+	// the groups are searched with the service account, users often may not search groups.
+	if l.conf.LDAPBindDN != "" && (l.conf.LDAPGroupFilter != "" || l.conf.LDAPAdminGroupFilter != "") {
+		if err := l.bindServiceAccount(conn); err != nil {
+			return false, false, err
+		}
+	}
+	if l.conf.LDAPGroupFilter != "" {
+		if ok, err = l.matches(conn, l.conf.LDAPGroupFilter, dn); err != nil || !ok {
+			return false, false, err
+		}
+	}
+	if l.conf.LDAPAdminGroupFilter != "" {
+		if admin, err = l.matches(conn, l.conf.LDAPAdminGroupFilter, dn); err != nil {
+			return false, false, err
+		}
+	}
+	return true, admin, nil
+}
+
+func (l *LDAP) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(l.conf.LDAPServer, ldap.DialWithDialer(&net.Dialer{Timeout: l.conf.LDAPTimeout}))
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	conn.SetTimeout(l.conf.LDAPTimeout)
+	return conn, nil
+}
+
+func (l *LDAP) bindServiceAccount(conn *ldap.Conn) error {
+	var err error
+	if l.conf.LDAPBindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(l.conf.LDAPBindDN, l.conf.LDAPBindPassword)
+	}
+	if err != nil {
+		return unavailable(fmt.Errorf("could not bind with the service account: %w", err))
+	}
+	return nil
+}
+
+// userDN returns the dn of the user, it is empty if the user doesn't exist.
+func (l *LDAP) userDN(conn *ldap.Conn, user string) (string, error) {
+	if l.conf.LDAPUserDNTemplate != "" {
+		return fmt.Sprintf(l.conf.LDAPUserDNTemplate, ldap.EscapeDN(user)), nil
+	}
+
+	if err := l.bindServiceAccount(conn); err != nil {
+		return "", err
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(l.conf.LDAPBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, 0, false, fmt.Sprintf(l.conf.LDAPUserFilter, ldap.EscapeFilter(user)), []string{"dn"}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return "", unavailable(err)
+	}
+	// an ambiguous filter must not log in one of the matching users.
+	if result == nil || len(result.Entries) != 1 {
+		return "", nil
+	}
+	return result.Entries[0].DN, nil
+}
+
+// matches returns whether the filter with the user dn finds an entry, e.g. a group with the user as member.
+func (l *LDAP) matches(conn *ldap.Conn, filter, dn string) (bool, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(l.conf.LDAPBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		1, 0, false, fmt.Sprintf(filter, ldap.EscapeFilter(dn)), []string{"dn"}, nil))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return true, nil
+	}
+	if err != nil {
+		return false, unavailable(err)
+	}
+	return len(result.Entries) > 0, nil
+}
+
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDirectory is a LDAP server that only supports simple binds and searches with a filter of entries.
+type fakeDirectory struct {
+	net.Listener
+	// passwords by dn.
+	passwords map[string]string
+	// dns of the entries that match a filter.
+	entries map[string][]string
+}
+
+func newFakeDirectory(t *testing.T) *fakeDirectory {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	directory := &fakeDirectory{
+		Listener: listener,
+		passwords: map[string]string{
+			"cn=service,dc=example,dc=org":          "service-pw",
+			"uid=alice,ou=people,dc=example,dc=org": "alice-pw",
+			"uid=bob,ou=people,dc=example,dc=org":   "bob-pw",
+		},
+		entries: map[string][]string{
+			"(uid=alice)": {"uid=alice,ou=people,dc=example,dc=org"},
+			"(uid=bob)":   {"uid=bob,ou=people,dc=example,dc=org"},
+			"(&(cn=screego)(member=uid=alice,ou=people,dc=example,dc=org))": {"cn=screego,ou=groups,dc=example,dc=org"},
+			"(&(cn=screego)(member=uid=bob,ou=people,dc=example,dc=org))":   {"cn=screego,ou=groups,dc=example,dc=org"},
+			"(&(cn=admins)(member=uid=alice,ou=people,dc=example,dc=org))":  {"cn=admins,ou=groups,dc=example,dc=org"},
+		},
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go directory.serve(conn)
+		}
+	}()
+	return directory
+}
+
+func (d *fakeDirectory) URL() string {
+	return "ldap://" + d.Addr().String()
+}
+
+func (d *fakeDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn, password := op.Children[1].Value.(string), op.Children[2].Data.String()
+			code := uint16(ldap.LDAPResultInvalidCredentials)
+			if (dn == "" && password == "") || (password != "" && d.passwords[dn] == password) {
+				code = ldap.LDAPResultSuccess
+			}
+			d.respond(conn, id, ldap.ApplicationBindResponse, code)
+		case ldap.ApplicationSearchRequest:
+			filter, _ := ldap.DecompileFilter(op.Children[6])
+			for _, dn := range d.entries[filter] {
+				envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+				envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+				entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+				entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, ""))
+				entry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, ""))
+				envelope.AppendChild(entry)
+				_, _ = conn.Write(envelope.Bytes())
+			}
+			d.respond(conn, id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+		default:
+			return
+		}
+	}
+}
+
+func (d *fakeDirectory) respond(conn net.Conn, id int64, tag ber.Tag, code uint16) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	envelope.AppendChild(response)
+	_, _ = conn.Write(envelope.Bytes())
+}
+
+func ldapConfig(server string) config.Config {
+	return config.Config{
+		LDAPServer:           server,
+		LDAPBindDN:           "cn=service,dc=example,dc=org",
+		LDAPBindPassword:     "service-pw",
+		LDAPBaseDN:           "dc=example,dc=org",
+		LDAPUserFilter:       "(uid=%s)",
+		LDAPGroupFilter:      "(&(cn=screego)(member=%s))",
+		LDAPAdminGroupFilter: "(&(cn=admins)(member=%s))",
+		LDAPTimeout:          time.Second,
+	}
+}
+
+func TestLDAP_authenticate(t *testing.T) {
+	directory := newFakeDirectory(t)
+	l := NewLDAP(ldapConfig(directory.URL()))
+	require.NoError(t, l.Check())
+
+	tests := []struct {
+		user, password string
+		ok, admin      bool
+	}{
+		{user: "alice", password: "alice-pw", ok: true, admin: true},
+		{user: "bob", password: "bob-pw", ok: true},
+		{user: "alice", password: "wrong"},
+		{user: "alice", password: ""},
+		{user: "carol", password: "carol-pw"},
+		{user: "*", password: "alice-pw"},
+	}
+	for _, test := range tests {
+		ok, admin, err := l.Authenticate(test.user, test.password)
+		assert.NoError(t, err, test.user)
+		assert.Equal(t, test.ok, ok, test.user+":"+test.password)
+		assert.Equal(t, test.admin, admin, test.user+":"+test.password)
+	}
+}
+
+func TestLDAP_groupFilter(t *testing.T) {
+	directory := newFakeDirectory(t)
+	delete(directory.entries, "(&(cn=screego)(member=uid=bob,ou=people,dc=example,dc=org))")
+	l := NewLDAP(ldapConfig(directory.URL()))
+
+	ok, _, err := l.Authenticate("bob", "bob-pw")
+	assert.NoError(t, err)
+	assert.False(t, ok, "bob is not in the screego group")
+}
+
+func TestLDAP_userDNTemplate(t *testing.T) {
+	directory := newFakeDirectory(t)
+	l := NewLDAP(config.Config{
+		LDAPServer:         directory.URL(),
+		LDAPUserDNTemplate: "uid=%s,ou=people,dc=example,dc=org",
+		LDAPTimeout:        time.Second,
+	})
+
+	ok, admin, err := l.Authenticate("bob", "bob-pw")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, admin)
+
+	ok, _, err = l.Authenticate("bob", "alice-pw")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	l = NewLDAP(config.Config{
+		LDAPServer:         directory.URL(),
+		LDAPUserDNTemplate: "uid=%s,dc=example,dc=org",
+		LDAPTimeout:        time.Second,
+	})
+	ok, _, err = l.Authenticate("alice,ou=people", "alice-pw")
+	assert.NoError(t, err)
+	assert.False(t, ok, "the user name is escaped in the dn")
+}
+
+func TestLDAP_unavailable(t *testing.T) {
+	directory := newFakeDirectory(t)
+	_ = directory.Close()
+	l := NewLDAP(ldapConfig(directory.URL()))
+
+	assert.ErrorIs(t, l.Check(), ErrDirectoryUnavailable)
+	ok, _, err := l.Authenticate("alice", "alice-pw")
+	assert.ErrorIs(t, err, ErrDirectoryUnavailable)
+	assert.False(t, ok)
+}
+
+func ldapLogin(users *Users, user, pass string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.PostForm = url.Values{"user": {user}, "pass": {pass}}
+	recorder := httptest.NewRecorder()
+	users.Authenticate(recorder, req)
+	return recorder
+}
+
+func TestAuthenticate_ldap(t *testing.T) {
+	directory := newFakeDirectory(t)
+	path := t.TempDir() + "/users"
+	writeUsersFile(t, path, "root")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	users.LDAP = NewLDAP(ldapConfig(directory.URL()))
+
+	response := ldapLogin(users, "bob", "bob-pw")
+	require.Equal(t, http.StatusOK, response.Code)
+	req := httptest.NewRequest("GET", "/config", nil)
+	for _, cookie := range response.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	user, loggedIn := users.CurrentUser(req)
+	assert.True(t, loggedIn)
+	assert.Equal(t, "bob", user)
+
+	assert.Equal(t, http.StatusUnauthorized, ldapLogin(users, "bob", "wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, ldapLogin(users, "root", "root-pw").Code, "the users file isn't used for logins")
+
+	assert.True(t, users.Validate("alice", "alice-pw"), "alice is in the admin group")
+	assert.False(t, users.Validate("bob", "bob-pw"))
+	assert.True(t, users.Validate("root", "root-pw"), "the users file is still used for basic auth")
+
+	_ = directory.Close()
+	assert.Equal(t, http.StatusBadGateway, ldapLogin(users, "bob", "bob-pw").Code)
+	assert.False(t, users.Validate("alice", "alice-pw"))
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+)
+
+// Networks restricts a request to client ips that aren't denied and, if an allow list is set, allowed. The nil
+// Networks allows all ips.
+type Networks struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewNetworks parses the CIDRs, e.g. 10.0.0.0/8 or 2001:db8::/32. It returns nil if both lists are empty.
+func NewNetworks(allow, deny []string) (*Networks, error) {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil, nil
+	}
+	n := &Networks{}
+	var err error
+	if n.allow, err = parseNetworks(allow); err != nil {
+		return nil, err
+	}
+	if n.deny, err = parseNetworks(deny); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// Check returns whether the ip is allowed and the rule that denied it, "deny <network>" or "not allowed". The deny
+// list is checked first. Unparsable ips, e.g. of the unix socket, are only allowed without an allow list.
+func (n *Networks) Check(ip net.IP) (bool, string) {
+	if n == nil {
+		return true, ""
+	}
+	for _, network := range n.deny {
+		if ip != nil && network.Contains(ip) {
+			return false, "deny " + network.String()
+		}
+	}
+	if len(n.allow) == 0 {
+		return true, ""
+	}
+	for _, network := range n.allow {
+		if ip != nil && network.Contains(ip) {
+			return true, ""
+		}
+	}
+	return false, "not allowed"
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworks(t *testing.T) {
+	networks, err := NewNetworks([]string{"10.0.0.0/8", "2001:db8::/32"}, []string{"10.1.0.0/16"})
+	require.NoError(t, err)
+
+	for ip, want := range map[string]string{
+		"10.0.0.1":        "",
+		"2001:db8::1":     "",
+		"10.1.2.3":        "deny 10.1.0.0/16",
+		"192.168.0.1":     "not allowed",
+		"2001:db9::1":     "not allowed",
+		"::ffff:10.0.0.1": "",
+	} {
+		ok, rule := networks.Check(net.ParseIP(ip))
+		assert.Equal(t, want == "", ok, ip)
+		assert.Equal(t, want, rule, ip)
+	}
+	ok, _ := networks.Check(nil)
+	assert.False(t, ok, "ips of the unix socket aren't in the allow list")
+
+	denyOnly, err := NewNetworks(nil, []string{"::1/128"})
+	require.NoError(t, err)
+	ok, rule := denyOnly.Check(net.ParseIP("::1"))
+	assert.False(t, ok)
+	assert.Equal(t, "deny ::1/128", rule)
+	ok, _ = denyOnly.Check(net.ParseIP("127.0.0.1"))
+	assert.True(t, ok)
+
+	none, err := NewNetworks(nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	ok, _ = none.Check(net.ParseIP("127.0.0.1"))
+	assert.True(t, ok)
+
+	_, err = NewNetworks([]string{"10.0.0.1"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gorilla/sessions"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/config"
+	"golang.org/x/oauth2"
+)
+
+// oidcLoginTimeout is how long the user has to log in at the provider.
+const oidcLoginTimeout = 10 * time.Minute
+
+// OIDC logs in users with the authorization code flow of an OpenID Connect provider. Logged in users get the same
+// session as with a password login.
+type OIDC struct {
+	users         *Users
+	oauth         oauth2.Config
+	verifier      *oidc.IDTokenVerifier
+	usernameClaim string
+	groupsClaim   string
+	allowedGroups []string
+	home          string
+}
+
+// NewOIDC discovers the provider of the issuer.
+func NewOIDC(ctx context.Context, conf config.Config, users *Users) (*OIDC, error) {
+	provider, err := oidc.NewProvider(ctx, conf.OIDCIssuer)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover the OIDC provider %s: %w", conf.OIDCIssuer, err)
+	}
+	return &OIDC{
+		users: users,
+		oauth: oauth2.Config{
+			ClientID:     conf.OIDCClientID,
+			ClientSecret: conf.OIDCClientSecret,
+			RedirectURL:  conf.OIDCRedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       conf.OIDCScopes,
+		},
+		// verifies the signature, issuer, audience and expiry of id tokens.
+		verifier:      provider.Verifier(&oidc.Config{ClientID: conf.OIDCClientID}),
+		usernameClaim: conf.OIDCUsernameClaim,
+		groupsClaim:   conf.OIDCGroupsClaim,
+		allowedGroups: conf.OIDCAllowedGroups,
+		home:          conf.BasePath + "/",
+	}, nil
+}
+
+// Login redirects to the provider.
+func (o *OIDC) Login(w http.ResponseWriter, r *http.Request) {
+	state, nonce, verifier := oauth2.GenerateVerifier(), oauth2.GenerateVerifier(), oauth2.GenerateVerifier()
+
+	session := sessions.NewSession(o.users.store, oidcSession)
+	session.IsNew = true
+	session.Options.MaxAge = int(oidcLoginTimeout.Seconds())
+	session.Options.HttpOnly = true
+	session.Options.SameSite = http.SameSiteLaxMode
+	session.Values["state"] = state
+	session.Values["nonce"] = nonce
+	session.Values["verifier"] = verifier
+	if err := o.users.store.Save(r, w, session); err != nil {
+		o.fail(w, http.StatusInternalServerError, "could not start the login", err)
+		return
+	}
+
+	http.Redirect(w, r, o.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// Callback exchanges the authorization code and logs in the user of the id token.
+func (o *OIDC) Callback(w http.ResponseWriter, r *http.Request) {
+	session, _ := o.users.store.Get(r, oidcSession)
+	state, _ := session.Values["state"].(string)
+	nonce, _ := session.Values["nonce"].(string)
+	verifier, _ := session.Values["verifier"].(string)
+	session.Options.MaxAge = -1
+	_ = o.users.store.Save(r, w, session)
+
+	if providerErr := r.URL.Query().Get("error"); providerErr != "" {
+		o.fail(w, http.StatusUnauthorized, "the login was rejected by the provider: "+providerErr, nil)
+		return
+	}
+	if state == "" || r.URL.Query().Get("state") != state {
+		o.fail(w, http.StatusBadRequest, "the login has expired or was started in another browser, please try again", nil)
+		return
+	}
+
+	token, err := o.oauth.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		o.fail(w, http.StatusBadGateway, "could not get a token from the provider", err)
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		o.fail(w, http.StatusBadGateway, "the provider did not return an id token", nil)
+		return
+	}
+	idToken, err := o.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		o.fail(w, http.StatusUnauthorized, "the id token of the provider is invalid", err)
+		return
+	}
+	if idToken.Nonce != nonce {
+		o.fail(w, http.StatusUnauthorized, "the id token of the provider is invalid", fmt.Errorf("nonce mismatch"))
+		return
+	}
+
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		o.fail(w, http.StatusBadGateway, "could not read the id token", err)
+		return
+	}
+	user, _ := claims[o.usernameClaim].(string)
+	if user == "" {
+		o.fail(w, http.StatusUnauthorized, fmt.Sprintf("the id token has no %s claim", o.usernameClaim), nil)
+		return
+	}
+	if !o.allowed(claims) {
+		log.Info().Str("user", user).Msg("OIDC login denied, user is not in an allowed group")
+		o.fail(w, http.StatusForbidden, "you are not allowed to use screego", nil)
+		return
+	}
+
+	if err := o.users.startSession(w, r, user, providerOIDC, RoleUser); err != nil {
+		o.fail(w, http.StatusInternalServerError, "could not save the login", err)
+		return
+	}
+	http.Redirect(w, r, o.home, http.StatusFound)
+}
+
+// allowed returns whether the groups claim contains one of the allowed groups, all users are allowed without them.
+func (o *OIDC) allowed(claims map[string]interface{}) bool {
+	if len(o.allowedGroups) == 0 {
+		return true
+	}
+	var groups []string
+	switch value := claims[o.groupsClaim].(type) {
+	case string:
+		groups = []string{value}
+	case []interface{}:
+		for _, group := range value {
+			if name, ok := group.(string); ok {
+				groups = append(groups, name)
+			}
+		}
+	}
+	for _, group := range groups {
+		for _, allowed := range o.allowedGroups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var oidcErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Screego - Login failed</title></head>
+<body>
+<h1>Login failed</h1>
+<p>{{.Message}}</p>
+<p><a href="{{.Home}}">Back to Screego</a></p>
+</body>
+</html>
+`))
+
+func (o *OIDC) fail(w http.ResponseWriter, status int, message string, err error) {
+	if err != nil {
+		log.Warn().Err(err).Int("status", status).Msg("OIDC login failed: " + message)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = oidcErrorPage.Execute(w, struct{ Message, Home string }{Message: message, Home: o.home})
+}
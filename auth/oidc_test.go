@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	lock   sync.Mutex
+	claims map[string]interface{}
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	provider := &fakeProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                provider.URL,
+			"authorization_endpoint":                provider.URL + "/authorize",
+			"token_endpoint":                        provider.URL + "/token",
+			"jwks_uri":                              provider.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code" || r.FormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     provider.idToken(t),
+		})
+	})
+	provider.Server = httptest.NewServer(mux)
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+// setClaims sets the claims of the next id token, they default to a valid token of alice.
+func (p *fakeProvider) setClaims(nonce string, override map[string]interface{}) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.claims = map[string]interface{}{
+		"iss":                p.URL,
+		"aud":                "screego",
+		"sub":                "1234",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"iat":                time.Now().Unix(),
+		"nonce":              nonce,
+		"preferred_username": "alice",
+		"groups":             []string{"staff"},
+	}
+	for key, value := range override {
+		p.claims[key] = value
+	}
+}
+
+func (p *fakeProvider) idToken(t *testing.T) string {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: p.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test"))
+	require.NoError(t, err)
+	payload, err := json.Marshal(p.claims)
+	require.NoError(t, err)
+	signed, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+func newTestOIDC(t *testing.T, provider *fakeProvider, allowedGroups ...string) (*OIDC, *Users) {
+	t.Helper()
+	users, err := ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	o, err := NewOIDC(context.Background(), config.Config{
+		OIDCIssuer:        provider.URL,
+		OIDCClientID:      "screego",
+		OIDCClientSecret:  "client-secret",
+		OIDCRedirectURL:   "http://screego.example/auth/oidc/callback",
+		OIDCScopes:        []string{"openid", "profile"},
+		OIDCUsernameClaim: "preferred_username",
+		OIDCGroupsClaim:   "groups",
+		OIDCAllowedGroups: allowedGroups,
+	}, users)
+	require.NoError(t, err)
+	return o, users
+}
+
+// oidcLogin starts the login and returns the cookies, state and nonce of the redirect to the provider.
+func oidcLogin(t *testing.T, o *OIDC, provider *fakeProvider) ([]*http.Cookie, string, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	o.Login(recorder, httptest.NewRequest("GET", "/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, provider.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+	query := location.Query()
+	assert.Equal(t, "screego", query.Get("client_id"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.NotEmpty(t, query.Get("code_challenge"))
+	return recorder.Result().Cookies(), query.Get("state"), query.Get("nonce")
+}
+
+func oidcCallback(o *OIDC, cookies []*http.Cookie, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/auth/oidc/callback?"+query, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	o.Callback(recorder, req)
+	return recorder
+}
+
+func TestOIDC_login(t *testing.T) {
+	provider := newFakeProvider(t)
+	o, users := newTestOIDC(t, provider, "staff")
+
+	cookies, state, nonce := oidcLogin(t, o, provider)
+	provider.setClaims(nonce, nil)
+	response := oidcCallback(o, cookies, "code=code&state="+url.QueryEscape(state))
+	require.Equal(t, http.StatusFound, response.Code, response.Body.String())
+	assert.Equal(t, "/", response.Header().Get("Location"))
+
+	req := httptest.NewRequest("GET", "/config", nil)
+	for _, cookie := range response.Result().Cookies() {
+		if cookie.Name == "user" {
+			req.AddCookie(cookie)
+		}
+	}
+	user, loggedIn := users.CurrentUser(req)
+	assert.True(t, loggedIn)
+	assert.Equal(t, "alice", user)
+	assert.NotEmpty(t, users.SessionID(req))
+}
+
+func TestOIDC_rejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		groups []string
+		status int
+	}{
+		{name: "audience", claims: map[string]interface{}{"aud": "other"}, status: http.StatusUnauthorized},
+		{name: "issuer", claims: map[string]interface{}{"iss": "https://evil.example"}, status: http.StatusUnauthorized},
+		{name: "expired", claims: map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}, status: http.StatusUnauthorized},
+		{name: "nonce", claims: map[string]interface{}{"nonce": "other"}, status: http.StatusUnauthorized},
+		{name: "username", claims: map[string]interface{}{"preferred_username": ""}, status: http.StatusUnauthorized},
+		{name: "group", groups: []string{"admins"}, status: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := newFakeProvider(t)
+			o, _ := newTestOIDC(t, provider, test.groups...)
+
+			cookies, state, nonce := oidcLogin(t, o, provider)
+			provider.setClaims(nonce, test.claims)
+			response := oidcCallback(o, cookies, "code=code&state="+url.QueryEscape(state))
+			assert.Equal(t, test.status, response.Code)
+			assert.Equal(t, "text/html; charset=utf-8", response.Header().Get("Content-Type"))
+			assert.Contains(t, response.Body.String(), "Login failed")
+			for _, cookie := range response.Result().Cookies() {
+				assert.NotEqual(t, "user", cookie.Name)
+			}
+		})
+	}
+}
+
+func TestOIDC_invalidCallback(t *testing.T) {
+	provider := newFakeProvider(t)
+	o, _ := newTestOIDC(t, provider)
+	cookies, state, nonce := oidcLogin(t, o, provider)
+	provider.setClaims(nonce, nil)
+
+	assert.Equal(t, http.StatusBadRequest, oidcCallback(o, cookies, "code=code&state=other").Code)
+	assert.Equal(t, http.StatusBadRequest, oidcCallback(o, nil, "code=code&state="+url.QueryEscape(state)).Code)
+	assert.Equal(t, http.StatusBadGateway, oidcCallback(o, cookies, "code=wrong&state="+url.QueryEscape(state)).Code)
+	denied := oidcCallback(o, cookies, "error=access_denied&state="+url.QueryEscape(state))
+	assert.Equal(t, http.StatusUnauthorized, denied.Code)
+	assert.Contains(t, denied.Body.String(), "access_denied")
+}
+
+func TestAuthenticate_passwordLoginDisabled(t *testing.T) {
+	path := t.TempDir() + "/users"
+	writeUsersFile(t, path, "alice")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	users.PasswordLoginDisabled = true
+
+	form := url.Values{"user": {"alice"}, "pass": {"alice-pw"}}
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.PostForm = form
+	recorder := httptest.NewRecorder()
+	users.Authenticate(recorder, req)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.True(t, users.Validate("alice", "alice-pw"), "the users file is still used for basic auth")
+}
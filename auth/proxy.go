@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/config"
+)
+
+// ProxyAuth trusts the user header of requests from a reverse proxy that already authenticated the user, e.g.
+// oauth2-proxy. The headers of all other requests are ignored, so they cannot be spoofed.
+type ProxyAuth struct {
+	trusted       []*net.IPNet
+	userHeader    string
+	groupsHeader  string
+	allowedGroups []string
+}
+
+// NewProxyAuth parses the trusted proxy networks.
+func NewProxyAuth(conf config.Config) (*ProxyAuth, error) {
+	p := &ProxyAuth{
+		userHeader:    conf.ProxyAuthUserHeader,
+		groupsHeader:  conf.ProxyAuthGroupsHeader,
+		allowedGroups: conf.ProxyAuthAllowedGroups,
+	}
+	for _, cidr := range conf.ProxyAuthTrustedProxies {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		p.trusted = append(p.trusted, network)
+	}
+	return p, nil
+}
+
+// user returns the user of the header, it is empty if the user isn't in an allowed group. trusted is false if the
+// request isn't from a trusted proxy or has no user header, then the session is used.
+func (p *ProxyAuth) user(r *http.Request) (user string, trusted bool) {
+	user = strings.TrimSpace(r.Header.Get(p.userHeader))
+	if user == "" || !p.isTrusted(r) {
+		return "", false
+	}
+	if !p.allowed(r) {
+		return "", true
+	}
+	return user, true
+}
+
+func (p *ProxyAuth) isTrusted(r *http.Request) bool {
+	// requests over the unix socket have no ip and are never trusted.
+	ip := net.ParseIP(audit.RemoteIP(r))
+	if ip == nil {
+		return false
+	}
+	for _, network := range p.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed returns whether the groups header contains one of the allowed groups, all users are allowed without them.
+func (p *ProxyAuth) allowed(r *http.Request) bool {
+	if len(p.allowedGroups) == 0 {
+		return true
+	}
+	for _, group := range strings.Split(r.Header.Get(p.groupsHeader), ",") {
+		group = strings.TrimSpace(group)
+		for _, allowed := range p.allowedGroups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ProxyLogin starts a session for the user of the proxy header, so that the session id and the audit log work like
+// with the other logins. The user of the header is also used without session, e.g. for the websocket upgrade.
+func (u *Users) ProxyLogin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, trusted := u.Proxy.user(r); trusted && user != "" && u.sessionUser(r) != user {
+			if err := u.startSession(w, r, user, providerProxy, RoleUser); err != nil {
+				log.Error().Err(err).Str("user", user).Msg("Could not start the session of the proxy user")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProxyAuth(t *testing.T, allowedGroups ...string) *Users {
+	t.Helper()
+	users, err := ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	users.Proxy, err = NewProxyAuth(config.Config{
+		ProxyAuthTrustedProxies: []string{"10.0.0.0/8", "::1/128"},
+		ProxyAuthUserHeader:     "X-Forwarded-User",
+		ProxyAuthGroupsHeader:   "X-Forwarded-Groups",
+		ProxyAuthAllowedGroups:  allowedGroups,
+	})
+	require.NoError(t, err)
+	return users
+}
+
+func TestProxyAuth_currentUser(t *testing.T) {
+	users := newTestProxyAuth(t)
+
+	tests := []struct {
+		name, remoteAddr, user string
+		loggedIn               bool
+	}{
+		{name: "trusted", remoteAddr: "10.1.2.3:1234", user: "alice", loggedIn: true},
+		{name: "trusted ipv6", remoteAddr: "[::1]:1234", user: "alice", loggedIn: true},
+		{name: "untrusted", remoteAddr: "192.0.2.1:1234", user: "alice"},
+		{name: "unix socket", remoteAddr: "@", user: "alice"},
+		{name: "no header", remoteAddr: "10.1.2.3:1234"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/stream", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.user != "" {
+				req.Header.Set("X-Forwarded-User", test.user)
+			}
+			user, loggedIn := users.CurrentUser(req)
+			assert.Equal(t, test.loggedIn, loggedIn)
+			if loggedIn {
+				assert.Equal(t, test.user, user)
+			} else {
+				assert.Equal(t, "guest", user)
+			}
+		})
+	}
+}
+
+func TestProxyAuth_allowedGroups(t *testing.T) {
+	users := newTestProxyAuth(t, "screego")
+
+	req := httptest.NewRequest("GET", "/config", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Set("X-Forwarded-User", "alice")
+	req.Header.Set("X-Forwarded-Groups", "staff, screego")
+	user, loggedIn := users.CurrentUser(req)
+	assert.True(t, loggedIn)
+	assert.Equal(t, "alice", user)
+
+	req.Header.Set("X-Forwarded-Groups", "staff")
+	_, loggedIn = users.CurrentUser(req)
+	assert.False(t, loggedIn)
+}
+
+func TestProxyLogin(t *testing.T) {
+	users := newTestProxyAuth(t)
+	handler := users.ProxyLogin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Set("X-Forwarded-User", "alice")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	// the session is only started once.
+	req = httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Set("X-Forwarded-User", "alice")
+	req.AddCookie(cookies[0])
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	assert.Empty(t, recorder.Result().Cookies())
+
+	// the header of untrusted requests neither starts nor replaces a session.
+	spoofed := httptest.NewRequest("GET", "/", nil)
+	spoofed.Header.Set("X-Forwarded-User", "bob")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, spoofed)
+	assert.Empty(t, recorder.Result().Cookies())
+
+	spoofed.AddCookie(cookies[0])
+	user, loggedIn := users.CurrentUser(spoofed)
+	assert.True(t, loggedIn)
+	assert.Equal(t, "alice", user)
+	assert.NotEmpty(t, users.SessionID(spoofed))
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/config"
+)
+
+// limiterCleanupInterval is how often the counters of addresses and users without recent failures are removed.
+const limiterCleanupInterval = time.Minute
+
+// LoginLimiter locks out addresses and user names after too many failed logins. Both are counted separately: a user
+// name is locked after a few failures, so that its password cannot be guessed from many addresses, and an address
+// only after many failures, so that other users behind the same NAT can still log in while one user name is locked.
+type LoginLimiter struct {
+	maxUserFailures int
+	maxIPFailures   int
+	lockout         time.Duration
+	maxLockout      time.Duration
+	trustProxy      bool
+	now             func() time.Time
+
+	lock        sync.Mutex
+	users       map[string]*failures
+	ips         map[string]*failures
+	lastCleanup time.Time
+}
+
+type failures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// NewLoginLimiter returns a limiter with the thresholds of the config.
+func NewLoginLimiter(conf config.Config) *LoginLimiter {
+	return &LoginLimiter{
+		maxUserFailures: conf.LoginMaxFailures,
+		maxIPFailures:   conf.LoginMaxFailuresPerIP,
+		lockout:         conf.LoginLockout,
+		maxLockout:      conf.LoginMaxLockout,
+		trustProxy:      conf.TrustProxyHeaders,
+		now:             time.Now,
+		users:           map[string]*failures{},
+		ips:             map[string]*failures{},
+	}
+}
+
+// locked returns how long the address or the user name of the request is still locked out.
+func (l *LoginLimiter) locked(r *http.Request, user string) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	wait := time.Duration(0)
+	for _, f := range []*failures{l.ips[audit.ClientIP(r, l.trustProxy)], l.users[user]} {
+		if f != nil && f.lockedUntil.Sub(now) > wait {
+			wait = f.lockedUntil.Sub(now)
+		}
+	}
+	return wait
+}
+
+// failed counts a failed login of the address and the user name, every failure after the threshold doubles the
+// lockout up to the maximum.
+func (l *LoginLimiter) failed(r *http.Request, user string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	l.cleanup(now)
+
+	ip := audit.ClientIP(r, l.trustProxy)
+	if lockout := l.count(l.ips, ip, l.maxIPFailures, now); lockout > 0 {
+		log.Warn().Str("ip", ip).Str("lockout", lockout.String()).Msg("Too many failed logins from address, locked out")
+	}
+	if lockout := l.count(l.users, user, l.maxUserFailures, now); lockout > 0 {
+		log.Warn().Str("ip", ip).Str("user", user).Str("lockout", lockout.String()).Msg("Too many failed logins of user, locked out")
+	}
+}
+
+// count adds a failure and returns the lockout if the threshold was reached.
+func (l *LoginLimiter) count(counters map[string]*failures, key string, threshold int, now time.Time) time.Duration {
+	f, ok := counters[key]
+	if !ok || now.Sub(f.last) > l.maxLockout {
+		f = &failures{}
+		counters[key] = f
+	}
+	f.count++
+	f.last = now
+	if threshold <= 0 || f.count < threshold {
+		return 0
+	}
+
+	lockout := l.lockout
+	for i := threshold; i < f.count && lockout < l.maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > l.maxLockout {
+		lockout = l.maxLockout
+	}
+	f.lockedUntil = now.Add(lockout)
+	return lockout
+}
+
+// succeeded resets the failures of the user name. The failures of the address are kept, otherwise an attacker with
+// an account could reset them between guessing the passwords of other users.
+func (l *LoginLimiter) succeeded(user string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	delete(l.users, user)
+}
+
+// cleanup removes the counters without failures within the maximum lockout, they would start from zero anyway.
+func (l *LoginLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < limiterCleanupInterval {
+		return
+	}
+	l.lastCleanup = now
+	for _, counters := range []map[string]*failures{l.users, l.ips} {
+		for key, f := range counters {
+			if now.Sub(f.last) > l.maxLockout && !now.Before(f.lockedUntil) {
+				delete(counters, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/screego/server/audit"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newLimitedUsers(t *testing.T, conf config.Config) (*Users, *fakeClock) {
+	t.Helper()
+	path := t.TempDir() + "/users"
+	writeUsersFile(t, path, "alice", "bob")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	users.Limiter = NewLoginLimiter(conf)
+	users.Limiter.now = clock.Now
+	return users, clock
+}
+
+func limitConfig() config.Config {
+	return config.Config{
+		LoginMaxFailures:      3,
+		LoginMaxFailuresPerIP: 5,
+		LoginLockout:          time.Minute,
+		LoginMaxLockout:       3 * time.Minute,
+	}
+}
+
+func loginFrom(users *Users, ip, user, pass string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.RemoteAddr = ip + ":1234"
+	req.PostForm = url.Values{"user": {user}, "pass": {pass}}
+	recorder := httptest.NewRecorder()
+	users.Authenticate(recorder, req)
+	return recorder
+}
+
+func TestLoginLimiter_userLockout(t *testing.T) {
+	users, clock := newLimitedUsers(t, limitConfig())
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, loginFrom(users, "10.0.0.1", "alice", "wrong").Code)
+	}
+	locked := loginFrom(users, "10.0.0.2", "alice", "alice-pw")
+	assert.Equal(t, http.StatusTooManyRequests, locked.Code, "the user is locked from all addresses")
+	assert.Equal(t, "61", locked.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, loginFrom(users, "10.0.0.1", "bob", "bob-pw").Code, "other users are not locked")
+
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, loginFrom(users, "10.0.0.1", "alice", "alice-pw").Code)
+	assert.Equal(t, http.StatusUnauthorized, loginFrom(users, "10.0.0.1", "alice", "wrong").Code, "the success reset the failures")
+}
+
+func TestLoginLimiter_backoff(t *testing.T) {
+	users, clock := newLimitedUsers(t, limitConfig())
+	req := httptest.NewRequest("POST", "/login", nil)
+
+	for i := 0; i < 3; i++ {
+		loginFrom(users, "10.0.0.1", "alice", "wrong")
+	}
+	assert.Equal(t, time.Minute, users.Limiter.locked(req, "alice"))
+	for _, lockout := range []time.Duration{2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		clock.now = clock.now.Add(users.Limiter.locked(req, "alice"))
+		assert.Equal(t, http.StatusUnauthorized, loginFrom(users, "10.0.0.1", "alice", "wrong").Code)
+		assert.Equal(t, lockout, users.Limiter.locked(req, "alice"))
+	}
+}
+
+func TestLoginLimiter_ipLockout(t *testing.T) {
+	users, clock := newLimitedUsers(t, limitConfig())
+
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5"} {
+		assert.Equal(t, http.StatusUnauthorized, loginFrom(users, "10.0.0.1", user, "wrong").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, loginFrom(users, "10.0.0.1", "bob", "bob-pw").Code)
+	assert.Equal(t, http.StatusOK, loginFrom(users, "10.0.0.2", "bob", "bob-pw").Code)
+
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, loginFrom(users, "10.0.0.1", "bob", "bob-pw").Code)
+}
+
+func TestLoginLimiter_realIP(t *testing.T) {
+	conf := limitConfig()
+	conf.TrustProxyHeaders = true
+	users, _ := newLimitedUsers(t, conf)
+
+	login := func(realIP, user string) int {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.Header.Set("X-Real-IP", realIP)
+		req.PostForm = url.Values{"user": {user}, "pass": {"wrong"}}
+		recorder := httptest.NewRecorder()
+		users.Authenticate(recorder, req)
+		return recorder.Code
+	}
+	for _, user := range []string{"u1", "u2", "u3", "u4", "u5"} {
+		assert.Equal(t, http.StatusUnauthorized, login("203.0.113.1", user))
+	}
+	assert.Equal(t, http.StatusTooManyRequests, login("203.0.113.1", "u6"))
+	assert.Equal(t, http.StatusUnauthorized, login("203.0.113.2", "u6"), "clients behind the proxy are counted separately")
+}
+
+func TestLoginLimiter_cleanup(t *testing.T) {
+	users, clock := newLimitedUsers(t, limitConfig())
+
+	assert.Equal(t, http.StatusUnauthorized, loginFrom(users, "10.0.0.1", "alice", "wrong").Code)
+	clock.now = clock.now.Add(4 * time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, loginFrom(users, "10.0.0.2", "bob", "wrong").Code)
+
+	assert.NotContains(t, users.Limiter.users, "alice")
+	assert.NotContains(t, users.Limiter.ips, "10.0.0.1")
+	assert.Contains(t, users.Limiter.users, "bob")
+}
+
+func TestLoginLimiter_audit(t *testing.T) {
+	users, _ := newLimitedUsers(t, limitConfig())
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := audit.Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+	users.Audit = log
+
+	for i := 0; i < 3; i++ {
+		loginFrom(users, "10.0.0.1", "alice", "wrong")
+	}
+	loginFrom(users, "10.0.0.2", "alice", "alice-pw")
+	loginFrom(users, "10.0.0.1", "bob", "bob-pw")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var entries []audit.Entry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var entry audit.Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	require.Len(t, entries, 5)
+	for _, entry := range entries[:3] {
+		assert.Equal(t, audit.Entry{Timestamp: entry.Timestamp, EventType: audit.LoginFailed, ActorUsername: "alice", SourceIP: "10.0.0.1", Reason: audit.ReasonInvalidCredentials, Outcome: audit.OutcomeFailure}, entry)
+	}
+	assert.Equal(t, audit.Entry{Timestamp: entries[3].Timestamp, EventType: audit.LoginFailed, ActorUsername: "alice", SourceIP: "10.0.0.2", Reason: audit.ReasonLockedOut, Outcome: audit.OutcomeFailure}, entries[3])
+	assert.Equal(t, audit.Login, entries[4].EventType)
+	assert.Equal(t, "bob", entries[4].ActorUsername)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/rs/zerolog/log"
+)
+
+// RenewSessions extends the session of every request by SessionIdleTimeout. The session is only saved again once a
+// quarter of the idle timeout has passed since the last renewal, so that not every request writes it.
+func (u *Users) RenewSessions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.renewSession(w, r)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (u *Users) renewSession(w http.ResponseWriter, r *http.Request) {
+	user := u.sessionUser(r)
+	if user == "" {
+		return
+	}
+	s := u.session(r)
+	// sessions of logins before the idle timeout was enabled expire with their cookie.
+	expires, ok := s.Values["expires"].(int64)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if time.Unix(0, expires).Sub(now) > u.SessionIdleTimeout-u.SessionIdleTimeout/4 {
+		return
+	}
+	if !u.slide(s, now) {
+		return
+	}
+	u.Cookie.apply(s.Options, r)
+	if err := u.store.Save(r, w, s); err != nil {
+		log.Warn().Err(err).Str("user", user).Msg("Could not renew session")
+	}
+}
+
+// slide moves the expiry of the session to the end of the idle timeout, but not past the session timeout since the
+// login. It returns false if the expiry didn't change, because the session timeout is reached.
+func (u *Users) slide(s *sessions.Session, now time.Time) bool {
+	expires := now.Add(u.SessionIdleTimeout)
+	if u.sessionTimeout > 0 {
+		created, _ := s.Values["created"].(int64)
+		if limit := time.Unix(0, created).Add(time.Duration(u.sessionTimeout) * time.Second); expires.After(limit) {
+			expires = limit
+		}
+	}
+	if current, ok := s.Values["expires"].(int64); ok && !expires.After(time.Unix(0, current)) {
+		return false
+	}
+	s.Values["expires"] = expires.UnixNano()
+	s.Options.MaxAge = int(math.Ceil(expires.Sub(now).Seconds()))
+	return true
+}
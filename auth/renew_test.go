@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renew(users *Users, cookie *http.Cookie) []*http.Cookie {
+	recorder := httptest.NewRecorder()
+	handler := users.RenewSessions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	handler.ServeHTTP(recorder, requestWith(cookie))
+	return recorder.Result().Cookies()
+}
+
+func TestRenewSessions(t *testing.T) {
+	for _, backend := range []string{"cookie", "file"} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			writeUsersFile(t, filepath.Join(dir, "users"), "alice")
+			users, err := ReadPasswordsFile(filepath.Join(dir, "users"), []byte("secret"), 5400)
+			require.NoError(t, err)
+			if backend == "file" {
+				sessions, err := OpenSessionFile(filepath.Join(dir, "sessions.json"))
+				require.NoError(t, err)
+				users.UseSessionBackend(sessions)
+			}
+			users.SessionIdleTimeout = time.Hour
+			users.Cookie.Path = "/"
+
+			cookie := login(t, users, "alice")
+			assert.InDelta(t, 3600, cookie.MaxAge, 1)
+			assert.Empty(t, renew(users, cookie), "the session was just created")
+
+			// the session is renewed once a quarter of the idle timeout has passed.
+			users.SessionIdleTimeout = 2 * time.Hour
+			cookies := renew(users, cookie)
+			require.Len(t, cookies, 1)
+			assert.InDelta(t, 5400, cookies[0].MaxAge, 1, "the session timeout is the maximum")
+			assert.Equal(t, "/", cookies[0].Path)
+			renewed := cookies[0]
+			user, loggedIn := users.CurrentUser(requestWith(renewed))
+			assert.True(t, loggedIn)
+			assert.Equal(t, "alice", user)
+
+			assert.Empty(t, renew(users, renewed), "the session timeout is reached")
+		})
+	}
+}
+
+func TestRenewSessions_expired(t *testing.T) {
+	dir := t.TempDir()
+	writeUsersFile(t, filepath.Join(dir, "users"), "alice")
+	users, err := ReadPasswordsFile(filepath.Join(dir, "users"), []byte("secret"), 60)
+	require.NoError(t, err)
+	users.SessionIdleTimeout = time.Millisecond
+
+	cookie := login(t, users, "alice")
+	time.Sleep(5 * time.Millisecond)
+	_, loggedIn := users.CurrentUser(requestWith(cookie))
+	assert.False(t, loggedIn, "the cookie is rejected after the idle timeout")
+	assert.Empty(t, renew(users, cookie))
+}
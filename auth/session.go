@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/rs/zerolog/log"
+)
+
+// sessionLifetime is the server side expiry of sessions without timeout, the cookie store rejects them after the
+// same time.
+const sessionLifetime = 30 * 24 * time.Hour
+
+// sessionCleanupInterval is how often expired sessions are removed from the backend.
+const sessionCleanupInterval = time.Hour
+
+// StoredSession is a login session of a SessionBackend.
+type StoredSession struct {
+	// Key is the hash of the cookie, so that the backend doesn't contain usable cookies.
+	Key      string    `json:"key"`
+	ID       string    `json:"id"`
+	User     string    `json:"user"`
+	Provider string    `json:"provider"`
+	Role     string    `json:"role"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+}
+
+// SessionBackend persists the login sessions, so that they survive restarts. FileSessions is the only backend for now,
+// a database like Redis can implement the same interface.
+type SessionBackend interface {
+	// Get returns the session with the key, expired sessions may still be returned.
+	Get(key string) (StoredSession, bool, error)
+	// Put creates or replaces the session.
+	Put(session StoredSession) error
+	// Delete removes the session, it doesn't fail if the session doesn't exist.
+	Delete(key string) error
+	// DeleteExpired removes all sessions that expired before now.
+	DeleteExpired(now time.Time) error
+	// DeleteUser removes all sessions of the user.
+	DeleteUser(user string) error
+	// ListUser returns the sessions of the user ordered by creation, expired sessions may still be returned.
+	ListUser(user string) ([]StoredSession, error)
+}
+
+// FileSessions keeps the sessions in memory and writes them to a json file on every change.
+type FileSessions struct {
+	path string
+
+	lock     sync.Mutex
+	sessions map[string]StoredSession
+}
+
+// OpenSessionFile loads the sessions of the file, a file that doesn't exist yet is created on the first login.
+func OpenSessionFile(path string) (*FileSessions, error) {
+	f := &FileSessions{path: path, sessions: map[string]StoredSession{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []StoredSession
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		f.sessions[session.Key] = session
+	}
+	return f, nil
+}
+
+func (f *FileSessions) Get(key string) (StoredSession, bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	session, ok := f.sessions[key]
+	return session, ok, nil
+}
+
+func (f *FileSessions) Put(session StoredSession) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.sessions[session.Key] = session
+	return f.write()
+}
+
+func (f *FileSessions) Delete(key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if _, ok := f.sessions[key]; !ok {
+		return nil
+	}
+	delete(f.sessions, key)
+	return f.write()
+}
+
+func (f *FileSessions) DeleteExpired(now time.Time) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	expired := 0
+	for key, session := range f.sessions {
+		if !now.Before(session.Expires) {
+			delete(f.sessions, key)
+			expired++
+		}
+	}
+	if expired == 0 {
+		return nil
+	}
+	log.Debug().Int("amount", expired).Msg("Removed expired sessions")
+	return f.write()
+}
+
+func (f *FileSessions) DeleteUser(user string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	deleted := 0
+	for key, session := range f.sessions {
+		if session.User == user {
+			delete(f.sessions, key)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return nil
+	}
+	return f.write()
+}
+
+func (f *FileSessions) ListUser(user string) ([]StoredSession, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	sessions := []StoredSession{}
+	for _, session := range f.sessions {
+		if session.User == user {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created.Before(sessions[j].Created)
+	})
+	return sessions, nil
+}
+
+// write replaces the file atomically, so that a crash doesn't leave a partial file.
+func (f *FileSessions) write() error {
+	sessions := make([]StoredSession, 0, len(f.sessions))
+	for _, session := range f.sessions {
+		sessions = append(sessions, session)
+	}
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".sessions-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// persistentStore stores the user session in the backend, the cookie only contains a random token. Other sessions,
+// like the short lived OIDC login, stay in the fallback store.
+type persistentStore struct {
+	backend  SessionBackend
+	fallback sessions.Store
+	now      func() time.Time
+}
+
+func (s *persistentStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	if name == oidcSession {
+		return s.fallback.Get(r, name)
+	}
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *persistentStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	if name == oidcSession {
+		return s.fallback.New(r, name)
+	}
+	session := sessions.NewSession(s, name)
+	session.IsNew = true
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	stored, ok, err := s.backend.Get(sessionKey(cookie.Value))
+	if err != nil || !ok || !s.now().Before(stored.Expires) {
+		return session, err
+	}
+	session.ID = cookie.Value
+	session.IsNew = false
+	session.Values["user"] = stored.User
+	session.Values["provider"] = stored.Provider
+	session.Values["role"] = stored.Role
+	session.Values["sid"] = stored.ID
+	session.Values["created"] = stored.Created.UnixNano()
+	session.Values["expires"] = stored.Expires.UnixNano()
+	return session, nil
+}
+
+// Save stores sessions with a user under a new token and deletes the previous session of the request, so that a
+// login always gets a new cookie. Sessions without user, like the one of the logout, only delete the session.
+func (s *persistentStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Name() == oidcSession {
+		return s.fallback.Save(r, w, session)
+	}
+	if cookie, err := r.Cookie(session.Name()); err == nil && cookie.Value != session.ID {
+		if err := s.backend.Delete(sessionKey(cookie.Value)); err != nil {
+			return err
+		}
+	}
+
+	user, _ := session.Values["user"].(string)
+	if user == "" || session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.backend.Delete(sessionKey(session.ID)); err != nil {
+				return err
+			}
+		}
+		options := *session.Options
+		options.MaxAge = -1
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", &options))
+		return nil
+	}
+
+	if session.ID == "" {
+		token := make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			return err
+		}
+		session.ID = base64.RawURLEncoding.EncodeToString(token)
+	}
+	now := s.now()
+	lifetime := sessionLifetime
+	if session.Options.MaxAge > 0 {
+		lifetime = time.Duration(session.Options.MaxAge) * time.Second
+	}
+	stored := StoredSession{Key: sessionKey(session.ID), User: user, Created: now, Expires: now.Add(lifetime)}
+	// renewed sessions keep their creation time, the expiry of the idle timeout is set by the caller.
+	if created, ok := session.Values["created"].(int64); ok {
+		stored.Created = time.Unix(0, created)
+	}
+	if expires, ok := session.Values["expires"].(int64); ok {
+		stored.Expires = time.Unix(0, expires)
+	}
+	stored.ID, _ = session.Values["sid"].(string)
+	stored.Provider, _ = session.Values["provider"].(string)
+	stored.Role, _ = session.Values["role"].(string)
+	if err := s.backend.Put(stored); err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+	return nil
+}
+
+func sessionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// UseSessionBackend stores the login sessions in the backend instead of the cookie, so that they survive restarts
+// even without SCREEGO_SECRET. Expired sessions are removed now and then every hour.
+func (u *Users) UseSessionBackend(backend SessionBackend) {
+	store := &persistentStore{backend: backend, fallback: u.store, now: time.Now}
+	u.store = store
+	if err := backend.DeleteExpired(store.now()); err != nil {
+		log.Error().Err(err).Msg("Could not remove expired sessions")
+	}
+	go func() {
+		for range time.Tick(sessionCleanupInterval) {
+			if err := backend.DeleteExpired(store.now()); err != nil {
+				log.Error().Err(err).Msg("Could not remove expired sessions")
+			}
+		}
+	}()
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/screego/server/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSessionUsers(t *testing.T, dir string, secret string) *Users {
+	t.Helper()
+	writeUsersFile(t, filepath.Join(dir, "users"), "alice:admin", "bob")
+	users, err := ReadPasswordsFile(filepath.Join(dir, "users"), []byte(secret), 0)
+	require.NoError(t, err)
+	sessions, err := OpenSessionFile(filepath.Join(dir, "sessions.json"))
+	require.NoError(t, err)
+	users.UseSessionBackend(sessions)
+	return users
+}
+
+func requestWith(cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest("GET", "/config", nil)
+	req.AddCookie(cookie)
+	return req
+}
+
+func TestSessionFile_restart(t *testing.T) {
+	dir := t.TempDir()
+	users := newSessionUsers(t, dir, "secret")
+	cookie := login(t, users, "alice")
+	sid := users.SessionID(requestWith(cookie))
+	assert.NotEmpty(t, sid)
+
+	restarted := newSessionUsers(t, dir, "other secret")
+	user, loggedIn := restarted.CurrentUser(requestWith(cookie))
+	assert.True(t, loggedIn)
+	assert.Equal(t, "alice", user)
+	assert.Equal(t, sid, restarted.SessionID(requestWith(cookie)))
+
+	data, err := os.ReadFile(filepath.Join(dir, "sessions.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), cookie.Value, "only the hash of the cookie is stored")
+	assert.Contains(t, string(data), sid)
+}
+
+func TestSessionFile_logout(t *testing.T) {
+	dir := t.TempDir()
+	users := newSessionUsers(t, dir, "secret")
+	cookie := login(t, users, "bob")
+
+	recorder := httptest.NewRecorder()
+	users.Logout(recorder, requestWith(cookie))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Less(t, cookies[0].MaxAge, 0)
+
+	_, loggedIn := users.CurrentUser(requestWith(cookie))
+	assert.False(t, loggedIn, "the old cookie is invalid after the logout")
+	_, loggedIn = newSessionUsers(t, dir, "secret").CurrentUser(requestWith(cookie))
+	assert.False(t, loggedIn)
+}
+
+func TestSessionFile_newLoginReplacesSession(t *testing.T) {
+	users := newSessionUsers(t, t.TempDir(), "secret")
+	first := login(t, users, "bob")
+
+	req := httptest.NewRequest("POST", "/login", nil)
+	req.AddCookie(first)
+	req.PostForm = map[string][]string{"user": {"alice"}, "pass": {"alice-pw"}}
+	recorder := httptest.NewRecorder()
+	users.Authenticate(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	second := recorder.Result().Cookies()[0]
+
+	assert.NotEqual(t, first.Value, second.Value)
+	_, loggedIn := users.CurrentUser(requestWith(first))
+	assert.False(t, loggedIn)
+	user, _ := users.CurrentUser(requestWith(second))
+	assert.Equal(t, "alice", user)
+	assert.Equal(t, RoleAdmin, users.CurrentRole(requestWith(second)))
+}
+
+func TestSessionFile_expiry(t *testing.T) {
+	dir := t.TempDir()
+	users := newSessionUsers(t, dir, "secret")
+	users.sessionTimeout = 60
+	store := users.store.(*persistentStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	cookie := login(t, users, "alice")
+	assert.Equal(t, 60, cookie.MaxAge)
+
+	now = now.Add(time.Minute)
+	_, loggedIn := users.CurrentUser(requestWith(cookie))
+	assert.False(t, loggedIn)
+
+	sessions := store.backend.(*FileSessions)
+	assert.Len(t, sessions.sessions, 1)
+	require.NoError(t, sessions.DeleteExpired(now))
+	assert.Empty(t, sessions.sessions)
+	reopened, err := OpenSessionFile(filepath.Join(dir, "sessions.json"))
+	require.NoError(t, err)
+	assert.Empty(t, reopened.sessions)
+}
+
+func TestSessionFile_logoutAll(t *testing.T) {
+	dir := t.TempDir()
+	users := newSessionUsers(t, dir, "secret")
+	first := login(t, users, "bob")
+	second := login(t, users, "bob")
+	alice := login(t, users, "alice")
+
+	require.NoError(t, users.LogoutAll("bob"))
+	for _, cookie := range []*http.Cookie{first, second} {
+		_, loggedIn := users.CurrentUser(requestWith(cookie))
+		assert.False(t, loggedIn)
+	}
+	_, loggedIn := users.CurrentUser(requestWith(alice))
+	assert.True(t, loggedIn)
+
+	restarted := newSessionUsers(t, dir, "secret")
+	_, loggedIn = restarted.CurrentUser(requestWith(first))
+	assert.False(t, loggedIn, "the revoked sessions are deleted from the file")
+	_, loggedIn = restarted.CurrentUser(requestWith(alice))
+	assert.True(t, loggedIn)
+}
+
+func TestStoreSessions(t *testing.T) {
+	shared := store.NewMemoryStore()
+	newInstance := func() *Users {
+		dir := t.TempDir()
+		writeUsersFile(t, filepath.Join(dir, "users"), "alice", "bob")
+		users, err := ReadPasswordsFile(filepath.Join(dir, "users"), []byte(dir), 0)
+		require.NoError(t, err)
+		users.UseSessionBackend(StoreSessions{Store: shared})
+		return users
+	}
+	first, second := newInstance(), newInstance()
+
+	alice := login(t, first, "alice")
+	bob := login(t, first, "bob")
+	user, loggedIn := second.CurrentUser(requestWith(alice))
+	assert.True(t, loggedIn, "the other instance accepts the session")
+	assert.Equal(t, "alice", user)
+
+	require.NoError(t, second.LogoutAll("alice"))
+	_, loggedIn = first.CurrentUser(requestWith(alice))
+	assert.False(t, loggedIn)
+	_, loggedIn = first.CurrentUser(requestWith(bob))
+	assert.True(t, loggedIn)
+
+	backend := StoreSessions{Store: shared}
+	require.NoError(t, backend.DeleteExpired(time.Now().Add(sessionLifetime)))
+	sessions, err := shared.ListSessions()
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/screego/server/store"
+)
+
+// StoreSessions keeps the sessions in a store.Store, e.g. in Redis, so that all instances of a deployment accept the
+// same cookies.
+type StoreSessions struct {
+	Store store.Store
+}
+
+func (s StoreSessions) Get(key string) (StoredSession, bool, error) {
+	session, err := s.Store.GetSession(key)
+	if errors.Is(err, store.ErrNotFound) {
+		return StoredSession{}, false, nil
+	}
+	if err != nil {
+		return StoredSession{}, false, err
+	}
+	return StoredSession(session), true, nil
+}
+
+func (s StoreSessions) Put(session StoredSession) error {
+	return s.Store.SaveSession(store.Session(session))
+}
+
+func (s StoreSessions) Delete(key string) error {
+	return s.Store.DeleteSession(key)
+}
+
+// DeleteExpired removes the expired sessions, stores with their own expiry like Redis have none left.
+func (s StoreSessions) DeleteExpired(now time.Time) error {
+	return s.deleteWhere(func(session store.Session) bool {
+		return !now.Before(session.Expires)
+	})
+}
+
+func (s StoreSessions) DeleteUser(user string) error {
+	return s.deleteWhere(func(session store.Session) bool {
+		return session.User == user
+	})
+}
+
+func (s StoreSessions) ListUser(user string) ([]StoredSession, error) {
+	sessions, err := s.Store.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	result := []StoredSession{}
+	for _, session := range sessions {
+		if session.User == user {
+			result = append(result, StoredSession(session))
+		}
+	}
+	return result, nil
+}
+
+func (s StoreSessions) deleteWhere(matches func(store.Session) bool) error {
+	sessions, err := s.Store.ListSessions()
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if matches(session) {
+			if err := s.Store.DeleteSession(session.Key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
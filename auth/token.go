@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Roles of API tokens.
+const (
+	// TokenRoleAdmin may use all admin endpoints.
+	TokenRoleAdmin = "admin"
+	// TokenRoleReadOnly may only use the GET admin endpoints, e.g. for monitoring.
+	TokenRoleReadOnly = "readonly"
+)
+
+// tokenHashPrefix marks the sha256 hash of a token, other values are the token itself.
+const tokenHashPrefix = "sha256-"
+
+// minTokenLength of tokens that aren't hashed, tokens are only hashed with sha256 and must not be guessable.
+const minTokenLength = 16
+
+// Token is an API token for the admin endpoints.
+type Token struct {
+	Name string
+	Role string
+	// Expires is zero if the token doesn't expire.
+	Expires time.Time
+
+	hash []byte
+}
+
+// Expired returns whether the token expired at the time.
+func (t Token) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+// Allows returns whether the role of the token may use the http method.
+func (t Token) Allows(method string) bool {
+	return t.Role == TokenRoleAdmin || method == "GET" || method == "HEAD"
+}
+
+// Tokens are the API tokens of the tokens file and the config.
+type Tokens struct {
+	tokens []Token
+}
+
+// ReadTokens reads the tokens of the file and the entries, both have the format name:token:role[:expiry] where the
+// token is either the token or its hash from HashToken and the expiry a date like 2024-12-31.
+func ReadTokens(path string, entries []string) (*Tokens, error) {
+	t := &Tokens{}
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		if err := t.read(file, path); err != nil {
+			return nil, err
+		}
+	}
+	for i, entry := range entries {
+		if err := t.read(strings.NewReader(entry), fmt.Sprintf("SCREEGO_API_TOKENS entry %d", i+1)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (t *Tokens) read(r io.Reader, source string) error {
+	reader := csv.NewReader(r)
+	reader.Comma = ':'
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		line, _ := reader.FieldPos(0)
+		token, err := parseToken(record)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", source, line, err)
+		}
+		for _, other := range t.tokens {
+			if other.Name == token.Name {
+				return fmt.Errorf("%s line %d: duplicate token name %q", source, line, token.Name)
+			}
+		}
+		t.tokens = append(t.tokens, token)
+	}
+}
+
+func parseToken(record []string) (Token, error) {
+	if len(record) != 3 && len(record) != 4 {
+		return Token{}, fmt.Errorf("malformed token, expected name:token:role[:expiry]")
+	}
+	token := Token{Name: record[0], Role: record[2]}
+	if token.Name == "" {
+		return Token{}, fmt.Errorf("token name must not be empty")
+	}
+	if token.Role != TokenRoleAdmin && token.Role != TokenRoleReadOnly {
+		return Token{}, fmt.Errorf("invalid role %q of token %s, it must be admin or readonly", token.Role, token.Name)
+	}
+
+	if strings.HasPrefix(record[1], tokenHashPrefix) {
+		hash, err := hex.DecodeString(strings.TrimPrefix(record[1], tokenHashPrefix))
+		if err != nil || len(hash) != sha256.Size {
+			return Token{}, fmt.Errorf("invalid hash of token %s", token.Name)
+		}
+		token.hash = hash
+	} else if len(record[1]) < minTokenLength {
+		return Token{}, fmt.Errorf("token %s must have at least %d characters", token.Name, minTokenLength)
+	} else {
+		sum := sha256.Sum256([]byte(record[1]))
+		token.hash = sum[:]
+	}
+
+	if len(record) == 4 && record[3] != "" {
+		expires, err := time.Parse("2006-01-02", record[3])
+		if err != nil {
+			return Token{}, fmt.Errorf("invalid expiry of token %s, expected a date like 2024-12-31", token.Name)
+		}
+		token.Expires = expires
+	}
+	return token, nil
+}
+
+// Lookup returns the token with the value, expired tokens are also returned. All tokens are compared in constant time,
+// so that the timing doesn't reveal the tokens.
+func (t *Tokens) Lookup(value string) (Token, bool) {
+	if t == nil {
+		return Token{}, false
+	}
+	sum := sha256.Sum256([]byte(value))
+	found := -1
+	for i, token := range t.tokens {
+		if subtle.ConstantTimeCompare(sum[:], token.hash) == 1 {
+			found = i
+		}
+	}
+	if found == -1 {
+		return Token{}, false
+	}
+	return t.tokens[found], true
+}
+
+// List returns all tokens.
+func (t *Tokens) List() []Token {
+	if t == nil {
+		return nil
+	}
+	return append([]Token{}, t.tokens...)
+}
+
+// GenerateToken returns a new random token.
+func GenerateToken() (string, error) {
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// HashToken returns the hash of the token for the tokens file.
+func HashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return tokenHashPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTokens(t *testing.T) {
+	path := t.TempDir() + "/tokens"
+	require.NoError(t, os.WriteFile(path, []byte("# monitoring\nprometheus:"+HashToken("prometheus-token-0123")+":readonly:2030-01-01\n"), 0o600))
+	tokens, err := ReadTokens(path, []string{"chatops:chatops-token-0123:admin"})
+	require.NoError(t, err)
+
+	token, ok := tokens.Lookup("prometheus-token-0123")
+	require.True(t, ok)
+	assert.Equal(t, "prometheus", token.Name)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), token.Expires)
+	assert.False(t, token.Allows("POST"))
+	assert.True(t, token.Allows("GET"))
+
+	token, ok = tokens.Lookup("chatops-token-0123")
+	require.True(t, ok)
+	assert.Equal(t, "chatops", token.Name)
+	assert.True(t, token.Expires.IsZero())
+	assert.True(t, token.Allows("POST"))
+
+	_, ok = tokens.Lookup("other")
+	assert.False(t, ok)
+	_, ok = (*Tokens)(nil).Lookup("chatops-token-0123")
+	assert.False(t, ok)
+	assert.Len(t, tokens.List(), 2)
+}
+
+func TestReadTokens_invalid(t *testing.T) {
+	for _, entry := range []string{
+		"name:token-0123456789",
+		":token-0123456789:admin",
+		"name:token-0123456789:root",
+		"name:short:admin",
+		"name:sha256-abc:admin",
+		"name:token-0123456789:admin:tomorrow",
+	} {
+		_, err := ReadTokens("", []string{entry})
+		assert.Error(t, err, entry)
+	}
+	_, err := ReadTokens("", []string{"name:token-0123456789:admin", "name:token-9876543210:admin"})
+	assert.EqualError(t, err, `SCREEGO_API_TOKENS entry 2 line 1: duplicate token name "name"`)
+}
+
+func TestToken_expired(t *testing.T) {
+	token := Token{Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.False(t, token.Expired(token.Expires.Add(-time.Second)))
+	assert.True(t, token.Expired(token.Expires))
+	assert.False(t, Token{}.Expired(time.Now()))
+}
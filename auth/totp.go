@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// totpPeriod is the lifetime of a code in seconds.
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is how many periods before and after the current one are accepted, for clocks that are a bit off.
+	totpSkew = 1
+)
+
+// totpNow is replaced in tests.
+var totpNow = time.Now
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random base32 secret for the users file.
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPURI returns the otpauth:// uri for authenticator apps, it is usually shown as QR code.
+func TOTPURI(issuer, user, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(user)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// decodeTOTPSecret accepts the secret in upper or lower case, with spaces and with padding like authenticator apps
+// show it.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.ReplaceAll(secret, " ", ""), "="))
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("invalid TOTP secret, it must be base32")
+	}
+	return key, nil
+}
+
+// totpCode is the code of the period according to RFC 6238 with HMAC-SHA1.
+func totpCode(key []byte, period int64) string {
+	mac := hmac.New(sha1.New, key)
+	_ = binary.Write(mac, binary.BigEndian, period)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// verifyTOTP checks the code of the user. A code is only accepted once, codes of the same or an earlier period than
+// the last accepted one are rejected, so that an observed code cannot be replayed.
+func (u *Users) verifyTOTP(user, secret, code string) bool {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return false
+	}
+	current := totpNow().Unix() / totpPeriod
+	for period := current - totpSkew; period <= current+totpSkew; period++ {
+		if subtle.ConstantTimeCompare([]byte(code), []byte(totpCode(key, period))) != 1 {
+			continue
+		}
+		u.lock.Lock()
+		defer u.lock.Unlock()
+		if period <= u.totpUsed[user] {
+			return false
+		}
+		if u.totpUsed == nil {
+			u.totpUsed = map[string]int64{}
+		}
+		u.totpUsed[user] = period
+		return true
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"encoding/base32"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the secret of the SHA1 test vectors of RFC 6238.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode_rfc6238(t *testing.T) {
+	key, err := decodeTOTPSecret(strings.ToLower(rfcSecret))
+	require.NoError(t, err)
+	// the RFC has 8 digit codes, 6 digit codes are their last digits.
+	for unix, code := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		assert.Equal(t, code, totpCode(key, unix/totpPeriod), unix)
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	key, err := decodeTOTPSecret(secret)
+	require.NoError(t, err)
+	assert.Len(t, key, 20)
+
+	uri, err := url.Parse(TOTPURI("Screego", "alice", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Screego:alice", uri.Path)
+	assert.Equal(t, secret, uri.Query().Get("secret"))
+	assert.Equal(t, "Screego", uri.Query().Get("issuer"))
+}
+
+func setTOTPTime(t *testing.T, unix int64) {
+	old := totpNow
+	t.Cleanup(func() { totpNow = old })
+	totpNow = func() time.Time { return time.Unix(unix, 0) }
+}
+
+func loginWithCode(users *Users, user, code string) (*httptest.ResponseRecorder, Response) {
+	form := url.Values{"user": {user}, "pass": {user + "-pw"}}
+	if code != "" {
+		form.Set("code", code)
+	}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	users.Authenticate(recorder, req)
+	var response Response
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder, response
+}
+
+func TestAuthenticate_totp(t *testing.T) {
+	setTOTPTime(t, 1111111109)
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice:admin::"+rfcSecret, "bob")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+
+	recorder, response := loginWithCode(users, "alice", "")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.True(t, response.TOTPRequired, "the password was correct, the code is missing")
+	assert.Empty(t, recorder.Result().Cookies())
+
+	recorder, response = loginWithCode(users, "alice", "123456")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.Equal(t, "invalid code", response.Message)
+
+	recorder, _ = loginWithCode(users, "alice", "081804")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Len(t, recorder.Result().Cookies(), 1)
+
+	recorder, _ = loginWithCode(users, "alice", "081804")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "a code can only be used once")
+
+	recorder, _ = loginWithCode(users, "bob", "")
+	assert.Equal(t, http.StatusOK, recorder.Code, "users without secret only need the password")
+
+	assert.False(t, users.Validate("alice", "alice-pw"), "basic auth has no second factor")
+}
+
+func TestAuthenticate_totpSkew(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice:::"+rfcSecret)
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+
+	// 081804 is the code of the period of 1111111109.
+	setTOTPTime(t, 1111111109+2*totpPeriod)
+	recorder, _ := loginWithCode(users, "alice", "081804")
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "two periods later")
+
+	setTOTPTime(t, 1111111109-totpPeriod)
+	recorder, _ = loginWithCode(users, "alice", "081804")
+	assert.Equal(t, http.StatusOK, recorder.Code, "one period of tolerance")
+}
+
+func TestAuthenticate_totpRequiredForAdmins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice:admin", "bob")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	users.RequireTOTPForAdmins = true
+
+	recorder, _ := loginWithCode(users, "alice", "")
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.False(t, users.Validate("alice", "alice-pw"))
+
+	recorder, _ = loginWithCode(users, "bob", "")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestReadPasswordsFile_invalidTOTPSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	writeUsersFile(t, path, "alice:::not base32!", "bob")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	assert.False(t, users.validateFile("alice", "alice-pw"), "the user is skipped")
+	assert.True(t, users.validateFile("bob", "bob-pw"))
+}
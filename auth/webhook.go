@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/config"
+)
+
+// maxWebhookResponse is the maximum size of a response body of the auth webhook.
+const maxWebhookResponse = 64 << 10
+
+var (
+	// ErrWebhookUnavailable is returned if the auth webhook cannot be reached, fails or answers with garbage. Like
+	// ErrDirectoryUnavailable it is distinct from wrong credentials.
+	ErrWebhookUnavailable = errors.New("the auth webhook is unavailable")
+	// ErrWebhookCircuitOpen is returned without calling the webhook after it failed too often in a row.
+	ErrWebhookCircuitOpen = errors.New("the auth webhook failed too often, logins are paused")
+)
+
+// AuthWebhook checks passwords by posting them to an external service. The service answers 200 with
+// {"allow": true, "role": "admin"} to accept the login, every other answer denies it.
+type AuthWebhook struct {
+	conf   config.Config
+	client *http.Client
+	now    func() time.Time
+
+	lock sync.Mutex
+	// failures are the failed calls in a row, the circuit opens at SCREEGO_AUTH_WEBHOOK_FAILURE_THRESHOLD.
+	failures  int
+	openUntil time.Time
+}
+
+type webhookRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type webhookResponse struct {
+	Allow bool   `json:"allow"`
+	Role  string `json:"role"`
+}
+
+// NewAuthWebhook returns the webhook backend, it doesn't call the webhook.
+func NewAuthWebhook(conf config.Config) *AuthWebhook {
+	return &AuthWebhook{
+		conf: conf,
+		client: &http.Client{
+			Timeout: conf.AuthWebhookTimeout,
+			// a redirect could send the password to another host or over http.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Authenticate returns whether the webhook accepts the password of the user and whether the user is an admin. Failed
+// calls aren't retried, the error wraps ErrWebhookUnavailable or is ErrWebhookCircuitOpen.
+func (h *AuthWebhook) Authenticate(user, password string) (ok, admin bool, err error) {
+	if user == "" || password == "" {
+		return false, false, nil
+	}
+	if h.open() {
+		return false, false, ErrWebhookCircuitOpen
+	}
+
+	ok, admin, err = h.call(user, password)
+	h.record(err)
+	return ok, admin, err
+}
+
+func (h *AuthWebhook) call(user, password string) (ok, admin bool, err error) {
+	body, err := json.Marshal(webhookRequest{Username: user, Password: password})
+	if err != nil {
+		return false, false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.conf.AuthWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return false, false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Screego-Secret", h.conf.AuthWebhookSecret)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return false, false, fmt.Errorf("%w: %s", ErrWebhookUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return false, false, fmt.Errorf("%w: status %d", ErrWebhookUnavailable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, false, nil
+	}
+
+	var result webhookResponse
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse+1))
+	if err != nil {
+		return false, false, fmt.Errorf("%w: %s", ErrWebhookUnavailable, err)
+	}
+	if len(content) > maxWebhookResponse {
+		return false, false, fmt.Errorf("%w: the response is bigger than %d bytes", ErrWebhookUnavailable, maxWebhookResponse)
+	}
+	if err := json.Unmarshal(content, &result); err != nil {
+		return false, false, fmt.Errorf("%w: malformed response: %s", ErrWebhookUnavailable, err)
+	}
+	if !result.Allow {
+		return false, false, nil
+	}
+	switch result.Role {
+	case "", RoleUser:
+		return true, false, nil
+	case RoleAdmin:
+		return true, true, nil
+	default:
+		log.Warn().Str("user", user).Str("role", result.Role).Msg("Unknown role of the auth webhook, expected user or admin. Denying login")
+		return false, false, nil
+	}
+}
+
+// open returns whether the circuit is open, the first call after the cooldown is let through.
+func (h *AuthWebhook) open() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.now().Before(h.openUntil)
+}
+
+func (h *AuthWebhook) record(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if err == nil {
+		h.failures = 0
+		return
+	}
+	h.failures++
+	if h.conf.AuthWebhookFailureThreshold > 0 && h.failures >= h.conf.AuthWebhookFailureThreshold {
+		h.openUntil = h.now().Add(h.conf.AuthWebhookCooldown)
+		// the first failure after the cooldown opens the circuit again.
+		h.failures = h.conf.AuthWebhookFailureThreshold - 1
+		log.Warn().Err(err).Dur("cooldown", h.conf.AuthWebhookCooldown).Msg("Auth webhook failed too often, denying logins")
+	}
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeWebhook accepts alice as admin and bob as user with the password name+"-pw".
+func newFakeWebhook(t *testing.T) (*httptest.Server, *AuthWebhook) {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Screego-Secret") != "webhook-secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var body webhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch {
+		case body.Username == "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case body.Username == "garbage":
+			_, _ = w.Write([]byte("<html>"))
+		case body.Username == "huge":
+			_, _ = w.Write([]byte(`{"allow":true,"role":"` + strings.Repeat("x", maxWebhookResponse) + `"}`))
+		case body.Username == "redirect":
+			http.Redirect(w, r, "http://example.org/login", http.StatusTemporaryRedirect)
+		case body.Password != body.Username+"-pw":
+			_ = json.NewEncoder(w).Encode(webhookResponse{Allow: false})
+		case body.Username == "alice":
+			_ = json.NewEncoder(w).Encode(webhookResponse{Allow: true, Role: RoleAdmin})
+		case body.Username == "mallory":
+			_ = json.NewEncoder(w).Encode(webhookResponse{Allow: true, Role: "root"})
+		case body.Username == "bob":
+			_ = json.NewEncoder(w).Encode(webhookResponse{Allow: true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	hook := NewAuthWebhook(config.Config{AuthWebhookURL: server.URL, AuthWebhookSecret: "webhook-secret",
+		AuthWebhookTimeout: time.Second, AuthWebhookFailureThreshold: 2, AuthWebhookCooldown: time.Minute})
+	hook.client.Transport = server.Client().Transport
+	return server, hook
+}
+
+func TestAuthWebhook_authenticate(t *testing.T) {
+	_, hook := newFakeWebhook(t)
+
+	for _, test := range []struct {
+		user, password string
+		ok, admin      bool
+		unavailable    bool
+	}{
+		{user: "alice", password: "alice-pw", ok: true, admin: true},
+		{user: "bob", password: "bob-pw", ok: true},
+		{user: "bob", password: "wrong"},
+		{user: "bob"},
+		{user: "mallory", password: "mallory-pw"},
+		{user: "redirect", password: "redirect-pw"},
+		{user: "broken", password: "broken-pw", unavailable: true},
+		{user: "garbage", password: "garbage-pw", unavailable: true},
+		{user: "huge", password: "huge-pw", unavailable: true},
+	} {
+		hook.failures = 0
+		ok, admin, err := hook.Authenticate(test.user, test.password)
+		assert.Equal(t, test.ok, ok, test.user)
+		assert.Equal(t, test.admin, admin, test.user)
+		if test.unavailable {
+			assert.ErrorIs(t, err, ErrWebhookUnavailable, test.user)
+			assert.NotContains(t, err.Error(), test.password, "the password isn't in the error")
+		} else {
+			assert.NoError(t, err, test.user)
+		}
+	}
+
+	hook.conf.AuthWebhookSecret = "wrong"
+	ok, _, err := hook.Authenticate("alice", "alice-pw")
+	assert.NoError(t, err)
+	assert.False(t, ok, "the webhook rejects requests without the secret")
+}
+
+func TestAuthWebhook_circuitBreaker(t *testing.T) {
+	server, hook := newFakeWebhook(t)
+	now := time.Now()
+	hook.now = func() time.Time { return now }
+
+	_, _, err := hook.Authenticate("broken", "broken-pw")
+	assert.ErrorIs(t, err, ErrWebhookUnavailable)
+	_, _, err = hook.Authenticate("bob", "bob-pw")
+	assert.NoError(t, err, "a success resets the failures")
+	_, _, err = hook.Authenticate("broken", "broken-pw")
+	assert.ErrorIs(t, err, ErrWebhookUnavailable)
+	_, _, err = hook.Authenticate("broken", "broken-pw")
+	assert.ErrorIs(t, err, ErrWebhookUnavailable)
+
+	_, _, err = hook.Authenticate("bob", "bob-pw")
+	assert.ErrorIs(t, err, ErrWebhookCircuitOpen, "the webhook isn't called during the cooldown")
+
+	now = now.Add(time.Minute)
+	server.Close()
+	_, _, err = hook.Authenticate("bob", "bob-pw")
+	assert.ErrorIs(t, err, ErrWebhookUnavailable, "the first call after the cooldown is let through")
+	_, _, err = hook.Authenticate("bob", "bob-pw")
+	assert.ErrorIs(t, err, ErrWebhookCircuitOpen, "one more failure opens the circuit again")
+}
+
+func TestAuthenticate_webhook(t *testing.T) {
+	_, hook := newFakeWebhook(t)
+	path := t.TempDir() + "/users"
+	writeUsersFile(t, path, "root:admin")
+	users, err := ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	users.Webhook = hook
+
+	assert.Equal(t, http.StatusOK, ldapLogin(users, "bob", "bob-pw").Code)
+	assert.Equal(t, http.StatusUnauthorized, ldapLogin(users, "bob", "wrong").Code)
+	assert.Equal(t, http.StatusUnauthorized, ldapLogin(users, "root", "root-pw").Code, "the users file isn't used for logins")
+
+	assert.True(t, users.Validate("alice", "alice-pw"), "alice has the admin role")
+	assert.False(t, users.Validate("bob", "bob-pw"))
+	assert.True(t, users.Validate("root", "root-pw"), "the users file is still used for basic auth")
+
+	assert.Equal(t, http.StatusBadGateway, ldapLogin(users, "broken", "broken-pw").Code)
+	assert.Equal(t, http.StatusBadGateway, ldapLogin(users, "broken", "broken-pw").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, ldapLogin(users, "bob", "bob-pw").Code)
+}
@@ -16,6 +16,9 @@ func Run(version, commitHash string) {
 		Commands: []cli.Command{
 			serveCmd(version),
 			hashCmd,
+			totpCmd,
+			tokenCmd,
+			versionCmd(version, commitHash),
 		},
 	}
 	err := app.Run(os.Args)
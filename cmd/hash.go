@@ -7,9 +7,9 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/screego/server/auth"
 	"github.com/screego/server/logger"
 	"github.com/urfave/cli"
-	"golang.org/x/crypto/bcrypt"
 	"golang.org/x/term"
 )
 
@@ -18,14 +18,27 @@ var hashCmd = cli.Command{
 	Flags: []cli.Flag{
 		&cli.StringFlag{Name: "name"},
 		&cli.StringFlag{Name: "pass"},
+		&cli.StringFlag{Name: "file", Usage: "append the user to this users file"},
+		&cli.StringFlag{Name: "role", Value: auth.RoleUser, Usage: "user or admin"},
+		&cli.StringFlag{Name: "algorithm", Value: auth.HashBcrypt, EnvVar: "SCREEGO_PASSWORD_HASH"},
+		&cli.IntFlag{Name: "bcrypt-cost", Value: 12, EnvVar: "SCREEGO_BCRYPT_COST"},
+		&cli.UintFlag{Name: "argon2-memory", Value: 65536, EnvVar: "SCREEGO_ARGON2_MEMORY"},
+		&cli.UintFlag{Name: "argon2-iterations", Value: 3, EnvVar: "SCREEGO_ARGON2_ITERATIONS"},
+		&cli.UintFlag{Name: "argon2-parallelism", Value: 2, EnvVar: "SCREEGO_ARGON2_PARALLELISM"},
 	},
 	Action: func(ctx *cli.Context) {
-		logger.Init(zerolog.ErrorLevel)
+		logger.Init(zerolog.ErrorLevel, logger.Options{})
 		name := ctx.String("name")
 		pass := []byte(ctx.String("pass"))
 		if name == "" {
 			log.Fatal().Msg("--name must be set")
 		}
+		if err := auth.ValidateUserName(name); err != nil {
+			log.Fatal().Err(err).Msg("invalid --name")
+		}
+		if role := ctx.String("role"); role != auth.RoleUser && role != auth.RoleAdmin {
+			log.Fatal().Msg("--role must be user or admin")
+		}
 
 		if len(pass) == 0 {
 			var err error
@@ -36,12 +49,29 @@ var hashCmd = cli.Command{
 			}
 			_, _ = fmt.Fprintln(os.Stderr, "")
 		}
-		hashedPw, err := bcrypt.GenerateFromPassword(pass, 12)
+		hashedPw, err := auth.HashPassword(pass, auth.HashParams{
+			Algorithm:         ctx.String("algorithm"),
+			BcryptCost:        ctx.Int("bcrypt-cost"),
+			Argon2Memory:      uint32(ctx.Uint("argon2-memory")),
+			Argon2Iterations:  uint32(ctx.Uint("argon2-iterations")),
+			Argon2Parallelism: uint8(ctx.Uint("argon2-parallelism")),
+		})
 		if err != nil {
 			log.Fatal().Err(err).Msg("could not generate password")
 		}
 
-		fmt.Printf("%s:%s", name, string(hashedPw))
-		fmt.Println("")
+		if file := ctx.String("file"); file != "" {
+			if err := auth.AppendUser(file, name, hashedPw, ctx.String("role")); err != nil {
+				log.Fatal().Err(err).Msg("could not add user")
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "Added %s to %s\n", name, file)
+			return
+		}
+
+		line, err := auth.UserLine(name, hashedPw, ctx.String("role"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not generate user")
+		}
+		fmt.Println(line)
 	},
 }
@@ -1,20 +1,33 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"os"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
 	"github.com/screego/server/auth"
 	"github.com/screego/server/config"
 	"github.com/screego/server/logger"
 	"github.com/screego/server/router"
 	"github.com/screego/server/server"
+	"github.com/screego/server/store"
 	"github.com/screego/server/turn"
 	"github.com/screego/server/ws"
 	"github.com/urfave/cli"
 )
 
+// Priorities of the shutdown hooks: the data retention stops first, it needs the open rooms. The rooms close the
+// websocket connections and end the sessions, then the TURN server stops relaying. The http server is shut down last, requests that need the stopped rooms get a 503 meanwhile.
+const (
+	shutdownRetention = iota
+	shutdownRooms
+	shutdownTURN
+)
+
 func serveCmd(version string) cli.Command {
 	return cli.Command{
 		Name: "serve",
@@ -22,7 +35,21 @@ func serveCmd(version string) cli.Command {
 			// 获取配置
 			conf, errs := config.Get()
 			// 初始化日志
-			logger.Init(conf.LogLevel.AsZeroLogLevel())
+			var pseudonyms *logger.Pseudonyms
+			if conf.PrivacyMode {
+				pseudonyms = logger.NewPseudonyms()
+			}
+			err := logger.Init(conf.LogLevel.AsZeroLogLevel(), logger.Options{
+				File:       conf.LogFile,
+				FileOnly:   !conf.LogConsole,
+				Syslog:     conf.LogSyslog,
+				SyslogTag:  conf.LogSyslogTag,
+				Alerts:     logger.Alerts{URL: conf.AlertWebhookURL, Secret: conf.AlertWebhookSecret},
+				Pseudonyms: pseudonyms,
+			})
+			if err != nil {
+				log.Fatal().Err(err).Msg("While initializing the logger")
+			}
 
 			// 处理配置信息
 			exit := false
@@ -35,6 +62,10 @@ func serveCmd(version string) cli.Command {
 				os.Exit(1)
 			}
 
+			// 记录生效的配置
+			log.Info().Fields(conf.Summary()).Msg("Effective configuration")
+			log.Debug().Fields(conf.Dump()).Msg("Effective configuration (all settings)")
+
 			// 检查 TURN IP 提供
 			if _, _, err := conf.TurnIPProvider.Get(); err != nil {
 				// error is already logged by .Get()
@@ -42,25 +73,187 @@ func serveCmd(version string) cli.Command {
 			}
 
 			// 读取用户文件
-			users, err := auth.ReadPasswordsFile(conf.UsersFile, conf.Secret, conf.SessionTimeoutSeconds)
+			users, err := auth.ReadUsersFiles(conf.UsersFile, conf.UsersDuplicates, conf.Secret, conf.SessionTimeoutSeconds)
 			if err != nil {
 				log.Fatal().Str("file", conf.UsersFile).Err(err).Msg("While loading users file")
 			}
+			if conf.UsersFile != "" {
+				users.ReloadOnSignal()
+			}
+			users.SessionIdleTimeout = time.Duration(conf.SessionIdleTimeoutSeconds) * time.Second
+			users.Cookie = auth.NewSessionCookie(conf)
+			users.RestrictRoomCreation = !conf.CanCreateRoomsDefault
+			users.RequireTOTPForAdmins = conf.RequireTOTPForAdmins
+
+			// 连接 Redis 或数据库，加载持久化的会话
+			var persistent store.Store
+			var redisStore *store.RedisStore
+			var sqliteStore *store.SQLiteStore
+			if conf.RedisURL != "" {
+				redisStore, err = store.OpenRedis(conf.RedisURL)
+				if err != nil {
+					log.Fatal().Err(err).Msg("could not connect to redis")
+				}
+				defer redisStore.Close()
+				persistent = redisStore
+			}
+			if conf.DBDriver == config.DBDriverSQLite {
+				sqliteStore, err = store.OpenSQLite(conf.DBDSN)
+				if err != nil {
+					log.Fatal().Err(err).Msg("could not open database")
+				}
+				defer sqliteStore.Close()
+				persistent = sqliteStore
+			}
+			switch {
+			case conf.SessionMode == config.SessionModeJWT:
+				// 签名的 JWT 会话，撤销时间通过 Redis 或数据库共享
+				var revocations store.Revocations = store.NewMemoryStore()
+				if persistentRevocations, ok := persistent.(store.Revocations); ok {
+					revocations = persistentRevocations
+				}
+				jwtSessions, err := auth.NewJWTSessions(conf, revocations)
+				if err != nil {
+					log.Fatal().Err(err).Msg("While loading the jwt session key")
+				}
+				users.UseJWTSessions(jwtSessions)
+			case persistent != nil:
+				users.UseSessionBackend(auth.StoreSessions{Store: persistent})
+			case conf.SessionFile != "":
+				sessions, err := auth.OpenSessionFile(conf.SessionFile)
+				if err != nil {
+					log.Fatal().Str("file", conf.SessionFile).Err(err).Msg("While loading sessions file")
+				}
+				users.UseSessionBackend(sessions)
+			}
+			users.PasswordLoginDisabled = conf.LoginMode == config.LoginModeOIDC
+			if conf.LoginMaxFailures > 0 || conf.LoginMaxFailuresPerIP > 0 {
+				users.Limiter = auth.NewLoginLimiter(conf)
+			}
+
+			// 检查 LDAP 连接
+			if conf.PasswordBackend == config.PasswordBackendLDAP {
+				users.LDAP = auth.NewLDAP(conf)
+				if err := users.LDAP.Check(); err != nil {
+					log.Warn().Err(err).Str("server", conf.LDAPServer).Msg("LDAP server is unreachable, logins will fail until it is available")
+				}
+			}
+			if conf.PasswordBackend == config.PasswordBackendWebhook {
+				users.Webhook = auth.NewAuthWebhook(conf)
+			}
+
+			// 读取 API 令牌
+			users.Tokens, err = auth.ReadTokens(conf.APITokensFile, conf.APITokens)
+			if err != nil {
+				log.Fatal().Str("file", conf.APITokensFile).Err(err).Msg("While loading API tokens")
+			}
+
+			// 信任反向代理的用户头
+			if len(conf.ProxyAuthTrustedProxies) > 0 {
+				users.Proxy, err = auth.NewProxyAuth(conf)
+				if err != nil {
+					log.Fatal().Err(err).Msg("could not start proxy auth")
+				}
+			}
+
+			// 发现 OIDC 提供者
+			var oidcLogin *auth.OIDC
+			if conf.LoginMode != config.LoginModePassword {
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				oidcLogin, err = auth.NewOIDC(ctx, conf, users)
+				cancel()
+				if err != nil {
+					log.Fatal().Err(err).Msg("could not start OIDC login")
+				}
+			}
+
+			// 打开审计日志
+			switch conf.AuditLogOutput {
+			case config.AuditLogOutputFile:
+				auditLog, err := audit.Open(conf.AuditLogFile)
+				if err != nil {
+					log.Fatal().Str("file", conf.AuditLogFile).Err(err).Msg("While opening audit log")
+				}
+				defer auditLog.Close()
+				auditLog.Pseudonyms = pseudonyms
+				auditLog.ReopenOnSignal()
+				users.Audit = auditLog
+			case config.AuditLogOutputLog:
+				auditLog := audit.Main()
+				auditLog.Pseudonyms = pseudonyms
+				users.Audit = auditLog
+			}
 
 			// 启动 TURN 服务器
-			auth, err := turn.Start(conf)
+			auth, err := turn.Start(conf, users)
 			if err != nil {
 				log.Fatal().Err(err).Msg("could not start turn server")
 			}
 
 			// 创建和启动房间管理
-			rooms := ws.NewRooms(auth, users, conf)
+			var roomStore ws.RoomStore = ws.NewMemoryRoomStore()
+			switch {
+			case redisStore != nil:
+				roomStore = ws.NewRedisRoomStore(redisStore)
+			case sqliteStore != nil:
+				roomStore, err = ws.NewSQLiteRoomStore(sqliteStore)
+				if err != nil {
+					log.Fatal().Err(err).Msg("could not load the rooms")
+				}
+			}
+			rooms := ws.NewRooms(auth, users, roomStore, conf)
+			rooms.Audit = users.Audit
+			if redisStore != nil {
+				if err := rooms.ShareEvents(redisStore); err != nil {
+					log.Fatal().Err(err).Msg("could not subscribe to the room events")
+				}
+			}
+
+			go func() {
+				if err := rooms.Start(); err != nil {
+					log.Fatal().Err(err).Msg("rooms")
+				}
+			}()
 
-			go rooms.Start()
+			// 每天删除旧的房间和过期的会话
+			stopRetention := func(context.Context) error { return nil }
+			if persistent != nil && conf.DataRetentionDays > 0 {
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					store.Retention{
+						Store:     persistent,
+						MaxAge:    time.Duration(conf.DataRetentionDays) * 24 * time.Hour,
+						RunAt:     conf.RetentionRunAtOffset,
+						LiveRooms: rooms.RoomIDs,
+					}.Start(ctx)
+				}()
+				stopRetention = func(shutdown context.Context) error {
+					cancel()
+					select {
+					case <-done:
+						return nil
+					case <-shutdown.Done():
+						return shutdown.Err()
+					}
+				}
+			}
 
 			// 启动 http 服务器
-			r := router.Router(conf, rooms, users, version)
-			if err := server.Start(r, conf.ServerAddress, conf.TLSCertFile, conf.TLSKeyFile); err != nil {
+			r := router.Router(conf, rooms, users, oidcLogin, auth, version)
+			socket := server.UnixSocket{Mode: conf.UnixSocketMode, Owner: conf.UnixSocketOwner, Group: conf.UnixSocketGroup}
+			// 关闭顺序：等待 SCREEGO_SHUTDOWN_DELAY → 数据保留 → 房间 → TURN → http 服务
+			if err := server.Start(r, conf.ServerAddress, conf.TLSCertFile, conf.TLSKeyFile, conf.HTTPRedirectAddress, socket,
+				server.WithTLSKeyPassword(conf.TLSKeyPassword),
+				server.WithShutdownDelay(conf.ShutdownDelay, rooms.NotReady),
+				server.WithShutdownHook(shutdownRetention, stopRetention),
+				server.WithShutdownHook(shutdownRooms, rooms.Stop),
+				server.WithShutdownHook(shutdownTURN, auth.Stop)); err != nil {
+				var bindErr *server.BindError
+				if errors.As(err, &bindErr) {
+					log.Fatal().Err(bindErr.Err).Str("addr", bindErr.Address).Str("hint", bindErr.Hint).Msg("could not start http server")
+				}
 				log.Fatal().Err(err).Msg("http server")
 			}
 		},
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/logger"
+	"github.com/urfave/cli"
+)
+
+var tokenCmd = cli.Command{
+	Name:  "token",
+	Usage: "generate an API token and its entry for SCREEGO_API_TOKENS_FILE",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "name"},
+		&cli.StringFlag{Name: "role", Value: auth.TokenRoleReadOnly, Usage: "admin or readonly"},
+		&cli.StringFlag{Name: "expires", Usage: "a date like 2024-12-31"},
+	},
+	Action: func(ctx *cli.Context) {
+		logger.Init(zerolog.ErrorLevel, logger.Options{})
+		name, role, expires := ctx.String("name"), ctx.String("role"), ctx.String("expires")
+		if name == "" || strings.ContainsAny(name, ":#\"\n") {
+			log.Fatal().Msg("--name must be set and must not contain ':', '#', quotes or newlines")
+		}
+		if role != auth.TokenRoleAdmin && role != auth.TokenRoleReadOnly {
+			log.Fatal().Msg("--role must be admin or readonly")
+		}
+		if _, err := time.Parse("2006-01-02", expires); expires != "" && err != nil {
+			log.Fatal().Msg("--expires must be a date like 2024-12-31")
+		}
+
+		token, err := auth.GenerateToken()
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not generate token")
+		}
+		entry := name + ":" + auth.HashToken(token) + ":" + role
+		if expires != "" {
+			entry += ":" + expires
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Token (it is only shown once): %s\n", token)
+		fmt.Println(entry)
+	},
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/logger"
+	"github.com/urfave/cli"
+)
+
+var totpCmd = cli.Command{
+	Name:  "totp",
+	Usage: "generate a TOTP secret for the fifth field of a users file entry",
+	Flags: []cli.Flag{
+		&cli.StringFlag{Name: "name"},
+		&cli.StringFlag{Name: "issuer", Value: "Screego", Usage: "the name shown in the authenticator app"},
+	},
+	Action: func(ctx *cli.Context) {
+		logger.Init(zerolog.ErrorLevel, logger.Options{})
+		name := ctx.String("name")
+		if err := auth.ValidateUserName(name); err != nil {
+			log.Fatal().Err(err).Msg("invalid --name")
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			log.Fatal().Err(err).Msg("could not generate secret")
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "Add the secret as fifth field to the entry of %s, e.g. %s:<hash>:<role>::%s\n", name, name, secret)
+		_, _ = fmt.Fprintln(os.Stderr, "Scan this uri as QR code or enter it in the authenticator app:")
+		_, _ = fmt.Fprintln(os.Stderr, auth.TOTPURI(ctx.String("issuer"), name, secret))
+		fmt.Println(secret)
+	},
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/urfave/cli"
+)
+
+// versionCmd prints the version without reading the config, the version is the same as in GET /version.
+func versionCmd(version, commitHash string) cli.Command {
+	return cli.Command{
+		Name:  "version",
+		Usage: "print the version",
+		Action: func(ctx *cli.Context) {
+			fmt.Printf("screego %s\ncommit: %s\ngo: %s %s/%s\n", version, commitHash, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		},
+	}
+}
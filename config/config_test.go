@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":                "",
+		"/":               "",
+		"screego":         "/screego",
+		"/screego":        "/screego",
+		"/screego/":       "/screego",
+		"/apps/screego//": "/apps/screego",
+	}
+	for input, expected := range tests {
+		actual, err := normalizeBasePath(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	_, err := normalizeBasePath("/screego?x=1")
+	assert.Error(t, err)
+}
+
+func TestNormalizeServerAddress(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		address  string
+		expected string
+		err      string
+	}{
+		{address: ":5050", expected: ":5050"},
+		{address: " :5050 ", expected: ":5050"},
+		{address: "127.0.0.1:5050", expected: "127.0.0.1:5050"},
+		{address: "localhost:05050", expected: "localhost:5050"},
+		{address: "[::1]:5050", expected: "[::1]:5050"},
+		{address: "unix:" + dir + "/screego.sock", expected: "unix:" + dir + "/screego.sock"},
+		{address: "unix:" + dir + "//screego.sock", expected: "unix:" + dir + "/screego.sock"},
+		{address: "5050", err: "missing port, it must be host:port, :port or unix:/path/to/socket"},
+		{address: "localhost", err: "missing port, it must be host:port, :port or unix:/path/to/socket"},
+		{address: "::1:5050", err: "it must be host:port, :port or unix:/path/to/socket, IPv6 addresses must be enclosed in brackets"},
+		{address: "localhost:", err: `invalid port "", it must be a number between 0 and 65535`},
+		{address: "localhost:http", err: `invalid port "http", it must be a number between 0 and 65535`},
+		{address: ":65536", err: `invalid port "65536", it must be a number between 0 and 65535`},
+		{address: "local host:5050", err: `invalid host "local host"`},
+		{address: "unix:", err: "the unix socket path is empty"},
+		{address: "unix:" + dir + "/missing/screego.sock", err: "the directory of the unix socket does not exist: " + dir + "/missing"},
+	}
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			actual, err := normalizeServerAddress(test.address)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestNormalizeServerAddress_readOnlyDirectory(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can write into read only directories")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o500))
+
+	_, err := normalizeServerAddress("unix:" + dir + "/screego.sock")
+	assert.EqualError(t, err, "the directory of the unix socket is not writable: "+dir)
+}
+
+func TestNormalizeExternalHost(t *testing.T) {
+	tests := []struct {
+		value    string
+		scheme   string
+		host     string
+		hostname string
+		err      string
+	}{
+		{value: "screego.example.org", host: "screego.example.org", hostname: "screego.example.org"},
+		{value: " screego.example.org:08443 ", host: "screego.example.org:8443", hostname: "screego.example.org"},
+		{value: "https://screego.example.org/", scheme: "https", host: "screego.example.org", hostname: "screego.example.org"},
+		{value: "WSS://screego.example.org:8443", scheme: "https", host: "screego.example.org:8443", hostname: "screego.example.org"},
+		{value: "ws://10.0.0.1", scheme: "http", host: "10.0.0.1", hostname: "10.0.0.1"},
+		{value: "[2001:db8::1]:5050", host: "[2001:db8::1]:5050", hostname: "2001:db8::1"},
+		{value: "[2001:db8::1]", host: "[2001:db8::1]", hostname: "2001:db8::1"},
+		{value: "ftp://screego.example.org", err: `invalid scheme "ftp", it must be http, https, ws or wss`},
+		{value: "screego.example.org/screego", err: "it must be a host name with optional port and scheme, without path or query"},
+		{value: "https://user@screego.example.org", err: "it must be a host name with optional port and scheme, without path or query"},
+		{value: "screego_example.org", err: `invalid host name "screego_example.org"`},
+		{value: "2001:db8::1", err: "it must be host or host:port, IPv6 addresses must be enclosed in brackets"},
+		{value: "screego.example.org:", err: `invalid port "", it must be a number between 1 and 65535`},
+		{value: "screego.example.org:0", err: `invalid port "0", it must be a number between 1 and 65535`},
+		{value: "screego.example.org:https", err: "it must be host or host:port, IPv6 addresses must be enclosed in brackets"},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			scheme, host, err := normalizeExternalHost(test.value)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.scheme, scheme)
+			assert.Equal(t, test.host, host)
+			assert.Equal(t, test.hostname, Config{ExternalHost: host}.ExternalHostname())
+		})
+	}
+}
+
+func TestBasePathOf(t *testing.T) {
+	for _, test := range []struct{ basePath, pathPrefix, expected string }{
+		{basePath: "", pathPrefix: "", expected: ""},
+		{basePath: "/screego/", pathPrefix: "", expected: "/screego"},
+		{basePath: "", pathPrefix: "/foo/", expected: "/foo"},
+		{basePath: "foo", pathPrefix: "/foo/", expected: "/foo"},
+	} {
+		actual, err := basePathOf(test.basePath, test.pathPrefix)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, actual)
+	}
+
+	_, err := basePathOf("/screego", "/foo")
+	assert.EqualError(t, err, "SCREEGO_BASE_PATH /screego and SCREEGO_SERVER_PATH_PREFIX /foo differ, set only one of them")
+	_, err = basePathOf("", "/foo?x=1")
+	assert.EqualError(t, err, "invalid SCREEGO_SERVER_PATH_PREFIX: must not contain a query or fragment")
+}
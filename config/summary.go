@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// redacted replaces secrets that are set in the debug dump.
+const redacted = "[redacted]"
+
+var (
+	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
+	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+)
+
+// Summary returns the most important settings after the env files and variables were merged, for the startup log.
+// It never contains secrets.
+func (c Config) Summary() map[string]interface{} {
+	turnPorts := "all"
+	if min, max, ok := c.PortRange(); ok {
+		turnPorts = fmt.Sprintf("%d-%d", min, max)
+	}
+	turn := c.TurnAddress
+	if c.TurnExternal {
+		turn = "external"
+	}
+	return map[string]interface{}{
+		"logLevel":           c.LogLevel.AsZeroLogLevel().String(),
+		"serverAddress":      c.ServerAddress,
+		"basePath":           c.BasePath,
+		"tls":                c.ServerTLS,
+		"turn":               turn,
+		"turnPorts":          turnPorts,
+		"turnAuth":           c.TurnAuth,
+		"authMode":           c.AuthMode,
+		"loginMode":          c.LoginMode,
+		"passwordBackend":    c.PasswordBackend,
+		"usersFile":          c.UsersFile,
+		"sessionMode":        c.SessionMode,
+		"maxRoomsPerUser":    c.MaxRoomsPerUser,
+		"maxSessionsPerUser": c.MaxSessionsPerUser,
+		"roomLifetime":       c.RoomLifetime.String(),
+		"roomMaxLifetime":    c.RoomMaxLifetime.String(),
+	}
+}
+
+// Dump returns all settings with their env names for the debug log. Fields with the tag secret:"true" are replaced if
+// they are set.
+func (c Config) Dump() map[string]interface{} {
+	result := map[string]interface{}{}
+	value := reflect.ValueOf(c)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Tag.Get("ignored") == "true" {
+			continue
+		}
+		if field.Tag.Get("secret") == "true" {
+			result[envName(field)] = redact(value.Field(i))
+			continue
+		}
+		result[envName(field)] = dumpValue(value.Field(i))
+	}
+	return result
+}
+
+// redact returns whether the secret is set, but never the secret.
+func redact(value reflect.Value) string {
+	if value.Len() == 0 {
+		return ""
+	}
+	return redacted
+}
+
+func dumpValue(value reflect.Value) interface{} {
+	switch v := value.Interface().(type) {
+	case LogLevel:
+		return v.AsZeroLogLevel().String()
+	case time.Duration:
+		return v.String()
+	case []time.Duration:
+		durations := make([]string, 0, len(v))
+		for _, d := range v {
+			durations = append(durations, d.String())
+		}
+		return strings.Join(durations, ",")
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return v
+	}
+}
+
+// envName returns the name of the environment variable of the field, the words are split like envconfig does.
+func envName(field reflect.StructField) string {
+	if name := field.Tag.Get("envconfig"); name != "" {
+		return "SCREEGO_" + name
+	}
+	if field.Tag.Get("split_words") != "true" {
+		return "SCREEGO_" + strings.ToUpper(field.Name)
+	}
+	var words []string
+	for _, word := range gatherRegexp.FindAllString(field.Name, -1) {
+		if m := acronymRegexp.FindStringSubmatch(word); len(m) == 3 {
+			words = append(words, m[1], m[2])
+		} else {
+			words = append(words, word)
+		}
+	}
+	return "SCREEGO_" + strings.ToUpper(strings.Join(words, "_"))
+}
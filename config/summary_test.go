@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	conf := Config{
+		ServerAddress: ":5050",
+		ServerTLS:     true,
+		TurnAddress:   ":3478",
+		TurnPortRange: "50000:55000",
+		Secret:        []byte("session-secret"),
+		TurnSecret:    "turn-secret",
+		RoomLifetime:  time.Hour,
+	}
+
+	summary := conf.Summary()
+	assert.Equal(t, ":5050", summary["serverAddress"])
+	assert.Equal(t, true, summary["tls"])
+	assert.Equal(t, "50000-55000", summary["turnPorts"])
+	assert.Equal(t, "1h0m0s", summary["roomLifetime"])
+	assert.NotContains(t, fmt.Sprint(summary), "secret")
+}
+
+func TestDump(t *testing.T) {
+	conf := Config{
+		ServerAddress:      ":5050",
+		Secret:             []byte("session-secret"),
+		APITokens:          []string{"ops:ops-token-0123456789:admin"},
+		OIDCClientSecret:   "oidc-secret",
+		OIDCClientID:       "screego",
+		RoomExpiryWarnings: []time.Duration{5 * time.Minute, time.Minute},
+		CheckOrigin:        func(string) bool { return true },
+	}
+
+	dump := conf.Dump()
+	assert.Equal(t, ":5050", dump["SCREEGO_SERVER_ADDRESS"])
+	assert.Equal(t, "screego", dump["SCREEGO_OIDC_CLIENT_ID"])
+	assert.Equal(t, "5m0s,1m0s", dump["SCREEGO_ROOM_EXPIRY_WARNINGS"])
+	assert.Equal(t, false, dump["SCREEGO_OPENAPI_ENABLED"])
+	assert.Equal(t, redacted, dump["SCREEGO_SECRET"])
+	assert.Equal(t, redacted, dump["SCREEGO_API_TOKENS"])
+	assert.Equal(t, redacted, dump["SCREEGO_OIDC_CLIENT_SECRET"])
+	assert.Equal(t, "", dump["SCREEGO_TURN_SECRET"], "secrets that aren't set are empty")
+	assert.NotContains(t, dump, "SCREEGO_CHECK_ORIGIN")
+}
@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// alertTimeout limits a single delivery.
+	alertTimeout = 5 * time.Second
+	// alertBatchSize limits the events of a batch, further events of the window are dropped.
+	alertBatchSize = 100
+)
+
+// alertWindow is how long events are collected before they are sent together, so that repeated errors don't cause a
+// request each.
+var alertWindow = time.Second
+
+// Alerts forwards the events at error level or higher to a webhook, it is disabled without URL.
+type Alerts struct {
+	URL string
+	// Secret signs the body with HMAC-SHA256, the hex encoded signature is sent in the X-Screego-Signature header.
+	Secret string
+}
+
+// alertWriter is a zerolog.LevelWriter, unlike a zerolog.Hook it gets the json of the event with all fields. The
+// events of a window are posted as json array in their own goroutine, failed deliveries are dropped so that the
+// logging never blocks. Fatal and panic events are sent right away, the process ends after them.
+type alertWriter struct {
+	url    string
+	secret []byte
+	client *http.Client
+
+	lock    sync.Mutex
+	pending []json.RawMessage
+}
+
+func newAlertWriter(alerts Alerts) *alertWriter {
+	return &alertWriter{url: alerts.URL, secret: []byte(alerts.Secret), client: &http.Client{Timeout: alertTimeout}}
+}
+
+func (a *alertWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (a *alertWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < zerolog.ErrorLevel || level > zerolog.PanicLevel {
+		return len(p), nil
+	}
+	// zerolog reuses the buffer of the event.
+	event := json.RawMessage(append([]byte(nil), bytes.TrimSpace(p)...))
+
+	a.lock.Lock()
+	if len(a.pending) < alertBatchSize {
+		a.pending = append(a.pending, event)
+	}
+	first := len(a.pending) == 1
+	a.lock.Unlock()
+
+	if level >= zerolog.FatalLevel {
+		a.flush()
+	} else if first {
+		time.AfterFunc(alertWindow, a.flush)
+	}
+	return len(p), nil
+}
+
+// flush sends the pending events, it does nothing if they were already sent.
+func (a *alertWriter) flush() {
+	a.lock.Lock()
+	events := a.pending
+	a.pending = nil
+	a.lock.Unlock()
+	if len(events) == 0 {
+		return
+	}
+	// below the error level, so that it isn't an alert again.
+	if err := a.deliver(events); err != nil {
+		log.Debug().Err(err).Int("events", len(events)).Msg("Could not send alerts, dropping them")
+	}
+}
+
+func (a *alertWriter) deliver(events []json.RawMessage) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	mac := hmac.New(sha256.New, a.secret)
+	_, _ = mac.Write(body)
+	req.Header.Set("X-Screego-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAlertServer(t *testing.T, status int) (string, <-chan []map[string]interface{}) {
+	t.Helper()
+	received := make(chan []map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Screego-Signature"))
+
+		var events []map[string]interface{}
+		require.NoError(t, json.Unmarshal(body, &events))
+		received <- events
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+func TestAlerts(t *testing.T) {
+	old := alertWindow
+	alertWindow = 50 * time.Millisecond
+	defer func() { alertWindow = old }()
+
+	url, received := newAlertServer(t, http.StatusOK)
+	logger := zerolog.New(newAlertWriter(Alerts{URL: url, Secret: "secret"}))
+	logger.Error().Str("room", "first").Msg("failed")
+	logger.Warn().Msg("ignored")
+	logger.Log().Msg("ignored without level")
+	logger.Error().Str("room", "second").Msg("failed")
+
+	select {
+	case events := <-received:
+		require.Len(t, events, 2, "the errors of the window are sent together")
+		assert.Equal(t, map[string]interface{}{"level": "error", "room": "first", "message": "failed"}, events[0])
+		assert.Equal(t, "second", events[1]["room"])
+	case <-time.After(time.Second):
+		t.Fatal("no alert received")
+	}
+
+	logger.Error().Msg("next window")
+	select {
+	case events := <-received:
+		require.Len(t, events, 1)
+	case <-time.After(time.Second):
+		t.Fatal("no alert received")
+	}
+}
+
+func TestAlerts_fatal(t *testing.T) {
+	url, received := newAlertServer(t, http.StatusInternalServerError)
+	writer := newAlertWriter(Alerts{URL: url, Secret: "secret"})
+	_, err := writer.WriteLevel(zerolog.ErrorLevel, []byte(`{"level":"error"}`))
+	require.NoError(t, err)
+	_, err = writer.WriteLevel(zerolog.FatalLevel, []byte(`{"level":"fatal"}`))
+	require.NoError(t, err)
+
+	// fatal events are sent before WriteLevel returns, the failed delivery is dropped.
+	require.Len(t, received, 1)
+	assert.Len(t, <-received, 2)
+	writer.flush()
+	assert.Empty(t, received)
+}
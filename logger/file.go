@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileCheckInterval is how often the file is checked for a rotation, so that not every write needs a stat.
+var fileCheckInterval = time.Second
+
+// fileWriter appends to the log file and opens it again once it was moved or removed, e.g. by logrotate. The rotation
+// is detected by comparing the open file with the file at the path, so no signal is needed.
+type fileWriter struct {
+	path string
+
+	lock    sync.Mutex
+	file    *os.File
+	checked time.Time
+}
+
+func openFileWriter(path string) (*fileWriter, error) {
+	f := &fileWriter{path: path}
+	file, err := openLogFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f.file, f.checked = file, time.Now()
+	return f, nil
+}
+
+func openLogFile(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+}
+
+func (f *fileWriter) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if now := time.Now(); now.Sub(f.checked) >= fileCheckInterval {
+		f.checked = now
+		f.reopenIfRotated()
+	}
+	return f.file.Write(p)
+}
+
+// reopenIfRotated keeps writing to the previous file if the new one cannot be opened, the logs would be lost
+// otherwise.
+func (f *fileWriter) reopenIfRotated() {
+	current, err := f.file.Stat()
+	if err != nil {
+		return
+	}
+	if atPath, err := os.Stat(f.path); err == nil && os.SameFile(current, atPath) {
+		return
+	}
+	file, err := openLogFile(f.path)
+	if err != nil {
+		return
+	}
+	_ = f.file.Close()
+	f.file = file
+}
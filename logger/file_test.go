@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readLogFile(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	entries := []map[string]interface{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestInit_file(t *testing.T) {
+	old := log.Logger
+	defer func() { log.Logger = old }()
+	path := filepath.Join(t.TempDir(), "screego.log")
+
+	require.NoError(t, Init(zerolog.InfoLevel, Options{File: path, FileOnly: true}))
+	log.Debug().Msg("below the level")
+	log.Info().Str("room", "room").Msg("created")
+
+	entries := readLogFile(t, path)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "info", entries[0]["level"])
+	assert.Equal(t, "room", entries[0]["room"])
+	assert.Equal(t, "created", entries[0]["message"])
+}
+
+func TestInit_fileError(t *testing.T) {
+	old := log.Logger
+	defer func() { log.Logger = old }()
+	assert.Error(t, Init(zerolog.InfoLevel, Options{File: filepath.Join(t.TempDir(), "missing", "screego.log")}))
+}
+
+func TestFileWriter_rotation(t *testing.T) {
+	old := fileCheckInterval
+	fileCheckInterval = 0
+	defer func() { fileCheckInterval = old }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "screego.log")
+	writer, err := openFileWriter(path)
+	require.NoError(t, err)
+	logger := zerolog.New(writer)
+
+	logger.Info().Msg("first")
+	require.NoError(t, os.Rename(path, path+".1"))
+	logger.Info().Msg("second")
+
+	assert.Equal(t, "first", readLogFile(t, path+".1")[0]["message"])
+	rotated := readLogFile(t, path)
+	require.Len(t, rotated, 1, "the file was created again after the rename")
+	assert.Equal(t, "second", rotated[0]["message"])
+
+	require.NoError(t, os.Remove(path))
+	logger.Info().Msg("third")
+	assert.Equal(t, "third", readLogFile(t, path)[0]["message"])
+}
@@ -1,6 +1,9 @@
 package logger
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"time"
 
@@ -8,8 +11,59 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// Init initializes the logger.
-func Init(lvl zerolog.Level) {
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}).Level(lvl)
+// Options of the log output, the zero value logs to the console only.
+type Options struct {
+	// File receives the logs as json lines if set, it is opened again after it was rotated.
+	File string
+	// FileOnly disables the console output if File is set.
+	FileOnly bool
+	// Syslog sends the logs to the local syslog daemon with SyslogTag as program name.
+	Syslog    bool
+	SyslogTag string
+	// Alerts forwards errors to a webhook.
+	Alerts Alerts
+	// Pseudonyms enables the privacy mode, ips, user names and session ids are replaced in all outputs.
+	Pseudonyms *Pseudonyms
+}
+
+// Init initializes the logger. The console is still used if the file or syslog cannot be opened, the error is
+// returned. Syslog is ignored with a warning on systems without it.
+func Init(lvl zerolog.Level, options Options) error {
+	var console io.Writer = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	writers := []io.Writer{}
+	var err error
+	if options.File != "" {
+		var file *fileWriter
+		if file, err = openFileWriter(options.File); err == nil {
+			writers = append(writers, file)
+		}
+	}
+	if len(writers) == 0 || !options.FileOnly {
+		writers = append([]io.Writer{console}, writers...)
+	}
+	syslogUnsupported := false
+	if options.Syslog {
+		priorities, syslogErr := dialSyslog(options.SyslogTag)
+		switch {
+		case syslogErr == nil:
+			writers = append(writers, syslogWriter{priorities: priorities})
+		case errors.Is(syslogErr, errSyslogUnsupported):
+			syslogUnsupported = true
+		case err == nil:
+			err = fmt.Errorf("connect to syslog: %w", syslogErr)
+		}
+	}
+	if options.Alerts.URL != "" {
+		writers = append(writers, newAlertWriter(options.Alerts))
+	}
+	output := zerolog.MultiLevelWriter(writers...)
+	if options.Pseudonyms != nil {
+		output = privacyWriter{next: output, pseudonyms: options.Pseudonyms}
+	}
+	log.Logger = log.Output(output).Level(lvl)
 	log.Debug().Msg("Logger initialized")
+	if syslogUnsupported {
+		log.Warn().Msg("Syslog is not supported on this system, the logs are not sent to it")
+	}
+	return err
 }
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Pseudonyms replaces ips, user names and session ids with a HMAC, so that the events of a day can still be
+// correlated without revealing who caused them. The key is random and derived again every day (UTC), pseudonyms of
+// different days or processes cannot be linked. A nil Pseudonyms returns the values unchanged.
+type Pseudonyms struct {
+	base []byte
+	now  func() time.Time
+
+	lock sync.Mutex
+	day  string
+	key  []byte
+}
+
+func NewPseudonyms() *Pseudonyms {
+	base := make([]byte, 32)
+	if _, err := rand.Read(base); err != nil {
+		panic(err)
+	}
+	return &Pseudonyms{base: base, now: time.Now}
+}
+
+// IP returns the pseudonym of the ip, the port of ip:port is removed so that all connections of an ip are equal.
+func (p *Pseudonyms) IP(ip string) string {
+	if p == nil || ip == "" {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return p.of("ip", ip)
+}
+
+func (p *Pseudonyms) User(name string) string {
+	if p == nil || name == "" {
+		return name
+	}
+	return p.of("user", name)
+}
+
+func (p *Pseudonyms) Session(id string) string {
+	if p == nil || id == "" {
+		return id
+	}
+	return p.of("session", id)
+}
+
+func (p *Pseudonyms) of(kind, value string) string {
+	mac := hmac.New(sha256.New, p.dailyKey())
+	_, _ = mac.Write([]byte(kind + ":" + value))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func (p *Pseudonyms) dailyKey() []byte {
+	day := p.now().UTC().Format("2006-01-02")
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if day != p.day {
+		mac := hmac.New(sha256.New, p.base)
+		_, _ = mac.Write([]byte(day))
+		p.day, p.key = day, mac.Sum(nil)
+	}
+	return p.key
+}
+
+// privateFields are the fields of log events that identify users. Fields with a nil function are removed.
+var privateFields = map[string]func(*Pseudonyms, string) string{
+	"ip":              (*Pseudonyms).IP,
+	"source_ip":       (*Pseudonyms).IP,
+	"user":            (*Pseudonyms).User,
+	"name":            (*Pseudonyms).User,
+	"username":        (*Pseudonyms).User,
+	"actor_username":  (*Pseudonyms).User,
+	"target_username": (*Pseudonyms).User,
+	"session_id":      (*Pseudonyms).Session,
+	"sid":             (*Pseudonyms).Session,
+	"user_agent":      nil,
+}
+
+// privacyWriter replaces the private fields of the json events before they reach the outputs, so that every logger
+// derived from log.Logger respects the privacy mode without changes at the call sites.
+type privacyWriter struct {
+	next       zerolog.LevelWriter
+	pseudonyms *Pseudonyms
+}
+
+func (w privacyWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (w privacyWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	redacted, err := w.redact(p)
+	if err != nil {
+		// not a json object, nothing can be replaced safely.
+		redacted = []byte(`{"level":"error","message":"Dropped a log event that couldn't be checked for private data"}` + "\n")
+	}
+	if _, err := w.next.WriteLevel(level, redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redact copies the top level fields of the event in their order, private fields are skipped or their string values
+// are replaced.
+func (w privacyWriter) redact(p []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(p))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, errors.New("not a json object")
+	}
+	out := bytes.NewBufferString("{")
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		replace, private := privateFields[key]
+		if private && replace == nil {
+			continue
+		}
+		var str string
+		if private && json.Unmarshal(value, &str) == nil {
+			value, _ = json.Marshal(replace(w.pseudonyms, str))
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteString("}\n")
+	return out.Bytes(), nil
+}
+
+// PrivacyLogger returns the logger of the context or the global logger. Both write through the privacy mode of Init.
+func PrivacyLogger(ctx context.Context) *zerolog.Logger {
+	if logger := zerolog.Ctx(ctx); logger.GetLevel() != zerolog.Disabled {
+		return logger
+	}
+	return &log.Logger
+}
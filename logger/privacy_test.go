@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInit_privacy(t *testing.T) {
+	old := log.Logger
+	defer func() { log.Logger = old }()
+	path := filepath.Join(t.TempDir(), "screego.log")
+	pseudonyms := NewPseudonyms()
+
+	require.NoError(t, Init(zerolog.InfoLevel, Options{File: path, FileOnly: true, Pseudonyms: pseudonyms}))
+	log.Info().Str("ip", "203.0.113.7:51234").Str("user", "alice").Str("user_agent", "Mozilla/5.0").Int("status", 200).Msg("HTTP")
+	log.Info().Str("ip", "203.0.113.7:40000").Str("name", "alice").Msg("Guest joined")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "203.0.113.7")
+	assert.NotContains(t, string(content), "alice")
+	assert.NotContains(t, string(content), "Mozilla")
+	assert.True(t, strings.HasPrefix(string(content), `{"level":"info","ip":"ip-`), "the order of the fields is kept")
+
+	entries := readLogFile(t, path)
+	require.Len(t, entries, 2)
+	assert.Equal(t, pseudonyms.IP("203.0.113.7"), entries[0]["ip"])
+	assert.Equal(t, entries[0]["ip"], entries[1]["ip"], "the port is ignored")
+	assert.Equal(t, entries[0]["user"], entries[1]["name"])
+	assert.NotContains(t, entries[0], "user_agent")
+	assert.Equal(t, float64(200), entries[0]["status"])
+	assert.Equal(t, "HTTP", entries[0]["message"])
+}
+
+func TestPseudonyms_daily(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	pseudonyms := NewPseudonyms()
+	pseudonyms.now = func() time.Time { return now }
+
+	first := pseudonyms.IP("203.0.113.7")
+	assert.Regexp(t, "^ip-[0-9a-f]{16}$", first)
+	assert.NotEqual(t, first, pseudonyms.User("203.0.113.7"), "the kinds have different pseudonyms")
+	assert.NotEqual(t, first, NewPseudonyms().IP("203.0.113.7"), "every process has its own key")
+
+	now = now.Add(59 * time.Minute)
+	assert.Equal(t, first, pseudonyms.IP("203.0.113.7"))
+	now = now.Add(time.Minute)
+	assert.NotEqual(t, first, pseudonyms.IP("203.0.113.7"), "the key changes every day")
+
+	var disabled *Pseudonyms
+	assert.Equal(t, "203.0.113.7", disabled.IP("203.0.113.7"))
+	assert.Empty(t, pseudonyms.User(""))
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"errors"
+
+	"github.com/rs/zerolog"
+)
+
+var errSyslogUnsupported = errors.New("syslog is not supported on this system")
+
+// syslogPriorities are the methods of *syslog.Writer that are used, so that the connection can be replaced in tests.
+type syslogPriorities interface {
+	Debug(m string) error
+	Info(m string) error
+	Warning(m string) error
+	Err(m string) error
+	Crit(m string) error
+}
+
+// syslogWriter sends the json of the events with the syslog priority of their level. Unlike
+// zerolog.SyslogLevelWriter, fatal events are critical and not emergencies, screego failing doesn't make the system
+// unusable.
+type syslogWriter struct {
+	priorities syslogPriorities
+}
+
+func (s syslogWriter) Write(p []byte) (int, error) {
+	return s.WriteLevel(zerolog.NoLevel, p)
+}
+
+func (s syslogWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	msg := string(p)
+	var err error
+	switch level {
+	case zerolog.TraceLevel, zerolog.DebugLevel:
+		err = s.priorities.Debug(msg)
+	case zerolog.WarnLevel:
+		err = s.priorities.Warning(msg)
+	case zerolog.ErrorLevel:
+		err = s.priorities.Err(msg)
+	case zerolog.FatalLevel, zerolog.PanicLevel:
+		err = s.priorities.Crit(msg)
+	default:
+		err = s.priorities.Info(msg)
+	}
+	return len(p), err
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !plan9
+
+package logger
+
+import "log/syslog"
+
+var dialSyslog = func(tag string) (syslogPriorities, error) {
+	writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
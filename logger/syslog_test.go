@@ -0,0 +1,94 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syslogMessage struct {
+	priority string
+	msg      string
+}
+
+type fakeSyslog struct {
+	messages []syslogMessage
+}
+
+func (f *fakeSyslog) record(priority, msg string) error {
+	f.messages = append(f.messages, syslogMessage{priority: priority, msg: msg})
+	return nil
+}
+
+func (f *fakeSyslog) Debug(m string) error   { return f.record("debug", m) }
+func (f *fakeSyslog) Info(m string) error    { return f.record("info", m) }
+func (f *fakeSyslog) Warning(m string) error { return f.record("warning", m) }
+func (f *fakeSyslog) Err(m string) error     { return f.record("err", m) }
+func (f *fakeSyslog) Crit(m string) error    { return f.record("crit", m) }
+
+func fakeDialSyslog(t *testing.T, err error) (*fakeSyslog, *string) {
+	t.Helper()
+	old := dialSyslog
+	t.Cleanup(func() { dialSyslog = old })
+	fake, tag := &fakeSyslog{}, new(string)
+	dialSyslog = func(name string) (syslogPriorities, error) {
+		*tag = name
+		if err != nil {
+			return nil, err
+		}
+		return fake, nil
+	}
+	return fake, tag
+}
+
+func TestSyslogWriter_levels(t *testing.T) {
+	tests := []struct {
+		level    zerolog.Level
+		priority string
+	}{
+		{level: zerolog.TraceLevel, priority: "debug"},
+		{level: zerolog.DebugLevel, priority: "debug"},
+		{level: zerolog.InfoLevel, priority: "info"},
+		{level: zerolog.WarnLevel, priority: "warning"},
+		{level: zerolog.ErrorLevel, priority: "err"},
+		{level: zerolog.FatalLevel, priority: "crit"},
+		{level: zerolog.PanicLevel, priority: "crit"},
+		{level: zerolog.NoLevel, priority: "info"},
+	}
+	for _, test := range tests {
+		fake := &fakeSyslog{}
+		n, err := syslogWriter{priorities: fake}.WriteLevel(test.level, []byte(`{"message":"x"}`))
+		require.NoError(t, err)
+		assert.Equal(t, 15, n)
+		assert.Equal(t, []syslogMessage{{priority: test.priority, msg: `{"message":"x"}`}}, fake.messages, test.level.String())
+	}
+}
+
+func TestInit_syslog(t *testing.T) {
+	old := log.Logger
+	defer func() { log.Logger = old }()
+	fake, tag := fakeDialSyslog(t, nil)
+
+	require.NoError(t, Init(zerolog.InfoLevel, Options{Syslog: true, SyslogTag: "screego-test"}))
+	log.Warn().Msg("careful")
+
+	assert.Equal(t, "screego-test", *tag)
+	require.Len(t, fake.messages, 1)
+	assert.Equal(t, "warning", fake.messages[0].priority)
+	assert.Contains(t, fake.messages[0].msg, `"message":"careful"`)
+}
+
+func TestInit_syslogError(t *testing.T) {
+	old := log.Logger
+	defer func() { log.Logger = old }()
+
+	fakeDialSyslog(t, errors.New("no syslog daemon"))
+	assert.EqualError(t, Init(zerolog.InfoLevel, Options{Syslog: true}), "connect to syslog: no syslog daemon")
+
+	fakeDialSyslog(t, errSyslogUnsupported)
+	assert.NoError(t, Init(zerolog.InfoLevel, Options{Syslog: true}), "unsupported systems only get a warning")
+}
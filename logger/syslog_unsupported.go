@@ -0,0 +1,7 @@
+//go:build windows || plan9
+
+package logger
+
+var dialSyslog = func(tag string) (syslogPriorities, error) {
+	return nil, errSyslogUnsupported
+}
@@ -0,0 +1,43 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/ws"
+)
+
+func listBans(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, loggedIn := users.CurrentUser(r)
+		if !loggedIn {
+			writeError(w, r, http.StatusUnauthorized, "you need to login")
+			return
+		}
+
+		bans, err := rooms.Bans(mux.Vars(r)["id"], user, users.CurrentRole(r) == auth.RoleAdmin)
+		if err != nil {
+			writeError(w, r, roomErrorStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, bans)
+	}
+}
+
+func removeBan(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, loggedIn := users.CurrentUser(r)
+		if !loggedIn {
+			writeError(w, r, http.StatusUnauthorized, "you need to login")
+			return
+		}
+
+		vars := mux.Vars(r)
+		if err := rooms.Unban(vars["id"], user, users.CurrentRole(r) == auth.RoleAdmin, vars["user"]); err != nil {
+			writeError(w, r, roomErrorStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, &auth.Response{Message: "unbanned"})
+	}
+}
@@ -0,0 +1,69 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/screego/server/audit"
+	"github.com/screego/server/ws"
+)
+
+// broadcastInterval is the minimum time between two broadcasts, so that members aren't flooded with notices.
+const broadcastInterval = 10 * time.Second
+
+type BroadcastRequest struct {
+	Level   ws.BroadcastLevel `json:"level"`
+	Message string            `json:"message"`
+}
+
+type BroadcastResponse struct {
+	Recipients int `json:"recipients"`
+}
+
+// broadcast sends a notice to all members of all rooms.
+func broadcast(rooms *ws.Rooms) http.HandlerFunc {
+	var lock sync.Mutex
+	var last time.Time
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body BroadcastRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, r, http.StatusBadRequest, "invalid json: "+err.Error())
+			return
+		}
+		if body.Level == "" {
+			body.Level = ws.BroadcastInfo
+		}
+
+		lock.Lock()
+		if wait := broadcastInterval - time.Since(last); wait > 0 {
+			lock.Unlock()
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeError(w, r, http.StatusTooManyRequests, "too many broadcasts, try again later")
+			return
+		}
+		recipients, err := rooms.Broadcast(body.Level, body.Message, adminUser(r))
+		if err == nil {
+			last = time.Now()
+		}
+		lock.Unlock()
+		if err == ws.ErrStopped {
+			writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		rooms.Audit.Write(audit.Entry{
+			EventType:     audit.Broadcast,
+			ActorUsername: adminUser(r),
+			SourceIP:      audit.RemoteIP(r),
+		})
+		writeJSON(w, http.StatusOK, &BroadcastResponse{Recipients: recipients})
+	}
+}
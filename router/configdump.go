@@ -0,0 +1,60 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+)
+
+// ConfigResponse is the effective configuration of the instance.
+type ConfigResponse struct {
+	// Settings are all settings with their env names like in the debug log at startup, secrets are redacted. The
+	// settings are read once at startup.
+	Settings map[string]interface{} `json:"settings"`
+	// UsersLoadedAt is when the users files were loaded last, they are reloaded on SIGHUP.
+	UsersLoadedAt *time.Time `json:"usersLoadedAt,omitempty"`
+}
+
+// configDump returns the effective configuration, every access is written to the audit log.
+func configDump(conf config.Config, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := ConfigResponse{Settings: conf.Dump()}
+		if loadedAt := users.LoadedAt(); !loadedAt.IsZero() {
+			response.UsersLoadedAt = &loadedAt
+		}
+		users.Audit.Write(audit.Entry{EventType: audit.ConfigRead, ActorUsername: adminUser(r), SourceIP: audit.RemoteIP(r)})
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// adminOnly is basicAuth for endpoints that only admins may use. readonly tokens are rejected and basic auth doesn't
+// accept all users of a users file without admins.
+func adminOnly(handler http.Handler, users *auth.Users) http.HandlerFunc {
+	withBasicAuth := basicAuth(handler, users)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if value, ok := bearerToken(r); ok {
+			if token, ok := users.Tokens.Lookup(value); ok && token.Role != auth.TokenRoleAdmin {
+				log.Info().Str("token", token.Name).Str("role", token.Role).Str("path", r.URL.Path).Msg("API token not allowed")
+				writeError(w, r, http.StatusForbidden, "the role of the token may not use this endpoint")
+				return
+			}
+			withBasicAuth(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); ok {
+			if !users.ValidateAdmin(user, pass) {
+				w.Header().Set("WWW-Authenticate", `Basic realm="screego"`)
+				writeError(w, r, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			handler.ServeHTTP(w, withAdmin(r, user))
+			return
+		}
+		// sessions must have the admin role.
+		withBasicAuth(w, r)
+	}
+}
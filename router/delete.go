@@ -0,0 +1,63 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/ws"
+)
+
+// UserDeleteResponse is what was deleted of a user.
+type UserDeleteResponse struct {
+	User string `json:"user"`
+	// UsersFiles are the users files that contained the user.
+	UsersFiles []string `json:"usersFiles"`
+	// Sessions is the amount of stored login sessions, sessions that are only in cookies are revoked too.
+	Sessions     int `json:"sessions"`
+	Connections  int `json:"connections"`
+	Rooms        int `json:"rooms"`
+	AuditEntries int `json:"auditEntries"`
+}
+
+// deleteUser deletes the personal data of the user of the path: the entry of the users file, the login sessions, the
+// connections and the audit log entries. The user_delete audit entry doesn't name the deleted user. Users that are in
+// no users file and have neither sessions nor connections aren't found.
+func deleteUser(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		sessions, err := users.Sessions(name)
+		if err != nil {
+			log.Error().Err(err).Msg("Delete user sessions")
+			writeError(w, r, http.StatusInternalServerError, "could not read the sessions")
+			return
+		}
+		files, err := users.DeleteUser(name)
+		if err != nil {
+			log.Error().Err(err).Msg("Delete user")
+			writeError(w, r, http.StatusInternalServerError, "could not delete the user")
+			return
+		}
+		deleted, err := rooms.DeleteUser(name)
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if len(files) == 0 && len(sessions) == 0 && deleted.Connections == 0 {
+			writeError(w, r, http.StatusNotFound, "user not found")
+			return
+		}
+		entries, err := users.Audit.DeleteUserEntries(name)
+		if err != nil {
+			log.Error().Err(err).Msg("Delete user audit log")
+			writeError(w, r, http.StatusInternalServerError, "could not delete the audit log entries")
+			return
+		}
+
+		users.Audit.Write(audit.Entry{EventType: audit.UserDelete, ActorUsername: adminUser(r), SourceIP: audit.RemoteIP(r)})
+		writeJSON(w, http.StatusOK, &UserDeleteResponse{User: name, UsersFiles: files, Sessions: len(sessions),
+			Connections: deleted.Connections, Rooms: deleted.Rooms, AuditEntries: entries})
+	}
+}
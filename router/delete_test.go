@@ -0,0 +1,88 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteUser(t *testing.T) {
+	router, users := newSessionRouter(t, config.Config{})
+	dir := t.TempDir()
+	sessions, err := auth.OpenSessionFile(filepath.Join(dir, "sessions.json"))
+	require.NoError(t, err)
+	users.UseSessionBackend(sessions)
+	users.Audit, err = audit.Open(filepath.Join(dir, "audit.log"))
+	require.NoError(t, err)
+	defer users.Audit.Close()
+
+	bob := sessionLogin(t, router, "bob")
+	admin := sessionLogin(t, router, "alice")
+	do := func(method, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, withCookies(method, path, cookies))
+		return recorder
+	}
+	assert.Equal(t, http.StatusUnauthorized, do("DELETE", "/api/v1/users/alice", bob).Code, "only admins may delete users")
+
+	// admin sessions only work for GET requests, other methods need basic auth or a token against csrf.
+	assert.Equal(t, http.StatusUnauthorized, do("DELETE", "/api/v1/users/bob", admin).Code)
+	req := httptest.NewRequest("DELETE", "/api/v1/users/bob", nil)
+	req.SetBasicAuth("alice", "alice-pw")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response UserDeleteResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Len(t, response.UsersFiles, 1)
+	assert.Equal(t, UserDeleteResponse{User: "bob", UsersFiles: response.UsersFiles, Sessions: 1, AuditEntries: 1}, response)
+
+	content, err := os.ReadFile(response.UsersFiles[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "bob:")
+	assert.Contains(t, string(content), "alice:")
+	stored, err := users.Sessions("bob")
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+	entries, err := users.Audit.UserEntries("bob")
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	entries, err = users.Audit.UserEntries("alice")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, audit.UserDelete, entries[1].EventType)
+
+	assert.Equal(t, http.StatusUnauthorized, do("GET", "/api/v1/users/bob/export", bob).Code, "the session is revoked")
+	req = httptest.NewRequest("POST", "/login", nil)
+	req.PostForm = url.Values{"user": {"bob"}, "pass": {"bob-pw"}}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "the user cannot login anymore")
+
+	req = httptest.NewRequest("DELETE", "/api/v1/users/bob", nil)
+	req.SetBasicAuth("alice", "alice-pw")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusNotFound, recorder.Code, "the user was deleted already")
+}
+
+func TestDeleteUser_usersFileWithoutAdmins(t *testing.T) {
+	router, users := newUsersRouter(t, config.Config{}, auth.RoleUser)
+
+	req := httptest.NewRequest("DELETE", "/api/v1/users/alice", nil)
+	req.SetBasicAuth("bob", "bob-pw")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "basic auth accepts all users without admins, but not here")
+	assert.True(t, users.Validate("alice", "alice-pw"), "alice wasn't deleted")
+}
@@ -0,0 +1,73 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// Codes of the error responses, they don't change so that frontends can switch on them.
+const (
+	CodeBadRequest   = "bad_request"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeRateLimited  = "rate_limited"
+	CodeUnavailable  = "unavailable"
+	CodeInternal     = "internal_error"
+)
+
+// ErrorResponse is the body of the error responses of the api. The login and logout of the auth package keep their
+// message body for the UI.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// RequestID is also in the X-Request-Id header and the access log.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// writeError writes the error envelope, the code is derived from the status.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeJSON(w, status, newErrorResponse(r, status, message))
+}
+
+func newErrorResponse(r *http.Request, status int, message string) *ErrorResponse {
+	return &ErrorResponse{Error: ErrorDetail{Code: errorCode(status), Message: message, RequestID: requestID(r)}}
+}
+
+func errorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	default:
+		if status < http.StatusInternalServerError {
+			return CodeBadRequest
+		}
+		return CodeInternal
+	}
+}
+
+// requestID returns the id of hlog.RequestIDHandler, it is empty for requests that didn't pass it.
+func requestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	if id, ok := hlog.IDFromRequest(r); ok {
+		return id.String()
+	}
+	return ""
+}
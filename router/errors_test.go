@@ -0,0 +1,65 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorResponses(t *testing.T) {
+	router, _ := newSessionRouter(t, config.Config{})
+	admin := func(req *http.Request) *http.Request {
+		req.SetBasicAuth("alice", "alice-pw")
+		return req
+	}
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		status int
+		code   string
+	}{
+		{name: "unknown route", req: httptest.NewRequest("GET", "/api/v1/unknown", nil), status: http.StatusNotFound, code: CodeNotFound},
+		{name: "invite", req: httptest.NewRequest("GET", "/join/invalid", nil), status: http.StatusNotFound, code: CodeNotFound},
+		{name: "basic auth", req: httptest.NewRequest("GET", "/api/v1/stats", nil), status: http.StatusUnauthorized, code: CodeUnauthorized},
+		{name: "login", req: httptest.NewRequest("GET", "/api/v1/rooms/room/bans", nil), status: http.StatusUnauthorized, code: CodeUnauthorized},
+		{
+			name:   "invalid json",
+			req:    admin(httptest.NewRequest("POST", "/api/v1/broadcast", strings.NewReader("{"))),
+			status: http.StatusBadRequest,
+			code:   CodeBadRequest,
+		},
+		{
+			name:   "token",
+			req:    withBearer(httptest.NewRequest("GET", "/api/v1/stats", nil), "unknown"),
+			status: http.StatusUnauthorized,
+			code:   CodeUnauthorized,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, test.req)
+			require.Equal(t, test.status, recorder.Code)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+			var body ErrorResponse
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&body))
+			assert.Equal(t, test.code, body.Error.Code)
+			assert.NotEmpty(t, body.Error.Message)
+			assert.NotEmpty(t, body.Error.RequestID)
+			assert.Equal(t, recorder.Header().Get("X-Request-Id"), body.Error.RequestID)
+		})
+	}
+}
+
+func withBearer(req *http.Request, token string) *http.Request {
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
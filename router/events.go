@@ -0,0 +1,19 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/screego/server/ws"
+)
+
+func roomEvents(rooms *ws.Rooms) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		events, err := rooms.RoomEvents(mux.Vars(r)["id"])
+		if err != nil {
+			writeError(w, r, roomErrorStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	}
+}
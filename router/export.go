@@ -0,0 +1,82 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws"
+)
+
+// UserExport is the personal data of a user that screego keeps.
+type UserExport struct {
+	User     string    `json:"user"`
+	Exported time.Time `json:"exported"`
+	// Sessions are the stored login sessions, they are empty if the sessions are only kept in the cookies.
+	Sessions []ExportedSession `json:"sessions"`
+	// Rooms are the open rooms, closed rooms are in the audit log.
+	Rooms []ws.UserRoom `json:"rooms"`
+	// Audit are the entries of the audit log file where the user is the actor or the target.
+	Audit []audit.Entry `json:"audit"`
+}
+
+// ExportedSession is a login session without the key of the cookie.
+type ExportedSession struct {
+	ID       string    `json:"id"`
+	Provider string    `json:"provider"`
+	Role     string    `json:"role"`
+	Created  time.Time `json:"created"`
+	Expires  time.Time `json:"expires"`
+}
+
+// exportUser returns the data of the user of the path to admins and to the user itself. The source ips of the audit
+// entries are removed unless SCREEGO_EXPORT_INCLUDE_IP is set, in privacy mode they are pseudonyms anyway.
+func exportUser(conf config.Config, rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := mux.Vars(r)["name"]
+		sessions, err := users.Sessions(name)
+		if err != nil {
+			log.Error().Err(err).Msg("Export sessions")
+			writeError(w, r, http.StatusInternalServerError, "could not read the sessions")
+			return
+		}
+		entries, err := users.Audit.UserEntries(name)
+		if err != nil {
+			log.Error().Err(err).Msg("Export audit log")
+			writeError(w, r, http.StatusInternalServerError, "could not read the audit log")
+			return
+		}
+
+		userRooms, err := rooms.UserRooms(name)
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+
+		export := UserExport{User: name, Exported: time.Now().UTC(), Sessions: []ExportedSession{}, Rooms: userRooms, Audit: entries}
+		for _, session := range sessions {
+			export.Sessions = append(export.Sessions, ExportedSession{ID: session.ID, Provider: session.Provider,
+				Role: session.Role, Created: session.Created, Expires: session.Expires})
+		}
+		if !conf.ExportIncludeIP {
+			for i := range export.Audit {
+				export.Audit[i].SourceIP = ""
+			}
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"-export.json"))
+		writeJSON(w, http.StatusOK, export)
+	})
+	asAdmin := adminOnly(handler, users)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, hasToken := bearerToken(r); !hasToken && users.OwnAccount(r, mux.Vars(r)["name"]) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		asAdmin(w, r)
+	}
+}
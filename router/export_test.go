@@ -0,0 +1,76 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportUser(t *testing.T) {
+	router, users := newSessionRouter(t, config.Config{})
+	dir := t.TempDir()
+	sessions, err := auth.OpenSessionFile(filepath.Join(dir, "sessions.json"))
+	require.NoError(t, err)
+	users.UseSessionBackend(sessions)
+	users.Audit, err = audit.Open(filepath.Join(dir, "audit.log"))
+	require.NoError(t, err)
+	defer users.Audit.Close()
+
+	bob := sessionLogin(t, router, "bob")
+	admin := sessionLogin(t, router, "alice")
+	do := func(path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, withCookies("GET", path, cookies))
+		return recorder
+	}
+
+	for _, cookies := range [][]*http.Cookie{bob, admin} {
+		recorder := do("/api/v1/users/bob/export", cookies)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `attachment; filename="bob-export.json"`, recorder.Header().Get("Content-Disposition"))
+
+		var export UserExport
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &export))
+		assert.Equal(t, "bob", export.User)
+		require.Len(t, export.Sessions, 1)
+		assert.Equal(t, "password", export.Sessions[0].Provider)
+		require.Len(t, export.Audit, 1)
+		assert.Equal(t, audit.Login, export.Audit[0].EventType)
+		assert.Empty(t, export.Audit[0].SourceIP, "SCREEGO_EXPORT_INCLUDE_IP is false")
+		assert.Empty(t, export.Rooms)
+	}
+	assert.Equal(t, http.StatusUnauthorized, do("/api/v1/users/alice/export", bob).Code, "users may only export themselves")
+}
+
+func TestExportUser_otherUsers(t *testing.T) {
+	router, users := newUsersRouter(t, config.Config{}, auth.RoleUser)
+	var err error
+	users.Proxy, err = auth.NewProxyAuth(config.Config{ProxyAuthTrustedProxies: []string{"192.0.2.0/24"}, ProxyAuthUserHeader: "X-Forwarded-User"})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/api/v1/users/alice/export", nil)
+	req.SetBasicAuth("bob", "bob-pw")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "basic auth accepts all users without admins, but not here")
+
+	req = httptest.NewRequest("GET", "/api/v1/users/bob/export", nil)
+	req.Header.Set("X-Forwarded-User", "bob")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "the proxy user bob isn't bob of the users file")
+
+	req = httptest.NewRequest("GET", "/api/v1/users/carol/export", nil)
+	req.Header.Set("X-Forwarded-User", "carol")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusOK, recorder.Code, "users of other logins export themselves")
+}
@@ -0,0 +1,27 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/screego/server/config"
+)
+
+// externalOrigin is the scheme and host of SCREEGO_EXTERNAL_HOST without the base path, it is empty if the option is unset and the clients
+// should use the address they loaded the ui from. Without scheme in the option it is taken from the request.
+func externalOrigin(conf config.Config, r *http.Request, websocket bool) string {
+	if conf.ExternalHost == "" {
+		return ""
+	}
+	secure := isTLS(conf, r)
+	if conf.ExternalScheme != "" {
+		secure = conf.ExternalScheme == "https"
+	}
+	scheme := "http"
+	if websocket {
+		scheme = "ws"
+	}
+	if secure {
+		scheme += "s"
+	}
+	return scheme + "://" + conf.ExternalHost
+}
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalOrigin(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      config.Config
+		tls       bool
+		proto     string
+		websocket bool
+		expected  string
+	}{
+		{name: "unset", conf: config.Config{}, tls: true, expected: ""},
+		{name: "http", conf: config.Config{ExternalHost: "screego.example.org"}, expected: "http://screego.example.org"},
+		{name: "tls", conf: config.Config{ExternalHost: "screego.example.org"}, tls: true, websocket: true, expected: "wss://screego.example.org"},
+		{name: "proxy", conf: config.Config{ExternalHost: "screego.example.org", TrustProxyHeaders: true}, proto: "https", expected: "https://screego.example.org"},
+		{name: "untrusted proxy", conf: config.Config{ExternalHost: "screego.example.org"}, proto: "https", expected: "http://screego.example.org"},
+		{name: "scheme", conf: config.Config{ExternalHost: "screego.example.org:8443", ExternalScheme: "https"}, websocket: true, expected: "wss://screego.example.org:8443"},
+		{name: "scheme over tls", conf: config.Config{ExternalHost: "10.0.0.1", ExternalScheme: "http"}, tls: true, expected: "http://10.0.0.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/config", nil)
+			if test.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			req.Header.Set("X-Forwarded-Proto", test.proto)
+			assert.Equal(t, test.expected, externalOrigin(test.conf, req, test.websocket))
+		})
+	}
+}
+
+func TestRouter_externalHost(t *testing.T) {
+	router := newTestRouter(t, config.Config{BasePath: "/screego", ExternalHost: "screego.example.org", ExternalScheme: "https"})
+
+	var uiConfig UIConfig
+	require.NoError(t, json.NewDecoder(request(router, "GET", "/screego/config").Body).Decode(&uiConfig))
+	assert.Equal(t, "wss://screego.example.org/screego/stream", uiConfig.WebSocketURL)
+
+	router = newTestRouter(t, config.Config{})
+	uiConfig = UIConfig{}
+	require.NoError(t, json.NewDecoder(request(router, "GET", "/config").Body).Decode(&uiConfig))
+	assert.Empty(t, uiConfig.WebSocketURL, "the ui uses its own address")
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/screego/server/config"
+)
+
+// securityHeaders sets hardening headers on all responses. HSTS is only sent for requests over TLS, so that plain
+// HTTP setups used during development aren't locked out.
+func securityHeaders(conf config.Config) mux.MiddlewareFunc {
+	frameOptions := frameOptions(conf.ContentSecurityPolicy)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := w.Header()
+			header.Set("X-Content-Type-Options", "nosniff")
+			header.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			if conf.ContentSecurityPolicy != "" {
+				header.Set("Content-Security-Policy", conf.ContentSecurityPolicy)
+			}
+			if frameOptions != "" {
+				header.Set("X-Frame-Options", frameOptions)
+			}
+			if conf.HSTSMaxAge > 0 && isTLS(conf, r) {
+				header.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d", int64(conf.HSTSMaxAge.Seconds())))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// frameOptions returns the X-Frame-Options equivalent of the frame-ancestors directive for older browsers. It is
+// empty if the directive is missing or allows other origins, which X-Frame-Options cannot express.
+func frameOptions(csp string) string {
+	for _, directive := range strings.Split(csp, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "frame-ancestors") {
+			continue
+		}
+		switch strings.Join(fields[1:], " ") {
+		case "'none'":
+			return "DENY"
+		case "'self'":
+			return "SAMEORIGIN"
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func isTLS(conf config.Config, r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return conf.TrustProxyHeaders && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
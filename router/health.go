@@ -0,0 +1,104 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/turn"
+	"github.com/screego/server/ws"
+)
+
+const (
+	checkOK      = "ok"
+	checkFailed  = "failed"
+	checkSkipped = "skipped"
+)
+
+// deepHealthTTL is how long the result of a deep health check is reused, the turn self test allocates a relay.
+var deepHealthTTL = 10 * time.Second
+
+// HealthCheck is the status of a subsystem in a deep health check.
+type HealthCheck struct {
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+	RelayAddress string `json:"relayAddress,omitempty"`
+}
+
+// selfTester is implemented by the internal turn server.
+type selfTester interface {
+	SelfTest(ctx context.Context) (string, error)
+}
+
+type deepHealth struct {
+	conf       config.Config
+	rooms      *ws.Rooms
+	turnServer turn.Server
+
+	lock    sync.Mutex
+	result  *HealthResponse
+	expires time.Time
+	now     func() time.Time
+}
+
+// healthz reports that the process is alive, with ?deep=1 it checks the subsystems and fails if one of them fails.
+func healthz(conf config.Config, rooms *ws.Rooms, turnServer turn.Server) http.HandlerFunc {
+	deep := &deepHealth{conf: conf, rooms: rooms, turnServer: turnServer, now: time.Now}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("deep") != "1" {
+			writeJSON(w, http.StatusOK, &HealthResponse{Status: "ok", Region: conf.Region})
+			return
+		}
+		result := deep.get(r.Context())
+		status := http.StatusOK
+		if result.Status != checkOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, result)
+	}
+}
+
+// get returns the cached result or runs the checks, concurrent requests wait for the same run.
+func (d *deepHealth) get(ctx context.Context) *HealthResponse {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.now()
+	if d.result != nil && now.Before(d.expires) {
+		return d.result
+	}
+	d.result = d.check(ctx)
+	d.expires = now.Add(deepHealthTTL)
+	return d.result
+}
+
+func (d *deepHealth) check(ctx context.Context) *HealthResponse {
+	checks := map[string]HealthCheck{
+		"rooms": {Status: checkOK},
+		"turn":  d.checkTurn(ctx),
+	}
+	if !d.rooms.Ready() {
+		checks["rooms"] = HealthCheck{Status: checkFailed, Error: "shutting down"}
+	}
+	status := checkOK
+	for _, check := range checks {
+		if check.Status == checkFailed {
+			status = checkFailed
+		}
+	}
+	return &HealthResponse{Status: status, Region: d.conf.Region, Checks: checks}
+}
+
+func (d *deepHealth) checkTurn(ctx context.Context) HealthCheck {
+	tester, ok := d.turnServer.(selfTester)
+	if !ok {
+		// the external turn server isn't reachable with our credentials from here.
+		return HealthCheck{Status: checkSkipped}
+	}
+	relay, err := tester.SelfTest(ctx)
+	if err != nil {
+		return HealthCheck{Status: checkFailed, Error: err.Error()}
+	}
+	return HealthCheck{Status: checkOK, RelayAddress: relay}
+}
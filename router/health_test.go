@@ -0,0 +1,100 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/turn"
+	"github.com/screego/server/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type selfTestTurnServer struct {
+	turn.Server
+	err   error
+	calls int
+}
+
+func (s *selfTestTurnServer) SelfTest(ctx context.Context) (string, error) {
+	s.calls++
+	if s.err != nil {
+		return "", s.err
+	}
+	return "127.0.0.1:50000", nil
+}
+
+func deepHealthz(handler http.Handler) (int, HealthResponse) {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/healthz?deep=1", nil))
+	var response HealthResponse
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	return recorder.Code, response
+}
+
+func TestHealthz_deep(t *testing.T) {
+	users, err := auth.ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	rooms := ws.NewRooms(nil, users, ws.NewMemoryRoomStore(), config.Config{})
+	turnServer := &selfTestTurnServer{}
+	handler := healthz(config.Config{}, rooms, turnServer)
+
+	code, response := deepHealthz(handler)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthResponse{Status: "ok", Checks: map[string]HealthCheck{
+		"rooms": {Status: "ok"},
+		"turn":  {Status: "ok", RelayAddress: "127.0.0.1:50000"},
+	}}, response)
+
+	turnServer.err = errors.New("allocate: timeout")
+	code, _ = deepHealthz(handler)
+	assert.Equal(t, http.StatusOK, code, "the result is cached")
+	assert.Equal(t, 1, turnServer.calls)
+
+	code, _ = deepHealthz(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.RawQuery = ""
+		handler(w, r)
+	}))
+	assert.Equal(t, http.StatusOK, code, "the shallow check doesn't run the self test")
+	assert.Equal(t, 1, turnServer.calls)
+}
+
+func TestDeepHealth_failed(t *testing.T) {
+	users, err := auth.ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	rooms := ws.NewRooms(nil, users, ws.NewMemoryRoomStore(), config.Config{})
+	turnServer := &selfTestTurnServer{err: errors.New("allocate: timeout")}
+	now := time.Now()
+	deep := &deepHealth{rooms: rooms, turnServer: turnServer, now: func() time.Time { return now }}
+
+	result := deep.get(context.Background())
+	assert.Equal(t, "failed", result.Status)
+	assert.Equal(t, HealthCheck{Status: "failed", Error: "allocate: timeout"}, result.Checks["turn"])
+
+	turnServer.err = nil
+	rooms.NotReady()
+	now = now.Add(deepHealthTTL)
+	result = deep.get(context.Background())
+	assert.Equal(t, "failed", result.Status)
+	assert.Equal(t, HealthCheck{Status: "failed", Error: "shutting down"}, result.Checks["rooms"])
+	assert.Equal(t, "ok", result.Checks["turn"].Status)
+	assert.Equal(t, 2, turnServer.calls)
+}
+
+func TestDeepHealth_externalTurn(t *testing.T) {
+	users, err := auth.ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	rooms := ws.NewRooms(nil, users, ws.NewMemoryRoomStore(), config.Config{})
+	deep := &deepHealth{rooms: rooms, turnServer: &turn.ExternalServer{}, now: time.Now}
+
+	result := deep.get(context.Background())
+	assert.Equal(t, "ok", result.Status)
+	assert.Equal(t, HealthCheck{Status: "skipped"}, result.Checks["turn"])
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws"
+)
+
+type InviteResponse struct {
+	Token   string    `json:"token"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+func createInvite(conf config.Config, rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, loggedIn := users.CurrentUser(r)
+		if !loggedIn {
+			writeError(w, r, http.StatusUnauthorized, "you need to login")
+			return
+		}
+
+		token, expires, err := rooms.CreateInvite(mux.Vars(r)["id"], user)
+		if err != nil {
+			writeError(w, r, roomErrorStatus(err), err.Error())
+			return
+		}
+
+		rooms.Audit.Write(audit.Entry{
+			EventType:     audit.InviteCreate,
+			ActorUsername: user,
+			RoomID:        mux.Vars(r)["id"],
+			SourceIP:      audit.RemoteIP(r),
+			SessionID:     users.SessionID(r),
+		})
+		writeJSON(w, http.StatusOK, &InviteResponse{
+			Token:   token,
+			URL:     externalOrigin(conf, r, false) + conf.BasePath + "/join/" + token,
+			Expires: expires,
+		})
+	}
+}
+
+func revokeInvite(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, loggedIn := users.CurrentUser(r)
+		if !loggedIn {
+			writeError(w, r, http.StatusUnauthorized, "you need to login")
+			return
+		}
+
+		vars := mux.Vars(r)
+		if err := rooms.RevokeInvite(vars["id"], user, vars["token"]); err != nil {
+			writeError(w, r, roomErrorStatus(err), err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, &auth.Response{Message: "revoked"})
+	}
+}
+
+func joinInvite(rooms *ws.Rooms) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := mux.Vars(r)["token"]
+		roomID, err := rooms.ValidateInvite(token)
+		if err == ws.ErrStopped {
+			writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// relative, so it keeps working when screego is served on a sub path.
+		w.Header().Set("Location", "../?room="+url.QueryEscape(roomID)+"&invite="+url.QueryEscape(token))
+		w.WriteHeader(http.StatusFound)
+	}
+}
+
+func roomErrorStatus(err error) int {
+	switch err {
+	case ws.ErrRoomNotFound, ws.ErrBanNotFound:
+		return http.StatusNotFound
+	case ws.ErrNotRoomOwner:
+		return http.StatusForbidden
+	case ws.ErrStopped:
+		return http.StatusServiceUnavailable
+	case ws.ErrRevokeInvite:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/ws"
+)
+
+type LogoutAllResponse struct {
+	// Connections is the amount of websocket connections that were closed.
+	Connections int `json:"connections"`
+}
+
+type RevokeAllResponse struct {
+	// NotBefore is the time until which the revoked sessions were created.
+	NotBefore time.Time `json:"notBefore"`
+	// Connections is the amount of websocket connections of this instance that were closed.
+	Connections int `json:"connections"`
+}
+
+// logoutAll revokes all sessions of the logged in user, also the one of the request, and closes their websocket
+// connections.
+func logoutAll(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sid := users.SessionID(r)
+		if sid == "" {
+			writeError(w, r, http.StatusUnauthorized, "not logged in")
+			return
+		}
+		user, _ := users.CurrentUser(r)
+		revokeSessions(w, r, rooms, users, audit.Entry{ActorUsername: user, TargetUsername: user, SessionID: sid})
+	}
+}
+
+// adminLogoutAll revokes all sessions of the user of the path, the user doesn't have to exist in the users file.
+func adminLogoutAll(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		revokeSessions(w, r, rooms, users, audit.Entry{ActorUsername: adminUser(r), TargetUsername: mux.Vars(r)["user"]})
+	}
+}
+
+func revokeSessions(w http.ResponseWriter, r *http.Request, rooms *ws.Rooms, users *auth.Users, entry audit.Entry) {
+	if err := users.LogoutAll(entry.TargetUsername); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	closed, err := rooms.CloseSessions(entry.TargetUsername)
+	if err != nil {
+		writeError(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	entry.EventType = audit.LogoutAll
+	entry.SourceIP = audit.RemoteIP(r)
+	rooms.Audit.Write(entry)
+	writeJSON(w, http.StatusOK, &LogoutAllResponse{Connections: closed})
+}
+
+// revokeAll revokes the jwt sessions of all users, e.g. after the key leaked, and closes their websocket connections.
+func revokeAll(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		notBefore, err := users.RevokeAllSessions()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		closed, err := rooms.CloseAllSessions()
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		rooms.Audit.Write(audit.Entry{EventType: audit.RevokeAll, ActorUsername: adminUser(r), SourceIP: audit.RemoteIP(r)})
+		writeJSON(w, http.StatusOK, &RevokeAllResponse{NotBefore: notBefore, Connections: closed})
+	}
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// regionGatherer adds the region label to all gathered metrics. Metrics that already have a region label keep it,
+// duplicate label names would make the exposition invalid.
+type regionGatherer struct {
+	prometheus.Gatherer
+	region string
+}
+
+func (g regionGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			if hasLabel(metric, "region") {
+				continue
+			}
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String("region"), Value: proto.String(g.region)})
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+	}
+	return families, err
+}
+
+func hasLabel(metric *dto.Metric, name string) bool {
+	for _, label := range metric.Label {
+		if label.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func metricsGatherer(region string) prometheus.Gatherer {
+	if region == "" {
+		return prometheus.DefaultGatherer
+	}
+	return regionGatherer{Gatherer: prometheus.DefaultGatherer, region: region}
+}
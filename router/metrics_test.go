@@ -0,0 +1,29 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegionGatherer_existingLabel(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	peers := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_peers"}, []string{"region"})
+	peers.WithLabelValues("us-east").Set(2)
+	rooms := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_rooms"})
+	registry.MustRegister(peers, rooms)
+
+	families, err := regionGatherer{Gatherer: registry, region: "eu-central"}.Gather()
+	require.NoError(t, err)
+	labels := map[string][]string{}
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			for _, label := range metric.Label {
+				labels[family.GetName()] = append(labels[family.GetName()], label.GetName()+"="+label.GetValue())
+			}
+		}
+	}
+	assert.Equal(t, map[string][]string{"test_peers": {"region=us-east"}, "test_rooms": {"region=eu-central"}}, labels)
+}
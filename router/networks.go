@@ -0,0 +1,26 @@
+package router
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+)
+
+// restrictNetworks responds with 403 to clients whose ip isn't allowed by the networks.
+func restrictNetworks(handler http.HandlerFunc, networks *auth.Networks, trustProxyHeaders bool) http.HandlerFunc {
+	if networks == nil {
+		return handler
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := audit.ClientIP(r, trustProxyHeaders)
+		if ok, rule := networks.Check(net.ParseIP(ip)); !ok {
+			log.Info().Str("ip", ip).Str("rule", rule).Str("path", r.URL.Path).Msg("Login denied for the network")
+			writeError(w, r, http.StatusForbidden, "forbidden")
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,413 @@
+package router
+
+import (
+	"encoding"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/turn"
+	"github.com/screego/server/ws"
+)
+
+// Security schemes of the operations, an operation accepts any of its schemes.
+const (
+	securityBasic   = "basicAuth"
+	securitySession = "session"
+	securityToken   = "apiToken"
+)
+
+// operation documents an endpoint of the router, the schemas are generated from the types of the examples.
+type operation struct {
+	Summary  string
+	Security []string
+	// Request is an example of the json body.
+	Request interface{}
+	// Form are the fields of an url encoded form body, OptionalForm the fields that may be omitted.
+	Form         []string
+	OptionalForm []string
+	// Status of a successful response, defaults to 200.
+	Status int
+	// Response is an example of the json body of a successful response.
+	Response interface{}
+	// ContentType of a successful response that isn't json.
+	ContentType string
+	// Errors are the status codes of the error responses with an ErrorResponse body.
+	Errors []int
+	// MessageErrors have an auth.Response body instead, like the login of the UI.
+	MessageErrors bool
+}
+
+var exampleTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// operations are keyed by method and path template without the base path. The unversioned api is deprecated and
+// isn't documented.
+var operations = map[string]operation{
+	"POST /login": {
+		Summary:       "Log in with user name and password, the session is stored in the user cookie. Users with two-factor authentication also send the TOTP code, without it the response is 401 with totpRequired.",
+		Form:          []string{"user", "pass"},
+		OptionalForm:  []string{"code"},
+		Response:      auth.Response{Message: "authenticated"},
+		Errors:        []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusBadGateway},
+		MessageErrors: true,
+	},
+	"POST /logout": {
+		Summary: "Log out of the session.",
+	},
+	"POST /auth/logout-all": {
+		Summary:  "Revoke all sessions of the logged in user including the current one and close their websocket connections.",
+		Security: []string{securitySession},
+		Response: LogoutAllResponse{Connections: 2},
+		Errors:   []int{http.StatusUnauthorized, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /auth/oidc/login": {
+		Summary: "Start the login at the OpenID Connect provider.",
+		Status:  http.StatusFound,
+	},
+	"GET /auth/oidc/callback": {
+		Summary:     "Finish the login at the OpenID Connect provider.",
+		Status:      http.StatusFound,
+		ContentType: "text/html",
+	},
+	"GET /join/{token}": {
+		Summary: "Redirect an invite link to its room.",
+		Status:  http.StatusFound,
+		Errors:  []int{http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	"GET /version": {
+		Summary:  "The version of screego.",
+		Response: VersionResponse{Version: "1.10.0", Region: "eu-central"},
+	},
+	"GET /healthz": {
+		Summary: "Whether screego is running, with ?deep=1 the subsystems are checked and a turn relay is allocated. " +
+			"The deep result is cached for a few seconds.",
+		Response: HealthResponse{Status: "ok", Region: "eu-central", Checks: map[string]HealthCheck{
+			"rooms": {Status: "ok"}, "turn": {Status: "ok", RelayAddress: "203.0.113.7:50123"}}},
+		Errors: []int{http.StatusServiceUnavailable},
+	},
+	"GET /readyz": {
+		Summary:  "Whether screego accepts new connections, it fails once the shutdown started.",
+		Response: HealthResponse{Status: "ok", Region: "eu-central"},
+		Errors:   []int{http.StatusServiceUnavailable},
+	},
+	"GET /config": {
+		Summary: "The settings of the ui and the current user.",
+		Response: UIConfig{AuthMode: config.AuthModeTurn, User: "alice", LoggedIn: true, Version: "1.10.0",
+			RoomName: "funny-cat", RoomPasswordsEnabled: true, PasswordLogin: true},
+	},
+	"GET /metrics": {
+		Summary:     "Prometheus metrics.",
+		Security:    []string{securityBasic, securityToken},
+		ContentType: "text/plain",
+		Errors:      []int{http.StatusUnauthorized},
+	},
+	"POST /api/v1/rooms/{id}/invites": {
+		Summary:  "Create an invite link for a room of the current user.",
+		Security: []string{securitySession},
+		Response: InviteResponse{Token: "cm9vbXwxNzA0MTEwNDAw", URL: "/join/cm9vbXwxNzA0MTEwNDAw", Expires: exampleTime},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	"DELETE /api/v1/rooms/{id}/invites/{token}": {
+		Summary:  "Revoke an invite link.",
+		Security: []string{securitySession},
+		Response: auth.Response{Message: "revoked"},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/rooms/{id}/bans": {
+		Summary:  "The bans of a room of the current user, admins may list the bans of every room.",
+		Security: []string{securitySession},
+		Response: []ws.Ban{{User: "mallory", IP: "192.0.2.1", Expires: exampleTime}},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	"DELETE /api/v1/rooms/{id}/bans/{user}": {
+		Summary:  "Remove a ban, like the list only for the creator of the room and admins.",
+		Security: []string{securitySession},
+		Response: auth.Response{Message: "unbanned"},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/rooms": {
+		Summary:  "All rooms.",
+		Security: []string{securityBasic, securityToken},
+		Response: []ws.RoomSummary{{ID: "funny-cat", Mode: ws.ConnectionTURN, Users: 3, Streaming: 1, CreatedBy: "alice", CreatedAt: exampleTime}},
+		Errors:   []int{http.StatusUnauthorized, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/rooms/events": {
+		Summary:     "Server-sent events of created and closed rooms, resumable with the Last-Event-ID header.",
+		Security:    []string{securitySession, securityBasic, securityToken},
+		ContentType: "text/event-stream",
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized},
+	},
+	"GET /api/v1/admin/rooms/{id}/events": {
+		Summary:  "The latest events of a room.",
+		Security: []string{securityBasic, securityToken},
+		Response: []ws.RoomEvent{{Time: exampleTime, Type: "join", User: "bob"}},
+		Errors:   []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/stats": {
+		Summary:  "Connection, room and member counts.",
+		Security: []string{securityBasic, securityToken},
+		Response: StatsResponse{Stats: ws.Stats{Connections: 4, Rooms: 1, Members: 3}, Goroutines: 42, UsersLoadedAt: &exampleTime},
+		Errors:   []int{http.StatusUnauthorized, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/users/{name}/export": {
+		Summary:  "Download the personal data of a user: the stored login sessions, the open rooms and the audit log entries. Admins may export every user, other users only themselves.",
+		Security: []string{securityBasic, securityToken, securitySession},
+		Response: UserExport{User: "alice", Exported: exampleTime,
+			Sessions: []ExportedSession{{ID: "cmbq3k0b0ps0r5lsk4ag", Provider: "password", Role: auth.RoleUser, Created: exampleTime, Expires: exampleTime.Add(24 * time.Hour)}},
+			Rooms:    []ws.UserRoom{{ID: "funny-cat", CreatedAt: exampleTime, Owner: true, Connections: 1}},
+			Audit:    []audit.Entry{{Timestamp: exampleTime, EventType: audit.Login, ActorUsername: "alice", SourceIP: "192.0.2.1", SessionID: "cmbq3k0b0ps0r5lsk4ag", Outcome: audit.OutcomeSuccess}}},
+		Errors: []int{http.StatusUnauthorized, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"DELETE /api/v1/users/{name}": {
+		Summary: "Delete the personal data of a user: the entries of the users files, the login sessions, the connections and the audit log entries. " +
+			"Connected users get a you_were_deleted message and are disconnected with the close code 4011. Only admins may delete users.",
+		Security: []string{securityBasic, securityToken},
+		Response: UserDeleteResponse{User: "alice", UsersFiles: []string{"/etc/screego/users"}, Sessions: 2, Connections: 1, Rooms: 1, AuditEntries: 14},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/config": {
+		Summary:  "The effective configuration with redacted secrets and when the users files were loaded. Only admins and admin tokens may read it, every access is audited.",
+		Security: []string{securityBasic, securityToken, securitySession},
+		Response: ConfigResponse{Settings: map[string]interface{}{"SCREEGO_SERVER_ADDRESS": ":5050", "SCREEGO_SECRET": "[redacted]"},
+			UsersLoadedAt: &exampleTime},
+		Errors: []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	"GET /api/v1/turn/stats": {
+		Summary:  "Statistics of the internal TURN server.",
+		Security: []string{securityBasic, securityToken},
+		Response: turn.Stats{Allocations: 2, AllocationsRejected: 1, Permissions: 4, BytesIn: 1024, BytesOut: 2048,
+			Transports: map[string]turn.TransportStats{"udp": {Allocations: 2, AllocationsTotal: 10, BytesIn: 1024, BytesOut: 2048}}},
+		Errors: []int{http.StatusUnauthorized},
+	},
+	"GET /api/v1/turn/credentials": {
+		Summary:  "TURN credentials of the current user.",
+		Security: []string{securitySession, securityBasic, securityToken},
+		Response: TurnCredentialsResponse{Username: "alice", Password: "c2VjcmV0", Realm: "screego"},
+		Errors:   []int{http.StatusUnauthorized},
+	},
+	"POST /api/v1/broadcast": {
+		Summary:  "Send a notice to all members of all rooms.",
+		Security: []string{securityBasic, securityToken},
+		Request:  BroadcastRequest{Level: ws.BroadcastWarning, Message: "Screego restarts in 5 minutes."},
+		Response: BroadcastResponse{Recipients: 12},
+		Errors:   []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/admin/tokens": {
+		Summary:  "The names, roles and expiry of the API tokens.",
+		Security: []string{securityBasic, securityToken},
+		Response: []TokenResponse{{Name: "monitoring", Role: auth.TokenRoleReadOnly, Expires: &exampleTime}},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden},
+	},
+	"POST /api/v1/admin/users/{user}/logout-all": {
+		Summary:  "Revoke all sessions of the user and close their websocket connections.",
+		Security: []string{securityBasic, securityToken},
+		Response: LogoutAllResponse{Connections: 2},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"POST /api/v1/admin/sessions/revoke": {
+		Summary:  "Revoke the jwt sessions of all users and close their websocket connections on this instance.",
+		Security: []string{securityBasic, securityToken},
+		Response: RevokeAllResponse{NotBefore: exampleTime, Connections: 2},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError, http.StatusServiceUnavailable},
+	},
+	"GET /api/v1/openapi.json": {
+		Summary:  "This OpenAPI document.",
+		Response: map[string]interface{}{"openapi": "3.0.3"},
+	},
+}
+
+// openAPI serves the OpenAPI document of the router, spec is set after all routes have been registered.
+func openAPI(spec *map[string]interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, *spec)
+	}
+}
+
+var pathParameter = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// openAPISpec builds the OpenAPI document of the documented routes of the router.
+func openAPISpec(router *mux.Router, conf config.Config, version string) map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		template = strings.TrimPrefix(template, conf.BasePath)
+		path := pathParameter.ReplaceAllString(template, "{$1}")
+		for _, method := range methods {
+			op, ok := operations[method+" "+path]
+			if !ok {
+				continue
+			}
+			if paths[path] == nil {
+				paths[path] = map[string]interface{}{}
+			}
+			paths[path][strings.ToLower(method)] = op.spec(pathParameter.FindAllStringSubmatch(template, -1))
+		}
+		return nil
+	})
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Screego",
+			"version": version,
+			"license": map[string]interface{}{"name": "GPL-3.0", "url": "https://github.com/screego/server/blob/master/LICENSE"},
+		},
+		"servers": []interface{}{map[string]interface{}{"url": conf.BasePath + "/"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				securityBasic:   map[string]interface{}{"type": "http", "scheme": "basic", "description": "A user of the users file."},
+				securitySession: map[string]interface{}{"type": "apiKey", "in": "cookie", "name": "user", "description": "The session of POST /login."},
+				securityToken:   map[string]interface{}{"type": "http", "scheme": "bearer", "description": "An API token of SCREEGO_API_TOKENS or SCREEGO_API_TOKENS_FILE."},
+			},
+		},
+	}
+}
+
+func (op operation) spec(parameters [][]string) map[string]interface{} {
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if op.Response != nil {
+		success["content"] = jsonContent(op.Response)
+	} else if op.ContentType != "" {
+		success["content"] = map[string]interface{}{op.ContentType: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): success}
+	for _, code := range op.Errors {
+		var body interface{} = ErrorResponse{Error: ErrorDetail{
+			Code: errorCode(code), Message: strings.ToLower(http.StatusText(code)), RequestID: "cn1fp0gbm5u5ld9c3mpg",
+		}}
+		if op.MessageErrors {
+			body = auth.Response{Message: strings.ToLower(http.StatusText(code))}
+		}
+		responses[strconv.Itoa(code)] = map[string]interface{}{"description": http.StatusText(code), "content": jsonContent(body)}
+	}
+
+	spec := map[string]interface{}{"summary": op.Summary, "responses": responses}
+	var params []interface{}
+	for _, parameter := range parameters {
+		params = append(params, map[string]interface{}{
+			"name": parameter[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	if params != nil {
+		spec["parameters"] = params
+	}
+	if op.Request != nil {
+		spec["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(op.Request)}
+	}
+	if op.Form != nil {
+		properties := map[string]interface{}{}
+		for _, field := range append(append([]string{}, op.Form...), op.OptionalForm...) {
+			properties[field] = map[string]interface{}{"type": "string"}
+		}
+		spec["requestBody"] = map[string]interface{}{"required": true, "content": map[string]interface{}{
+			"application/x-www-form-urlencoded": map[string]interface{}{
+				"schema": map[string]interface{}{"type": "object", "properties": properties, "required": op.Form},
+			},
+		}}
+	}
+	if op.Security != nil {
+		var security []interface{}
+		for _, scheme := range op.Security {
+			security = append(security, map[string]interface{}{scheme: []string{}})
+		}
+		spec["security"] = security
+	}
+	return spec
+}
+
+func jsonContent(example interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{
+		"schema":  schemaOf(reflect.TypeOf(example)),
+		"example": example,
+	}}
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOf returns the schema of the json encoding of the type.
+func schemaOf(t reflect.Type) map[string]interface{} {
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t.Implements(textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaOf(t.Elem())
+		schema["nullable"] = true
+		return schema
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		addProperties(t, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// addProperties adds the json fields of the struct, fields of embedded structs are inlined like encoding/json does.
+func addProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if field.Anonymous && tag == "" {
+			addProperties(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type)
+		if options != "omitempty" {
+			*required = append(*required, name)
+		}
+	}
+}
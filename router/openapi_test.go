@@ -0,0 +1,73 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gorilla/mux"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	conf := config.Config{OpenAPIEnabled: true, BasePath: "/screego", RoomEventLogSize: 10, TurnAuth: config.TurnAuthUsers, Prometheus: true,
+		SessionMode: config.SessionModeJWT}
+	router := newTestRouter(t, conf)
+
+	response := request(router, "GET", "/screego/api/v1/openapi.json")
+	require.Equal(t, http.StatusOK, response.Code)
+	doc, err := openapi3.NewLoader().LoadFromData(response.Body.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, doc.Validate(context.Background()))
+
+	assert.Equal(t, "/screego/", doc.Servers[0].URL)
+	invites := doc.Paths.Find("/api/v1/rooms/{id}/invites").Post
+	require.NotNil(t, invites)
+	assert.Equal(t, "id", invites.Parameters[0].Value.Name)
+	assert.Equal(t, securitySession, firstScheme(invites.Security))
+	assert.NotNil(t, invites.Responses.Get(http.StatusOK).Value.Content.Get("application/json").Schema.Value.Properties["expires"])
+	broadcast := doc.Paths.Find("/api/v1/broadcast").Post
+	require.NotNil(t, broadcast)
+	assert.NotNil(t, broadcast.RequestBody)
+	assert.Equal(t, securityBasic, firstScheme(broadcast.Security))
+	assert.Nil(t, doc.Paths.Find("/api/stats"), "the unversioned api is not documented")
+}
+
+func firstScheme(security *openapi3.SecurityRequirements) string {
+	for scheme := range (*security)[0] {
+		return scheme
+	}
+	return ""
+}
+
+func TestOpenAPI_disabled(t *testing.T) {
+	router := newTestRouter(t, config.Config{})
+	assert.Equal(t, http.StatusNotFound, request(router, "GET", "/api/v1/openapi.json").Code)
+}
+
+func TestOpenAPI_allRoutesDocumented(t *testing.T) {
+	conf := config.Config{OpenAPIEnabled: true, RoomEventLogSize: 10, TurnAuth: config.TurnAuthUsers, Prometheus: true,
+		SessionMode: config.SessionModeJWT, CheckOrigin: func(string) bool { return true }}
+	users, err := auth.ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	router := Router(conf, ws.NewRooms(nil, users, ws.NewMemoryRoomStore(), conf), users, &auth.OIDC{}, nil, "test")
+
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		require.NoError(t, err)
+		methods, err := route.GetMethods()
+		if err != nil || (strings.HasPrefix(template, "/api/") && !strings.HasPrefix(template, "/api/v1/")) {
+			return nil
+		}
+		for _, method := range methods {
+			assert.Contains(t, operations, method+" "+template, "add the route to operations in openapi.go")
+		}
+		return nil
+	})
+}
@@ -3,15 +3,19 @@ package router
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog/hlog"
 	"github.com/rs/zerolog/log"
 	"github.com/screego/server/auth"
 	"github.com/screego/server/config"
+	"github.com/screego/server/logger"
+	"github.com/screego/server/turn"
 	"github.com/screego/server/ui"
 	"github.com/screego/server/ws"
 )
@@ -23,21 +27,114 @@ type UIConfig struct {
 	Version                  string `json:"version"`
 	RoomName                 string `json:"roomName"`
 	CloseRoomWhenOwnerLeaves bool   `json:"closeRoomWhenOwnerLeaves"`
+	RoomPasswordsEnabled     bool   `json:"roomPasswordsEnabled"`
+	BasePath                 string `json:"basePath"`
+	AllowGuestJoin           bool   `json:"allowGuestJoin"`
+	AllowGuestCreate         bool   `json:"allowGuestCreate"`
+	RequireAuthToShare       bool   `json:"requireAuthToShare"`
+	PasswordLogin            bool   `json:"passwordLogin"`
+	OIDCLogin                bool   `json:"oidcLogin"`
+	CanCreateRooms           bool   `json:"canCreateRooms"`
+	WebSocketURL             string `json:"webSocketUrl,omitempty"`
+	Region                   string `json:"region,omitempty"`
+	// HostLeavePolicy overrides closeRoomWhenOwnerLeaves if it is set.
+	HostLeavePolicy string `json:"hostLeavePolicy,omitempty"`
+	// E2EEncryption is whether peers may exchange keys with e2e_key_exchange messages.
+	E2EEncryption bool `json:"e2eEncryption"`
 }
 
-func Router(conf config.Config, rooms *ws.Rooms, users *auth.Users, version string) *mux.Router {
-	router := mux.NewRouter()
-	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+type VersionResponse struct {
+	Version string `json:"version"`
+	Region  string `json:"region,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	Region string `json:"region,omitempty"`
+	// Checks are only set for deep health checks.
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// Router returns the http handler, oidc is nil if OIDC login is disabled.
+func Router(conf config.Config, rooms *ws.Rooms, users *auth.Users, oidc *auth.OIDC, turnServer turn.Server, version string) *mux.Router {
+	root := mux.NewRouter()
+	requestIDs := hlog.RequestIDHandler("", "X-Request-Id")
+	// the middlewares don't run for unknown routes.
+	root.NotFoundHandler = requestIDs(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// https://github.com/gorilla/mux/issues/416
 		accessLogger(r, 404, 0, 0)
-	})
-	router.Use(hlog.AccessHandler(accessLogger))
-	router.Use(handlers.CORS(handlers.AllowedMethods([]string{"GET", "POST"}), handlers.AllowedOriginValidator(conf.CheckOrigin)))
+		writeError(w, r, http.StatusNotFound, "not found")
+	}))
+	root.Use(requestIDs)
+	root.Use(hlog.AccessHandler(accessLogger))
+	root.Use(securityHeaders(conf))
+	if users.Proxy != nil {
+		root.Use(users.ProxyLogin)
+	}
+	if users.SessionIdleTimeout > 0 {
+		root.Use(users.RenewSessions)
+	}
+	root.Use(handlers.CORS(handlers.AllowedMethods([]string{"GET", "POST", "DELETE"}), handlers.AllowedOriginValidator(conf.CheckOrigin)))
+
+	router := root
+	if conf.BasePath != "" {
+		root.Path(conf.BasePath).Handler(http.RedirectHandler(conf.BasePath+"/", http.StatusMovedPermanently))
+		router = root.PathPrefix(conf.BasePath).Subrouter()
+	}
+	// the networks are validated by the config.
+	loginNetworks, _ := auth.NewNetworks(conf.LoginAllowedNetworks, conf.LoginDeniedNetworks)
 	router.HandleFunc("/stream", rooms.Upgrade)
-	router.Methods("POST").Path("/login").HandlerFunc(users.Authenticate)
+	router.Methods("POST").Path("/login").HandlerFunc(restrictNetworks(users.Authenticate, loginNetworks, conf.TrustProxyHeaders))
 	router.Methods("POST").Path("/logout").HandlerFunc(users.Logout)
+	router.Methods("POST").Path("/auth/logout-all").HandlerFunc(logoutAll(rooms, users))
+	if oidc != nil {
+		router.Methods("GET").Path("/auth/oidc/login").HandlerFunc(restrictNetworks(oidc.Login, loginNetworks, conf.TrustProxyHeaders))
+		router.Methods("GET").Path("/auth/oidc/callback").HandlerFunc(oidc.Callback)
+	}
+
+	// new endpoints are only added to v1, it must be registered before the unversioned api.
+	v1 := router.PathPrefix("/api/v1").Subrouter()
+	registerAPI(v1, conf, rooms, users, turnServer)
+	v1.Methods("GET").Path("/rooms").Handler(basicAuth(listRooms(rooms), users))
+	v1.Methods("POST").Path("/broadcast").Handler(basicAuth(broadcast(rooms), users))
+	v1.Methods("GET").Path("/admin/tokens").Handler(basicAuth(listTokens(users), users))
+	v1.Methods("POST").Path("/admin/users/{user}/logout-all").Handler(basicAuth(adminLogoutAll(rooms, users), users))
+	if conf.SessionMode == config.SessionModeJWT {
+		v1.Methods("POST").Path("/admin/sessions/revoke").Handler(basicAuth(revokeAll(rooms, users), users))
+	}
+	var spec map[string]interface{}
+	if conf.OpenAPIEnabled {
+		v1.Methods("GET").Path("/openapi.json").HandlerFunc(openAPI(&spec))
+	}
+	if !conf.TurnExternal && conf.TurnAuth == config.TurnAuthUsers {
+		v1.Methods("GET").Path("/turn/credentials").HandlerFunc(turnCredentials(conf, users))
+	}
+
+	api := router.PathPrefix("/api").Subrouter()
+	if conf.APIDeprecationWarnings {
+		api.Use(deprecated(conf.BasePath))
+	}
+	registerAPI(api, conf, rooms, users, turnServer)
+
+	router.Methods("GET").Path("/join/{token}").HandlerFunc(joinInvite(rooms))
+	router.Methods("GET").Path("/version").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, &VersionResponse{Version: version, Region: conf.Region})
+	})
+	router.Methods("GET").Path("/healthz").HandlerFunc(healthz(conf, rooms, turnServer))
+	// readyz fails once the shutdown started, healthz keeps reporting that the process is alive.
+	router.Methods("GET").Path("/readyz").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rooms.Ready() {
+			writeError(w, r, http.StatusServiceUnavailable, "shutting down")
+			return
+		}
+		writeJSON(w, http.StatusOK, &HealthResponse{Status: "ok", Region: conf.Region})
+	})
 	router.Methods("GET").Path("/config").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, loggedIn := users.CurrentUser(r)
+		webSocketURL := ""
+		if origin := externalOrigin(conf, r, true); origin != "" {
+			webSocketURL = origin + conf.BasePath + "/stream"
+		}
 		_ = json.NewEncoder(w).Encode(&UIConfig{
 			AuthMode:                 conf.AuthMode,
 			LoggedIn:                 loggedIn,
@@ -45,20 +142,69 @@ func Router(conf config.Config, rooms *ws.Rooms, users *auth.Users, version stri
 			Version:                  version,
 			RoomName:                 rooms.RandRoomName(),
 			CloseRoomWhenOwnerLeaves: conf.CloseRoomWhenOwnerLeaves,
+			HostLeavePolicy:          conf.HostLeavePolicy,
+			E2EEncryption:            conf.E2EEncryption,
+			RoomPasswordsEnabled:     conf.RoomPasswordsEnabled,
+			BasePath:                 conf.BasePath,
+			AllowGuestJoin:           conf.AllowGuestJoin,
+			AllowGuestCreate:         conf.AllowGuestCreate,
+			RequireAuthToShare:       conf.RequireAuthToShare,
+			PasswordLogin:            !users.PasswordLoginDisabled,
+			OIDCLogin:                oidc != nil,
+			CanCreateRooms:           users.CanCreateRooms(users.FileUser(r)),
+			WebSocketURL:             webSocketURL,
+			Region:                   conf.Region,
 		})
 	})
 	if conf.Prometheus {
 		log.Info().Msg("Prometheus enabled")
-		router.Methods("GET").Path("/metrics").Handler(basicAuth(promhttp.Handler(), users))
+		metrics := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(metricsGatherer(conf.Region), promhttp.HandlerOpts{}))
+		router.Methods("GET").Path("/metrics").Handler(basicAuth(metrics, users))
 	}
 
-	ui.Register(router)
+	ui.Register(router, conf.BasePath)
+
+	if conf.OpenAPIEnabled {
+		spec = openAPISpec(root, conf, version)
+	}
+	return root
+}
+
+// registerAPI registers the endpoints that exist in the unversioned api and in v1.
+func registerAPI(api *mux.Router, conf config.Config, rooms *ws.Rooms, users *auth.Users, turnServer turn.Server) {
+	api.Methods("POST").Path("/rooms/{id}/invites").HandlerFunc(createInvite(conf, rooms, users))
+	api.Methods("DELETE").Path("/rooms/{id}/invites/{token}").HandlerFunc(revokeInvite(rooms, users))
+	api.Methods("GET").Path("/rooms/{id}/bans").HandlerFunc(listBans(rooms, users))
+	api.Methods("DELETE").Path("/rooms/{id}/bans/{user}").HandlerFunc(removeBan(rooms, users))
+	api.Methods("GET").Path("/stats").Handler(basicAuth(stats(rooms, users), users))
+	api.Methods("GET").Path("/config").Handler(adminOnly(configDump(conf, users), users))
+	api.Methods("GET").Path("/users/{name}/export").HandlerFunc(exportUser(conf, rooms, users))
+	api.Methods("DELETE").Path("/users/{name}").Handler(adminOnly(deleteUser(rooms, users), users))
+	api.Methods("GET").Path("/rooms/events").Handler(sessionOrBasicAuth(roomEventStream(rooms), users))
+	if !conf.TurnExternal {
+		api.Methods("GET").Path("/turn/stats").Handler(basicAuth(turnStats(turnServer), users))
+	}
+	if conf.RoomEventLogSize > 0 {
+		api.Methods("GET").Path("/admin/rooms/{id}/events").Handler(basicAuth(roomEvents(rooms), users))
+	}
+}
 
-	return router
+// deprecated marks responses of the unversioned api as deprecated and links to the v1 endpoint.
+func deprecated(basePath string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			successor := basePath + "/api/v1" + strings.TrimPrefix(r.URL.Path, basePath+"/api")
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func accessLogger(r *http.Request, status, size int, dur time.Duration) {
-	log.Debug().
+	logger.PrivacyLogger(r.Context()).Debug().
+		Str("request_id", requestID(r)).
 		Str("host", r.Host).
 		Int("status", status).
 		Int("size", size).
@@ -68,17 +214,29 @@ func accessLogger(r *http.Request, status, size int, dur time.Duration) {
 		Msg("HTTP")
 }
 
+// basicAuth allows admins of the users file and API tokens, the token is checked first. Sessions of admins are only
+// accepted for GET requests, so that other sites cannot make the browser of an admin change something.
 func basicAuth(handler http.Handler, users *auth.Users) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if value, ok := bearerToken(r); ok {
+			tokenAuth(handler, users, value, w, r)
+			return
+		}
+		if _, _, hasBasicAuth := r.BasicAuth(); !hasBasicAuth && (r.Method == "GET" || r.Method == "HEAD") &&
+			users.CurrentRole(r) == auth.RoleAdmin {
+			user, _ := users.CurrentUser(r)
+			handler.ServeHTTP(w, withAdmin(r, user))
+			return
+		}
+
 		user, pass, ok := r.BasicAuth()
 
 		if !ok || !users.Validate(user, pass) {
 			w.Header().Set("WWW-Authenticate", `Basic realm="screego"`)
-			w.WriteHeader(401)
-			_, _ = w.Write([]byte("Unauthorized.\n"))
+			writeError(w, r, http.StatusUnauthorized, "unauthorized")
 			return
 		}
 
-		handler.ServeHTTP(w, r)
+		handler.ServeHTTP(w, withAdmin(r, user))
 	}
 }
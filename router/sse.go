@@ -0,0 +1,101 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/ws"
+)
+
+// sseKeepAlive is the interval of comments that keep idle streams open through proxies.
+var sseKeepAlive = 30 * time.Second
+
+// roomEventStream streams the room events as server-sent events. Clients resume with the Last-Event-ID header, the
+// stream ends when the server shuts down.
+func roomEventStream(rooms *ws.Rooms) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, r, http.StatusInternalServerError, "streaming is not supported")
+			return
+		}
+
+		var lastID uint64
+		if header := r.Header.Get("Last-Event-ID"); header != "" {
+			id, err := strconv.ParseUint(header, 10, 64)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, "invalid Last-Event-ID")
+				return
+			}
+			lastID = id
+		}
+
+		missed, events, cancel := rooms.SubscribeEvents(lastID)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// disables the response buffering of nginx.
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		for _, event := range missed {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event ws.FeedEvent) error {
+	data, err := json.Marshal(event.WebhookEvent)
+	if err != nil {
+		log.Error().Err(err).Msg("Room event encode")
+		return nil
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Event, data)
+	return err
+}
+
+// sessionOrBasicAuth allows logged in users and requests with the basic auth credentials of a user.
+func sessionOrBasicAuth(handler http.Handler, users *auth.Users) http.HandlerFunc {
+	withBasicAuth := basicAuth(handler, users)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, hasToken := bearerToken(r); hasToken {
+			withBasicAuth(w, r)
+			return
+		}
+		if _, loggedIn := users.CurrentUser(r); loggedIn {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		withBasicAuth(w, r)
+	}
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newStreamServer(t *testing.T) (*ws.Rooms, *httptest.Server) {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "users")
+	require.NoError(t, os.WriteFile(path, []byte("admin:"+string(hash)), 0o600))
+	users, err := auth.ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+
+	conf := config.Config{AuthMode: config.AuthModeNone, CheckOrigin: func(string) bool { return true },
+		WSSendQueueSize: 10, WSWriteTimeout: time.Second, WSPingInterval: time.Minute, WSPongTimeout: time.Minute, WSMaxMessageSize: 1024}
+	rooms := ws.NewRooms(nil, users, ws.NewMemoryRoomStore(), conf)
+	go rooms.Start()
+	server := httptest.NewServer(Router(conf, rooms, users, nil, nil, "test"))
+	t.Cleanup(server.Close)
+	return rooms, server
+}
+
+func openStream(t *testing.T, server *httptest.Server, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, err := http.NewRequest("GET", server.URL+"/api/rooms/events", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("admin", "pw")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent returns the lines of the next event.
+func readEvent(t *testing.T, reader *bufio.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestRoomEventStream(t *testing.T) {
+	rooms, server := newStreamServer(t)
+	stream := openStream(t, server, "")
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/stream"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"create","payload":{"id":"room","mode":"local"}}`)))
+
+	assert.Equal(t, []string{"id: 1", "event: room.created"}, readEvent(t, stream)[:2])
+	joined := readEvent(t, stream)
+	assert.Equal(t, []string{"id: 2", "event: user.joined"}, joined[:2])
+	assert.Contains(t, joined[2], `"room":"room"`)
+
+	resumed := openStream(t, server, "1")
+	assert.Equal(t, "id: 2", readEvent(t, resumed)[0])
+
+	require.NoError(t, rooms.Stop(context.Background()))
+	var ids []string
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if strings.HasPrefix(line, "event: ") {
+			ids = append(ids, strings.TrimSpace(strings.TrimPrefix(line, "event: ")))
+		}
+	}
+	assert.Contains(t, ids, "room.closed", "pending events are flushed before the stream ends")
+}
+
+func TestRoomEventStream_auth(t *testing.T) {
+	_, server := newStreamServer(t)
+
+	resp, err := http.Get(server.URL + "/api/rooms/events")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest("GET", server.URL+"/api/rooms/events", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("admin", "pw")
+	req.Header.Set("Last-Event-ID", "abc")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -0,0 +1,70 @@
+package router
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/screego/server/auth"
+	"github.com/screego/server/turn"
+	"github.com/screego/server/ws"
+)
+
+const statsCacheDuration = time.Second
+
+type StatsResponse struct {
+	ws.Stats
+	Goroutines int `json:"goroutines"`
+	// UsersLoadedAt is the last successful (re)load of the users file.
+	UsersLoadedAt *time.Time `json:"usersLoadedAt,omitempty"`
+}
+
+// stats returns the stats of the rooms. The result is cached, so the endpoint can't be used to stall the rooms event
+// loop.
+func stats(rooms *ws.Rooms, users *auth.Users) http.HandlerFunc {
+	var lock sync.Mutex
+	var cached StatsResponse
+	var updated time.Time
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		if time.Since(updated) >= statsCacheDuration {
+			stats, err := rooms.Stats()
+			if err != nil {
+				lock.Unlock()
+				writeError(w, r, http.StatusServiceUnavailable, err.Error())
+				return
+			}
+			cached = StatsResponse{Stats: stats, Goroutines: runtime.NumGoroutine()}
+			if loadedAt := users.LoadedAt(); !loadedAt.IsZero() {
+				cached.UsersLoadedAt = &loadedAt
+			}
+			updated = time.Now()
+		}
+		response := cached
+		lock.Unlock()
+
+		writeJSON(w, http.StatusOK, &response)
+	}
+}
+
+// turnStats returns the stats of the internal TURN server, they are read from atomic counters and aren't cached.
+func turnStats(turnServer turn.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := turnServer.Stats()
+		writeJSON(w, http.StatusOK, &stats)
+	}
+}
+
+// listRooms returns all rooms, unlike stats it isn't cached because it is only used by administrators.
+func listRooms(rooms *ws.Rooms) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := rooms.RoomList()
+		if err != nil {
+			writeError(w, r, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
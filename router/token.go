@@ -0,0 +1,84 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+)
+
+type TokenResponse struct {
+	Name    string     `json:"name"`
+	Role    string     `json:"role"`
+	Expires *time.Time `json:"expires,omitempty"`
+	Expired bool       `json:"expired"`
+}
+
+type adminKey struct{}
+
+// withAdmin stores the name of the admin, it is the user of the basic auth or token:<name> for API tokens.
+func withAdmin(r *http.Request, name string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), adminKey{}, name))
+}
+
+// adminUser returns the name of the admin that passed basicAuth.
+func adminUser(r *http.Request) string {
+	name, _ := r.Context().Value(adminKey{}).(string)
+	return name
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, value, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(value), true
+}
+
+// tokenAuth serves the request if the API token is valid and its role allows the method. The name of the token is
+// logged, never the token.
+func tokenAuth(handler http.Handler, users *auth.Users, value string, w http.ResponseWriter, r *http.Request) {
+	token, ok := users.Tokens.Lookup(value)
+	if !ok {
+		log.Info().Str("ip", audit.RemoteIP(r)).Str("path", r.URL.Path).Msg("Unknown API token")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="screego"`)
+		writeError(w, r, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	if token.Expired(time.Now()) {
+		log.Info().Str("token", token.Name).Str("ip", audit.RemoteIP(r)).Str("path", r.URL.Path).Msg("Expired API token")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="screego", error="invalid_token"`)
+		writeError(w, r, http.StatusUnauthorized, "token expired")
+		return
+	}
+	if !token.Allows(r.Method) {
+		log.Info().Str("token", token.Name).Str("role", token.Role).Str("method", r.Method).Str("path", r.URL.Path).Msg("API token not allowed")
+		writeError(w, r, http.StatusForbidden, "the role of the token may not use this endpoint")
+		return
+	}
+
+	log.Info().Str("token", token.Name).Str("role", token.Role).Str("ip", audit.RemoteIP(r)).Str("method", r.Method).
+		Str("path", r.URL.Path).Msg("API token used")
+	handler.ServeHTTP(w, withAdmin(r, "token:"+token.Name))
+}
+
+// listTokens returns the names and expiry of the API tokens, but not the tokens.
+func listTokens(users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+		result := []TokenResponse{}
+		for _, token := range users.Tokens.List() {
+			response := TokenResponse{Name: token.Name, Role: token.Role, Expired: token.Expired(now)}
+			if !token.Expires.IsZero() {
+				expires := token.Expires
+				response.Expires = &expires
+			}
+			result = append(result, response)
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,30 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+)
+
+type TurnCredentialsResponse struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Realm    string `json:"realm"`
+}
+
+// turnCredentials returns the TURN credentials of the logged in user, they are accepted with the users TURN auth.
+func turnCredentials(conf config.Config, users *auth.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, loggedIn := users.CurrentUser(r)
+		if basicUser, pass, ok := r.BasicAuth(); !loggedIn && ok && users.Validate(basicUser, pass) {
+			user, loggedIn = basicUser, true
+		}
+		password, exists := users.TURNPassword(user)
+		if !loggedIn || !exists {
+			writeError(w, r, http.StatusUnauthorized, "you need to login")
+			return
+		}
+		writeJSON(w, http.StatusOK, &TurnCredentialsResponse{Username: user, Password: password, Realm: conf.TurnRealm})
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Option configures Start.
+type Option func(*options)
+
+type options struct {
+	lifecycle
+	keyPassword string
+}
+
+// WithTLSKeyPassword decrypts the TLS key with the password.
+func WithTLSKeyPassword(password string) Option {
+	return func(o *options) {
+		o.keyPassword = password
+	}
+}
+
+// WithShutdownHook runs fn when the server shuts down, before the http servers are shut down. The hooks run one after
+// another in ascending priority, hooks with the same priority in the order they were added. Every hook gets its own
+// timeout, a slow hook doesn't shorten the time of the following ones.
+func WithShutdownHook(priority int, fn func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.hooks = append(o.hooks, shutdownHook{priority: priority, fn: fn})
+	}
+}
+
+// WithShutdownDelay waits for the delay after the interrupt signal before the shutdown starts, the server keeps serving
+// requests meanwhile. notReady is called right after the signal, e.g. to fail the readiness probe, so that load
+// balancers like the Kubernetes endpoints stop sending new requests before the server goes away.
+func WithShutdownDelay(delay time.Duration, notReady func()) Option {
+	return func(o *options) {
+		o.delay = delay
+		o.notReady = notReady
+	}
+}
+
+type shutdownHook struct {
+	priority int
+	fn       func(ctx context.Context) error
+}
+
+// lifecycle runs the shutdown hooks in the order of their priority.
+type lifecycle struct {
+	hooks    []shutdownHook
+	timeout  time.Duration
+	delay    time.Duration
+	notReady func()
+}
+
+func newOptions(timeout time.Duration, opts []Option) *options {
+	o := &options{lifecycle: lifecycle{timeout: timeout}}
+	for _, opt := range opts {
+		opt(o)
+	}
+	sort.SliceStable(o.hooks, func(i, j int) bool {
+		return o.hooks[i].priority < o.hooks[j].priority
+	})
+	return o
+}
+
+func newLifecycle(timeout time.Duration, opts []Option) *lifecycle {
+	return &newOptions(timeout, opts).lifecycle
+}
+
+// wait marks the server as not ready and waits for the shutdown delay.
+func (l *lifecycle) wait() {
+	if l.notReady != nil {
+		l.notReady()
+	}
+	if l.delay > 0 {
+		log.Info().Str("delay", l.delay.String()).Msg("Waiting before shutdown")
+		time.Sleep(l.delay)
+	}
+}
+
+// shutdown runs all hooks, errors are logged and don't stop the following hooks.
+func (l *lifecycle) shutdown() {
+	for _, hook := range l.hooks {
+		ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+		err := hook.fn(ctx)
+		cancel()
+		if err != nil {
+			log.Warn().Err(err).Int("priority", hook.priority).Msg("Shutdown hook")
+		}
+	}
+}
@@ -2,17 +2,26 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"os/user"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 )
 
+// shutdownTimeout is the time of every shutdown hook and of the shutdown of the http servers.
+const shutdownTimeout = 2 * time.Second
+
 var (
 	notifySignal   = signal.Notify
 	serverShutdown = func(server *http.Server, ctx context.Context) error {
@@ -26,12 +35,21 @@ var (
 // @param address string: 本机的 ip 地址
 // @param cert string: cert 参数表示 SSL/TLS 证书文件的路径
 // @param key string: 私钥文件的路径
+// @param redirectAddress string: 重定向到 HTTPS 的 HTTP 监听地址，为空时不启动
+// @param socket UnixSocket: unix socket 的权限和所有者
+// @param opts ...Option: 例如 WithShutdownHook，关闭 http 服务前按优先级调用的钩子
 // @return error: 返回错误码
-func Start(mux *mux.Router, address, cert, key string) error {
+func Start(mux *mux.Router, address, cert, key, redirectAddress string, socket UnixSocket, opts ...Option) error {
+	// 每个服务最多发送两个错误（serve 和 shutdown），缓冲避免未读取的发送阻塞
+	shutdown := make(chan error, 4)
+	o := newOptions(shutdownTimeout, opts)
 	// 服务开启
-	server, shutdown := startServer(mux, address, cert, key)
+	servers := []*http.Server{startServer(mux, address, tlsFiles{cert: cert, key: key, password: o.keyPassword}, socket, shutdown)}
+	if redirectAddress != "" {
+		servers = append(servers, startRedirectServer(redirectAddress, address, shutdown))
+	}
 	// 因中断信号关闭服务的处理
-	shutdownOnInterruptSignal(server, 2*time.Second, shutdown)
+	shutdownOnInterruptSignal(servers, &o.lifecycle, shutdown)
 	// 报错处理，等待 server 关闭
 	return waitForServerToClose(shutdown)
 }
@@ -40,65 +58,217 @@ func Start(mux *mux.Router, address, cert, key string) error {
 //
 // @param mux *mux.Router: gorilla/mux 包提供的一个路由器类型的指针
 // @param address string: 本机的 ip 地址
-// @param cert string: cert 参数表示 SSL/TLS 证书文件的路径
-// @param key string: 私钥文件的路径
+// @param files tlsFiles: SSL/TLS 证书和私钥文件的路径，以及私钥的密码
+// @param socket UnixSocket: unix socket 的权限和所有者
+// @param shutdown chan<- error: 用于传递 error 类型的通道。
 // @return *http.Server: 一个指向 http.Server 类型的指针。
-// @return chan error: 用于传递 error 类型的通道。
-func startServer(mux *mux.Router, address, cert, key string) (*http.Server, chan error) {
+func startServer(mux *mux.Router, address string, files tlsFiles, socket UnixSocket, shutdown chan<- error) *http.Server {
 	// 根据 ip 和路由器类，创建一个 http.Server 实例
 	srv := &http.Server{
 		Addr:    address,
 		Handler: mux,
 	}
 
-	// 创建传递 error 信息的通道
-	shutdown := make(chan error)
 	// 启动一个 goroutine 来运行 listenAndServe 函数。
 	go func() {
 		// 如果得到错误信息，传递到错误通道
-		err := listenAndServe(srv, address, cert, key)
+		err := listenAndServe(srv, address, files, socket)
 		shutdown <- err
 	}()
-	return srv, shutdown
+	return srv
+}
+
+// 开启只把请求重定向到 HTTPS 的 HTTP 服务
+func startRedirectServer(address, httpsAddress string, shutdown chan<- error) *http.Server {
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           redirectToHTTPS(httpsAddress),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		shutdown <- listenAndServe(srv, address, tlsFiles{}, UnixSocket{})
+	}()
+	return srv
+}
+
+// redirectToHTTPS redirects to the same host and path on the port of the https address. The default https port is used
+// for unix sockets, they are usually behind a proxy.
+func redirectToHTTPS(httpsAddress string) http.Handler {
+	port := "443"
+	if _, p, err := net.SplitHostPort(httpsAddress); err == nil && p != "" && !strings.HasPrefix(httpsAddress, "unix:") {
+		port = p
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
 }
 
 // 
-func listenAndServe(srv *http.Server, address, cert, key string) error {
+func listenAndServe(srv *http.Server, address string, files tlsFiles, socket UnixSocket) error {
 	var err error
 	var listener net.Listener
 
 	// 根据地址前缀（unix: 或 tcp）创建一个网络监听器。
 	if strings.HasPrefix(address, "unix:") {
-		listener, err = net.Listen("unix", strings.TrimPrefix(address, "unix:"))
+		path := strings.TrimPrefix(address, "unix:")
+		listener, err = net.Listen("unix", path)
+		if err == nil {
+			// 在开始服务前设置 socket 的权限和所有者，失败时关闭监听器（同时删除 socket 文件）
+			if err := socket.apply(path); err != nil {
+				listener.Close()
+				return err
+			}
+		}
 	} else {
 		listener, err = net.Listen("tcp", address)
 	}
 	if err != nil {
-		return err
+		return bindError(address, err)
 	}
 
 	// 如果提供了证书和密钥，将启动 HTTPS 服务器，否则启动 HTTP 服务器。
-	if cert != "" || key != "" {
+	if files.cert != "" || files.key != "" {
+		// 私钥有密码时先解密，ServeTLS 无法读取加密的私钥
+		if files.password != "" {
+			certificate, err := loadKeyPair(files.cert, files.key, files.password)
+			if err != nil {
+				listener.Close()
+				return err
+			}
+			srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+			files.cert, files.key = "", ""
+		}
 		log.Info().Str("addr", address).Msg("Start HTTP with tls")
-		return srv.ServeTLS(listener, cert, key)
+		return srv.ServeTLS(listener, files.cert, files.key)
 	} else {
 		log.Info().Str("addr", address).Msg("Start HTTP")
 		return srv.Serve(listener)
 	}
 }
 
+// UnixSocket configures the permissions of the unix socket. Empty values leave the defaults untouched.
+// unix socket 的权限和所有者，为空时保持默认值
+type UnixSocket struct {
+	Mode  os.FileMode
+	Owner string
+	Group string
+}
+
+// 设置 socket 文件的权限和所有者
+func (s UnixSocket) apply(path string) error {
+	uid, err := lookupID(s.Owner, func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid unix socket owner %q: %w", s.Owner, err)
+	}
+	gid, err := lookupID(s.Group, func(name string) (string, error) {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return g.Gid, nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid unix socket group %q: %w", s.Group, err)
+	}
+
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(path, uid, gid); err != nil {
+			if errors.Is(err, syscall.EPERM) {
+				return fmt.Errorf("could not change owner of unix socket %s, the process needs root or the CAP_CHOWN capability: %w", path, err)
+			}
+			return fmt.Errorf("could not change owner of unix socket %s: %w", path, err)
+		}
+	}
+	if s.Mode != 0 {
+		if err := os.Chmod(path, s.Mode); err != nil {
+			return fmt.Errorf("could not change mode of unix socket %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// 将用户名/组名或数字 id 转换为 id，为空时返回 -1（os.Chown 不修改）
+func lookupID(value string, lookup func(name string) (string, error)) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	id, err := lookup(value)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
+
+// BindError is returned when the http server cannot listen on its address. 监听地址失败时返回的错误
+type BindError struct {
+	Address string
+	Hint    string
+	Err     error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("could not listen on %s: %s", e.Address, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// 根据错误类型为监听错误添加修复提示
+func bindError(address string, err error) error {
+	bindErr := &BindError{Address: address, Err: err}
+	unix := strings.HasPrefix(address, "unix:")
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE) && unix:
+		bindErr.Hint = "the socket file already exists, remove it if no other process is using it"
+	case errors.Is(err, syscall.EADDRINUSE):
+		bindErr.Hint = "the address is already in use by another process, stop it or change SCREEGO_SERVER_ADDRESS"
+	case errors.Is(err, syscall.EACCES) && unix:
+		bindErr.Hint = "permission denied, make sure the socket directory is writable by the screego user"
+	case errors.Is(err, syscall.EACCES):
+		bindErr.Hint = "permission denied, use a port above 1023 or grant the CAP_NET_BIND_SERVICE capability"
+	case unix:
+		bindErr.Hint = "invalid unix socket path, make sure the directory exists and the path is not too long"
+	default:
+		bindErr.Hint = "check the format of SCREEGO_SERVER_ADDRESS, it must be host:port or unix:/path/to/socket"
+	}
+	return bindErr
+}
+
 // 接受中断信号的处理函数
-func shutdownOnInterruptSignal(server *http.Server, timeout time.Duration, shutdown chan<- error) {
+func shutdownOnInterruptSignal(servers []*http.Server, lifecycle *lifecycle, shutdown chan<- error) {
 	interrupt := make(chan os.Signal, 1)
-	notifySignal(interrupt, os.Interrupt)
+	notifySignal(interrupt, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-interrupt
 		log.Info().Msg("Received interrupt. Shutting down...")
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		// 关闭前等待，期间继续处理请求，但就绪探针已失败
+		lifecycle.wait()
+		// 先执行关闭钩子，例如关闭 websocket 连接，它们不会被 http 服务关闭
+		lifecycle.shutdown()
+		ctx, cancel := context.WithTimeout(context.Background(), lifecycle.timeout)
 		defer cancel()
-		if err := serverShutdown(server, ctx); err != nil {
-			shutdown <- err
+		for _, server := range servers {
+			if err := serverShutdown(server, ctx); err != nil {
+				shutdown <- err
+			}
 		}
 	}()
 }
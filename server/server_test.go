@@ -5,13 +5,16 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShutdownOnErrorWhileShutdown(t *testing.T) {
@@ -25,7 +28,7 @@ func TestShutdownOnErrorWhileShutdown(t *testing.T) {
 	finished := make(chan error)
 
 	go func() {
-		finished <- Start(mux.NewRouter(), ":"+strconv.Itoa(port()), "", "")
+		finished <- Start(mux.NewRouter(), ":"+strconv.Itoa(port()), "", "", "", UnixSocket{})
 	}()
 
 	select {
@@ -40,7 +43,7 @@ func TestShutdownAfterError(t *testing.T) {
 	finished := make(chan error)
 
 	go func() {
-		finished <- Start(mux.NewRouter(), ":-5", "", "")
+		finished <- Start(mux.NewRouter(), ":-5", "", "", "", UnixSocket{})
 	}()
 
 	select {
@@ -58,7 +61,7 @@ func TestShutdown(t *testing.T) {
 	finished := make(chan error)
 
 	go func() {
-		finished <- Start(mux.NewRouter(), ":"+strconv.Itoa(port()), "", "")
+		finished <- Start(mux.NewRouter(), ":"+strconv.Itoa(port()), "", "", "", UnixSocket{})
 	}()
 
 	select {
@@ -69,6 +72,58 @@ func TestShutdown(t *testing.T) {
 	}
 }
 
+func TestShutdown_hook(t *testing.T) {
+	dispose := fakeInterrupt(t)
+	defer dispose()
+
+	called := make(chan struct{}, 1)
+	hook := func(ctx context.Context) error {
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		called <- struct{}{}
+		return errors.New("ignored")
+	}
+
+	err := Start(mux.NewRouter(), ":"+strconv.Itoa(port()), "", "", "", UnixSocket{}, WithShutdownHook(0, hook))
+	assert.Nil(t, err)
+	assert.Len(t, called, 1)
+}
+
+func TestShutdown_hookOrder(t *testing.T) {
+	var order []int
+	hook := func(id int) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			order = append(order, id)
+			return nil
+		}
+	}
+
+	newLifecycle(time.Second, []Option{
+		WithShutdownHook(2, hook(3)),
+		WithShutdownHook(1, hook(1)),
+		WithShutdownHook(1, hook(2)),
+	}).shutdown()
+	assert.Equal(t, []int{1, 2, 3}, order)
+}
+
+func TestShutdown_delay(t *testing.T) {
+	dispose := fakeInterrupt(t)
+	defer dispose()
+
+	var notReady, hookCalled time.Time
+	start := time.Now()
+	err := Start(mux.NewRouter(), ":"+strconv.Itoa(port()), "", "", "", UnixSocket{},
+		WithShutdownDelay(200*time.Millisecond, func() { notReady = time.Now() }),
+		WithShutdownHook(0, func(ctx context.Context) error {
+			hookCalled = time.Now()
+			return nil
+		}))
+	assert.Nil(t, err)
+	require.False(t, notReady.IsZero())
+	assert.True(t, notReady.After(start))
+	assert.GreaterOrEqual(t, hookCalled.Sub(notReady), 200*time.Millisecond, "the hooks run after the delay")
+}
+
 func fakeInterrupt(t *testing.T) func() {
 	oldNotify := notifySignal
 	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) {
@@ -106,3 +161,103 @@ func port() int {
 	defer l.Close()
 	return l.Addr().(*net.TCPAddr).Port
 }
+
+func TestBindError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	err = Start(mux.NewRouter(), listener.Addr().String(), "", "", "", UnixSocket{})
+
+	var bindErr *BindError
+	if assert.True(t, errors.As(err, &bindErr)) {
+		assert.Equal(t, listener.Addr().String(), bindErr.Address)
+		assert.Contains(t, bindErr.Hint, "already in use")
+	}
+}
+
+func TestBindError_unixSocketPath(t *testing.T) {
+	err := Start(mux.NewRouter(), "unix:/does/not/exist/screego.sock", "", "", "", UnixSocket{})
+
+	var bindErr *BindError
+	if assert.True(t, errors.As(err, &bindErr)) {
+		assert.Contains(t, bindErr.Hint, "invalid unix socket path")
+	}
+}
+
+func TestUnixSocket_apply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "screego.sock")
+	listener, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	socket := UnixSocket{Mode: 0o660, Owner: strconv.Itoa(os.Getuid()), Group: strconv.Itoa(os.Getgid())}
+	assert.NoError(t, socket.apply(path))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+}
+
+func TestUnixSocket_unknownOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "screego.sock")
+	err := Start(mux.NewRouter(), "unix:"+path, "", "", "", UnixSocket{Owner: "screego-does-not-exist"})
+
+	assert.ErrorContains(t, err, "invalid unix socket owner")
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "socket file should be removed")
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		httpsAddress string
+		target       string
+		expected     string
+	}{
+		{httpsAddress: ":443", target: "http://example.org/room?x=1", expected: "https://example.org/room?x=1"},
+		{httpsAddress: ":443", target: "http://example.org:80/", expected: "https://example.org/"},
+		{httpsAddress: ":5050", target: "http://example.org/", expected: "https://example.org:5050/"},
+		{httpsAddress: "127.0.0.1:5050", target: "http://[::1]:8080/a/b", expected: "https://[::1]:5050/a/b"},
+		{httpsAddress: "unix:/run/screego.sock", target: "http://example.org/", expected: "https://example.org/"},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		redirectToHTTPS(test.httpsAddress).ServeHTTP(recorder, httptest.NewRequest("GET", test.target, nil))
+		assert.Equal(t, http.StatusMovedPermanently, recorder.Code)
+		assert.Equal(t, test.expected, recorder.Header().Get("Location"))
+	}
+}
+
+func TestShutdown_redirectServer(t *testing.T) {
+	dispose := fakeInterrupt(t)
+	defer dispose()
+
+	redirectAddress := "127.0.0.1:" + strconv.Itoa(port())
+	finished := make(chan error)
+	go func() {
+		finished <- Start(mux.NewRouter(), ":"+strconv.Itoa(port()), "", "", redirectAddress, UnixSocket{})
+	}()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	var response *http.Response
+	assert.Eventually(t, func() bool {
+		var err error
+		response, err = client.Get("http://" + redirectAddress + "/join/abc")
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	if response != nil {
+		response.Body.Close()
+		assert.Equal(t, http.StatusMovedPermanently, response.StatusCode)
+		assert.Contains(t, response.Header.Get("Location"), "/join/abc")
+	}
+
+	select {
+	case <-time.After(time.Second):
+		t.Fatal("Server should be closed")
+	case err := <-finished:
+		assert.Nil(t, err)
+	}
+
+	_, err := client.Get("http://" + redirectAddress + "/")
+	assert.Error(t, err, "redirect server should be closed")
+}
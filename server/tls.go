@@ -0,0 +1,76 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/youmark/pkcs8"
+)
+
+// tlsFiles are the paths of the certificate and the private key, password decrypts the key if it is set.
+type tlsFiles struct {
+	cert     string
+	key      string
+	password string
+}
+
+// loadKeyPair loads the certificate and decrypts the private key with the password. Encrypted PKCS#8 keys (BEGIN
+// ENCRYPTED PRIVATE KEY) and the legacy PEM encryption of PKCS#1 keys (Proc-Type: 4,ENCRYPTED) are supported, keys
+// that aren't encrypted are used as they are.
+func loadKeyPair(certFile, keyFile, password string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyPEM, err = decryptKey(keyPEM, []byte(password))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not decrypt the tls key %s: %w", keyFile, err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// decryptKey returns the first private key of the PEM data without encryption.
+func decryptKey(data, password []byte) ([]byte, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no private key found")
+		}
+		if !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			continue
+		}
+
+		switch {
+		case block.Type == "ENCRYPTED PRIVATE KEY":
+			key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, password)
+			if err != nil {
+				return nil, err
+			}
+			der, err := x509.MarshalPKCS8PrivateKey(key)
+			if err != nil {
+				return nil, err
+			}
+			return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+		case x509.IsEncryptedPEMBlock(block):
+			// the legacy PEM encryption is deprecated as insecure, but still created by e.g. openssl genrsa -aes256.
+			der, err := x509.DecryptPEMBlock(block, password)
+			if err != nil {
+				return nil, err
+			}
+			return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+		default:
+			return pem.EncodeToMemory(block), nil
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/youmark/pkcs8"
+)
+
+func writeCertificate(t *testing.T, dir string, key crypto.Signer) string {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return path
+}
+
+func writeKey(t *testing.T, dir string, block *pem.Block) string {
+	t.Helper()
+	path := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+	return path
+}
+
+func TestLoadKeyPair(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pkcs8RSA, err := pkcs8.ConvertPrivateKeyToPKCS8(rsaKey, []byte("pw"))
+	require.NoError(t, err)
+	pkcs8EC, err := pkcs8.ConvertPrivateKeyToPKCS8(ecKey, []byte("pw"))
+	require.NoError(t, err)
+	pkcs1, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), []byte("pw"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  crypto.Signer
+		pem  *pem.Block
+	}{
+		{name: "pkcs8 rsa", key: rsaKey, pem: &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: pkcs8RSA}},
+		{name: "pkcs8 ecdsa", key: ecKey, pem: &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: pkcs8EC}},
+		{name: "pkcs1 rsa", key: rsaKey, pem: pkcs1},
+		{name: "unencrypted", key: rsaKey, pem: &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cert := writeCertificate(t, dir, test.key)
+			key := writeKey(t, dir, test.pem)
+
+			certificate, err := loadKeyPair(cert, key, "pw")
+			require.NoError(t, err)
+			public := certificate.PrivateKey.(crypto.Signer).Public()
+			assert.True(t, test.key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(public))
+
+			if test.name != "unencrypted" {
+				_, err = loadKeyPair(cert, key, "wrong")
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestStart_tlsKeyPassword(t *testing.T) {
+	dispose := fakeInterrupt(t)
+	defer dispose()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	encrypted, err := pkcs8.ConvertPrivateKeyToPKCS8(key, []byte("pw"))
+	require.NoError(t, err)
+	dir := t.TempDir()
+	cert := writeCertificate(t, dir, key)
+	keyFile := writeKey(t, dir, &pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encrypted})
+
+	router := mux.NewRouter()
+	router.Path("/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	address := "127.0.0.1:" + strconv.Itoa(port())
+	finished := make(chan error, 1)
+	go func() {
+		finished <- Start(router, address, cert, keyFile, "", UnixSocket{}, WithTLSKeyPassword("pw"))
+	}()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	require.Eventually(t, func() bool {
+		resp, err := client.Get("https://" + address + "/")
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, <-finished)
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in memory, it is lost on restart.
+type MemoryStore struct {
+	lock     sync.RWMutex
+	rooms    map[string]Room
+	sessions map[string]Session
+	// revokedInvites are the expiries of the revoked invite tokens.
+	revokedInvites map[string]time.Time
+	// notBefore is the time of the last revocation of all sessions.
+	notBefore time.Time
+}
+
+// NewMemoryStore returns an empty store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{rooms: map[string]Room{}, sessions: map[string]Session{}, revokedInvites: map[string]time.Time{}}
+}
+
+func (m *MemoryStore) SaveRoom(room Room) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	room.PasswordHash = append([]byte(nil), room.PasswordHash...)
+	m.rooms[room.ID] = room
+	return nil
+}
+
+func (m *MemoryStore) GetRoom(id string) (Room, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	room, ok := m.rooms[id]
+	if !ok {
+		return Room{}, ErrNotFound
+	}
+	return room, nil
+}
+
+func (m *MemoryStore) DeleteRoom(id string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.rooms, id)
+	return nil
+}
+
+func (m *MemoryStore) SaveSession(session Session) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.sessions[session.Key] = session
+	return nil
+}
+
+func (m *MemoryStore) GetSession(key string) (Session, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	session, ok := m.sessions[key]
+	if !ok {
+		return Session{}, ErrNotFound
+	}
+	return session, nil
+}
+
+func (m *MemoryStore) DeleteSession(key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.sessions, key)
+	return nil
+}
+
+// ListSessions returns all sessions ordered by creation.
+func (m *MemoryStore) ListSessions() ([]Session, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	sessions := make([]Session, 0, len(m.sessions))
+	for _, session := range m.sessions {
+		sessions = append(sessions, session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created.Before(sessions[j].Created)
+	})
+	return sessions, nil
+}
+
+func (m *MemoryStore) DeleteRoomsBefore(t time.Time, keep []string) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	kept := map[string]bool{}
+	for _, id := range keep {
+		kept[id] = true
+	}
+	deleted := 0
+	for id, room := range m.rooms {
+		if room.Created.Before(t) && !kept[id] {
+			delete(m.rooms, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) DeleteExpiredSessions(now time.Time) (int, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	deleted := 0
+	for key, session := range m.sessions {
+		if !session.Expires.IsZero() && session.Expires.Before(now) {
+			delete(m.sessions, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) RevokeInvite(hash string, expires time.Time) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := time.Now()
+	for revoked, revokedExpires := range m.revokedInvites {
+		if revokedExpires.Before(now) {
+			delete(m.revokedInvites, revoked)
+		}
+	}
+	if expires.After(now) {
+		m.revokedInvites[hash] = expires
+	}
+	return nil
+}
+
+func (m *MemoryStore) InviteRevoked(hash string) (bool, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	expires, ok := m.revokedInvites[hash]
+	return ok && expires.After(time.Now()), nil
+}
+
+func (m *MemoryStore) SessionsNotBefore() (time.Time, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.notBefore, nil
+}
+
+func (m *MemoryStore) SetSessionsNotBefore(t time.Time) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.notBefore = t
+	return nil
+}
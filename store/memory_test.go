@@ -0,0 +1,64 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_rooms(t *testing.T) {
+	var s Store = NewMemoryStore()
+
+	_, err := s.GetRoom("room")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	hash := []byte("hash")
+	require.NoError(t, s.SaveRoom(Room{ID: "room", Mode: "turn", CreatedBy: "alice", PasswordHash: hash}))
+	hash[0] = 'x'
+	room, err := s.GetRoom("room")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", room.CreatedBy)
+	assert.Equal(t, []byte("hash"), room.PasswordHash, "the store keeps its own copy")
+
+	require.NoError(t, s.DeleteRoom("room"))
+	require.NoError(t, s.DeleteRoom("room"))
+	_, err = s.GetRoom("room")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStore_sessions(t *testing.T) {
+	var s Store = NewMemoryStore()
+	now := time.Now()
+
+	require.NoError(t, s.SaveSession(Session{Key: "b", User: "bob", Created: now.Add(time.Second)}))
+	require.NoError(t, s.SaveSession(Session{Key: "a", User: "alice", Created: now}))
+	session, err := s.GetSession("a")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", session.User)
+
+	sessions, err := s.ListSessions()
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "alice", sessions[0].User)
+
+	require.NoError(t, s.DeleteSession("a"))
+	_, err = s.GetSession("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryStore_sessionsNotBefore(t *testing.T) {
+	s := NewMemoryStore()
+	var _ Revocations = s
+
+	notBefore, err := s.SessionsNotBefore()
+	require.NoError(t, err)
+	assert.True(t, notBefore.IsZero())
+
+	now := time.Now()
+	require.NoError(t, s.SetSessionsNotBefore(now))
+	notBefore, err = s.SessionsNotBefore()
+	require.NoError(t, err)
+	assert.Equal(t, now, notBefore)
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis connects to SCREEGO_TEST_REDIS_URL if it is set, e.g. to a Redis container, and to an in-process
+// miniredis otherwise. fastForward moves the clock of miniredis, with a real Redis it waits.
+func newTestRedis(t *testing.T) (*RedisStore, func(time.Duration)) {
+	t.Helper()
+	url := os.Getenv("SCREEGO_TEST_REDIS_URL")
+	fastForward := time.Sleep
+	if url == "" {
+		server := miniredis.RunT(t)
+		url = "redis://" + server.Addr() + "/0"
+		fastForward = server.FastForward
+	}
+	s, err := OpenRedis(url)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	require.NoError(t, s.client.FlushDB(context.Background()).Err())
+	return s, fastForward
+}
+
+func TestRedisStore_rooms(t *testing.T) {
+	s, fastForward := newTestRedis(t)
+	var _ Store = s
+
+	_, err := s.GetRoom("room")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	created := time.Now().Truncate(time.Millisecond)
+	room := Room{ID: "room", Mode: "turn", CreatedBy: "alice", WaitingRoom: true, PasswordHash: []byte("hash"),
+		Created: created, Expires: created.Add(2 * time.Second)}
+	require.NoError(t, s.SaveRoom(room))
+	loaded, err := s.GetRoom("room")
+	require.NoError(t, err)
+	assert.True(t, room.Created.Equal(loaded.Created))
+	loaded.Created, loaded.Expires = room.Created, room.Expires
+	assert.Equal(t, room, loaded)
+
+	fastForward(3 * time.Second)
+	_, err = s.GetRoom("room")
+	assert.ErrorIs(t, err, ErrNotFound, "the room expired")
+
+	require.NoError(t, s.SaveRoom(Room{ID: "other"}))
+	require.NoError(t, s.DeleteRoom("other"))
+	require.NoError(t, s.DeleteRoom("other"))
+	_, err = s.GetRoom("other")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRedisStore_sessions(t *testing.T) {
+	s, fastForward := newTestRedis(t)
+	now := time.Now()
+
+	require.NoError(t, s.SaveSession(Session{Key: "b", User: "bob", Created: now.Add(time.Second), Expires: now.Add(time.Hour)}))
+	require.NoError(t, s.SaveSession(Session{Key: "a", ID: "sid", User: "alice", Provider: "password", Role: "admin",
+		Created: now, Expires: now.Add(2 * time.Second)}))
+	require.NoError(t, s.SaveSession(Session{Key: "c", User: "carol", Created: now, Expires: now.Add(-time.Second)}))
+
+	session, err := s.GetSession("a")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", session.User)
+	assert.Equal(t, "sid", session.ID)
+	assert.Equal(t, "admin", session.Role)
+	_, err = s.GetSession("c")
+	assert.ErrorIs(t, err, ErrNotFound, "expired sessions aren't stored")
+
+	sessions, err := s.ListSessions()
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "alice", sessions[0].User)
+
+	fastForward(3 * time.Second)
+	_, err = s.GetSession("a")
+	assert.ErrorIs(t, err, ErrNotFound, "redis removes expired sessions")
+
+	require.NoError(t, s.DeleteSession("b"))
+	sessions, err = s.ListSessions()
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestRedisStore_events(t *testing.T) {
+	s, _ := newTestRedis(t)
+	first, err := s.SubscribeEvents()
+	require.NoError(t, err)
+	second, err := s.SubscribeEvents()
+	require.NoError(t, err)
+
+	require.NoError(t, s.PublishEvent([]byte("room.created")))
+	for _, events := range []<-chan []byte{first, second} {
+		select {
+		case event := <-events:
+			assert.Equal(t, "room.created", string(event))
+		case <-time.After(time.Second):
+			t.Fatal("event wasn't received")
+		}
+	}
+
+	require.NoError(t, s.Close())
+	_, open := <-first
+	assert.False(t, open, "closing the store ends the subscriptions")
+}
+
+func TestRedisStore_sessionsNotBefore(t *testing.T) {
+	s, _ := newTestRedis(t)
+	var _ Revocations = s
+
+	notBefore, err := s.SessionsNotBefore()
+	require.NoError(t, err)
+	assert.True(t, notBefore.IsZero())
+
+	now := time.Now()
+	require.NoError(t, s.SetSessionsNotBefore(now))
+	notBefore, err = s.SessionsNotBefore()
+	require.NoError(t, err)
+	assert.True(t, now.Equal(notBefore))
+}
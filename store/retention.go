@@ -0,0 +1,70 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Retention deletes old data from the store once a day (SCREEGO_DATA_RETENTION_DAYS).
+type Retention struct {
+	Store Store
+	// MaxAge is how long rooms are kept after they were created.
+	MaxAge time.Duration
+	// RunAt is the local time of day of the run as offset from midnight.
+	RunAt time.Duration
+	// LiveRooms returns the ids of the rooms that are open on this instance, they are kept regardless of their age.
+	LiveRooms func() ([]string, error)
+}
+
+// Start runs the retention every day at RunAt until ctx is done.
+func (r Retention) Start(ctx context.Context) {
+	for {
+		now := time.Now()
+		timer := time.NewTimer(r.next(now).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := r.Run(time.Now()); err != nil {
+			log.Error().Err(err).Msg("Data retention failed")
+		}
+	}
+}
+
+// Run deletes the rooms created before now-MaxAge that aren't open anymore and the expired sessions.
+func (r Retention) Run(now time.Time) error {
+	var live []string
+	if r.LiveRooms != nil {
+		var err error
+		if live, err = r.LiveRooms(); err != nil {
+			return fmt.Errorf("list open rooms: %w", err)
+		}
+	}
+	rooms, err := r.Store.DeleteRoomsBefore(now.Add(-r.MaxAge), live)
+	if err != nil {
+		return fmt.Errorf("delete rooms: %w", err)
+	}
+	sessions, err := r.Store.DeleteExpiredSessions(now)
+	if err != nil {
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+	log.Info().Int("rooms", rooms).Int("sessions", sessions).Msg("Deleted old data")
+	return nil
+}
+
+// next is the next run after now. The date is normalized by time.Date, so the time of day stays the same when the
+// clock changes for daylight saving time.
+func (r Retention) next(now time.Time) time.Time {
+	year, month, day := now.Date()
+	minutes := int(r.RunAt / time.Minute)
+	next := time.Date(year, month, day, 0, minutes, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(year, month, day+1, 0, minutes, 0, 0, now.Location())
+	}
+	return next
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetention_run(t *testing.T) {
+	redisStore, _ := newTestRedis(t)
+	sqliteStore, _ := newTestSQLite(t)
+	stores := map[string]Store{"memory": NewMemoryStore(), "redis": redisStore, "sqlite": sqliteStore}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			require.NoError(t, s.SaveRoom(Room{ID: "stale", Created: now.Add(-31 * 24 * time.Hour)}))
+			require.NoError(t, s.SaveRoom(Room{ID: "recent", Created: now.Add(-29 * 24 * time.Hour)}))
+			require.NoError(t, s.SaveRoom(Room{ID: "open", Created: now.Add(-31 * 24 * time.Hour)}))
+			require.NoError(t, s.SaveSession(Session{Key: "active", Created: now, Expires: now.Add(time.Hour)}))
+			require.NoError(t, s.SaveSession(Session{Key: "forever", Created: now}))
+			if _, ok := s.(*RedisStore); !ok {
+				// Redis removes expired hashes itself, the other stores keep them until the retention.
+				require.NoError(t, s.SaveSession(Session{Key: "expired", Created: now, Expires: now.Add(-time.Minute)}))
+			}
+
+			retention := Retention{Store: s, MaxAge: 30 * 24 * time.Hour, LiveRooms: func() ([]string, error) {
+				return []string{"open"}, nil
+			}}
+			require.NoError(t, retention.Run(now.Add(2*time.Hour)))
+
+			_, err := s.GetRoom("stale")
+			assert.ErrorIs(t, err, ErrNotFound)
+			_, err = s.GetRoom("recent")
+			assert.NoError(t, err)
+			_, err = s.GetRoom("open")
+			assert.NoError(t, err, "open rooms are kept")
+
+			sessions, err := s.ListSessions()
+			require.NoError(t, err)
+			keys := []string{}
+			for _, session := range sessions {
+				keys = append(keys, session.Key)
+			}
+			assert.ElementsMatch(t, []string{"forever"}, keys)
+		})
+	}
+}
+
+func TestRetention_next(t *testing.T) {
+	retention := Retention{RunAt: 3 * time.Hour}
+	before := time.Date(2024, 5, 10, 1, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 5, 10, 3, 0, 0, 0, time.UTC), retention.next(before))
+	after := time.Date(2024, 5, 10, 3, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 5, 11, 3, 0, 0, 0, time.UTC), retention.next(after))
+}
+
+func TestRetention_liveRoomsFailed(t *testing.T) {
+	s := NewMemoryStore()
+	require.NoError(t, s.SaveRoom(Room{ID: "room"}))
+	retention := Retention{Store: s, LiveRooms: func() ([]string, error) {
+		return nil, errors.New("stopped")
+	}}
+
+	assert.Error(t, retention.Run(time.Now()))
+	_, err := s.GetRoom("room")
+	assert.NoError(t, err, "nothing is deleted without the open rooms")
+}
+
+func TestRetention_start(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Retention{Store: NewMemoryStore()}.Start(ctx)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the retention didn't stop")
+	}
+}
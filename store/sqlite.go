@@ -0,0 +1,272 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	// registers the database/sql driver "sqlite", it is written in Go and needs no cgo.
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order, the number of applied migrations is kept in the schema_version table.
+// Migrations are never changed after a release, new ones are appended.
+var sqliteMigrations = []string{
+	`CREATE TABLE rooms (
+		id                   TEXT PRIMARY KEY,
+		mode                 TEXT NOT NULL,
+		created_by           TEXT NOT NULL,
+		close_on_owner_leave INTEGER NOT NULL,
+		waiting_room         INTEGER NOT NULL,
+		password_hash        BLOB,
+		created              INTEGER NOT NULL,
+		expires              INTEGER NOT NULL
+	);
+	CREATE TABLE sessions (
+		key      TEXT PRIMARY KEY,
+		id       TEXT NOT NULL,
+		user     TEXT NOT NULL,
+		provider TEXT NOT NULL,
+		role     TEXT NOT NULL,
+		created  INTEGER NOT NULL,
+		expires  INTEGER NOT NULL
+	);
+	CREATE INDEX sessions_created ON sessions (created);
+	CREATE TABLE settings (
+		name  TEXT PRIMARY KEY,
+		value INTEGER NOT NULL
+	);`,
+	`CREATE TABLE revoked_invites (
+		hash    TEXT PRIMARY KEY,
+		expires INTEGER NOT NULL
+	);`,
+}
+
+// SQLiteStore keeps the rooms and sessions in a SQLite database (SCREEGO_DB_DRIVER=sqlite3), so that they survive
+// restarts of a single instance. Use the RedisStore for multiple instances.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens the database of the dsn, e.g. /var/lib/screego/screego.db, and migrates it to the current schema.
+func OpenSQLite(dsn string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time, a single connection avoids busy errors. It also keeps :memory: databases,
+	// they exist per connection.
+	db.SetMaxOpenConns(1)
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate database: %w", err)
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) migrate() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	version := 0
+	err = tx.QueryRow(`SELECT version FROM schema_version`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = tx.Exec(`INSERT INTO schema_version (version) VALUES (0)`)
+	}
+	if err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("the database schema %d is newer than this version of screego supports", version)
+	}
+
+	for ; version < len(sqliteMigrations); version++ {
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+	}
+	if _, err := tx.Exec(`UPDATE schema_version SET version = ?`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) SaveRoom(room Room) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO rooms
+		(id, mode, created_by, close_on_owner_leave, waiting_room, password_hash, created, expires)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		room.ID, room.Mode, room.CreatedBy, room.CloseOnOwnerLeave, room.WaitingRoom, room.PasswordHash,
+		unixNano(room.Created), unixNano(room.Expires))
+	return err
+}
+
+func (s *SQLiteStore) GetRoom(id string) (Room, error) {
+	var room Room
+	var created, expires int64
+	err := s.db.QueryRow(`SELECT id, mode, created_by, close_on_owner_leave, waiting_room, password_hash, created, expires
+		FROM rooms WHERE id = ?`, id).
+		Scan(&room.ID, &room.Mode, &room.CreatedBy, &room.CloseOnOwnerLeave, &room.WaitingRoom, &room.PasswordHash,
+			&created, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Room{}, ErrNotFound
+	}
+	if err != nil {
+		return Room{}, err
+	}
+	if len(room.PasswordHash) == 0 {
+		room.PasswordHash = nil
+	}
+	room.Created, room.Expires = fromUnixNano(created), fromUnixNano(expires)
+	return room, nil
+}
+
+func (s *SQLiteStore) DeleteRoom(id string) error {
+	_, err := s.db.Exec(`DELETE FROM rooms WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLiteStore) SaveSession(session Session) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO sessions (key, id, user, provider, role, created, expires)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		session.Key, session.ID, session.User, session.Provider, session.Role,
+		unixNano(session.Created), unixNano(session.Expires))
+	return err
+}
+
+func (s *SQLiteStore) GetSession(key string) (Session, error) {
+	sessions, err := s.querySessions(`WHERE key = ?`, key)
+	if err != nil {
+		return Session{}, err
+	}
+	if len(sessions) == 0 {
+		return Session{}, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+func (s *SQLiteStore) DeleteSession(key string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE key = ?`, key)
+	return err
+}
+
+// ListSessions returns all sessions ordered by creation.
+func (s *SQLiteStore) ListSessions() ([]Session, error) {
+	return s.querySessions(`ORDER BY created`)
+}
+
+func (s *SQLiteStore) DeleteRoomsBefore(t time.Time, keep []string) (int, error) {
+	query := `DELETE FROM rooms WHERE created < ?`
+	args := []interface{}{unixNano(t)}
+	if len(keep) > 0 {
+		query += ` AND id NOT IN (?` + strings.Repeat(`, ?`, len(keep)-1) + `)`
+		for _, id := range keep {
+			args = append(args, id)
+		}
+	}
+	return s.deleteWhere(query, args...)
+}
+
+func (s *SQLiteStore) DeleteExpiredSessions(now time.Time) (int, error) {
+	return s.deleteWhere(`DELETE FROM sessions WHERE expires != 0 AND expires < ?`, unixNano(now))
+}
+
+// RevokeInvite stores the revocation and deletes the revocations of expired tokens.
+func (s *SQLiteStore) RevokeInvite(hash string, expires time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM revoked_invites WHERE expires < ?`, time.Now().UnixNano()); err != nil {
+		return err
+	}
+	if expires.After(time.Now()) {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO revoked_invites (hash, expires) VALUES (?, ?)`, hash, unixNano(expires)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) InviteRevoked(hash string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM revoked_invites WHERE hash = ? AND expires > ?)`,
+		hash, time.Now().UnixNano()).Scan(&revoked)
+	return revoked, err
+}
+
+func (s *SQLiteStore) SessionsNotBefore() (time.Time, error) {
+	var notBefore int64
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE name = 'sessions_not_before'`).Scan(&notBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fromUnixNano(notBefore), nil
+}
+
+func (s *SQLiteStore) SetSessionsNotBefore(t time.Time) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO settings (name, value) VALUES ('sessions_not_before', ?)`, unixNano(t))
+	return err
+}
+
+func (s *SQLiteStore) querySessions(where string, args ...interface{}) ([]Session, error) {
+	rows, err := s.db.Query(`SELECT key, id, user, provider, role, created, expires FROM sessions `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		var created, expires int64
+		if err := rows.Scan(&session.Key, &session.ID, &session.User, &session.Provider, &session.Role,
+			&created, &expires); err != nil {
+			return nil, err
+		}
+		session.Created, session.Expires = fromUnixNano(created), fromUnixNano(expires)
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *SQLiteStore) deleteWhere(query string, args ...interface{}) (int, error) {
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// unixNano returns 0 for the zero time, so that it is read as zero again.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLite(t *testing.T) (*SQLiteStore, string) {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "screego.db")
+	s, err := OpenSQLite(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s, dsn
+}
+
+func TestSQLiteStore_rooms(t *testing.T) {
+	s, _ := newTestSQLite(t)
+	var _ Store = s
+
+	_, err := s.GetRoom("room")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	created := time.Now()
+	room := Room{ID: "room", Mode: "turn", CreatedBy: "alice", WaitingRoom: true, PasswordHash: []byte("hash"),
+		Created: created, Expires: created.Add(time.Hour)}
+	require.NoError(t, s.SaveRoom(room))
+	loaded, err := s.GetRoom("room")
+	require.NoError(t, err)
+	assert.True(t, room.Created.Equal(loaded.Created))
+	assert.True(t, room.Expires.Equal(loaded.Expires))
+	loaded.Created, loaded.Expires = room.Created, room.Expires
+	assert.Equal(t, room, loaded)
+
+	require.NoError(t, s.SaveRoom(Room{ID: "room", Mode: "stun"}))
+	loaded, err = s.GetRoom("room")
+	require.NoError(t, err)
+	assert.Equal(t, Room{ID: "room", Mode: "stun"}, loaded, "saving replaces the room")
+
+	require.NoError(t, s.DeleteRoom("room"))
+	require.NoError(t, s.DeleteRoom("room"))
+	_, err = s.GetRoom("room")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLiteStore_sessions(t *testing.T) {
+	s, _ := newTestSQLite(t)
+	now := time.Now()
+
+	require.NoError(t, s.SaveSession(Session{Key: "b", User: "bob", Created: now.Add(time.Second)}))
+	require.NoError(t, s.SaveSession(Session{Key: "a", ID: "sid", User: "alice", Provider: "password", Role: "admin",
+		Created: now, Expires: now.Add(time.Hour)}))
+
+	session, err := s.GetSession("a")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", session.User)
+	assert.Equal(t, "sid", session.ID)
+	assert.Equal(t, "admin", session.Role)
+	assert.True(t, now.Add(time.Hour).Equal(session.Expires))
+
+	sessions, err := s.ListSessions()
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "alice", sessions[0].User)
+	assert.True(t, sessions[1].Expires.IsZero())
+
+	require.NoError(t, s.DeleteSession("a"))
+	_, err = s.GetSession("a")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSQLiteStore_sessionsNotBefore(t *testing.T) {
+	s, _ := newTestSQLite(t)
+	var _ Revocations = s
+
+	notBefore, err := s.SessionsNotBefore()
+	require.NoError(t, err)
+	assert.True(t, notBefore.IsZero())
+
+	now := time.Now()
+	require.NoError(t, s.SetSessionsNotBefore(now))
+	require.NoError(t, s.SetSessionsNotBefore(now.Add(time.Minute)))
+	notBefore, err = s.SessionsNotBefore()
+	require.NoError(t, err)
+	assert.True(t, now.Add(time.Minute).Equal(notBefore))
+}
+
+func TestSQLiteStore_reopen(t *testing.T) {
+	s, dsn := newTestSQLite(t)
+	require.NoError(t, s.SaveSession(Session{Key: "a", User: "alice", Created: time.Now()}))
+	require.NoError(t, s.Close())
+
+	s, err := OpenSQLite(dsn)
+	require.NoError(t, err)
+	defer s.Close()
+	session, err := s.GetSession("a")
+	require.NoError(t, err, "the sessions survive a restart")
+	assert.Equal(t, "alice", session.User)
+
+	_, err = s.db.Exec(`UPDATE schema_version SET version = ?`, len(sqliteMigrations)+1)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	_, err = OpenSQLite(dsn)
+	assert.ErrorContains(t, err, "newer than this version")
+}
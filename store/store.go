@@ -0,0 +1,73 @@
+// Package store persists the metadata of rooms and the login sessions, so that they can survive restarts.
+//
+// The MemoryStore keeps everything in the process, the RedisStore shares it between multiple instances
+// (SCREEGO_REDIS_URL) and the SQLiteStore keeps it in a database file of a single instance (SCREEGO_DB_DRIVER=sqlite3).
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by the Get methods if the room or session doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// Room is the metadata of a room, the connections of its users aren't stored.
+type Room struct {
+	ID                string
+	Mode              string
+	CreatedBy         string
+	CloseOnOwnerLeave bool
+	WaitingRoom       bool
+	PasswordHash      []byte
+	Created           time.Time
+	// Expires is zero if the room doesn't expire.
+	Expires time.Time
+}
+
+// Session is a login session, Key is the hash of the cookie.
+type Session struct {
+	Key      string
+	ID       string
+	User     string
+	Provider string
+	Role     string
+	Created  time.Time
+	Expires  time.Time
+}
+
+// Store persists rooms and sessions. Implementations must be safe for concurrent use.
+type Store interface {
+	SaveRoom(room Room) error
+	GetRoom(id string) (Room, error)
+	DeleteRoom(id string) error
+	SaveSession(session Session) error
+	GetSession(key string) (Session, error)
+	DeleteSession(key string) error
+	ListSessions() ([]Session, error)
+	// DeleteRoomsBefore deletes the rooms created before t except the rooms of keep, e.g. the rooms that are still open,
+	// and returns how many were deleted.
+	DeleteRoomsBefore(t time.Time, keep []string) (int, error)
+	// DeleteExpiredSessions deletes the sessions that expired before now and returns how many were deleted.
+	DeleteExpiredSessions(now time.Time) (int, error)
+	// RevokeInvite keeps the hash of a revoked invite token until the token expires, expired revocations are removed.
+	RevokeInvite(hash string, expires time.Time) error
+	// InviteRevoked returns whether the invite token of the hash was revoked and hasn't expired yet.
+	InviteRevoked(hash string) (bool, error)
+}
+
+// Revocations keeps the time before which all login sessions are invalid. It is used for the jwt sessions, they aren't
+// stored and can't be deleted.
+type Revocations interface {
+	// SessionsNotBefore is zero if the sessions were never revoked.
+	SessionsNotBefore() (time.Time, error)
+	SetSessionsNotBefore(t time.Time) error
+}
+
+// EventBus propagates the room events between the instances of a deployment.
+type EventBus interface {
+	// PublishEvent sends the event to the subscribers of all instances, including this one.
+	PublishEvent(data []byte) error
+	// SubscribeEvents returns the events of all instances, the channel is closed when the bus is closed.
+	SubscribeEvents() (<-chan []byte, error)
+}
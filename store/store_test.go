@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_revokedInvites(t *testing.T) {
+	redisStore, fastForward := newTestRedis(t)
+	sqliteStore, dsn := newTestSQLite(t)
+	stores := map[string]Store{"memory": NewMemoryStore(), "redis": redisStore, "sqlite": sqliteStore}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			revoked, err := s.InviteRevoked("hash")
+			require.NoError(t, err)
+			assert.False(t, revoked)
+
+			require.NoError(t, s.RevokeInvite("hash", time.Now().Add(time.Hour)))
+			require.NoError(t, s.RevokeInvite("expired", time.Now().Add(-time.Minute)))
+			revoked, err = s.InviteRevoked("hash")
+			require.NoError(t, err)
+			assert.True(t, revoked)
+			revoked, err = s.InviteRevoked("expired")
+			require.NoError(t, err)
+			assert.False(t, revoked, "the token expired anyway")
+		})
+	}
+
+	require.NoError(t, redisStore.RevokeInvite("short", time.Now().Add(2*time.Second)))
+	fastForward(3 * time.Second)
+	revoked, err := redisStore.InviteRevoked("short")
+	require.NoError(t, err)
+	assert.False(t, revoked, "redis removes the revocation with the token expiry")
+
+	require.NoError(t, sqliteStore.Close())
+	reopened, err := OpenSQLite(dsn)
+	require.NoError(t, err)
+	defer reopened.Close()
+	revoked, err = reopened.InviteRevoked("hash")
+	require.NoError(t, err)
+	assert.True(t, revoked, "revocations survive a restart")
+	require.NoError(t, reopened.RevokeInvite("soon", time.Now().Add(10*time.Millisecond)))
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, reopened.RevokeInvite("other", time.Now().Add(time.Hour)))
+	var hashes []string
+	rows, err := reopened.db.Query(`SELECT hash FROM revoked_invites`)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var hash string
+		require.NoError(t, rows.Scan(&hash))
+		hashes = append(hashes, hash)
+	}
+	assert.ElementsMatch(t, []string{"hash", "other"}, hashes, "expired revocations are deleted")
+}
@@ -0,0 +1,37 @@
+package turn
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/screego/server/config"
+)
+
+// UserCredentials provides the TURN passwords of the users file accounts.
+type UserCredentials interface {
+	// TURNPassword returns the TURN password of the user, false if the user doesn't exist.
+	TURNPassword(user string) (string, bool)
+}
+
+// password returns the password of credentials that aren't issued for screego sessions.
+func (a *InternalServer) password(username string) (string, bool) {
+	switch a.auth {
+	case config.TurnAuthSharedSecret:
+		expiry, _, ok := strings.Cut(username, ":")
+		if !ok {
+			return "", false
+		}
+		expires, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil || a.now().Unix() > expires {
+			return "", false
+		}
+		return sharedSecretPassword(a.secret, username), true
+	case config.TurnAuthUsers:
+		if a.users == nil {
+			return "", false
+		}
+		return a.users.TURNPassword(username)
+	default:
+		return "", false
+	}
+}
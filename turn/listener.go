@@ -0,0 +1,59 @@
+package turn
+
+import (
+	"net"
+	"sync"
+)
+
+// trackingListener remembers the accepted TCP connections. The TURN server only closes the listener on shutdown, the
+// connections of the clients would stay open until the clients close them.
+type trackingListener struct {
+	net.Listener
+	quota *allocationQuota
+
+	lock  sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newTrackingListener(listener net.Listener, quota *allocationQuota) *trackingListener {
+	return &trackingListener{Listener: listener, quota: quota, conns: map[net.Conn]struct{}{}}
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.conns[conn] = struct{}{}
+	return &trackedConn{Conn: conn, listener: l}, nil
+}
+
+// closeConns closes all accepted connections that are still open.
+func (l *trackingListener) closeConns() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for conn := range l.conns {
+		_ = conn.Close()
+		delete(l.conns, conn)
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	listener *trackingListener
+}
+
+func (c *trackedConn) Close() error {
+	c.listener.lock.Lock()
+	delete(c.listener.conns, c.Conn)
+	c.listener.lock.Unlock()
+	return c.Conn.Close()
+}
+
+// Write passes the responses to the quota, the TURN server writes every message with a single call.
+func (c *trackedConn) Write(p []byte) (int, error) {
+	c.listener.quota.observe("tcp", c.RemoteAddr(), p)
+	return c.Conn.Write(p)
+}
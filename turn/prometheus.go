@@ -0,0 +1,11 @@
+package turn
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var allocationsRejectedTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "screego_turn_allocations_rejected_total",
+	Help: "The total number of TURN requests rejected because the client IP reached the allocation limit",
+})
@@ -0,0 +1,146 @@
+package turn
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	allocateSuccess = stun.NewType(stun.MethodAllocate, stun.ClassSuccessResponse).Value()
+	refreshSuccess  = stun.NewType(stun.MethodRefresh, stun.ClassSuccessResponse).Value()
+)
+
+// allocationQuota limits the active allocations per client IP, so that a single client can't exhaust the relay ports.
+// The TURN server doesn't report its allocations, they are tracked from the responses that are sent to the clients:
+// an allocation is active from the success response of the Allocate request until its lifetime has passed since the
+// last refresh, or until a refresh with lifetime 0 deletes it. A nil quota allows everything.
+type allocationQuota struct {
+	// rejected must be first to be aligned on 32 bit platforms.
+	rejected uint64
+
+	max  int
+	lock sync.Mutex
+	// allocations are the expiry and the IP of the active allocations by transport and client address, the 5-tuple of
+	// the TURN server.
+	allocations map[string]activeAllocation
+	now         func() time.Time
+}
+
+type activeAllocation struct {
+	ip      string
+	expires time.Time
+}
+
+func newAllocationQuota(max int) *allocationQuota {
+	if max <= 0 {
+		return nil
+	}
+	return &allocationQuota{max: max, allocations: map[string]activeAllocation{}, now: time.Now}
+}
+
+// allow is called by the auth handler. Requests of active allocations are always allowed, other requests are
+// rejected once the IP of the client has the maximum of active allocations. Only Allocate requests are sent from a
+// client address without allocation, other requests would fail anyway.
+func (q *allocationQuota) allow(transport string, addr net.Addr) bool {
+	if q == nil {
+		return true
+	}
+	ip := addrIP(addr)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, ok := q.allocations[transport+"/"+addr.String()]; ok {
+		return true
+	}
+	now := q.now()
+	active := 0
+	for key, allocation := range q.allocations {
+		if !allocation.expires.After(now) {
+			delete(q.allocations, key)
+		} else if allocation.ip == ip {
+			active++
+		}
+	}
+	if active < q.max {
+		return true
+	}
+	atomic.AddUint64(&q.rejected, 1)
+	allocationsRejectedTotal.Inc()
+	log.Debug().Str("ip", addr.String()).Int("allocations", active).Msg("TURN allocation quota reached")
+	return false
+}
+
+// observe inspects a message that the TURN server sends to the client and tracks the allocation of successful Allocate
+// and Refresh responses. Relayed data is skipped after looking at the message type.
+func (q *allocationQuota) observe(transport string, addr net.Addr, p []byte) {
+	if q == nil || !stun.IsMessage(p) {
+		return
+	}
+	if messageType := binary.BigEndian.Uint16(p[0:2]); messageType != allocateSuccess && messageType != refreshSuccess {
+		return
+	}
+	msg := &stun.Message{Raw: append([]byte(nil), p...)}
+	if err := msg.Decode(); err != nil {
+		return
+	}
+	value, err := msg.Get(stun.AttrLifetime)
+	if err != nil || len(value) != 4 {
+		return
+	}
+	lifetime := time.Duration(binary.BigEndian.Uint32(value)) * time.Second
+
+	key := transport + "/" + addr.String()
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if lifetime == 0 {
+		delete(q.allocations, key)
+		return
+	}
+	q.allocations[key] = activeAllocation{ip: addrIP(addr), expires: q.now().Add(lifetime)}
+}
+
+func (q *allocationQuota) rejections() uint64 {
+	if q == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&q.rejected)
+}
+
+func addrIP(addr net.Addr) string {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP.String()
+	case *net.TCPAddr:
+		return addr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// addrTransport returns the transport of the client address that the TURN server passes to the auth handler.
+func addrTransport(addr net.Addr) string {
+	if _, ok := addr.(*net.TCPAddr); ok {
+		return "tcp"
+	}
+	return "udp"
+}
+
+// quotaPacketConn passes the responses of the UDP listener to the quota.
+type quotaPacketConn struct {
+	net.PacketConn
+	quota *allocationQuota
+}
+
+func (c *quotaPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.quota.observe("udp", addr, p)
+	return c.PacketConn.WriteTo(p, addr)
+}
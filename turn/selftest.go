@@ -0,0 +1,108 @@
+package turn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pion/turn/v2"
+	"github.com/screego/server/util"
+)
+
+// selfTestTimeout limits the self test if the context has no deadline.
+const selfTestTimeout = 5 * time.Second
+
+// selfTestRoom is the room scope of the self test credentials, it cannot clash with real rooms because their scopes
+// are derived from the room ids.
+const selfTestRoom = "screego-self-test"
+
+var selfTestPayload = []byte("screego self test")
+
+// selfTestAddr is the address that the self test connects to, unspecified listen addresses are reached over
+// loopback.
+func selfTestAddr(listen net.Addr) string {
+	addr, ok := listen.(*net.UDPAddr)
+	if !ok || addr.IP.IsUnspecified() {
+		port := 0
+		if ok {
+			port = addr.Port
+		}
+		return net.JoinHostPort("127.0.0.1", fmt.Sprint(port))
+	}
+	return addr.String()
+}
+
+// SelfTest allocates a relay with temporary credentials and sends a packet through it to a local peer. It fails if
+// the listener, the authentication, the external ip lookup or the relay ports don't work. It returns the relayed
+// address that clients would get.
+func (a *InternalServer) SelfTest(ctx context.Context) (string, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, selfTestTimeout)
+		defer cancel()
+	}
+	server, err := net.ResolveUDPAddr("udp", a.selfTestAddr)
+	if err != nil {
+		return "", err
+	}
+	loopback := "127.0.0.1:0"
+	if server.IP.To4() == nil {
+		loopback = "[::1]:0"
+	}
+
+	conn, err := net.ListenPacket("udp", loopback)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	peer, err := net.ListenPacket("udp", loopback)
+	if err != nil {
+		return "", err
+	}
+	defer peer.Close()
+	// the client has no context, closing its connection aborts the pending requests.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+			_ = peer.Close()
+		case <-done:
+		}
+	}()
+
+	username, password := a.Credentials(selfTestRoom, util.RandString(8), server.IP)
+	defer a.Disallow(username)
+	client, err := turn.NewClient(&turn.ClientConfig{STUNServerAddr: a.selfTestAddr, TURNServerAddr: a.selfTestAddr,
+		Conn: conn, Username: username, Password: password, Realm: a.realm})
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	if err := client.Listen(); err != nil {
+		return "", err
+	}
+	relay, err := client.Allocate()
+	if err != nil {
+		return "", fmt.Errorf("allocate: %w", err)
+	}
+	defer relay.Close()
+
+	if _, err := relay.WriteTo(selfTestPayload, peer.LocalAddr()); err != nil {
+		return "", fmt.Errorf("relay: %w", err)
+	}
+	deadline, _ := ctx.Deadline()
+	_ = peer.SetReadDeadline(deadline)
+	buf := make([]byte, 64)
+	n, _, err := peer.ReadFrom(buf)
+	if err != nil {
+		return "", fmt.Errorf("relay: no packet received: %w", err)
+	}
+	if string(buf[:n]) != string(selfTestPayload) {
+		return "", errors.New("relay: unexpected packet received")
+	}
+	return relay.LocalAddr().String(), nil
+}
@@ -1,12 +1,17 @@
 package turn
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/pion/turn/v2"
@@ -17,13 +22,39 @@ import (
 )
 
 type Server interface {
-	Credentials(id string, addr net.IP) (string, string)
+	// Credentials returns the username and password of a session in the room. The username contains the room scope,
+	// see roomScope.
+	Credentials(room, id string, addr net.IP) (string, string)
 	Disallow(username string)
+	// DisallowRoom revokes all credentials of the room.
+	DisallowRoom(room string)
+	// TTL returns how long new credentials are valid, 0 if they are valid until they are disallowed.
+	TTL() time.Duration
+	Stats() Stats
+	// Stop stops relaying, allocations of running sessions are closed.
+	Stop(ctx context.Context) error
 }
 
 type InternalServer struct {
-	lock   sync.RWMutex
-	lookup map[string]Entry
+	lock        sync.RWMutex
+	lookup      map[string]Entry
+	realm       string
+	transports  map[string]*transportCounters
+	permissions *permissions
+	quota       *allocationQuota
+
+	// auth selects which credentials are accepted besides the ones of screego sessions, see config.TurnAuth.
+	auth   string
+	secret []byte
+	users  UserCredentials
+	now    func() time.Time
+	// scopeKey derives the room scopes, it is random because the scopes are only checked by this server.
+	scopeKey []byte
+
+	server *turn.Server
+	tcp    *trackingListener
+	// selfTestAddr is the udp address of the server for SelfTest.
+	selfTestAddr string
 }
 
 type ExternalServer struct {
@@ -34,13 +65,13 @@ type ExternalServer struct {
 type Entry struct {
 	addr     net.IP
 	password []byte
+	scope    string
 }
 
-const Realm = "screego"
-
 type Generator struct {
 	turn.RelayAddressGenerator
 	IPProvider ipdns.Provider
+	counters   *transportCounters
 }
 
 func (r *Generator) AllocatePacketConn(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
@@ -61,17 +92,41 @@ func (r *Generator) AllocatePacketConn(network string, requestedPort int) (net.P
 		relayAddr.IP = v6
 	}
 	if err == nil {
-		log.Debug().Str("addr", addr.String()).Str("relayaddr", relayAddr.String()).Msg("TURN allocated")
+		log.Debug().Str("ip", addr.String()).Str("relayaddr", relayAddr.String()).Msg("TURN allocated")
+	}
+	if r.counters != nil {
+		conn = r.counters.track(conn)
 	}
 	return conn, &relayAddr, err
 }
 
-func Start(conf config.Config) (Server, error) {
+// Start starts the internal TURN server or returns the external one. users is only used with the users TURN auth.
+// startRetrySleep is replaced in tests.
+var startRetrySleep = time.Sleep
+
+// Start starts the internal TURN server, binding the address is retried up to conf.TurnStartRetries times with a
+// doubling delay if the address is still in use.
+func Start(conf config.Config, users UserCredentials) (Server, error) {
 	if conf.TurnExternal {
 		return newExternalServer(conf)
-	} else {
-		return newInternalServer(conf)
 	}
+	delay := conf.TurnStartRetryDelay
+	for attempt := 1; ; attempt++ {
+		svr, err := newInternalServer(conf, users)
+		if err == nil || attempt > conf.TurnStartRetries || !transientStartError(err) {
+			return svr, err
+		}
+		log.Warn().Err(err).Int("attempt", attempt).Int("retries", conf.TurnStartRetries).Dur("delay", delay).
+			Msg("Could not start TURN/STUN, retrying")
+		startRetrySleep(delay)
+		delay *= 2
+	}
+}
+
+// transientStartError reports errors that may go away on their own, like the port still being used by the previous
+// process during a restart. Errors of the config, like an invalid address, are not retried.
+func transientStartError(err error) bool {
+	return errors.Is(err, syscall.EADDRINUSE)
 }
 
 func newExternalServer(conf config.Config) (Server, error) {
@@ -81,38 +136,60 @@ func newExternalServer(conf config.Config) (Server, error) {
 	}, nil
 }
 
-func newInternalServer(conf config.Config) (Server, error) {
+func newInternalServer(conf config.Config, users UserCredentials) (Server, error) {
 	udpListener, err := net.ListenPacket("udp", conf.TurnAddress)
 	if err != nil {
-		return nil, fmt.Errorf("udp: could not listen on %s: %s", conf.TurnAddress, err)
+		return nil, fmt.Errorf("udp: could not listen on %s: %w", conf.TurnAddress, err)
 	}
 	tcpListener, err := net.Listen("tcp", conf.TurnAddress)
 	if err != nil {
-		return nil, fmt.Errorf("tcp: could not listen on %s: %s", conf.TurnAddress, err)
+		_ = udpListener.Close()
+		return nil, fmt.Errorf("tcp: could not listen on %s: %w", conf.TurnAddress, err)
 	}
 
-	svr := &InternalServer{lookup: map[string]Entry{}}
+	quota := newAllocationQuota(conf.TurnMaxAllocationsPerIP)
+	svr := &InternalServer{
+		tcp:          newTrackingListener(tcpListener, quota),
+		lookup:       map[string]Entry{},
+		realm:        conf.TurnRealm,
+		transports:   map[string]*transportCounters{"udp": {}, "tcp": {}},
+		permissions:  newPermissions(),
+		quota:        quota,
+		auth:         conf.TurnAuth,
+		secret:       []byte(conf.TurnSecret),
+		users:        users,
+		now:          time.Now,
+		scopeKey:     []byte(util.RandString(32)),
+		selfTestAddr: selfTestAddr(udpListener.LocalAddr()),
+	}
 
-	gen := &Generator{
-		RelayAddressGenerator: generator(conf),
-		IPProvider:            conf.TurnIPProvider,
+	relayGenerator := generator(conf)
+	newGenerator := func(transport string) *Generator {
+		return &Generator{
+			RelayAddressGenerator: relayGenerator,
+			IPProvider:            conf.TurnIPProvider,
+			counters:              svr.transports[transport],
+		}
 	}
 
-	_, err = turn.NewServer(turn.ServerConfig{
-		Realm:       Realm,
+	svr.server, err = turn.NewServer(turn.ServerConfig{
+		Realm:       conf.TurnRealm,
 		AuthHandler: svr.authenticate,
 		ListenerConfigs: []turn.ListenerConfig{
-			{Listener: tcpListener, RelayAddressGenerator: gen},
+			{Listener: svr.tcp, RelayAddressGenerator: newGenerator("tcp"), PermissionHandler: svr.permissions.handle},
 		},
 		PacketConnConfigs: []turn.PacketConnConfig{
-			{PacketConn: udpListener, RelayAddressGenerator: gen},
+			{PacketConn: &quotaPacketConn{PacketConn: udpListener, quota: quota}, RelayAddressGenerator: newGenerator("udp"), PermissionHandler: svr.permissions.handle},
 		},
 	})
 	if err != nil {
+		_ = udpListener.Close()
+		_ = tcpListener.Close()
 		return nil, err
 	}
 
-	log.Info().Str("addr", conf.TurnAddress).Msg("Start TURN/STUN")
+	log.Info().Str("addr", conf.TurnAddress).Str("realm", conf.TurnRealm).Str("auth", conf.TurnAuth).
+		Int("maxAllocationsPerIP", conf.TurnMaxAllocationsPerIP).Msg("Start TURN/STUN")
 	return svr, nil
 }
 
@@ -125,12 +202,13 @@ func generator(conf config.Config) turn.RelayAddressGenerator {
 	return &RelayAddressGeneratorNone{}
 }
 
-func (a *InternalServer) allow(username, password string, addr net.IP) {
+func (a *InternalServer) allow(username, password, scope string, addr net.IP) {
 	a.lock.Lock()
 	defer a.lock.Unlock()
 	a.lookup[username] = Entry{
 		addr:     addr,
-		password: turn.GenerateAuthKey(username, Realm, password),
+		password: turn.GenerateAuthKey(username, a.realm, password),
+		scope:    scope,
 	}
 }
 
@@ -145,31 +223,118 @@ func (a *ExternalServer) Disallow(username string) {
 	// not supported, will expire on TTL
 }
 
+// DisallowRoom revokes the credentials of all sessions of the room, also the ones whose revocation is still delayed
+// by the credential rotation overlap.
+func (a *InternalServer) DisallowRoom(room string) {
+	scope := roomScope(a.scopeKey, room)
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for username, entry := range a.lookup {
+		if entry.scope == scope {
+			delete(a.lookup, username)
+		}
+	}
+}
+
+func (a *ExternalServer) DisallowRoom(room string) {
+	// not supported, will expire on TTL
+}
+
+// Stop closes the listeners and the connections of the clients, then it waits until all allocations are closed and
+// their relay sockets are released.
+func (a *InternalServer) Stop(ctx context.Context) error {
+	if a.server == nil {
+		return nil
+	}
+	err := a.server.Close()
+	a.tcp.closeConns()
+
+	// the TURN server closes the allocations in the background after its listeners are closed.
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for a.allocations() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d TURN allocations are still open: %w", a.allocations(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	log.Info().Msg("Stopped TURN/STUN")
+	return err
+}
+
+func (a *InternalServer) allocations() (count int64) {
+	for _, counters := range a.transports {
+		count += counters.get().Allocations
+	}
+	return count
+}
+
+func (a *ExternalServer) Stop(ctx context.Context) error {
+	// the external server is managed by the operator.
+	return nil
+}
+
+func (a *InternalServer) TTL() time.Duration {
+	return 0
+}
+
+func (a *ExternalServer) TTL() time.Duration {
+	return a.ttl
+}
+
 func (a *InternalServer) authenticate(username, realm string, addr net.Addr) ([]byte, bool) {
-	a.lock.RLock()
-	defer a.lock.RUnlock()
+	if realm != a.realm {
+		log.Debug().Str("ip", addr.String()).Str("username", username).Str("realm", realm).Msg("TURN realm mismatch")
+		return nil, false
+	}
+	if !a.quota.allow(addrTransport(addr), addr) {
+		return nil, false
+	}
 
+	a.lock.RLock()
 	entry, ok := a.lookup[username]
+	a.lock.RUnlock()
+	if ok {
+		log.Debug().Str("ip", addr.String()).Str("realm", realm).Msg("TURN authenticated")
+		return entry.password, true
+	}
 
-	if !ok {
-		log.Debug().Interface("addr", addr).Str("username", username).Msg("TURN username not found")
-		return nil, false
+	if password, ok := a.password(username); ok {
+		log.Debug().Str("ip", addr.String()).Str("realm", realm).Str("auth", a.auth).Msg("TURN authenticated")
+		return turn.GenerateAuthKey(username, realm, password), true
 	}
 
-	log.Debug().Interface("addr", addr.String()).Str("realm", realm).Msg("TURN authenticated")
-	return entry.password, true
+	log.Debug().Str("ip", addr.String()).Str("username", username).Msg("TURN username not found")
+	return nil, false
 }
 
-func (a *InternalServer) Credentials(id string, addr net.IP) (string, string) {
+func (a *InternalServer) Credentials(room, id string, addr net.IP) (string, string) {
+	scope := roomScope(a.scopeKey, room)
+	username := scope + ":" + id
 	password := util.RandString(20)
-	a.allow(id, password, addr)
-	return id, password
+	a.allow(username, password, scope, addr)
+	return username, password
 }
 
-func (a *ExternalServer) Credentials(id string, addr net.IP) (string, string) {
-	username := fmt.Sprintf("%d:%s", time.Now().Add(a.ttl).Unix(), id)
-	mac := hmac.New(sha1.New, a.secret)
+// Credentials returns TURN REST API credentials, the username is "expiry:scope:id". The scope is signed with the
+// password, so the credentials cannot be changed to the scope of another room.
+func (a *ExternalServer) Credentials(room, id string, addr net.IP) (string, string) {
+	username := fmt.Sprintf("%d:%s:%s", time.Now().Add(a.ttl).Unix(), roomScope(a.secret, room), id)
+	return username, sharedSecretPassword(a.secret, username)
+}
+
+// roomScope identifies the room in TURN usernames without revealing its name in the logs of the TURN server. The
+// prefix keeps the scope from being parsed as expiry of shared secret credentials.
+func roomScope(key []byte, room string) string {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(room))
+	return "r" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// sharedSecretPassword returns the password of the TURN REST API credentials, the username is "expiry:id".
+func sharedSecretPassword(secret []byte, username string) string {
+	mac := hmac.New(sha1.New, secret)
 	_, _ = mac.Write([]byte(username))
-	password := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-	return username, password
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
 }
@@ -0,0 +1,363 @@
+package turn
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/pion/turn/v2"
+	"github.com/screego/server/config"
+	"github.com/screego/server/config/ipdns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalServer_realm(t *testing.T) {
+	svr := &InternalServer{lookup: map[string]Entry{}, realm: "example.org"}
+	username, password := svr.Credentials("room", "id", net.ParseIP("127.0.0.1"))
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+
+	key, ok := svr.authenticate(username, "example.org", addr)
+	assert.True(t, ok)
+	assert.Equal(t, turn.GenerateAuthKey(username, "example.org", password), key)
+
+	_, ok = svr.authenticate(username, "screego", addr)
+	assert.False(t, ok)
+}
+
+func TestStats(t *testing.T) {
+	svr := &InternalServer{
+		transports:  map[string]*transportCounters{"udp": {}, "tcp": {}},
+		permissions: newPermissions(),
+	}
+
+	relay, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer peer.Close()
+
+	conn := svr.transports["udp"].track(relay)
+	_, err = conn.WriteTo([]byte("hello"), peer.LocalAddr())
+	require.NoError(t, err)
+	_, err = peer.WriteTo([]byte("hi"), relay.LocalAddr())
+	require.NoError(t, err)
+	_, _, err = conn.ReadFrom(make([]byte, 10))
+	require.NoError(t, err)
+
+	clientAddr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+	assert.True(t, svr.permissions.handle(clientAddr, net.ParseIP("10.0.0.1")))
+	assert.True(t, svr.permissions.handle(clientAddr, net.ParseIP("10.0.0.1")))
+	assert.True(t, svr.permissions.handle(clientAddr, net.ParseIP("10.0.0.2")))
+
+	assert.Equal(t, Stats{
+		Allocations: 1,
+		Permissions: 2,
+		BytesIn:     2,
+		BytesOut:    5,
+		Transports: map[string]TransportStats{
+			"udp": {Allocations: 1, AllocationsTotal: 1, BytesIn: 2, BytesOut: 5},
+			"tcp": {},
+		},
+	}, svr.Stats())
+
+	require.NoError(t, conn.Close())
+	_ = conn.Close()
+	stats := svr.Stats()
+	assert.Equal(t, int64(0), stats.Allocations, "closed allocations are only subtracted once")
+	assert.Equal(t, uint64(1), stats.Transports["udp"].AllocationsTotal)
+}
+
+func TestPermissions_expire(t *testing.T) {
+	now := time.Now()
+	permissions := newPermissions()
+	permissions.now = func() time.Time { return now }
+	clientAddr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+
+	permissions.handle(clientAddr, net.ParseIP("10.0.0.1"))
+	now = now.Add(permissionLifetime - time.Second)
+	permissions.handle(clientAddr, net.ParseIP("10.0.0.2"))
+	assert.Equal(t, 2, permissions.count())
+
+	now = now.Add(time.Second)
+	assert.Equal(t, 1, permissions.count(), "permissions expire without refresh")
+}
+
+type fakeUsers map[string]string
+
+func (u fakeUsers) TURNPassword(user string) (string, bool) {
+	password, ok := u[user]
+	return password, ok
+}
+
+func TestInternalServer_sharedSecret(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svr := &InternalServer{lookup: map[string]Entry{}, realm: "screego", auth: config.TurnAuthSharedSecret,
+		secret: []byte("secret"), now: func() time.Time { return now }}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+
+	username := "1700000060:alice"
+	password := sharedSecretPassword([]byte("secret"), username)
+	key, ok := svr.authenticate(username, "screego", addr)
+	assert.True(t, ok)
+	assert.Equal(t, turn.GenerateAuthKey(username, "screego", password), key)
+
+	now = now.Add(2 * time.Minute)
+	_, ok = svr.authenticate(username, "screego", addr)
+	assert.False(t, ok, "expired")
+	_, ok = svr.authenticate("alice", "screego", addr)
+	assert.False(t, ok, "missing expiry")
+
+	sessionName, sessionPassword := svr.Credentials("room", "id", net.ParseIP("127.0.0.1"))
+	key, ok = svr.authenticate(sessionName, "screego", addr)
+	assert.True(t, ok)
+	assert.Equal(t, turn.GenerateAuthKey(sessionName, "screego", sessionPassword), key)
+}
+
+func TestInternalServer_users(t *testing.T) {
+	users := fakeUsers{"alice": "alice-turn"}
+	svr := &InternalServer{lookup: map[string]Entry{}, realm: "screego", auth: config.TurnAuthUsers, users: users}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+
+	key, ok := svr.authenticate("alice", "screego", addr)
+	assert.True(t, ok)
+	assert.Equal(t, turn.GenerateAuthKey("alice", "screego", "alice-turn"), key)
+	_, ok = svr.authenticate("bob", "screego", addr)
+	assert.False(t, ok)
+	_, ok = svr.authenticate("alice", "other", addr)
+	assert.False(t, ok)
+
+	svr.auth = config.TurnAuthEphemeral
+	_, ok = svr.authenticate("alice", "screego", addr)
+	assert.False(t, ok)
+}
+
+func TestInternalServer_roomScope(t *testing.T) {
+	svr := &InternalServer{lookup: map[string]Entry{}, realm: "screego", auth: config.TurnAuthSharedSecret,
+		secret: []byte("secret"), scopeKey: []byte("key"), now: time.Now}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+
+	first, _ := svr.Credentials("first", "id", net.ParseIP("127.0.0.1"))
+	second, _ := svr.Credentials("second", "id", net.ParseIP("127.0.0.1"))
+	assert.Equal(t, roomScope([]byte("key"), "first")+":id", first)
+	assert.NotEqual(t, first, second, "the same session id in another room")
+	assert.NotContains(t, first, "first", "the room name isn't revealed")
+
+	svr.DisallowRoom("first")
+	_, ok := svr.authenticate(first, "screego", addr)
+	assert.False(t, ok, "the room is closed")
+	_, ok = svr.authenticate(second, "screego", addr)
+	assert.True(t, ok)
+}
+
+func TestExternalServer_roomScope(t *testing.T) {
+	svr := &ExternalServer{secret: []byte("secret"), ttl: time.Hour}
+	username, password := svr.Credentials("room", "id", net.ParseIP("127.0.0.1"))
+
+	expiry, rest, _ := strings.Cut(username, ":")
+	assert.Equal(t, roomScope([]byte("secret"), "room")+":id", rest)
+	assert.Equal(t, sharedSecretPassword([]byte("secret"), username), password)
+
+	// the internal server with the same secret verifies it like coturn.
+	internal := &InternalServer{lookup: map[string]Entry{}, realm: "screego", auth: config.TurnAuthSharedSecret,
+		secret: []byte("secret"), now: time.Now}
+	addr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 5000}
+	key, ok := internal.authenticate(username, "screego", addr)
+	assert.True(t, ok)
+	assert.Equal(t, turn.GenerateAuthKey(username, "screego", password), key)
+
+	other := expiry + ":" + roomScope([]byte("secret"), "other") + ":id"
+	key, ok = internal.authenticate(other, "screego", addr)
+	assert.True(t, ok, "the password is derived from the username")
+	assert.NotEqual(t, turn.GenerateAuthKey(other, "screego", password), key, "the password doesn't work for another room")
+}
+
+func TestInternalServer_stop(t *testing.T) {
+	// find a port that is free for tcp and udp.
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := probe.Addr().String()
+	require.NoError(t, probe.Close())
+
+	server, err := newInternalServer(config.Config{TurnAddress: address, TurnRealm: "screego", TurnAuth: config.TurnAuthEphemeral,
+		TurnIPProvider: &ipdns.Static{V4: net.ParseIP("127.0.0.1")}}, nil)
+	require.NoError(t, err)
+	svr := server.(*InternalServer)
+
+	username, password := svr.Credentials("room", "id", net.ParseIP("127.0.0.1"))
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	client, err := turn.NewClient(&turn.ClientConfig{STUNServerAddr: address, TURNServerAddr: address, Conn: conn,
+		Username: username, Password: password, Realm: "screego"})
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.Listen())
+	_, err = client.Allocate()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), svr.Stats().Allocations)
+
+	control, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer control.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, svr.Stop(ctx))
+	assert.Equal(t, int64(0), svr.Stats().Allocations)
+
+	require.NoError(t, control.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = control.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF, "the connections of the clients are closed")
+
+	udp, err := net.ListenPacket("udp", address)
+	require.NoError(t, err, "the udp port is released")
+	_ = udp.Close()
+	tcp, err := net.Listen("tcp", address)
+	require.NoError(t, err, "the tcp port is released")
+	_ = tcp.Close()
+}
+
+func TestAllocationQuota(t *testing.T) {
+	now := time.Now()
+	quota := newAllocationQuota(2)
+	quota.now = func() time.Time { return now }
+	first := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}
+	second := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5001}
+	third := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5002}
+	other := &net.UDPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5000}
+
+	response := func(method stun.Method, lifetime time.Duration) []byte {
+		value := make([]byte, 4)
+		binary.BigEndian.PutUint32(value, uint32(lifetime/time.Second))
+		msg, err := stun.Build(stun.TransactionID, stun.NewType(method, stun.ClassSuccessResponse),
+			stun.RawAttribute{Type: stun.AttrLifetime, Value: value})
+		require.NoError(t, err)
+		return msg.Raw
+	}
+
+	assert.True(t, quota.allow("udp", first))
+	quota.observe("udp", first, response(stun.MethodAllocate, 10*time.Minute))
+	assert.True(t, quota.allow("tcp", second))
+	quota.observe("tcp", second, response(stun.MethodAllocate, 5*time.Minute))
+	quota.observe("udp", third, []byte("relayed data"))
+
+	assert.False(t, quota.allow("udp", third))
+	assert.True(t, quota.allow("udp", first), "requests of active allocations are allowed")
+	assert.True(t, quota.allow("udp", other))
+	assert.Equal(t, uint64(1), quota.rejections())
+
+	quota.observe("udp", first, response(stun.MethodRefresh, 0))
+	assert.True(t, quota.allow("udp", third), "the refresh with lifetime 0 deletes the allocation")
+	quota.observe("udp", third, response(stun.MethodAllocate, 10*time.Minute))
+	assert.False(t, quota.allow("udp", first))
+
+	now = now.Add(5 * time.Minute)
+	assert.True(t, quota.allow("udp", first), "allocations expire without refresh")
+
+	var disabled *allocationQuota
+	assert.True(t, disabled.allow("udp", first))
+	assert.Equal(t, uint64(0), disabled.rejections())
+}
+
+func TestInternalServer_maxAllocationsPerIP(t *testing.T) {
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := probe.Addr().String()
+	require.NoError(t, probe.Close())
+
+	server, err := newInternalServer(config.Config{TurnAddress: address, TurnRealm: "screego", TurnAuth: config.TurnAuthEphemeral,
+		TurnMaxAllocationsPerIP: 1, TurnIPProvider: &ipdns.Static{V4: net.ParseIP("127.0.0.1")}}, nil)
+	require.NoError(t, err)
+	svr := server.(*InternalServer)
+	defer svr.Stop(context.Background())
+
+	username, password := svr.Credentials("room", "id", net.ParseIP("127.0.0.1"))
+	newClient := func() *turn.Client {
+		conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		client, err := turn.NewClient(&turn.ClientConfig{STUNServerAddr: address, TURNServerAddr: address, Conn: conn,
+			Username: username, Password: password, Realm: "screego", RTO: 100 * time.Millisecond})
+		require.NoError(t, err)
+		t.Cleanup(client.Close)
+		require.NoError(t, client.Listen())
+		return client
+	}
+
+	relay, err := newClient().Allocate()
+	require.NoError(t, err)
+	_, err = newClient().Allocate()
+	assert.Error(t, err)
+	assert.Equal(t, uint64(1), svr.Stats().AllocationsRejected)
+
+	require.NoError(t, relay.Close())
+	_, err = newClient().Allocate()
+	assert.NoError(t, err, "the closed allocation doesn't count")
+}
+
+func TestStart_retry(t *testing.T) {
+	blocker, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := blocker.Addr().String()
+
+	var delays []time.Duration
+	old := startRetrySleep
+	startRetrySleep = func(delay time.Duration) {
+		delays = append(delays, delay)
+		if len(delays) == 2 {
+			_ = blocker.Close()
+		}
+	}
+	defer func() { startRetrySleep = old }()
+
+	conf := config.Config{TurnAddress: address, TurnRealm: "screego", TurnAuth: config.TurnAuthEphemeral,
+		TurnIPProvider: &ipdns.Static{V4: net.ParseIP("127.0.0.1")}, TurnStartRetries: 3, TurnStartRetryDelay: time.Second}
+	server, err := Start(conf, nil)
+	require.NoError(t, err)
+	defer server.Stop(context.Background())
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, delays)
+}
+
+func TestStart_retryGivesUp(t *testing.T) {
+	blocker, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer blocker.Close()
+
+	attempts := 0
+	old := startRetrySleep
+	startRetrySleep = func(time.Duration) { attempts++ }
+	defer func() { startRetrySleep = old }()
+
+	conf := config.Config{TurnAddress: blocker.Addr().String(), TurnStartRetries: 2}
+	_, err = Start(conf, nil)
+	assert.ErrorIs(t, err, syscall.EADDRINUSE)
+	assert.Equal(t, 2, attempts)
+
+	attempts = 0
+	conf.TurnAddress = "invalid"
+	_, err = Start(conf, nil)
+	assert.Error(t, err)
+	assert.Equal(t, 0, attempts, "config errors are not retried")
+}
+
+func TestInternalServer_selfTest(t *testing.T) {
+	server, err := newInternalServer(config.Config{TurnAddress: "0.0.0.0:0", TurnRealm: "screego", TurnAuth: config.TurnAuthEphemeral,
+		TurnIPProvider: &ipdns.Static{V4: net.ParseIP("127.0.0.1")}}, nil)
+	require.NoError(t, err)
+	svr := server.(*InternalServer)
+	defer svr.Stop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := svr.SelfTest(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, relay, "127.0.0.1:")
+	assert.Empty(t, svr.lookup, "the credentials are disallowed")
+}
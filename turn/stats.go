@@ -0,0 +1,136 @@
+package turn
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// permissionLifetime is how long a TURN permission is valid without refresh, see RFC 5766 section 8.
+const permissionLifetime = 5 * time.Minute
+
+// Stats are the statistics of the internal TURN server. Bytes are counted on the relay sockets, BytesIn were received
+// from peers and BytesOut were sent to peers. AllocationsRejected counts the requests that were rejected because the
+// client IP reached SCREEGO_TURN_MAX_ALLOCATIONS_PER_IP.
+type Stats struct {
+	Allocations         int64                     `json:"allocations"`
+	AllocationsRejected uint64                    `json:"allocationsRejected"`
+	Permissions         int                       `json:"permissions"`
+	BytesIn             uint64                    `json:"bytesIn"`
+	BytesOut            uint64                    `json:"bytesOut"`
+	Transports          map[string]TransportStats `json:"transports"`
+}
+
+// TransportStats are the statistics of the allocations that were requested over a transport.
+type TransportStats struct {
+	Allocations      int64  `json:"allocations"`
+	AllocationsTotal uint64 `json:"allocationsTotal"`
+	BytesIn          uint64 `json:"bytesIn"`
+	BytesOut         uint64 `json:"bytesOut"`
+}
+
+// transportCounters are updated by the relay sockets, they are atomic so that reading the stats doesn't slow down the
+// relay.
+type transportCounters struct {
+	// the 64 bit fields must be first to be aligned on 32 bit platforms.
+	allocations      int64
+	allocationsTotal uint64
+	bytesIn          uint64
+	bytesOut         uint64
+}
+
+func (c *transportCounters) get() TransportStats {
+	return TransportStats{
+		Allocations:      atomic.LoadInt64(&c.allocations),
+		AllocationsTotal: atomic.LoadUint64(&c.allocationsTotal),
+		BytesIn:          atomic.LoadUint64(&c.bytesIn),
+		BytesOut:         atomic.LoadUint64(&c.bytesOut),
+	}
+}
+
+// track counts the allocation and the relayed bytes of the relay socket.
+func (c *transportCounters) track(conn net.PacketConn) net.PacketConn {
+	atomic.AddInt64(&c.allocations, 1)
+	atomic.AddUint64(&c.allocationsTotal, 1)
+	return &countingPacketConn{PacketConn: conn, counters: c}
+}
+
+type countingPacketConn struct {
+	net.PacketConn
+	counters *transportCounters
+	closed   int32
+}
+
+func (c *countingPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	if n > 0 {
+		atomic.AddUint64(&c.counters.bytesIn, uint64(n))
+	}
+	return n, addr, err
+}
+
+func (c *countingPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(p, addr)
+	if n > 0 {
+		atomic.AddUint64(&c.counters.bytesOut, uint64(n))
+	}
+	return n, err
+}
+
+func (c *countingPacketConn) Close() error {
+	if atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		atomic.AddInt64(&c.counters.allocations, -1)
+	}
+	return c.PacketConn.Close()
+}
+
+// permissions tracks the TURN permissions. The TURN server doesn't report expired permissions or deleted
+// allocations, so a permission counts as active until its lifetime has passed since the last refresh.
+type permissions struct {
+	lock    sync.Mutex
+	expires map[string]time.Time
+	now     func() time.Time
+}
+
+func newPermissions() *permissions {
+	return &permissions{expires: map[string]time.Time{}, now: time.Now}
+}
+
+// handle is the permission handler of the TURN server, it is called when a permission is created or refreshed.
+func (p *permissions) handle(clientAddr net.Addr, peerIP net.IP) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.expires[clientAddr.String()+"/"+peerIP.String()] = p.now().Add(permissionLifetime)
+	return true
+}
+
+func (p *permissions) count() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	now := p.now()
+	for key, expires := range p.expires {
+		if !expires.After(now) {
+			delete(p.expires, key)
+		}
+	}
+	return len(p.expires)
+}
+
+func (a *InternalServer) Stats() Stats {
+	stats := Stats{Permissions: a.permissions.count(), AllocationsRejected: a.quota.rejections(),
+		Transports: map[string]TransportStats{}}
+	for transport, counters := range a.transports {
+		transportStats := counters.get()
+		stats.Allocations += transportStats.Allocations
+		stats.BytesIn += transportStats.BytesIn
+		stats.BytesOut += transportStats.BytesOut
+		stats.Transports[transport] = transportStats
+	}
+	return stats
+}
+
+// Stats of an external TURN server are unknown.
+func (a *ExternalServer) Stats() Stats {
+	return Stats{}
+}
@@ -14,11 +14,12 @@ import (
 var buildFiles embed.FS
 var files, _ = fs.Sub(buildFiles, "build")
 
-// Register registers the ui on the root path.
-func Register(r *mux.Router) {
+// Register registers the ui on the router, basePath is the prefix of the router that is removed before the assets are
+// looked up.
+func Register(r *mux.Router, basePath string) {
 	r.Handle("/", serveFile("index.html", "text/html"))
 	r.Handle("/index.html", serveFile("index.html", "text/html"))
-	r.Handle("/assets/{resource}", http.FileServer(http.FS(files)))
+	r.Handle("/assets/{resource}", http.StripPrefix(basePath, http.FileServer(http.FS(files))))
 
 	r.Handle("/favicon.ico", serveFile("favicon.ico", "image/x-icon"))
 	r.Handle("/logo.svg", serveFile("logo.svg", "image/svg+xml"))
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister_basePath(t *testing.T) {
+	embedded := files
+	defer func() { files = embedded }()
+	// the assets are only embedded after the ui was built.
+	files = fstest.MapFS{
+		"index.html":           {Data: []byte(`<script src="./assets/index.js"></script>`)},
+		"assets/index.js":      {Data: []byte("console.log('screego')")},
+		"favicon.ico":          {},
+		"logo.svg":             {},
+		"apple-touch-icon.png": {},
+		"og-banner.png":        {},
+	}
+
+	for _, basePath := range []string{"", "/screego"} {
+		root := mux.NewRouter()
+		router := root
+		if basePath != "" {
+			router = root.PathPrefix(basePath).Subrouter()
+		}
+		Register(router, basePath)
+
+		recorder := httptest.NewRecorder()
+		root.ServeHTTP(recorder, httptest.NewRequest("GET", basePath+"/assets/index.js", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code, basePath)
+		assert.Equal(t, "console.log('screego')", recorder.Body.String(), basePath)
+
+		recorder = httptest.NewRecorder()
+		root.ServeHTTP(recorder, httptest.NewRequest("GET", basePath+"/assets/missing.js", nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, basePath)
+	}
+}
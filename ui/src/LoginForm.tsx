@@ -11,17 +11,28 @@ import {
 } from '@mui/material';
 import makeStyles from '@mui/styles/makeStyles';
 import {green} from '@mui/material/colors';
+import {urlWithSlash} from './url';
 
-export const LoginForm = ({config: {login}, hide}: {config: UseConfig; hide?: () => void}) => {
+export const LoginForm = ({
+    config: {login, passwordLogin = true, oidcLogin},
+    hide,
+}: {
+    config: UseConfig;
+    hide?: () => void;
+}) => {
     const [user, setUser] = React.useState('');
     const [pass, setPass] = React.useState('');
+    const [code, setCode] = React.useState('');
+    const [totp, setTotp] = React.useState(false);
     const [loading, setLoading] = React.useState(false);
     const submit = async (event: {preventDefault: () => void}) => {
         event.preventDefault();
         setLoading(true);
-        login(user, pass)
-            .then(() => {
+        login(user, pass, totp ? code : undefined)
+            .then((result) => {
                 setLoading(false);
+                setTotp(result === 'totp');
+                setCode('');
             })
             .catch(() => setLoading(false));
     };
@@ -37,34 +48,61 @@ export const LoginForm = ({config: {login}, hide}: {config: UseConfig; hide?: ()
                             </Button>
                         ) : undefined}
                     </div>
-                    <TextField
-                        fullWidth
-                        value={user}
-                        onChange={(e) => setUser(e.target.value)}
-                        label="Username"
-                        size="small"
-                        margin="dense"
-                    />
-                    <TextField
-                        fullWidth
-                        value={pass}
-                        type="password"
-                        onChange={(e) => setPass(e.target.value)}
-                        label="Password"
-                        size="small"
-                        margin="dense"
-                    />
-                    <Box marginTop={1}>
-                        <LoadingButton
-                            type="submit"
-                            loading={loading}
-                            onClick={submit}
-                            fullWidth
-                            variant="contained"
-                        >
-                            Login
-                        </LoadingButton>
-                    </Box>
+                    {passwordLogin ? (
+                        <>
+                            <TextField
+                                fullWidth
+                                value={user}
+                                onChange={(e) => setUser(e.target.value)}
+                                label="Username"
+                                size="small"
+                                margin="dense"
+                            />
+                            <TextField
+                                fullWidth
+                                value={pass}
+                                type="password"
+                                onChange={(e) => setPass(e.target.value)}
+                                label="Password"
+                                size="small"
+                                margin="dense"
+                            />
+                            {totp ? (
+                                <TextField
+                                    fullWidth
+                                    autoFocus
+                                    value={code}
+                                    onChange={(e) => setCode(e.target.value)}
+                                    label="Code of your authenticator app"
+                                    inputProps={{inputMode: 'numeric', autoComplete: 'one-time-code'}}
+                                    size="small"
+                                    margin="dense"
+                                />
+                            ) : undefined}
+                            <Box marginTop={1}>
+                                <LoadingButton
+                                    type="submit"
+                                    loading={loading}
+                                    onClick={submit}
+                                    fullWidth
+                                    variant="contained"
+                                >
+                                    Login
+                                </LoadingButton>
+                            </Box>
+                        </>
+                    ) : undefined}
+                    {oidcLogin ? (
+                        <Box marginTop={1}>
+                            <Button
+                                href={`${urlWithSlash}auth/oidc/login`}
+                                fullWidth
+                                variant={passwordLogin ? 'outlined' : 'contained'}
+                            >
+                                Login with SSO
+                            </Button>
+                        </Box>
+                    ) : undefined}
                 </form>
             </FormControl>
         </div>
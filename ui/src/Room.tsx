@@ -24,6 +24,9 @@ const flags = (user: RoomUser) => {
     if (user.owner) {
         result.push('Owner');
     }
+    if (user.guest) {
+        result.push('Guest');
+    }
     if (user.streaming) {
         result.push('Streaming');
     }
@@ -21,16 +21,19 @@ const CreateRoom = ({room, config}: Pick<UseRoom, 'room'> & {config: UIConfig})
     const [id, setId] = React.useState(() => getRoomFromURL() ?? config.roomName);
     const mode = authModeToRoomMode(config.authMode, config.loggedIn);
     const [ownerLeave, setOwnerLeave] = React.useState(config.closeRoomWhenOwnerLeaves);
+    const canCreate = config.canCreateRooms !== false;
     const submit = () =>
-        room({
-            type: 'create',
-            payload: {
-                mode,
-                closeOnOwnerLeave: ownerLeave,
-                joinIfExist: true,
-                id: id || undefined,
-            },
-        });
+        canCreate
+            ? room({
+                  type: 'create',
+                  payload: {
+                      mode,
+                      closeOnOwnerLeave: ownerLeave,
+                      joinIfExist: true,
+                      id: id || undefined,
+                  },
+              })
+            : room({type: 'join', payload: {id}});
     return (
         <div>
             <FormControl fullWidth>
@@ -41,15 +44,17 @@ const CreateRoom = ({room, config}: Pick<UseRoom, 'room'> & {config: UIConfig})
                     label="id"
                     margin="dense"
                 />
-                <FormControlLabel
-                    control={
-                        <Checkbox
-                            checked={ownerLeave}
-                            onChange={(_, checked) => setOwnerLeave(checked)}
-                        />
-                    }
-                    label="Close Room after you leave"
-                />
+                {canCreate && (
+                    <FormControlLabel
+                        control={
+                            <Checkbox
+                                checked={ownerLeave}
+                                onChange={(_, checked) => setOwnerLeave(checked)}
+                            />
+                        }
+                        label="Close Room after you leave"
+                    />
+                )}
                 <Box paddingBottom={0.5}>
                     <Typography>
                         Nat Traversal via:{' '}
@@ -63,7 +68,7 @@ const CreateRoom = ({room, config}: Pick<UseRoom, 'room'> & {config: UIConfig})
                     </Typography>
                 </Box>
                 <Button onClick={submit} fullWidth variant="contained">
-                    Create or Join a Room
+                    {canCreate ? 'Create or Join a Room' : 'Join a Room'}
                 </Button>
             </FormControl>
         </div>
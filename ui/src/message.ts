@@ -12,6 +12,10 @@ export interface UIConfig {
     version: string;
     roomName: string;
     closeRoomWhenOwnerLeaves: boolean;
+    passwordLogin?: boolean;
+    oidcLogin?: boolean;
+    canCreateRooms?: boolean;
+    webSocketUrl?: string;
 }
 
 export interface RoomConfiguration {
@@ -62,6 +66,8 @@ export interface RoomUser {
     streaming: boolean;
     you: boolean;
     owner: boolean;
+    guest?: boolean;
+    idle?: boolean;
 }
 
 export interface P2PMessage<T> {
@@ -83,6 +89,10 @@ export type StopShare = Typed<{}, 'stopshare'>;
 export type RoomCreate = Typed<RoomConfiguration & {joinIfExist?: boolean}, 'create'>;
 export type JoinRoom = Typed<JoinConfiguration, 'join'>;
 export type EndShare = Typed<string, 'endshare'>;
+export type ProtocolVersion = Typed<{version: number}, 'protocol_version'>;
+export type Ping = Typed<{}, 'ping'>;
+export type Pong = Typed<{}, 'pong'>;
+export type Broadcast = Typed<{level: 'info' | 'warning' | 'error'; message: string}, 'broadcast'>;
 
 export type IncomingMessage =
     | Room
@@ -93,7 +103,10 @@ export type IncomingMessage =
     | ClientICECandidate
     | HostOffer
     | EndShare
-    | ClientAnswer;
+    | ClientAnswer
+    | ProtocolVersion
+    | Ping
+    | Broadcast;
 
 export type OutgoingMessage =
     | RoomCreate
@@ -104,4 +117,5 @@ export type OutgoingMessage =
     | HostOffer
     | StopShare
     | ClientAnswer
-    | StartSharing;
+    | StartSharing
+    | Pong;
@@ -3,8 +3,11 @@ import {useSnackbar} from 'notistack';
 import React from 'react';
 import {urlWithSlash} from './url';
 
+// totp: the password was correct, the code of the authenticator app is needed.
+export type LoginResult = 'ok' | 'totp' | 'failed';
+
 export interface UseConfig extends UIConfig {
-    login: (username: string, password: string) => Promise<void>;
+    login: (username: string, password: string, code?: string) => Promise<LoginResult>;
     refetch: () => void;
     logout: () => Promise<void>;
     loading: boolean;
@@ -28,18 +31,29 @@ export const useConfig = (): UseConfig => {
             .then(setConfig);
     }, [setConfig]);
 
-    const login = async (username: string, password: string) => {
+    const login = async (
+        username: string,
+        password: string,
+        code?: string
+    ): Promise<LoginResult> => {
         const body = new FormData();
         body.set('user', username);
         body.set('pass', password);
+        if (code) {
+            body.set('code', code);
+        }
         const result = await fetch(`${urlWithSlash}login`, {method: 'POST', body});
         const json = await result.json();
         if (result.status !== 200) {
+            if (json.totpRequired && !code) {
+                return 'totp';
+            }
             enqueueSnackbar('Login Failed: ' + json.message, {variant: 'error'});
-        } else {
-            await refetch();
-            enqueueSnackbar('Logged in!', {variant: 'success'});
+            return json.totpRequired ? 'totp' : 'failed';
         }
+        await refetch();
+        enqueueSnackbar('Logged in!', {variant: 'success'});
+        return 'ok';
     };
 
     const logout = async () => {
@@ -15,6 +15,9 @@ import {urlWithSlash} from './url';
 import {authModeToRoomMode} from './useConfig';
 import {getFromURL, useRoomID} from './useRoomID';
 
+// The websocket protocol version the ui speaks, see ws/compat.go.
+const protocolVersion = 2;
+
 export type RoomState = false | ConnectedRoom;
 export type ConnectedRoom = {
     ws: WebSocket;
@@ -168,7 +171,9 @@ export const useRoom = (config: UIConfig): UseRoom => {
         (create) => {
             return new Promise<void>((resolve) => {
                 const ws = (conn.current = new WebSocket(
-                    urlWithSlash.replace('http', 'ws') + 'stream'
+                    (config.webSocketUrl ?? urlWithSlash.replace('http', 'ws') + 'stream') +
+                        '?protocol=' +
+                        protocolVersion
                 ));
                 const send = (message: OutgoingMessage) => {
                     if (ws.readyState === ws.OPEN) ws.send(JSON.stringify(message));
@@ -176,6 +181,9 @@ export const useRoom = (config: UIConfig): UseRoom => {
                 let first = true;
                 ws.onmessage = (data) => {
                     const event: IncomingMessage = JSON.parse(data.data);
+                    if (event.type === 'protocol_version') {
+                        return;
+                    }
                     if (first) {
                         first = false;
                         if (event.type === 'room') {
@@ -274,6 +282,15 @@ export const useRoom = (config: UIConfig): UseRoom => {
                         case 'hostice':
                             client.current[event.payload.sid]?.addIceCandidate(event.payload.value);
                             return;
+                        case 'ping':
+                            send({type: 'pong', payload: {}});
+                            return;
+                        case 'broadcast':
+                            enqueueSnackbar(event.payload.message, {
+                                variant: event.payload.level,
+                                persist: event.payload.level !== 'info',
+                            });
+                            return;
                         case 'endshare':
                             client.current[event.payload]?.close();
                             host.current[event.payload]?.close();
@@ -311,7 +328,7 @@ export const useRoom = (config: UIConfig): UseRoom => {
                 };
             });
         },
-        [setState, enqueueSnackbar, setRoomID]
+        [setState, enqueueSnackbar, setRoomID, config.webSocketUrl]
     );
 
     const share = async () => {
@@ -12,6 +12,9 @@ export default defineConfig({
                 target: 'http://localhost:5050',
                 ws: true,
             },
+            '^/(api|join|auth)/': {
+                target: 'http://localhost:5050',
+            },
         },
     },
     build: {outDir: 'build/'},
@@ -0,0 +1,68 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/screego/server/audit"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log, err := audit.Open(path)
+	require.NoError(t, err)
+	defer log.Close()
+
+	rooms := newTestRooms(config.Config{})
+	rooms.Audit = log
+	owner := newTestClient("alice")
+	bob := newTestClient("")
+	carol := newTestClient("")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	require.NoError(t, (&Join{ID: "room", UserName: "bob"}).Execute(rooms, bob))
+	require.NoError(t, (&Join{ID: "room", UserName: "carol"}).Execute(rooms, carol))
+	require.NoError(t, (&KickUser{Room: "room", ID: bob.ID}).Execute(rooms, owner))
+	require.NoError(t, (&BanUser{Room: "room", ID: carol.ID, Duration: 60}).Execute(rooms, owner))
+	bob.RoomID = "room"
+	require.NoError(t, (&Disconnected{}).Execute(rooms, bob))
+	carol.RoomID = "room"
+	require.NoError(t, (&Disconnected{}).Execute(rooms, carol))
+	owner.RoomID = "room"
+	require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var entries []audit.Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry audit.Entry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+
+	expected := []audit.Entry{
+		{EventType: audit.RoomCreate, ActorUsername: "alice", RoomID: "room", SourceIP: "127.0.0.1", SessionID: owner.ID.String()},
+		{EventType: audit.RoomJoin, ActorUsername: "bob", RoomID: "room", SourceIP: "127.0.0.1", SessionID: bob.ID.String()},
+		{EventType: audit.RoomJoin, ActorUsername: "carol", RoomID: "room", SourceIP: "127.0.0.1", SessionID: carol.ID.String()},
+		{EventType: audit.Kick, ActorUsername: "alice", TargetUsername: "bob", RoomID: "room", SourceIP: "127.0.0.1", SessionID: owner.ID.String()},
+		{EventType: audit.Ban, ActorUsername: "alice", TargetUsername: "carol", RoomID: "room", SourceIP: "127.0.0.1", SessionID: owner.ID.String()},
+		{EventType: audit.RoomLeave, ActorUsername: "bob", RoomID: "room", SourceIP: "127.0.0.1", SessionID: bob.ID.String()},
+		{EventType: audit.RoomLeave, ActorUsername: "carol", RoomID: "room", SourceIP: "127.0.0.1", SessionID: carol.ID.String()},
+		{EventType: audit.RoomLeave, ActorUsername: "alice", RoomID: "room", SourceIP: "127.0.0.1", SessionID: owner.ID.String()},
+		{EventType: audit.RoomClose, ActorUsername: "alice", RoomID: "room", Reason: audit.ReasonEmpty},
+	}
+	require.Len(t, entries, len(expected))
+	for i := range entries {
+		assert.False(t, entries[i].Timestamp.IsZero())
+		entries[i].Timestamp = expected[i].Timestamp
+		expected[i].Outcome = audit.OutcomeSuccess
+	}
+	assert.Equal(t, expected, entries)
+}
@@ -0,0 +1,54 @@
+package ws
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/ws/outgoing"
+)
+
+// maxBroadcastLength is the maximum length of a broadcast message in characters.
+const maxBroadcastLength = 500
+
+var (
+	ErrInvalidBroadcastLevel = errors.New("level must be info, warning or error")
+	ErrInvalidBroadcast      = errors.New("message must be between 1 and 500 characters")
+)
+
+// BroadcastLevel is the severity of a broadcast, the ui displays it in a matching color.
+type BroadcastLevel string
+
+const (
+	BroadcastInfo    BroadcastLevel = "info"
+	BroadcastWarning BroadcastLevel = "warning"
+	BroadcastError   BroadcastLevel = "error"
+)
+
+// Broadcast sends a notice to every member of every room, e.g. to announce a maintenance. It returns the count of
+// members that received the notice.
+func (r *Rooms) Broadcast(level BroadcastLevel, message, by string) (int, error) {
+	switch level {
+	case BroadcastInfo, BroadcastWarning, BroadcastError:
+	default:
+		return 0, ErrInvalidBroadcastLevel
+	}
+	if length := utf8.RuneCountInString(message); length == 0 || length > maxBroadcastLength {
+		return 0, ErrInvalidBroadcast
+	}
+
+	recipients := 0
+	if err := r.do(func() {
+		msg := outgoing.Broadcast{Level: string(level), Message: message}
+		for _, room := range r.store.ListRooms() {
+			for _, user := range room.Users {
+				user.send(msg)
+				recipients++
+			}
+		}
+	}); err != nil {
+		return 0, err
+	}
+	log.Info().Str("by", by).Str("severity", string(level)).Str("text", message).Int("recipients", recipients).Msg("Broadcast")
+	return recipients, nil
+}
@@ -0,0 +1,55 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcast(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	owner := newTestClient("alice")
+	member := newTestClient("")
+	other := newTestClient("bob")
+	idle := newTestClient("")
+	rooms.do(func() {
+		for _, client := range []ClientInfo{owner, member, other, idle} {
+			require.NoError(t, (&Connected{}).Execute(rooms, client))
+		}
+		require.NoError(t, createRoom(t, rooms, &owner, "room"))
+		require.NoError(t, (&Join{ID: "room"}).Execute(rooms, member))
+		require.NoError(t, createRoom(t, rooms, &other, "other"))
+	})
+	for _, client := range []ClientInfo{owner, member, other, idle} {
+		drain(client)
+	}
+
+	recipients, err := rooms.Broadcast(BroadcastWarning, "restart in 5 minutes", "admin")
+	require.NoError(t, err)
+	assert.Equal(t, 3, recipients)
+	for _, client := range []ClientInfo{owner, member, other} {
+		assert.Equal(t, []outgoing.Message{outgoing.Broadcast{Level: "warning", Message: "restart in 5 minutes"}}, drain(client))
+	}
+	assert.Empty(t, drain(idle), "only members of rooms receive broadcasts")
+}
+
+func TestBroadcast_invalid(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	_, err := rooms.Broadcast("critical", "restart", "admin")
+	assert.Equal(t, ErrInvalidBroadcastLevel, err)
+	_, err = rooms.Broadcast(BroadcastInfo, "", "admin")
+	assert.Equal(t, ErrInvalidBroadcast, err)
+	_, err = rooms.Broadcast(BroadcastInfo, strings.Repeat("ä", maxBroadcastLength+1), "admin")
+	assert.Equal(t, ErrInvalidBroadcast, err)
+	recipients, err := rooms.Broadcast(BroadcastInfo, strings.Repeat("ä", maxBroadcastLength), "admin")
+	assert.NoError(t, err)
+	assert.Zero(t, recipients)
+}
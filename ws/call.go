@@ -0,0 +1,51 @@
+package ws
+
+import "errors"
+
+// ErrStopped is returned by calls into the rooms event loop after it has stopped.
+var ErrStopped = errors.New("the rooms are stopped")
+
+// call executes a function inside the rooms event loop. It is used to access the room state from other goroutines,
+// like http handlers.
+type call struct {
+	f    func()
+	done chan struct{}
+}
+
+func (e *call) Execute(rooms *Rooms, current ClientInfo) error {
+	defer close(e.done)
+	e.f()
+	return nil
+}
+
+// do executes f in the rooms event loop and waits until it has finished. It returns ErrStopped without executing f,
+// if the event loop has stopped.
+func (r *Rooms) do(f func()) error {
+	done := make(chan struct{})
+	if !r.post(ClientMessage{Incoming: &call{f: f, done: done}}) {
+		return ErrStopped
+	}
+	select {
+	case <-done:
+		return nil
+	case <-r.stopped:
+		// the event loop may have executed f before it stopped.
+		select {
+		case <-done:
+			return nil
+		default:
+			return ErrStopped
+		}
+	}
+}
+
+// post passes the message to the rooms event loop. It returns false, if the event loop has stopped and nobody reads
+// the message anymore.
+func (r *Rooms) post(msg ClientMessage) bool {
+	select {
+	case r.Incoming <- msg:
+		return true
+	case <-r.stopped:
+		return false
+	}
+}
@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDo_stopped(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+	require.NoError(t, rooms.Stop(context.Background()))
+
+	done := make(chan error)
+	go func() {
+		done <- rooms.do(func() {
+			t.Error("executed after stop")
+		})
+	}()
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrStopped, err)
+	case <-time.After(time.Second):
+		t.Fatal("do blocked after stop")
+	}
+	_, err := rooms.Stats()
+	assert.Equal(t, ErrStopped, err)
+	assert.False(t, rooms.post(ClientMessage{Incoming: &joinTimeout{}}))
+}
+
+func TestUpgrade_stopped(t *testing.T) {
+	rooms, url := startTestServer(t, config.Config{})
+	require.NoError(t, rooms.Stop(context.Background()))
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {url}})
+	require.NoError(t, err)
+	defer conn.Close()
+	err = readUntilClose(t, conn, time.Second)
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseCodeShutdown, closeErr.Code)
+}
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,7 +11,8 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/rs/xid"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+	"github.com/screego/server/config"
+	"github.com/screego/server/logger"
 	"github.com/screego/server/ws/outgoing"
 )
 
@@ -18,8 +20,16 @@ var ping = func(conn *websocket.Conn) error {
 	return conn.WriteMessage(websocket.PingMessage, nil)
 }
 
-var writeJSON = func(conn *websocket.Conn, v interface{}) error {
-	return conn.WriteJSON(v)
+var writeMessage = func(conn *websocket.Conn, codec Codec, msg outgoing.Message) error {
+	w, err := conn.NextWriter(codec.MessageType())
+	if err != nil {
+		return err
+	}
+	if err := codec.Encode(w, msg); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
 }
 
 const (
@@ -27,10 +37,16 @@ const (
 )
 
 type Client struct {
-	conn *websocket.Conn
-	info ClientInfo
-	once once
-	read chan<- ClientMessage
+	conn         *websocket.Conn
+	codec        Codec
+	info         ClientInfo
+	once         once
+	read         chan<- ClientMessage
+	writeTimeout time.Duration
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	// logger is the privacy logger of the upgrade request, it pseudonymizes the ip in privacy mode.
+	logger *zerolog.Logger
 }
 
 type ClientMessage struct {
@@ -43,31 +59,52 @@ type ClientInfo struct {
 	RoomID            string
 	Authenticated     bool
 	AuthenticatedUser string
-	Write             chan outgoing.Message
-	Close             chan string
-	Addr              net.IP
+	// SessionID is the id of the login session, it is empty for guests.
+	SessionID string
+	Write     chan outgoing.Message
+	Close     chan closeFrame
+	Addr      net.IP
+	QueryName string
+	Protocol  int
+	Observer  bool
+	// Admin may join every room without password or waiting room and kick or ban its users.
+	Admin bool
+	// FileUser is the user of the users file that logged in, the permissions are looked up with it.
+	FileUser string
 }
 
-func newClient(conn *websocket.Conn, req *http.Request, read chan ClientMessage, authenticatedUser string, authenticated, trustProxy bool) *Client {
+func newClient(conn *websocket.Conn, req *http.Request, read chan ClientMessage, authenticatedUser string, authenticated bool, conf config.Config) *Client {
 	ip := conn.RemoteAddr().(*net.TCPAddr).IP
-	if realIP := req.Header.Get("X-Real-IP"); trustProxy && realIP != "" {
+	if realIP := req.Header.Get("X-Real-IP"); conf.TrustProxyHeaders && realIP != "" {
 		ip = net.ParseIP(realIP)
 	}
 
 	client := &Client{
-		conn: conn,
+		conn:  conn,
+		codec: codecFor(conn.Subprotocol()),
 		info: ClientInfo{
 			Authenticated:     authenticated,
 			AuthenticatedUser: authenticatedUser,
 			ID:                xid.New(),
 			RoomID:            "",
 			Addr:              ip,
-			Write:             make(chan outgoing.Message, 1),
-			Close:             make(chan string, 1),
+			QueryName:         req.URL.Query().Get("name"),
+			Protocol:          parseProtocol(req.URL.Query().Get("protocol")),
+			Write:             make(chan outgoing.Message, conf.WSSendQueueSize),
+			Close:             make(chan closeFrame, 1),
 		},
-		read: read,
+		read:         read,
+		writeTimeout: conf.WSWriteTimeout,
+		pingInterval: conf.WSPingInterval,
+		pongTimeout:  conf.WSPongTimeout,
+		logger:       logger.PrivacyLogger(req.Context()),
 	}
-	client.debug().Msg("WebSocket New Connection")
+	conn.SetReadLimit(conf.WSMaxMessageSize)
+	if conf.WSCompression {
+		// the level is validated by the config.
+		_ = conn.SetCompressionLevel(conf.WSCompressionLevel)
+	}
+	client.debug().Int("protocol", client.info.Protocol).Msg("WebSocket New Connection")
 	conn.SetCloseHandler(func(code int, text string) error {
 		message := websocket.FormatCloseMessage(code, text)
 		client.debug().Str("reason", text).Int("code", code).Msg("WebSocket Close")
@@ -76,42 +113,81 @@ func newClient(conn *websocket.Conn, req *http.Request, read chan ClientMessage,
 	return client
 }
 
+func (c ClientInfo) send(msg outgoing.Message) {
+	queue(c.Write, c.Close, msg)
+}
+
+// queue adds a message to the send queue of a client without blocking the rooms event loop. A client with a full queue
+// can't keep up with the messages and gets disconnected.
+func queue(write chan<- outgoing.Message, closeChan chan<- closeFrame, msg outgoing.Message) {
+	select {
+	case write <- msg:
+	default:
+		closeConnection(closeChan, CloseCodeTooSlow, CloseTooSlow)
+	}
+}
+
 // Close closes the connection.
 func (c *Client) Close() {
+	c.close(false)
+}
+
+func (c *Client) close(lost bool) {
 	c.once.Do(func() {
 		c.conn.Close()
 		go func() {
 			c.read <- ClientMessage{
 				Info:     c.info,
-				Incoming: &Disconnected{},
+				Incoming: &Disconnected{lost: lost},
 			}
 		}()
 	})
 }
 
-// startWriteHandler starts listening on the client connection. As we do not need anything from the client,
-// we ignore incoming messages. Leaves the loop on errors.
-func (c *Client) startReading(pongWait time.Duration) {
+// connectionLost returns whether the read error was caused by a broken connection instead of a close handshake.
+func connectionLost(err error) bool {
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return false
+	}
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code == websocket.CloseAbnormalClosure
+	}
+	return true
+}
+
+// startReading reads the messages of the client and passes them to the rooms event loop. The connection is closed if
+// no pong arrives within the pong timeout after a ping. Leaves the loop on errors.
+func (c *Client) startReading() {
 	defer c.Close()
+	// the next ping is sent within the ping interval, its pong must arrive within the pong timeout.
+	pongWait := c.pingInterval + c.pongTimeout
 	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(appData string) error {
+		c.debug().Msg("WebSocket Pong")
 		_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 	for {
 		t, m, err := c.conn.NextReader()
 		if err != nil {
-			c.printWebSocketError("read", err)
+			c.readError(err)
+			c.close(connectionLost(err))
 			return
 		}
-		if t == websocket.BinaryMessage {
-			_ = c.conn.CloseHandler()(websocket.CloseUnsupportedData, fmt.Sprintf("unsupported binary message type: %s", err))
+		if t != c.codec.MessageType() {
+			c.rejectMessage(newError(CodeProtocolError, "", "unsupported message type: %d", t))
 			return
 		}
 
-		incoming, err := ReadTypedIncoming(m)
+		incoming, err := c.codec.Decode(m)
+		if errors.Is(err, websocket.ErrReadLimit) {
+			c.readError(err)
+			c.close(false)
+			return
+		}
 		if err != nil {
-			_ = c.conn.CloseHandler()(websocket.CloseNormalClosure, fmt.Sprintf("malformed message: %s", err))
+			c.rejectMessage(newError(CodeProtocolError, "", "malformed message: %s", err))
 			return
 		}
 		c.debug().Interface("event", fmt.Sprintf("%T", incoming)).Msg("WebSocket Receive")
@@ -119,12 +195,23 @@ func (c *Client) startReading(pongWait time.Duration) {
 	}
 }
 
+// rejectMessage rejects an unreadable message through the rooms event loop and ignores everything the client sends
+// until the connection is closed.
+func (c *Client) rejectMessage(err *Error) {
+	c.read <- ClientMessage{Info: c.info, Incoming: &protocolError{err: err}}
+	for {
+		if _, _, err := c.conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
 // startWriteHandler starts the write loop. The method has the following tasks:
-// * ping the client in the interval provided as parameter
+// * ping the client in the ping interval
 // * write messages send by the channel to the client
 // * on errors exit the loop.
-func (c *Client) startWriteHandler(pingPeriod time.Duration) {
-	pingTicker := time.NewTicker(pingPeriod)
+func (c *Client) startWriteHandler() {
+	pingTicker := time.NewTicker(c.pingInterval)
 
 	dead := false
 	conClosed := func() {
@@ -136,50 +223,80 @@ func (c *Client) startWriteHandler(pingPeriod time.Duration) {
 	defer func() {
 		c.debug().Msg("WebSocket Done")
 	}()
+	write := func(message outgoing.Message) {
+		if dead {
+			c.debug().Msg("WebSocket write on dead connection")
+			return
+		}
+		if !compatOutgoing(c.info.Protocol, message) {
+			return
+		}
+
+		_ = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+		c.debug().Interface("event", message.Type()).Msg("WebSocket Send")
+
+		if room, ok := message.(outgoing.Room); ok {
+			c.info.RoomID = room.ID
+		}
+
+		if err := writeMessage(c.conn, c.codec, message); err != nil {
+			conClosed()
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				c.debug().Str("reason", CloseTooSlow).Msg("WebSocket write timeout")
+				return
+			}
+			c.printWebSocketError("write", err)
+		}
+	}
 	for {
 		select {
-		case reason := <-c.info.Close:
-			if reason == CloseDone {
+		case frame := <-c.info.Close:
+			if frame.Reason == CloseDone {
 				return
 			} else {
-				_ = c.conn.CloseHandler()(websocket.CloseNormalClosure, reason)
-				conClosed()
+				if frame.Code != CloseCodeTooSlow {
+					// queued messages like errors may explain why the connection is closed.
+					for len(c.info.Write) > 0 {
+						write(<-c.info.Write)
+					}
+				}
+				_ = c.conn.CloseHandler()(frame.Code, frame.Reason)
+				// the CloseDone of the disconnect may be dropped while this frame was pending.
+				return
 			}
 		case message := <-c.info.Write:
-			if dead {
-				c.debug().Msg("WebSocket write on dead connection")
-				continue
-			}
-
-			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			typed, err := ToTypedOutgoing(message)
-			c.debug().Interface("event", typed.Type).Msg("WebSocket Send")
-			if err != nil {
-				c.debug().Err(err).Msg("could not get typed message, exiting connection.")
-				conClosed()
-				continue
-			}
-
-			if room, ok := message.(outgoing.Room); ok {
-				c.info.RoomID = room.ID
-			}
-
-			if err := writeJSON(c.conn, typed); err != nil {
-				conClosed()
-				c.printWebSocketError("write", err)
-			}
+			write(message)
 		case <-pingTicker.C:
 			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if err := ping(c.conn); err != nil {
 				conClosed()
 				c.printWebSocketError("ping", err)
+			} else {
+				c.debug().Msg("WebSocket Ping")
 			}
 		}
 	}
 }
 
 func (c *Client) debug() *zerolog.Event {
-	return log.Debug().Str("id", c.info.ID.String()).Str("ip", c.info.Addr.String())
+	return c.logger.Debug().Str("id", c.info.ID.String()).Str("ip", c.info.Addr.String())
+}
+
+func (c *Client) warn() *zerolog.Event {
+	return c.logger.Warn().Str("id", c.info.ID.String()).Str("ip", c.info.Addr.String())
+}
+
+// readError logs the error of a failed read. gorilla/websocket already sent the close frame for too big messages.
+func (c *Client) readError(err error) {
+	if errors.Is(err, websocket.ErrReadLimit) {
+		c.warn().Msg("WebSocket message too big")
+		return
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		c.warn().Dur("timeout", c.pongTimeout).Msg("WebSocket closed, no pong received")
+		return
+	}
+	c.printWebSocketError("read", err)
 }
 
 func (c *Client) printWebSocketError(typex string, err error) {
@@ -0,0 +1,165 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/config/ipdns"
+	"github.com/screego/server/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dialTestServer starts the rooms with a websocket endpoint and connects a client to it.
+func dialTestServer(t *testing.T, conf config.Config) (*Rooms, *websocket.Conn) {
+	t.Helper()
+	rooms, url := startTestServer(t, conf)
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {url}})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	rooms.do(func() {})
+	return rooms, conn
+}
+
+// startTestServer starts the rooms with a websocket endpoint and returns its url.
+func startTestServer(t *testing.T, conf config.Config) (*Rooms, string) {
+	t.Helper()
+	users, err := auth.ReadPasswordsFile("", []byte("secret"), 0)
+	require.NoError(t, err)
+	conf.AuthMode = config.AuthModeNone
+	conf.TurnIPProvider = &ipdns.Static{V4: net.ParseIP("127.0.0.1")}
+	conf.WSSendQueueSize = 10
+	conf.WSWriteTimeout = time.Second
+	if conf.WSPingInterval == 0 {
+		conf.WSPingInterval = 25 * time.Second
+		conf.WSPongTimeout = 10 * time.Second
+	}
+	rooms := NewRooms(&fakeTurnServer{}, users, NewMemoryRoomStore(), conf)
+	go rooms.Start()
+	t.Cleanup(func() {
+		_ = rooms.Stop(context.Background())
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(rooms.Upgrade))
+	t.Cleanup(server.Close)
+
+	return rooms, "ws" + strings.TrimPrefix(server.URL, "http") + "?protocol=2"
+}
+
+// readUntilClose reads all messages until the connection is closed and returns the close error. The close frame
+// isn't answered, the server may already have closed the connection.
+func readUntilClose(t *testing.T, conn *websocket.Conn, timeout time.Duration) error {
+	t.Helper()
+	conn.SetCloseHandler(func(int, string) error {
+		return nil
+	})
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(timeout)))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return err
+		}
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	const limit = 1024
+	pong := `{"type":"pong","payload":{}}`
+	tests := []struct {
+		name string
+		size int
+		open bool
+	}{
+		{name: "below limit", size: limit - 1, open: true},
+		{name: "at limit", size: limit, open: true},
+		{name: "above limit", size: limit + 1, open: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms, conn := dialTestServer(t, config.Config{WSMaxMessageSize: limit})
+
+			message := pong + strings.Repeat(" ", test.size-len(pong))
+			require.Len(t, message, test.size)
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(message)))
+
+			err := readUntilClose(t, conn, 500*time.Millisecond)
+			if test.open {
+				var netErr net.Error
+				require.ErrorAs(t, err, &netErr)
+				assert.True(t, netErr.Timeout())
+				assert.Equal(t, 1, currentStats(t, rooms).Connections)
+				return
+			}
+			var closeErr *websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, websocket.CloseMessageTooBig, closeErr.Code)
+		})
+	}
+}
+
+func TestPongTimeout(t *testing.T) {
+	conf := config.Config{WSPingInterval: 50 * time.Millisecond, WSPongTimeout: 50 * time.Millisecond}
+
+	rooms, conn := dialTestServer(t, conf)
+	err := readUntilClose(t, conn, 500*time.Millisecond)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr, "clients answering pings stay connected")
+	assert.True(t, netErr.Timeout())
+	assert.Equal(t, 1, currentStats(t, rooms).Connections)
+
+	rooms, conn = dialTestServer(t, conf)
+	conn.SetPingHandler(func(string) error {
+		return nil
+	})
+	err = readUntilClose(t, conn, 2*time.Second)
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "the server closes the connection without pong")
+	assert.Eventually(t, func() bool {
+		stats, err := rooms.Stats()
+		return err == nil && stats.Connections == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestUpgrade_proxyAuth(t *testing.T) {
+	rooms, url := startTestServer(t, config.Config{})
+	proxy, err := auth.NewProxyAuth(config.Config{ProxyAuthTrustedProxies: []string{"127.0.0.1/32"}, ProxyAuthUserHeader: "X-Forwarded-User"})
+	require.NoError(t, err)
+	rooms.users.Proxy = proxy
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {url}, "X-Forwarded-User": {"alice"}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	var info ClientInfo
+	require.Eventually(t, func() bool {
+		rooms.do(func() {
+			for _, client := range rooms.clients {
+				info = client
+			}
+		})
+		return info.Authenticated
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "alice", info.AuthenticatedUser)
+}
+
+func TestReadError_privacyLogger(t *testing.T) {
+	var buf bytes.Buffer
+	requestLogger := zerolog.New(&buf)
+	req := httptest.NewRequest("GET", "/stream", nil).WithContext(requestLogger.WithContext(context.Background()))
+	client := &Client{info: ClientInfo{Addr: net.ParseIP("192.0.2.1")}, logger: logger.PrivacyLogger(req.Context())}
+
+	client.readError(websocket.ErrReadLimit)
+	assert.Contains(t, buf.String(), `"message":"WebSocket message too big"`)
+}
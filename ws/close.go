@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"errors"
+
+	"github.com/gorilla/websocket"
+)
+
+// Close codes of the websocket close frame, when the server closes a connection. Clients should use the code to
+// react on the close, the reason is a human readable text. Rejections send an error message with a more specific
+// error code before the close frame.
+const (
+	// CloseCodeNormal the connection ended normally.
+	CloseCodeNormal = websocket.CloseNormalClosure
+	// CloseCodeShutdown the server is shutting down, browsers and proxies know the standard going away code.
+	CloseCodeShutdown = websocket.CloseGoingAway
+	// CloseCodeError an error without a more specific close code, like a room that doesn't exist.
+	CloseCodeError = 4000
+	// CloseCodeLimitReached the user has reached the maximum of rooms or sessions.
+	CloseCodeLimitReached = 4001
+	// CloseCodeKicked the room owner kicked or banned the user.
+	CloseCodeKicked = 4002
+	// CloseCodeRoomClosed the room was closed, for example because the owner left.
+	CloseCodeRoomClosed = 4004
+	// CloseCodeRejected the user isn't allowed to join, was rejected in the waiting room or entered wrong passwords.
+	CloseCodeRejected = 4005
+	// CloseCodeProtocolError the client sent an invalid message or speaks an unsupported protocol version.
+	CloseCodeProtocolError = 4006
+	// CloseCodeIdle the user didn't answer the presence pings.
+	CloseCodeIdle = 4007
+	// CloseCodeTooSlow the client couldn't keep up with the messages.
+	CloseCodeTooSlow = 4008
+	// CloseCodeJoinTimeout the client didn't create or join a room in time.
+	CloseCodeJoinTimeout = 4009
+	// CloseCodeLoggedOut the login session of the connection was revoked.
+	CloseCodeLoggedOut = 4010
+	// CloseCodeDeleted the user was deleted by an admin.
+	CloseCodeDeleted = 4011
+)
+
+// maxCloseReason is the maximum length of the close reason, control frames are limited to 125 bytes including the
+// two bytes of the close code.
+const maxCloseReason = 123
+
+var errorCloseCodes = map[ErrorCode]int{
+	CodeLimitReached:    CloseCodeLimitReached,
+	CodeServerShutdown:  CloseCodeShutdown,
+	CodeOwnerLeft:       CloseCodeRoomClosed,
+	CodeRoomClosed:      CloseCodeRoomClosed,
+	CodeRoomExpired:     CloseCodeRoomClosed,
+	CodeNotAuthorized:   CloseCodeRejected,
+	CodeBanned:          CloseCodeRejected,
+	CodeRejected:        CloseCodeRejected,
+	CodeApprovalTimeout: CloseCodeRejected,
+	CodeRateLimited:     CloseCodeRejected,
+	CodeProtocolError:   CloseCodeProtocolError,
+	CodeOutdatedClient:  CloseCodeProtocolError,
+	CodeIdle:            CloseCodeIdle,
+	CodeJoinTimeout:     CloseCodeJoinTimeout,
+}
+
+type closeFrame struct {
+	Code   int
+	Reason string
+}
+
+func closeCode(err error) int {
+	var codeErr *Error
+	if errors.As(err, &codeErr) {
+		if code, ok := errorCloseCodes[codeErr.Code]; ok {
+			return code
+		}
+	}
+	return CloseCodeError
+}
+
+// closeConnection requests closing the connection with the given code and reason. It doesn't block when a close is
+// already pending.
+func closeConnection(closeChan chan<- closeFrame, code int, reason string) {
+	if len(reason) > maxCloseReason {
+		reason = reason[:maxCloseReason]
+	}
+	select {
+	case closeChan <- closeFrame{Code: code, Reason: reason}:
+	default:
+	}
+}
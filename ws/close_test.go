@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(t *testing.T, rooms *Rooms, conn *websocket.Conn)
+		code   int
+		reason string
+	}{
+		{
+			name: "room not found",
+			action: func(t *testing.T, rooms *Rooms, conn *websocket.Conn) {
+				require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"join","payload":{"id":"unknown"}}`)))
+			},
+			code:   CloseCodeError,
+			reason: "room with id unknown does not exist",
+		},
+		{
+			name: "malformed message",
+			action: func(t *testing.T, rooms *Rooms, conn *websocket.Conn) {
+				require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, []byte{1}))
+			},
+			code:   CloseCodeProtocolError,
+			reason: "unsupported message type: 2",
+		},
+		{
+			name: "shutdown",
+			action: func(t *testing.T, rooms *Rooms, conn *websocket.Conn) {
+				go rooms.Stop(context.Background())
+			},
+			code:   CloseCodeShutdown,
+			reason: CloseServerShutdown,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms, conn := dialTestServer(t, config.Config{})
+			test.action(t, rooms, conn)
+
+			err := readUntilClose(t, conn, 5*time.Second)
+			var closeErr *websocket.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, test.code, closeErr.Code)
+			assert.Equal(t, test.reason, closeErr.Text)
+		})
+	}
+}
+
+func TestCloseConnection_truncatesReason(t *testing.T) {
+	closeChan := make(chan closeFrame, 1)
+	closeConnection(closeChan, CloseCodeError, strings.Repeat("a", 200))
+
+	frame := <-closeChan
+	assert.Equal(t, CloseCodeError, frame.Code)
+	assert.Len(t, frame.Reason, maxCloseReason)
+}
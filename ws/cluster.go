@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"encoding/json"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/store"
+)
+
+// sharedEvent is a room event on the event bus, Instance identifies the instance that published it.
+type sharedEvent struct {
+	Instance string       `json:"instance"`
+	Event    WebhookEvent `json:"event"`
+}
+
+// sharedEvents publishes the room events of this instance in its own goroutine, like the webhooks, so that the bus
+// doesn't block the rooms event loop.
+type sharedEvents struct {
+	bus      store.EventBus
+	instance string
+	queue    chan WebhookEvent
+}
+
+// ShareEvents publishes the room events to the bus and adds the events of the other instances to the event feed, so
+// that /api/v1/rooms/events shows the rooms of all instances. Webhooks are only sent by the instance of the room. It
+// must be called before Start.
+func (r *Rooms) ShareEvents(bus store.EventBus) error {
+	events, err := bus.SubscribeEvents()
+	if err != nil {
+		return err
+	}
+	r.shared = &sharedEvents{bus: bus, instance: xid.New().String(), queue: make(chan WebhookEvent, webhookQueueSize)}
+	go r.shared.run()
+	go func() {
+		for data := range events {
+			var event sharedEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				log.Warn().Err(err).Msg("Invalid shared room event")
+				continue
+			}
+			if event.Instance != r.shared.instance {
+				r.feed.publish(event.Event)
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *sharedEvents) publish(e WebhookEvent) {
+	select {
+	case s.queue <- e:
+	default:
+		log.Warn().Str("event", e.Event).Str("room", e.Room).Msg("Shared event queue is full, dropping event")
+	}
+}
+
+func (s *sharedEvents) run() {
+	for event := range s.queue {
+		data, err := json.Marshal(sharedEvent{Instance: s.instance, Event: event})
+		if err != nil {
+			log.Error().Err(err).Msg("Shared event encode")
+			continue
+		}
+		if err := s.bus.PublishEvent(data); err != nil {
+			log.Warn().Err(err).Str("event", event.Event).Str("room", event.Room).Msg("Could not share room event")
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBus delivers the published events to all subscribers, like Redis pub/sub.
+type fakeBus struct {
+	subscribers []chan []byte
+}
+
+func (b *fakeBus) PublishEvent(data []byte) error {
+	for _, subscriber := range b.subscribers {
+		subscriber <- data
+	}
+	return nil
+}
+
+func (b *fakeBus) SubscribeEvents() (<-chan []byte, error) {
+	subscriber := make(chan []byte, 10)
+	b.subscribers = append(b.subscribers, subscriber)
+	return subscriber, nil
+}
+
+func TestShareEvents(t *testing.T) {
+	bus := &fakeBus{}
+	first := newTestRooms(config.Config{})
+	second := newTestRooms(config.Config{})
+	require.NoError(t, first.ShareEvents(bus))
+	require.NoError(t, second.ShareEvents(bus))
+	_, firstEvents, cancel := first.SubscribeEvents(0)
+	defer cancel()
+	_, secondEvents, cancel := second.SubscribeEvents(0)
+	defer cancel()
+
+	first.webhook(WebhookRoomCreated, "room", nil)
+
+	receive := func(events <-chan FeedEvent) FeedEvent {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(time.Second):
+			t.Fatal("event wasn't received")
+			return FeedEvent{}
+		}
+	}
+	assert.Equal(t, "room", receive(firstEvents).Room)
+	assert.Equal(t, WebhookRoomCreated, receive(secondEvents).Event, "the other instance gets the event")
+	select {
+	case event := <-firstEvents:
+		t.Fatalf("the own event was received again: %v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
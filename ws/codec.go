@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPackProtocol is the websocket subprotocol a client requests to use MessagePack instead of JSON.
+const MsgPackProtocol = "screego-msgpack"
+
+// Codec encodes and decodes the messages of a single websocket connection.
+type Codec interface {
+	// MessageType returns the websocket message type used by the codec.
+	MessageType() int
+	Decode(r io.Reader) (Event, error)
+	Encode(w io.Writer, msg outgoing.Message) error
+}
+
+// codecFor returns the codec for the negotiated subprotocol, connections without subprotocol use JSON.
+func codecFor(subprotocol string) Codec {
+	if subprotocol == MsgPackProtocol {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) MessageType() int {
+	return websocket.TextMessage
+}
+
+func (jsonCodec) Decode(r io.Reader) (Event, error) {
+	return ReadTypedIncoming(r)
+}
+
+func (jsonCodec) Encode(w io.Writer, msg outgoing.Message) error {
+	typed, err := ToTypedOutgoing(msg)
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(typed)
+}
+
+// msgpackCodec uses the json struct tags, so both codecs produce the same field names.
+type msgpackCodec struct{}
+
+type msgpackTyped struct {
+	Type    string             `msgpack:"type"`
+	Payload msgpack.RawMessage `msgpack:"payload"`
+}
+
+func (msgpackCodec) MessageType() int {
+	return websocket.BinaryMessage
+}
+
+func (msgpackCodec) Decode(r io.Reader) (Event, error) {
+	typed := msgpackTyped{}
+	if err := msgpack.NewDecoder(r).Decode(&typed); err != nil {
+		return nil, err
+	}
+
+	payload, err := newEvent(typed.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := msgpack.NewDecoder(bytes.NewReader(typed.Payload))
+	decoder.SetCustomStructTag("json")
+	if err := decoder.Decode(payload); err != nil {
+		return nil, fmt.Errorf("incoming payload %s", err)
+	}
+	return payload, nil
+}
+
+func (msgpackCodec) Encode(w io.Writer, msg outgoing.Message) error {
+	var payload bytes.Buffer
+	encoder := msgpack.NewEncoder(&payload)
+	encoder.SetCustomStructTag("json")
+	if err := encoder.Encode(msg); err != nil {
+		return err
+	}
+	return msgpack.NewEncoder(w).Encode(&msgpackTyped{Type: msg.Type(), Payload: payload.Bytes()})
+}
+
+func newEvent(t string) (Event, error) {
+	create, ok := provider[t]
+	if !ok {
+		return nil, errors.New("cannot handle " + t)
+	}
+	return create(), nil
+}
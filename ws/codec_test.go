@@ -0,0 +1,156 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+var codecs = map[string]Codec{"json": jsonCodec{}, "msgpack": msgpackCodec{}}
+
+func incomingSamples() map[string]Event {
+	value := json.RawMessage(`{"candidate":"candidate:1 1 udp 2122260223 192.168.0.2 50000 typ host"}`)
+	return map[string]Event{
+		"create":              &Create{ID: "room", Mode: ConnectionTURN, CloseOnOwnerLeave: true, UserName: "alice", JoinIfExist: true, Password: "pw", WaitingRoom: true},
+		"join":                &Join{ID: "room", UserName: "bob", Password: "pw", Invite: "token"},
+		"name":                &Name{UserName: "carol"},
+		"share":               &StartShare{},
+		"stopshare":           &StopShare{},
+		"hostice":             &HostICE{SID: xid.New(), Value: value},
+		"clientice":           &ClientICE{SID: xid.New(), Value: value},
+		"hostoffer":           &HostOffer{SID: xid.New(), Value: value},
+		"clientanswer":        &ClientAnswer{SID: xid.New(), Value: value},
+		"room_password":       &RoomPassword{Password: "pw"},
+		"admit_user":          &AdmitUser{ID: xid.New()},
+		"reject_user":         &RejectUser{ID: xid.New()},
+		"kick_user":           &KickUser{Room: "room", ID: xid.New()},
+		"ban_user":            &BanUser{Room: "room", ID: xid.New(), Duration: 600},
+		"pong":                &Pong{},
+		"reconnect":           &Reconnect{Token: "token"},
+		"refresh_credentials": &RefreshCredentials{ID: xid.New()},
+		"request_stop_share":  &RequestStopShare{Room: "room", ID: xid.New()},
+		"force_stop_share":    &ForceStopShare{Room: "room", ID: xid.New()},
+		"extend_room":         &ExtendRoom{Room: "room"},
+		"e2e_key_exchange":    &E2EKeyExchange{SID: xid.New(), PublicKey: "a2V5"},
+	}
+}
+
+func outgoingSamples() []outgoing.Message {
+	ice := []outgoing.ICEServer{{URLs: []string{"turn:127.0.0.1:3478"}, Username: "user", Credential: "pw"}}
+	value := json.RawMessage(`{"type":"offer","sdp":"v=0"}`)
+	return []outgoing.Message{
+		outgoing.Room{ID: "room", Mode: outgoing.ConnectionTURN, Users: []outgoing.User{{ID: xid.New(), Name: "alice", Streaming: true, You: true, Owner: true}}},
+		outgoing.HostSession{ID: xid.New(), Peer: xid.New(), ICEServers: ice},
+		outgoing.ClientSession{ID: xid.New(), Peer: xid.New(), ICEServers: ice},
+		outgoing.HostICE{SID: xid.New(), Value: value},
+		outgoing.ClientICE{SID: xid.New(), Value: value},
+		outgoing.HostOffer{SID: xid.New(), Value: value},
+		outgoing.ClientAnswer{SID: xid.New(), Value: value},
+		outgoing.EndShare(xid.New()),
+		outgoing.ICEServersUpdate{ID: xid.New(), ICEServers: ice},
+		outgoing.RoomPasswordRequired{ID: "room"},
+		outgoing.RoomPasswordIncorrect{ID: "room", RemainingAttempts: 2},
+		outgoing.WaitingForApproval{},
+		outgoing.UserWaiting{ID: xid.New(), Name: "bob"},
+		outgoing.YouWereKicked{Room: "room"},
+		outgoing.ProtocolVersion{Version: CurrentProtocol},
+		outgoing.Ping{},
+		outgoing.UserIdle{ID: xid.New()},
+		outgoing.UserActive{ID: xid.New()},
+		outgoing.RoomJoined{ID: "room", ReconnectToken: "token"},
+		outgoing.StopShareRequested{Room: "room", By: xid.New()},
+		outgoing.ShareStopped{Room: "room", By: xid.New()},
+		outgoing.RoomExpiring{Room: "room", Remaining: 60},
+		outgoing.RoomExtended{Room: "room"},
+		outgoing.E2EKeyExchange{SID: xid.New(), PublicKey: "a2V5"},
+		outgoing.Error{Code: string(CodeRoomNotFound), Message: "room with id room does not exist", Room: "room"},
+	}
+}
+
+func TestCodec_incomingRoundTrip(t *testing.T) {
+	samples := incomingSamples()
+	for typ := range provider {
+		assert.Contains(t, samples, typ, "missing sample for incoming message type")
+	}
+
+	for name, codec := range codecs {
+		for typ, sample := range samples {
+			t.Run(name+"/"+typ, func(t *testing.T) {
+				decoded, err := codec.Decode(bytes.NewReader(encodeIncoming(t, name, typ, sample)))
+				require.NoError(t, err)
+				assert.Equal(t, sample, decoded)
+			})
+		}
+	}
+}
+
+func TestCodec_outgoingRoundTrip(t *testing.T) {
+	for name, codec := range codecs {
+		for _, sample := range outgoingSamples() {
+			t.Run(name+"/"+sample.Type(), func(t *testing.T) {
+				var buf bytes.Buffer
+				require.NoError(t, codec.Encode(&buf, sample))
+
+				typ, decoded := decodeOutgoing(t, name, buf.Bytes(), reflect.TypeOf(sample))
+				assert.Equal(t, sample.Type(), typ)
+				assert.Equal(t, sample, decoded)
+			})
+		}
+	}
+}
+
+func TestCodec_unknownType(t *testing.T) {
+	for name, codec := range codecs {
+		_, err := codec.Decode(bytes.NewReader(encodeIncoming(t, name, "unknown", &Join{})))
+		assert.EqualError(t, err, "cannot handle unknown", name)
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	assert.Equal(t, msgpackCodec{}, codecFor(MsgPackProtocol))
+	assert.Equal(t, jsonCodec{}, codecFor(""))
+}
+
+// encodeIncoming encodes a message like a client would.
+func encodeIncoming(t *testing.T, codec, typ string, event Event) []byte {
+	if codec == "json" {
+		payload, err := json.Marshal(event)
+		require.NoError(t, err)
+		result, err := json.Marshal(Typed{Type: typ, Payload: payload})
+		require.NoError(t, err)
+		return result
+	}
+
+	var payload bytes.Buffer
+	encoder := msgpack.NewEncoder(&payload)
+	encoder.SetCustomStructTag("json")
+	require.NoError(t, encoder.Encode(event))
+	result, err := msgpack.Marshal(&msgpackTyped{Type: typ, Payload: payload.Bytes()})
+	require.NoError(t, err)
+	return result
+}
+
+// decodeOutgoing decodes a message like a client would.
+func decodeOutgoing(t *testing.T, codec string, data []byte, target reflect.Type) (string, interface{}) {
+	value := reflect.New(target)
+	if codec == "json" {
+		typed := Typed{}
+		require.NoError(t, json.Unmarshal(data, &typed))
+		require.NoError(t, json.Unmarshal(typed.Payload, value.Interface()))
+		return typed.Type, value.Elem().Interface()
+	}
+
+	typed := msgpackTyped{}
+	require.NoError(t, msgpack.Unmarshal(data, &typed))
+	decoder := msgpack.NewDecoder(bytes.NewReader(typed.Payload))
+	decoder.SetCustomStructTag("json")
+	require.NoError(t, decoder.Decode(value.Interface()))
+	return typed.Type, value.Elem().Interface()
+}
@@ -0,0 +1,77 @@
+package ws
+
+import (
+	"strconv"
+
+	"github.com/screego/server/ws/outgoing"
+)
+
+// Protocol versions of the websocket protocol. Clients declare their version with the protocol query parameter on
+// the websocket upgrade, clients without it are treated as ProtocolV1. Everything that differs between the supported
+// versions is handled in this file.
+const (
+	// ProtocolV1 is the protocol before versioning, it has no error codes and presence heartbeat.
+	ProtocolV1 = 1
+	// ProtocolV2 adds error codes, the presence heartbeat and every message listed in messagesSinceV2.
+	ProtocolV2 = 2
+
+	CurrentProtocol = ProtocolV2
+	minProtocol     = ProtocolV1
+)
+
+// messagesSinceV2 are unknown to ProtocolV1 clients, the old frontend even closes the connection when the first
+// message isn't a room.
+var messagesSinceV2 = map[string]bool{
+	outgoing.ProtocolVersion{}.Type():    true,
+	outgoing.Error{}.Type():              true,
+	outgoing.Ping{}.Type():               true,
+	outgoing.UserIdle{}.Type():           true,
+	outgoing.UserActive{}.Type():         true,
+	outgoing.RoomJoined{}.Type():         true,
+	outgoing.StopShareRequested{}.Type(): true,
+	outgoing.ShareStopped{}.Type():       true,
+	outgoing.RoomExpiring{}.Type():       true,
+	outgoing.RoomExtended{}.Type():       true,
+	outgoing.E2EKeyExchange{}.Type():     true,
+}
+
+// parseProtocol returns the declared protocol version, or 0 if it is invalid.
+func parseProtocol(value string) int {
+	if value == "" {
+		return ProtocolV1
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0
+	}
+	return version
+}
+
+// negotiateProtocol tells the client the version the server speaks or rejects unsupported versions.
+func negotiateProtocol(current ClientInfo) error {
+	if current.Protocol < minProtocol {
+		return newError(CodeOutdatedClient, "", "outdated client, please refresh")
+	}
+	if current.Protocol > CurrentProtocol {
+		return newError(CodeOutdatedClient, "", "unsupported protocol version %d, please refresh", current.Protocol)
+	}
+	current.send(outgoing.ProtocolVersion{Version: current.Protocol})
+	return nil
+}
+
+// compatOutgoing returns false if the message must not be sent to a client with the protocol version.
+func compatOutgoing(protocol int, msg outgoing.Message) bool {
+	return protocol >= ProtocolV2 || !messagesSinceV2[msg.Type()]
+}
+
+// answersPings returns whether clients with the protocol version answer the presence pings. Other clients are never
+// marked idle.
+func answersPings(protocol int) bool {
+	return protocol >= ProtocolV2
+}
+
+// supportsReconnect returns whether clients with the protocol version receive reconnect tokens. Other clients leave
+// the room as soon as their connection is lost.
+func supportsReconnect(protocol int) bool {
+	return protocol >= ProtocolV2
+}
@@ -0,0 +1,63 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProtocol(t *testing.T) {
+	assert.Equal(t, ProtocolV1, parseProtocol(""))
+	assert.Equal(t, ProtocolV2, parseProtocol("2"))
+	assert.Equal(t, 3, parseProtocol("3"))
+	assert.Equal(t, 0, parseProtocol("0"))
+	assert.Equal(t, 0, parseProtocol("latest"))
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		protocol int
+		messages []outgoing.Message
+		err      string
+	}{
+		{protocol: 0, err: "outdated client, please refresh"},
+		{protocol: ProtocolV1, messages: []outgoing.Message{outgoing.ProtocolVersion{Version: ProtocolV1}}},
+		{protocol: ProtocolV2, messages: []outgoing.Message{outgoing.ProtocolVersion{Version: ProtocolV2}}},
+		{protocol: CurrentProtocol + 1, err: "unsupported protocol version 3, please refresh"},
+	}
+	for _, test := range tests {
+		client := newTestClient("")
+		client.Protocol = test.protocol
+		err := (&Connected{}).Execute(newTestRooms(config.Config{}), client)
+		if test.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+			assert.Equal(t, CodeOutdatedClient, err.(*Error).Code)
+		}
+		assert.Equal(t, test.messages, drain(client))
+	}
+}
+
+func TestCompatOutgoing(t *testing.T) {
+	for _, msg := range outgoingSamples() {
+		assert.True(t, compatOutgoing(ProtocolV2, msg), msg.Type())
+	}
+	assert.True(t, compatOutgoing(ProtocolV1, outgoing.Room{}))
+	assert.False(t, compatOutgoing(ProtocolV1, outgoing.ProtocolVersion{Version: ProtocolV1}))
+	assert.False(t, compatOutgoing(ProtocolV1, outgoing.Error{}))
+	assert.False(t, compatOutgoing(ProtocolV1, outgoing.Ping{}))
+}
+
+func TestPresence_v1ClientsAreNotPinged(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	owner := newTestClient("alice")
+	owner.Protocol = ProtocolV1
+	assert.NoError(t, createRoom(t, rooms, &owner, "room"))
+	drain(owner)
+
+	rooms.pingUsers()
+	assert.False(t, getRoom(rooms, "room").Users[owner.ID].awaitingPong)
+}
@@ -0,0 +1,140 @@
+package ws
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/xid"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		_, url := startTestServer(t, config.Config{WSCompression: enabled, WSCompressionLevel: 1})
+		dialer := websocket.Dialer{EnableCompression: true}
+		conn, resp, err := dialer.Dial(url, http.Header{"Origin": {url}})
+		require.NoError(t, err)
+
+		assert.Equal(t, enabled, strings.Contains(resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate"))
+		var msg map[string]interface{}
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, "protocol_version", msg["type"])
+		_ = conn.Close()
+	}
+}
+
+func TestCompression_clientWithoutSupport(t *testing.T) {
+	_, url := startTestServer(t, config.Config{WSCompression: true, WSCompressionLevel: 1})
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {url}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.Empty(t, resp.Header.Get("Sec-WebSocket-Extensions"))
+	var msg map[string]interface{}
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "protocol_version", msg["type"])
+}
+
+// sdpOffer is a typical offer of a browser sharing a screen with video and audio.
+const sdpOffer = "v=0\r\no=- 4611731400430051336 2 IN IP4 127.0.0.1\r\ns=-\r\nt=0 0\r\na=group:BUNDLE 0 1\r\n" +
+	"a=extmap-allow-mixed\r\na=msid-semantic: WMS 5a1b2c3d\r\n" +
+	"m=video 9 UDP/TLS/RTP/SAVPF 96 97 102 103 104 105 106 107 108 109 127 125 39 40 45 46 98 99 100 101 112 113 116 117 118\r\n" +
+	"c=IN IP4 0.0.0.0\r\na=rtcp:9 IN IP4 0.0.0.0\r\na=ice-ufrag:8hhY\r\na=ice-pwd:asd88fgpdd777uzjYhagZg\r\n" +
+	"a=ice-options:trickle\r\na=fingerprint:sha-256 D2:FA:0E:C3:22:59:5E:14:95:69:92:3D:13:B4:84:24:2C:C2:A2:C0:3E:FD:34:8E:5E:EA:6F:AF:52:CE:E6:0F\r\n" +
+	"a=setup:actpass\r\na=mid:0\r\na=extmap:1 urn:ietf:params:rtp-hdrext:toffset\r\n" +
+	"a=extmap:2 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time\r\na=extmap:3 urn:3gpp:video-orientation\r\n" +
+	"a=extmap:4 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01\r\n" +
+	"a=extmap:5 http://www.webrtc.org/experiments/rtp-hdrext/playout-delay\r\n" +
+	"a=extmap:6 http://www.webrtc.org/experiments/rtp-hdrext/video-content-type\r\n" +
+	"a=extmap:7 http://www.webrtc.org/experiments/rtp-hdrext/video-timing\r\n" +
+	"a=extmap:8 http://www.webrtc.org/experiments/rtp-hdrext/color-space\r\na=extmap:9 urn:ietf:params:rtp-hdrext:sdes:mid\r\n" +
+	"a=sendonly\r\na=msid:5a1b2c3d 9e8f7a6b\r\na=rtcp-mux\r\na=rtcp-rsize\r\n" +
+	"a=rtpmap:96 VP8/90000\r\na=rtcp-fb:96 goog-remb\r\na=rtcp-fb:96 transport-cc\r\na=rtcp-fb:96 ccm fir\r\n" +
+	"a=rtcp-fb:96 nack\r\na=rtcp-fb:96 nack pli\r\na=rtpmap:97 rtx/90000\r\na=fmtp:97 apt=96\r\n" +
+	"a=rtpmap:102 H264/90000\r\na=rtcp-fb:102 goog-remb\r\na=rtcp-fb:102 transport-cc\r\na=rtcp-fb:102 ccm fir\r\n" +
+	"a=rtcp-fb:102 nack\r\na=rtcp-fb:102 nack pli\r\na=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f\r\n" +
+	"a=rtpmap:103 rtx/90000\r\na=fmtp:103 apt=102\r\na=rtpmap:104 H264/90000\r\na=rtcp-fb:104 goog-remb\r\n" +
+	"a=rtcp-fb:104 transport-cc\r\na=rtcp-fb:104 ccm fir\r\na=rtcp-fb:104 nack\r\na=rtcp-fb:104 nack pli\r\n" +
+	"a=fmtp:104 level-asymmetry-allowed=1;packetization-mode=0;profile-level-id=42001f\r\n" +
+	"a=rtpmap:39 AV1/90000\r\na=rtcp-fb:39 goog-remb\r\na=rtcp-fb:39 transport-cc\r\na=rtcp-fb:39 ccm fir\r\n" +
+	"a=rtcp-fb:39 nack\r\na=rtcp-fb:39 nack pli\r\na=rtpmap:98 VP9/90000\r\na=rtcp-fb:98 goog-remb\r\n" +
+	"a=rtcp-fb:98 transport-cc\r\na=rtcp-fb:98 ccm fir\r\na=rtcp-fb:98 nack\r\na=rtcp-fb:98 nack pli\r\n" +
+	"a=fmtp:98 profile-id=0\r\na=ssrc-group:FID 1175238437 3326411413\r\na=ssrc:1175238437 cname:fZ0Sk2qy1hCm1WbD\r\n" +
+	"a=ssrc:1175238437 msid:5a1b2c3d 9e8f7a6b\r\na=ssrc:3326411413 cname:fZ0Sk2qy1hCm1WbD\r\n" +
+	"a=ssrc:3326411413 msid:5a1b2c3d 9e8f7a6b\r\n"
+
+// countingConn counts the bytes that are written to the connection.
+type countingConn struct {
+	net.Conn
+	written *int64
+}
+
+func (c countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(c.written, int64(n))
+	return n, err
+}
+
+// BenchmarkWriteMessage writes host offers with and without permessage-deflate. wire-B/op are the bytes that are
+// sent over the connection for each message.
+func BenchmarkWriteMessage(b *testing.B) {
+	value, err := json.Marshal(map[string]string{"type": "offer", "sdp": sdpOffer})
+	require.NoError(b, err)
+	msg := outgoing.HostOffer{SID: xid.New(), Value: value}
+
+	for _, compression := range []bool{false, true} {
+		name := "uncompressed"
+		if compression {
+			name = "compressed"
+		}
+		b.Run(name, func(b *testing.B) {
+			upgrader := websocket.Upgrader{EnableCompression: compression}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				for {
+					if _, _, err := conn.NextReader(); err != nil {
+						return
+					}
+				}
+			}))
+			defer server.Close()
+
+			var written int64
+			dialer := websocket.Dialer{
+				EnableCompression: compression,
+				NetDial: func(network, addr string) (net.Conn, error) {
+					conn, err := net.Dial(network, addr)
+					return countingConn{Conn: conn, written: &written}, err
+				},
+			}
+			conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			require.NoError(b, err)
+			defer conn.Close()
+			require.NoError(b, conn.SetCompressionLevel(1))
+
+			atomic.StoreInt64(&written, 0)
+			b.SetBytes(int64(len(value)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := writeMessage(conn, jsonCodec{}, msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadInt64(&written))/float64(b.N), "wire-B/op")
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/screego/server/ws/outgoing"
+)
+
+// ErrorCode is a stable machine readable code for errors sent to the client. The message of an error may change,
+// clients should use the code for handling and localization.
+type ErrorCode string
+
+const (
+	// CodeRoomNotFound the room doesn't exist (anymore).
+	CodeRoomNotFound ErrorCode = "room_not_found"
+	// CodeRoomExists a room with the id does already exist.
+	CodeRoomExists ErrorCode = "room_exists"
+	// CodeWrongPassword the room password is incorrect, the client may try again.
+	CodeWrongPassword ErrorCode = "wrong_password"
+	// CodeNotAuthorized the client needs to login or isn't allowed to do this.
+	CodeNotAuthorized ErrorCode = "not_authorized"
+	// CodeRateLimited the client made too many attempts.
+	CodeRateLimited ErrorCode = "rate_limited"
+	// CodeNotPermitted the user doesn't have the permission, e.g. to create rooms.
+	CodeNotPermitted ErrorCode = "not_permitted"
+	// CodeLimitReached the user has reached the configured maximum of rooms or sessions.
+	CodeLimitReached ErrorCode = "limit_reached"
+	// CodeInvalidName the display name of a user without login is empty, too long or contains invalid characters.
+	CodeInvalidName ErrorCode = "invalid_name"
+	// CodeBanned the client is banned from the room.
+	CodeBanned ErrorCode = "banned"
+	// CodeFeatureDisabled the feature is disabled on this server.
+	CodeFeatureDisabled ErrorCode = "feature_disabled"
+	// CodeRejected the room owner rejected the client in the waiting room.
+	CodeRejected ErrorCode = "rejected"
+	// CodeApprovalTimeout the room owner didn't admit the client in time.
+	CodeApprovalTimeout ErrorCode = "approval_timeout"
+	// CodeOwnerLeft the room owner left and the room was closed.
+	CodeOwnerLeft ErrorCode = "owner_left"
+	// CodeRoomClosed the room was closed.
+	CodeRoomClosed ErrorCode = "room_closed"
+	// CodeRoomExpired the room reached the end of its lifetime.
+	CodeRoomExpired ErrorCode = "room_expired"
+	// CodeIdle the user didn't answer pings for SCREEGO_IDLE_DISCONNECT.
+	CodeIdle ErrorCode = "idle"
+	// CodeJoinTimeout the client didn't create or join a room within SCREEGO_JOIN_TIMEOUT.
+	CodeJoinTimeout ErrorCode = "join_timeout"
+	// CodeReconnectFailed the reconnect token is invalid or expired, the client must join the room again.
+	CodeReconnectFailed ErrorCode = "reconnect_failed"
+	// CodeOutdatedClient the client speaks an unsupported protocol version and needs to be reloaded.
+	CodeOutdatedClient ErrorCode = "outdated_client"
+	// CodeServerShutdown the server is shutting down.
+	CodeServerShutdown ErrorCode = "server_shutdown"
+	// CodeProtocolError the client sent a message that is malformed or not allowed in its current state.
+	CodeProtocolError ErrorCode = "protocol_error"
+	// CodeInternalError something went wrong on the server.
+	CodeInternalError ErrorCode = "internal_error"
+)
+
+// Error is an error with a code that is sent to the client.
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Room    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code ErrorCode, room, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Room: room, Message: fmt.Sprintf(format, args...)}
+}
+
+func errNotInRoom() *Error {
+	return newError(CodeProtocolError, "", "not in a room")
+}
+
+func errRoomNotFound(id string) *Error {
+	return newError(CodeRoomNotFound, id, "room with id %s does not exist", id)
+}
+
+func errorMessage(err error) outgoing.Error {
+	var codeErr *Error
+	if errors.As(err, &codeErr) {
+		return outgoing.Error{Code: string(codeErr.Code), Message: codeErr.Message, Room: codeErr.Room}
+	}
+	return outgoing.Error{Code: string(CodeInternalError), Message: err.Error()}
+}
+
+// reject sends the error to the client and closes the connection. The error message is used as close reason, like
+// before error codes were introduced.
+func reject(write chan<- outgoing.Message, closeChan chan<- closeFrame, err error) {
+	queue(write, closeChan, errorMessage(err))
+	closeConnection(closeChan, closeCode(err), err.Error())
+}
+
+func (c ClientInfo) reject(err error) {
+	reject(c.Write, c.Close, err)
+}
+
+func (u *User) reject(err error) {
+	reject(u.Write, u.Close, err)
+}
+
+// protocolError rejects a message that could not be read, so that the error is sent like any other.
+type protocolError struct {
+	err *Error
+}
+
+func (e *protocolError) Execute(rooms *Rooms, current ClientInfo) error {
+	return e.err
+}
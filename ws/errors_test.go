@@ -0,0 +1,219 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   config.Config
+		create Create
+		// events are executed in order, the error is expected on the client connection.
+		events func(rooms *Rooms, owner, client ClientInfo) []ClientMessage
+		code   ErrorCode
+		// close is the expected close code, when the connection is closed.
+		close int
+		room  string
+		// open is set when the connection stays open after the error.
+		open bool
+	}{
+		{
+			name: "join unknown room",
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{{Info: client, Incoming: &Join{ID: "unknown"}}}
+			},
+			code:  CodeRoomNotFound,
+			close: CloseCodeError,
+			room:  "unknown",
+		},
+		{
+			name: "create existing room",
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{{Info: client, Incoming: &Create{ID: "room", Mode: ConnectionLocal}}}
+			},
+			code:  CodeRoomExists,
+			close: CloseCodeError,
+			room:  "room",
+		},
+		{
+			name: "create without login",
+			conf: config.Config{AuthMode: config.AuthModeAll},
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{{Info: client, Incoming: &Create{ID: "other", Mode: ConnectionLocal}}}
+			},
+			code:  CodeNotAuthorized,
+			close: CloseCodeRejected,
+			room:  "other",
+		},
+		{
+			name: "admit by non owner",
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				joined := client
+				joined.RoomID = "room"
+				return []ClientMessage{
+					{Info: client, Incoming: &Join{ID: "room"}},
+					{Info: joined, Incoming: &AdmitUser{ID: owner.ID}},
+				}
+			},
+			code:  CodeNotAuthorized,
+			close: CloseCodeRejected,
+			room:  "room",
+		},
+		{
+			name: "room limit",
+			conf: config.Config{MaxRoomsPerUser: 1},
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				second := newTestClient("alice")
+				second.Write, second.Close = client.Write, client.Close
+				return []ClientMessage{{Info: second, Incoming: &Create{ID: "other", Mode: ConnectionLocal}}}
+			},
+			code:  CodeLimitReached,
+			close: CloseCodeLimitReached,
+			room:  "other",
+		},
+		{
+			name: "room passwords disabled",
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{{Info: client, Incoming: &Create{ID: "other", Mode: ConnectionLocal, Password: "pw"}}}
+			},
+			code:  CodeFeatureDisabled,
+			close: CloseCodeError,
+			room:  "other",
+		},
+		{
+			name:   "wrong password",
+			conf:   config.Config{RoomPasswordsEnabled: true},
+			create: Create{Password: "pw"},
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{{Info: client, Incoming: &Join{ID: "room", Password: "wrong"}}}
+			},
+			code: CodeWrongPassword,
+			room: "room",
+			open: true,
+		},
+		{
+			name:   "too many wrong passwords",
+			conf:   config.Config{RoomPasswordsEnabled: true},
+			create: Create{Password: "pw"},
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				join := ClientMessage{Info: client, Incoming: &Join{ID: "room", Password: "wrong"}}
+				return []ClientMessage{join, join, join}
+			},
+			code:  CodeRateLimited,
+			close: CloseCodeRejected,
+			room:  "room",
+		},
+		{
+			name: "banned",
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				rooms.bans["room"] = map[string]*Ban{"bob": {User: "bob", Expires: time.Now().Add(time.Hour)}}
+				return []ClientMessage{{Info: client, Incoming: &Join{ID: "room", UserName: "bob"}}}
+			},
+			code:  CodeBanned,
+			close: CloseCodeRejected,
+			room:  "room",
+		},
+		{
+			name:   "rejected from waiting room",
+			create: Create{WaitingRoom: true},
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{
+					{Info: client, Incoming: &Join{ID: "room"}},
+					{Info: owner, Incoming: &RejectUser{ID: client.ID}},
+				}
+			},
+			code:  CodeRejected,
+			close: CloseCodeRejected,
+			room:  "room",
+		},
+		{
+			name:   "waiting room timeout",
+			create: Create{WaitingRoom: true},
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{
+					{Info: client, Incoming: &Join{ID: "room"}},
+					{Info: client, Incoming: &waitingTimeout{}},
+				}
+			},
+			code:  CodeApprovalTimeout,
+			close: CloseCodeRejected,
+			room:  "room",
+		},
+		{
+			name:   "owner left",
+			create: Create{CloseOnOwnerLeave: true},
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{
+					{Info: client, Incoming: &Join{ID: "room"}},
+					{Info: owner, Incoming: &Disconnected{}},
+				}
+			},
+			code:  CodeOwnerLeft,
+			close: CloseCodeRoomClosed,
+			room:  "room",
+		},
+		{
+			name: "name without room",
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{{Info: client, Incoming: &Name{UserName: "bob"}}}
+			},
+			code:  CodeProtocolError,
+			close: CloseCodeProtocolError,
+		},
+		{
+			name: "malformed message",
+			events: func(rooms *Rooms, owner, client ClientInfo) []ClientMessage {
+				return []ClientMessage{{Info: client, Incoming: &protocolError{err: newError(CodeProtocolError, "", "malformed message")}}}
+			},
+			code:  CodeProtocolError,
+			close: CloseCodeProtocolError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms := newTestRooms(test.conf)
+			go rooms.Start()
+
+			owner := newTestClient("alice")
+			client := newTestClient("")
+			create := test.create
+			create.ID, create.Mode = "room", ConnectionLocal
+			var events []ClientMessage
+			rooms.do(func() {
+				require.NoError(t, createRoomWith(t, rooms, &owner, &create))
+				events = test.events(rooms, owner, client)
+			})
+			for _, event := range events {
+				rooms.Incoming <- event
+			}
+			rooms.do(func() {})
+
+			var errs []outgoing.Error
+			for _, msg := range drain(client) {
+				if err, ok := msg.(outgoing.Error); ok {
+					errs = append(errs, err)
+				}
+			}
+			require.NotEmpty(t, errs)
+			last := errs[len(errs)-1]
+			assert.Equal(t, string(test.code), last.Code)
+			assert.Equal(t, test.room, last.Room)
+			assert.NotEmpty(t, last.Message)
+
+			if test.open {
+				assert.Empty(t, client.Close)
+			} else {
+				require.Len(t, client.Close, 1)
+				assert.Equal(t, closeFrame{Code: test.close, Reason: last.Message}, <-client.Close)
+			}
+		})
+	}
+}
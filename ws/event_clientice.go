@@ -1,8 +1,6 @@
 package ws
 
 import (
-	"fmt"
-
 	"github.com/rs/zerolog/log"
 	"github.com/screego/server/ws/outgoing"
 )
@@ -17,12 +15,12 @@ type ClientICE outgoing.P2PMessage
 
 func (e *ClientICE) Execute(rooms *Rooms, current ClientInfo) error {
 	if current.RoomID == "" {
-		return fmt.Errorf("not in a room")
+		return errNotInRoom()
 	}
 
-	room, ok := rooms.Rooms[current.RoomID]
+	room, ok := rooms.store.GetRoom(current.RoomID)
 	if !ok {
-		return fmt.Errorf("room with id %s does not exist", current.RoomID)
+		return errRoomNotFound(current.RoomID)
 	}
 
 	session, ok := room.Sessions[e.SID]
@@ -33,10 +31,11 @@ func (e *ClientICE) Execute(rooms *Rooms, current ClientInfo) error {
 	}
 
 	if session.Client != current.ID {
-		return fmt.Errorf("permission denied for session %s", e.SID)
+		return newError(CodeNotAuthorized, current.RoomID, "permission denied for session %s", e.SID)
 	}
 
-	room.Users[session.Host].Write <- outgoing.ClientICE(*e)
+	room.logEvent(RoomEventSignaling, room.Users[current.ID], "clientice")
+	room.Users[session.Host].send(outgoing.ClientICE(*e))
 
 	return nil
 }
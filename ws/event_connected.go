@@ -0,0 +1,33 @@
+package ws
+
+// Connected is sent once for every new websocket connection. The connection is counted even if it gets rejected,
+// because the rejection closes the connection which in turn releases it again via Disconnected.
+type Connected struct{}
+
+func (e *Connected) Execute(rooms *Rooms, current ClientInfo) error {
+	rooms.clients[current.ID] = current
+	rooms.startJoinTimer(current)
+	if rooms.stopping {
+		return newError(CodeServerShutdown, "", CloseServerShutdown)
+	}
+	if !current.Authenticated {
+		return negotiateProtocol(current)
+	}
+
+	rooms.sessionsByUser[current.AuthenticatedUser]++
+	if rooms.config.MaxSessionsPerUser > 0 && rooms.sessionsByUser[current.AuthenticatedUser] > rooms.config.MaxSessionsPerUser {
+		return newError(CodeLimitReached, "", "user %s has reached the maximum of %d sessions", current.AuthenticatedUser, rooms.config.MaxSessionsPerUser)
+	}
+	return negotiateProtocol(current)
+}
+
+func (r *Rooms) releaseSession(current ClientInfo) {
+	if !current.Authenticated {
+		return
+	}
+
+	r.sessionsByUser[current.AuthenticatedUser]--
+	if r.sessionsByUser[current.AuthenticatedUser] <= 0 {
+		delete(r.sessionsByUser, current.AuthenticatedUser)
+	}
+}
@@ -1,10 +1,10 @@
 package ws
 
 import (
-	"errors"
-	"fmt"
-
 	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
 	"github.com/screego/server/config"
 )
 
@@ -20,48 +20,89 @@ type Create struct {
 	CloseOnOwnerLeave bool           `json:"closeOnOwnerLeave"`
 	UserName          string         `json:"username"`
 	JoinIfExist       bool           `json:"joinIfExist,omitempty"`
+	Password          string         `json:"password,omitempty"`
+	WaitingRoom       bool           `json:"waitingRoom,omitempty"`
+
+	// passwordHash is the hash of Password, it is computed outside of the event loop.
+	passwordHash []byte
 }
 
 func (e *Create) Execute(rooms *Rooms, current ClientInfo) error {
 	if current.RoomID != "" {
-		return fmt.Errorf("cannot join room, you are already in one")
+		return newError(CodeProtocolError, current.RoomID, "cannot join room, you are already in one")
+	}
+	if _, waiting := rooms.waiting[current.ID]; waiting {
+		return newError(CodeProtocolError, "", "cannot join room, you are waiting for approval")
 	}
 
-	if _, ok := rooms.Rooms[e.ID]; ok {
+	if _, ok := rooms.store.GetRoom(e.ID); ok {
 		if e.JoinIfExist {
-			join := &Join{UserName: e.UserName, ID: e.ID}
+			join := &Join{UserName: e.UserName, ID: e.ID, Password: e.Password}
 			return join.Execute(rooms, current)
 		}
 
-		return fmt.Errorf("room with id %s does already exist", e.ID)
+		return newError(CodeRoomExists, e.ID, "room with id %s does already exist", e.ID)
+	}
+	if current.Observer {
+		return newError(CodeNotAuthorized, e.ID, "observers cannot create rooms")
+	}
+	if ok, rule := rooms.createNetworks.Check(current.Addr); !ok {
+		log.Info().Str("ip", current.Addr.String()).Str("rule", rule).Str("room", e.ID).Msg("Room creation denied for the network")
+		return newError(CodeNotPermitted, e.ID, "you cannot create rooms from this network")
+	}
+
+	switch rooms.config.AuthMode {
+	case config.AuthModeNone, config.AuthModeAll, config.AuthModeTurn:
+	default:
+		return newError(CodeInternalError, e.ID, "invalid authmode:%s", rooms.config.AuthMode)
+	}
+	guest := !current.Authenticated && rooms.loginRequired(e.Mode)
+	if guest && !rooms.config.AllowGuestCreate {
+		return newError(CodeNotAuthorized, e.ID, "you need to login")
 	}
 
 	name := e.UserName
 	if current.Authenticated {
 		name = current.AuthenticatedUser
+	} else if guest && name != "" {
+		var err error
+		if name, err = guestName(name); err != nil {
+			return newError(CodeInvalidName, e.ID, "%s", err)
+		}
 	}
 	if name == "" {
 		name = rooms.RandUserName()
 	}
+	if rooms.config.RequireAuthToShare && !current.Authenticated {
+		return newError(CodeNotAuthorized, e.ID, "you need to login")
+	}
+	// the permission is looked up on every creation, so that a reload of the users file applies to connected users.
+	if !rooms.users.CanCreateRooms(current.FileUser) {
+		return newError(CodeNotPermitted, e.ID, "you are not permitted to create rooms")
+	}
 
-	switch rooms.config.AuthMode {
-	case config.AuthModeNone:
-	case config.AuthModeAll:
-		if !current.Authenticated {
-			return errors.New("you need to login")
+	owner := ownerKey(current)
+	if rooms.config.MaxRoomsPerUser > 0 && rooms.roomsByOwner[owner] >= rooms.config.MaxRoomsPerUser {
+		return newError(CodeLimitReached, e.ID, "you have reached the maximum of %d rooms", rooms.config.MaxRoomsPerUser)
+	}
+
+	if e.Password != "" {
+		if !rooms.config.RoomPasswordsEnabled {
+			return newError(CodeFeatureDisabled, e.ID, "room passwords are disabled")
 		}
-	case config.AuthModeTurn:
-		if e.Mode != ConnectionSTUN && e.Mode != ConnectionLocal && !current.Authenticated {
-			return errors.New("you need to login")
+		if e.passwordHash == nil {
+			return e.hashPassword(rooms, current)
 		}
-	default:
-		return errors.New("invalid authmode:" + rooms.config.AuthMode)
 	}
 
 	room := &Room{
 		ID:                e.ID,
 		CloseOnOwnerLeave: e.CloseOnOwnerLeave,
 		Mode:              e.Mode,
+		WaitingRoom:       e.WaitingRoom,
+		PasswordHash:      e.passwordHash,
+		ownerKey:          owner,
+		events:            newEventLog(rooms.config.RoomEventLogSize),
 		Sessions:          map[xid.ID]*RoomSession{},
 		Users: map[xid.ID]*User{
 			current.ID: {
@@ -69,15 +110,57 @@ func (e *Create) Execute(rooms *Rooms, current ClientInfo) error {
 				Name:      name,
 				Streaming: false,
 				Owner:     true,
+				Guest:     guest,
 				Addr:      current.Addr,
+				Protocol:  current.Protocol,
 				Write:     current.Write,
 				Close:     current.Close,
+				joinedAt:  rooms.now(),
 			},
 		},
 	}
-	rooms.Rooms[e.ID] = room
+	room.createdAt = rooms.now()
+	if rooms.config.RoomLifetime > 0 {
+		room.expiresAt = room.createdAt.Add(rooms.config.RoomLifetime)
+	}
+	if current.Authenticated {
+		room.CreatedBy = current.AuthenticatedUser
+	}
+	if err := rooms.store.CreateRoom(room); err != nil {
+		return err
+	}
+	rooms.roomsByOwner[owner]++
+	rooms.stopJoinTimer(current.ID)
+	logEvent := log.Debug().Str("room", e.ID).Str("mode", string(e.Mode))
+	if rooms.config.Region != "" {
+		logEvent = logEvent.Str("region", rooms.config.Region)
+	}
+	logEvent.Msg("Room created")
 	room.notifyInfoChanged()
+	rooms.issueReconnectToken(room, room.Users[current.ID])
 	usersJoinedTotal.Inc()
+	roomJoinTotal.Inc()
 	roomsCreatedTotal.Inc()
+	room.logEvent(RoomEventJoin, room.Users[current.ID], "")
+	rooms.auditLog(audit.RoomCreate, current, name, "", room.ID)
+	rooms.webhook(WebhookRoomCreated, room.ID, nil)
+	rooms.webhook(WebhookUserJoined, room.ID, room.Users[current.ID])
 	return nil
 }
+
+// hashPassword hashes the room password outside of the event loop and executes the creation again afterwards, the
+// rooms may have changed in the meantime.
+func (e *Create) hashPassword(rooms *Rooms, current ClientInfo) error {
+	params := auth.HashParamsFrom(rooms.config)
+	var hash string
+	var err error
+	return rooms.offload(current, func() {
+		hash, err = auth.HashPassword([]byte(e.Password), params)
+	}, func() error {
+		if err != nil {
+			return newError(CodeInternalError, e.ID, "could not hash room password: %s", err)
+		}
+		e.passwordHash = []byte(hash)
+		return e.Execute(rooms, current)
+	})
+}
@@ -3,17 +3,32 @@ package ws
 import (
 	"bytes"
 
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/config"
 	"github.com/screego/server/ws/outgoing"
 )
 
-type Disconnected struct{}
+// Disconnected is sent when a connection is closed. lost is set if the connection broke without a close handshake,
+// users of such connections may reconnect.
+type Disconnected struct {
+	lost bool
+}
 
 func (e *Disconnected) Execute(rooms *Rooms, current ClientInfo) error {
+	delete(rooms.clients, current.ID)
+	rooms.stopJoinTimer(current.ID)
+	delete(rooms.pendingJoins, current.ID)
+	delete(rooms.passwordAttempts, current.ID)
+	delete(rooms.offloading, current.ID)
+	rooms.releaseSession(current)
+	delete(rooms.waiting, current.ID)
+
 	if current.RoomID == "" {
 		return nil
 	}
 
-	room, ok := rooms.Rooms[current.RoomID]
+	room, ok := rooms.store.GetRoom(current.RoomID)
 	if !ok {
 		// room may already be removed
 		return nil
@@ -26,41 +41,129 @@ func (e *Disconnected) Execute(rooms *Rooms, current ClientInfo) error {
 		return nil
 	}
 
-	current.Close <- CloseDone
-	delete(room.Users, current.ID)
+	closeConnection(current.Close, CloseCodeNormal, CloseDone)
+	if e.lost && user.reconnectNonce != "" && !rooms.stopping {
+		rooms.holdForReconnect(room, user)
+		return nil
+	}
+	rooms.leave(room, user)
+	return nil
+}
+
+// leave removes the user from the room and closes the room if it is empty or the owner left a room that should be
+// closed on owner leave.
+func (r *Rooms) leave(room *Room, user *User) {
+	if err := r.store.RemoveUser(room.ID, user.ID); err != nil {
+		log.Warn().Err(err).Str("room", room.ID).Msg("Could not remove user")
+	}
 	usersLeftTotal.Inc()
+	roomLeaveTotal.Inc()
+	room.logEvent(RoomEventLeave, user, "")
+	if user.Streaming {
+		room.logEvent(RoomEventShareStop, user, "")
+		r.webhook(WebhookScreenshareStop, room.ID, user)
+	}
+	r.auditLog(audit.RoomLeave, ClientInfo{ID: user.ID, Addr: user.Addr}, user.Name, "", room.ID)
+	r.webhook(WebhookUserLeft, room.ID, user)
+	if user.Guest {
+		log.Info().Str("name", user.Name).Str("ip", user.Addr.String()).Str("room", room.ID).Msg("Guest left")
+	}
 
-	for id, session := range room.Sessions {
-		if bytes.Equal(session.Client.Bytes(), current.ID.Bytes()) {
-			host, ok := room.Users[session.Host]
-			if ok {
-				host.Write <- outgoing.EndShare(id)
-			}
-			room.closeSession(rooms, id)
-		}
-		if bytes.Equal(session.Host.Bytes(), current.ID.Bytes()) {
-			client, ok := room.Users[session.Client]
-			if ok {
-				client.Write <- outgoing.EndShare(id)
-			}
-			room.closeSession(rooms, id)
-		}
+	room.endSessionsOf(r, user)
+
+	policy := r.hostLeavePolicy(room)
+	if user.Owner && policy != config.HostLeavePolicyMigrate {
+		r.rejectWaiting(room.ID, newError(CodeOwnerLeft, room.ID, CloseOwnerLeft))
 	}
 
-	if user.Owner && room.CloseOnOwnerLeave {
+	if user.Owner && policy == config.HostLeavePolicyClose {
 		for _, member := range room.Users {
-			member.Close <- CloseOwnerLeft
+			room.logEvent(RoomEventDisconnect, member, CloseOwnerLeft)
+			member.reject(newError(CodeOwnerLeft, room.ID, CloseOwnerLeft))
 		}
-		rooms.closeRoom(current.RoomID)
-		return nil
+		r.closeRoom(room.ID, audit.ReasonOwnerLeft)
+		return
 	}
 
-	if len(room.Users) == 0 {
-		rooms.closeRoom(current.RoomID)
-		return nil
+	if room.members() == 0 {
+		for _, observer := range room.Users {
+			observer.reject(newError(CodeRoomClosed, room.ID, CloseRoomClosed))
+		}
+		r.closeRoom(room.ID, audit.ReasonEmpty)
+		return
 	}
 
+	if user.Owner && policy == config.HostLeavePolicyMigrate {
+		r.migrateHost(room)
+	}
 	room.notifyInfoChanged()
+}
 
-	return nil
+// hostLeavePolicy returns the configured policy, without policy the room setting decides between close and persist.
+func (r *Rooms) hostLeavePolicy(room *Room) string {
+	if r.config.HostLeavePolicy != "" {
+		return r.config.HostLeavePolicy
+	}
+	if room.CloseOnOwnerLeave {
+		return config.HostLeavePolicyClose
+	}
+	return config.HostLeavePolicyPersist
+}
+
+// migrateHost makes the member that is longest in the room the owner, connected members are preferred over members
+// that wait for their reconnect. The room still counts for the room limit of its creator.
+func (r *Rooms) migrateHost(room *Room) {
+	var host *User
+	for _, user := range room.Users {
+		if user.Observer {
+			continue
+		}
+		if host == nil || longerInRoom(user, host) {
+			host = user
+		}
+	}
+	if host == nil {
+		return
+	}
+	host.Owner = true
+	room.logEvent(RoomEventHostChange, host, "")
+	for _, user := range room.Users {
+		user.send(outgoing.HostChanged{Room: room.ID, ID: host.ID, Name: host.Name})
+	}
+	for id, waiting := range r.waiting {
+		if waiting.RoomID == room.ID {
+			host.send(outgoing.UserWaiting{ID: id, Name: waiting.Name})
+		}
+	}
+}
+
+func longerInRoom(user, other *User) bool {
+	connected, otherConnected := user.disconnectedAt.IsZero(), other.disconnectedAt.IsZero()
+	if connected != otherConnected {
+		return connected
+	}
+	if !user.joinedAt.Equal(other.joinedAt) {
+		return user.joinedAt.Before(other.joinedAt)
+	}
+	return user.ID.Compare(other.ID) < 0
+}
+
+// endSessionsOf closes all sessions the user takes part in and tells the peers about it.
+func (r *Room) endSessionsOf(rooms *Rooms, user *User) {
+	for id, session := range r.Sessions {
+		if bytes.Equal(session.Client.Bytes(), user.ID.Bytes()) {
+			host, ok := r.Users[session.Host]
+			if ok {
+				host.send(outgoing.EndShare(id))
+			}
+			r.closeSession(rooms, id)
+		}
+		if bytes.Equal(session.Host.Bytes(), user.ID.Bytes()) {
+			client, ok := r.Users[session.Client]
+			if ok {
+				client.send(outgoing.EndShare(id))
+			}
+			r.closeSession(rooms, id)
+		}
+	}
 }
@@ -0,0 +1,132 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newHostLeaveRoom creates a room of owner that first joins before second.
+func newHostLeaveRoom(t *testing.T, policy string, closeOnOwnerLeave bool) (*Rooms, ClientInfo, ClientInfo, ClientInfo) {
+	t.Helper()
+	rooms := newTestRooms(config.Config{HostLeavePolicy: policy, RoomEventLogSize: 10})
+	now := time.Now()
+	rooms.now = func() time.Time { return now }
+
+	owner := newTestClient("alice")
+	require.NoError(t, createRoomWith(t, rooms, &owner, &Create{ID: "room", Mode: ConnectionLocal, CloseOnOwnerLeave: closeOnOwnerLeave}))
+	var members []ClientInfo
+	for _, name := range []string{"bob", "carol"} {
+		now = now.Add(time.Second)
+		member := newTestClient(name)
+		require.NoError(t, (&Join{ID: "room"}).Execute(rooms, member))
+		member.RoomID = "room"
+		members = append(members, member)
+	}
+	for _, client := range []ClientInfo{owner, members[0], members[1]} {
+		drain(client)
+	}
+	return rooms, owner, members[0], members[1]
+}
+
+func TestHostLeave_close(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		policy            string
+		closeOnOwnerLeave bool
+	}{
+		{name: "policy", policy: config.HostLeavePolicyClose},
+		{name: "room setting", closeOnOwnerLeave: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rooms, owner, first, second := newHostLeaveRoom(t, test.policy, test.closeOnOwnerLeave)
+
+			require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+			assert.Nil(t, getRoom(rooms, "room"))
+			for _, member := range []ClientInfo{first, second} {
+				assert.Equal(t, []outgoing.Message{outgoing.Error{Code: string(CodeOwnerLeft), Message: CloseOwnerLeft, Room: "room"}}, drain(member))
+				assert.Equal(t, closeFrame{Code: CloseCodeRoomClosed, Reason: CloseOwnerLeft}, <-member.Close)
+			}
+		})
+	}
+}
+
+func TestHostLeave_persist(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy string
+	}{
+		{name: "policy", policy: config.HostLeavePolicyPersist},
+		{name: "room setting"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rooms, owner, first, second := newHostLeaveRoom(t, test.policy, false)
+
+			require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+			room := getRoom(rooms, "room")
+			require.NotNil(t, room)
+			assert.Len(t, room.Users, 2)
+			for _, user := range room.Users {
+				assert.False(t, user.Owner, user.Name)
+			}
+			assert.Empty(t, first.Close)
+			assert.Empty(t, second.Close)
+		})
+	}
+}
+
+func TestHostLeave_migrate(t *testing.T) {
+	rooms, owner, first, second := newHostLeaveRoom(t, config.HostLeavePolicyMigrate, true)
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+	room := getRoom(rooms, "room")
+	require.NotNil(t, room, "the migrate policy overrides the room setting")
+	assert.True(t, room.Users[first.ID].Owner, "the longest member becomes the owner")
+	assert.False(t, room.Users[second.ID].Owner)
+	for _, member := range []ClientInfo{first, second} {
+		messages := drain(member)
+		require.NotEmpty(t, messages)
+		assert.Equal(t, outgoing.HostChanged{Room: "room", ID: first.ID, Name: "bob"}, messages[0])
+	}
+	assert.Equal(t, RoomEventHostChange, room.events.list()[len(room.events.list())-1].Type)
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, first))
+	assert.True(t, room.Users[second.ID].Owner, "the new owner can hand over the room too")
+}
+
+func TestHostLeave_migrateSkipsObserversAndDisconnected(t *testing.T) {
+	rooms, owner, first, second := newHostLeaveRoom(t, config.HostLeavePolicyMigrate, false)
+	room := getRoom(rooms, "room")
+	room.Users[first.ID].disconnectedAt = time.Now()
+	observer := newTestClient("")
+	observer.Observer = true
+	require.NoError(t, rooms.addUser(room, observer, "observer", false))
+	room.Users[observer.ID].joinedAt = time.Time{}
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+	assert.False(t, room.Users[observer.ID].Owner)
+	assert.False(t, room.Users[first.ID].Owner, "members waiting for their reconnect are skipped")
+	assert.True(t, room.Users[second.ID].Owner)
+}
+
+func TestDisconnected_closePending(t *testing.T) {
+	rooms, _, first, _ := newHostLeaveRoom(t, config.HostLeavePolicyMigrate, false)
+	closeConnection(first.Close, CloseCodeTooSlow, CloseTooSlow)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- (&Disconnected{}).Execute(rooms, first)
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the disconnect blocked on the pending close")
+	}
+	assert.Equal(t, closeFrame{Code: CloseCodeTooSlow, Reason: CloseTooSlow}, <-first.Close)
+	assert.Len(t, getRoom(rooms, "room").Users, 2)
+}
@@ -0,0 +1,60 @@
+package ws
+
+import (
+	"encoding/base64"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/ws/outgoing"
+)
+
+func init() {
+	register("e2e_key_exchange", func() Event {
+		return &E2EKeyExchange{}
+	})
+}
+
+// maxE2EPublicKey is the maximum decoded size of a public key, it fits X25519, P-521 and 4096 bit finite field keys.
+const maxE2EPublicKey = 1024
+
+// E2EKeyExchange relays the public key to the other peer of the session. Like other signaling messages only the type
+// is recorded, the key is neither logged nor stored.
+type E2EKeyExchange outgoing.E2EKeyExchange
+
+func (e *E2EKeyExchange) Execute(rooms *Rooms, current ClientInfo) error {
+	if !rooms.config.E2EEncryption {
+		return newError(CodeFeatureDisabled, current.RoomID, "end-to-end encryption is disabled")
+	}
+	if current.RoomID == "" {
+		return errNotInRoom()
+	}
+
+	room, ok := rooms.store.GetRoom(current.RoomID)
+	if !ok {
+		return errRoomNotFound(current.RoomID)
+	}
+
+	session, ok := room.Sessions[e.SID]
+	if !ok {
+		log.Debug().Str("id", e.SID.String()).Msg("unknown session")
+		return nil
+	}
+
+	peer := session.Client
+	switch current.ID {
+	case session.Host:
+	case session.Client:
+		peer = session.Host
+	default:
+		return newError(CodeNotAuthorized, current.RoomID, "permission denied for session %s", e.SID)
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(e.PublicKey); err != nil || len(key) == 0 || len(key) > maxE2EPublicKey {
+		return newError(CodeProtocolError, current.RoomID, "the public key must be base64 with at most %d bytes", maxE2EPublicKey)
+	}
+
+	room.logEvent(RoomEventSignaling, room.Users[current.ID], "e2e_key_exchange")
+	if user, ok := room.Users[peer]; ok {
+		user.send(outgoing.E2EKeyExchange(*e))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newE2ERoom returns a room where host shares the screen with viewer and the id of their session.
+func newE2ERoom(t *testing.T, conf config.Config) (*Rooms, ClientInfo, ClientInfo, xid.ID) {
+	t.Helper()
+	rooms := newTestRooms(conf)
+	host := newTestClient("alice")
+	require.NoError(t, createRoom(t, rooms, &host, "room"))
+	viewer := newTestClient("bob")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, viewer))
+	viewer.RoomID = "room"
+	require.NoError(t, (&StartShare{}).Execute(rooms, host))
+	drain(host)
+	drain(viewer)
+
+	for id := range getRoom(rooms, "room").Sessions {
+		return rooms, host, viewer, id
+	}
+	t.Fatal("no session")
+	return nil, host, viewer, xid.ID{}
+}
+
+func TestE2EKeyExchange(t *testing.T) {
+	rooms, host, viewer, sid := newE2ERoom(t, config.Config{E2EEncryption: true, RoomEventLogSize: 10})
+	key := base64.StdEncoding.EncodeToString([]byte("public key of the host"))
+
+	require.NoError(t, (&E2EKeyExchange{SID: sid, PublicKey: key}).Execute(rooms, host))
+	assert.Equal(t, []outgoing.Message{outgoing.E2EKeyExchange{SID: sid, PublicKey: key}}, drain(viewer))
+	assert.Empty(t, drain(host))
+
+	require.NoError(t, (&E2EKeyExchange{SID: sid, PublicKey: key}).Execute(rooms, viewer))
+	assert.Equal(t, []outgoing.Message{outgoing.E2EKeyExchange{SID: sid, PublicKey: key}}, drain(host))
+
+	events := getRoom(rooms, "room").events.list()
+	last := events[len(events)-1]
+	assert.Equal(t, RoomEventSignaling, last.Type)
+	assert.Equal(t, "e2e_key_exchange", last.Detail)
+	for _, event := range events {
+		assert.NotContains(t, event.Detail, key, "the key isn't recorded")
+	}
+}
+
+func TestE2EKeyExchange_rejected(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte("key"))
+	tests := []struct {
+		name    string
+		conf    config.Config
+		message func(sid xid.ID) *E2EKeyExchange
+		other   bool
+		code    ErrorCode
+	}{
+		{name: "disabled", message: func(sid xid.ID) *E2EKeyExchange { return &E2EKeyExchange{SID: sid, PublicKey: key} },
+			code: CodeFeatureDisabled},
+		{name: "not base64", conf: config.Config{E2EEncryption: true},
+			message: func(sid xid.ID) *E2EKeyExchange { return &E2EKeyExchange{SID: sid, PublicKey: "not base64!"} },
+			code:    CodeProtocolError},
+		{name: "too big", conf: config.Config{E2EEncryption: true},
+			message: func(sid xid.ID) *E2EKeyExchange {
+				return &E2EKeyExchange{SID: sid, PublicKey: base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", maxE2EPublicKey+1)))}
+			},
+			code: CodeProtocolError},
+		{name: "other user", conf: config.Config{E2EEncryption: true},
+			message: func(sid xid.ID) *E2EKeyExchange { return &E2EKeyExchange{SID: sid, PublicKey: key} },
+			other:   true, code: CodeNotAuthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms, host, viewer, sid := newE2ERoom(t, test.conf)
+			sender := host
+			if test.other {
+				sender = newTestClient("carol")
+				require.NoError(t, (&Join{ID: "room"}).Execute(rooms, sender))
+				sender.RoomID = "room"
+				drain(host)
+				drain(viewer)
+			}
+
+			err := test.message(sid).Execute(rooms, sender)
+			var codeErr *Error
+			require.ErrorAs(t, err, &codeErr)
+			assert.Equal(t, test.code, codeErr.Code)
+			assert.Empty(t, drain(viewer), "nothing is relayed")
+		})
+	}
+}
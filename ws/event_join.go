@@ -1,7 +1,11 @@
 package ws
 
 import (
-	"fmt"
+	"bytes"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/ws/outgoing"
 )
 
 func init() {
@@ -13,38 +17,120 @@ func init() {
 type Join struct {
 	ID       string `json:"id"`
 	UserName string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Invite   string `json:"invite,omitempty"`
+
+	// verifiedHash is the room password hash the password was verified against.
+	verifiedHash []byte
 }
 
 func (e *Join) Execute(rooms *Rooms, current ClientInfo) error {
 	if current.RoomID != "" {
-		return fmt.Errorf("cannot join room, you are already in one")
+		return newError(CodeProtocolError, current.RoomID, "cannot join room, you are already in one")
+	}
+	if _, waiting := rooms.waiting[current.ID]; waiting {
+		return newError(CodeProtocolError, "", "cannot join room, you are waiting for approval")
 	}
 
-	room, ok := rooms.Rooms[e.ID]
+	room, ok := rooms.store.GetRoom(e.ID)
 	if !ok {
-		return fmt.Errorf("room with id %s does not exist", e.ID)
+		return errRoomNotFound(e.ID)
+	}
+	if current.Observer {
+		return rooms.addObserver(room, current, e.UserName)
+	}
+
+	invited := e.Invite != "" && rooms.validInvite(e.Invite, room)
+	guest := !current.Authenticated && rooms.loginRequired(room.Mode)
+	if guest {
+		if !rooms.config.AllowGuestJoin {
+			return newError(CodeNotAuthorized, room.ID, "you need to login")
+		}
+		if room.PasswordHash != nil && !invited {
+			return newError(CodeNotAuthorized, room.ID, "guests need an invite to join this room")
+		}
+	}
+
+	if room.PasswordHash != nil && !invited && !current.Admin && !bytes.Equal(e.verifiedHash, room.PasswordHash) {
+		if e.Password == "" {
+			rooms.pendingJoins[current.ID] = e
+			current.send(outgoing.RoomPasswordRequired{ID: room.ID})
+			return nil
+		}
+		// the join is executed again after the check, the room may have changed in the meantime.
+		return rooms.checkRoomPassword(room, current, e.Password, func(hash []byte) error {
+			e.verifiedHash = hash
+			return e.Execute(rooms, current)
+		})
+	}
+
+	return rooms.join(room, current, e.UserName, guest)
+}
+
+func (r *Rooms) join(room *Room, current ClientInfo, userName string, guest bool) error {
+	name := userName
+	if name == "" {
+		name = current.QueryName
 	}
-	name := e.UserName
 	if current.Authenticated {
 		name = current.AuthenticatedUser
+	} else if name != "" {
+		// the name of the query comes from a link and isn't trusted either.
+		var err error
+		if name, err = guestName(name); err != nil {
+			return newError(CodeInvalidName, room.ID, "%s", err)
+		}
 	}
 	if name == "" {
-		name = rooms.RandUserName()
+		name = r.RandUserName()
+	}
+	if r.banned(room.ID, name, current.Addr) {
+		return newError(CodeBanned, room.ID, "you are banned from this room")
 	}
 
-	room.Users[current.ID] = &User{
+	if room.WaitingRoom && !current.Admin {
+		return r.wait(room, current, name, guest)
+	}
+	return r.addUser(room, current, name, guest)
+}
+
+func (r *Rooms) addUser(room *Room, current ClientInfo, name string, guest bool) error {
+	r.stopJoinTimer(current.ID)
+	if guest {
+		name = room.uniqueName(name)
+	}
+	user := &User{
 		ID:        current.ID,
 		Name:      name,
 		Streaming: false,
 		Owner:     false,
+		Guest:     guest,
+		Observer:  current.Observer,
+		Protocol:  current.Protocol,
 		Addr:      current.Addr,
 		Write:     current.Write,
 		Close:     current.Close,
+		joinedAt:  r.now(),
+	}
+	if err := r.store.AddUser(room.ID, user); err != nil {
+		return err
 	}
 	room.notifyInfoChanged()
+	r.issueReconnectToken(room, user)
 	usersJoinedTotal.Inc()
+	roomJoinTotal.Inc()
+	room.logEvent(RoomEventJoin, user, "")
+	r.auditLog(audit.RoomJoin, current, name, "", room.ID)
+	r.webhook(WebhookUserJoined, room.ID, user)
+	if guest {
+		log.Info().Str("name", name).Str("ip", current.Addr.String()).Str("room", room.ID).Msg("Guest joined")
+	}
+	return r.receiveShares(room, current)
+}
 
-	v4, v6, err := rooms.config.TurnIPProvider.Get()
+// receiveShares creates sessions from all streaming users of the room to the current user.
+func (r *Rooms) receiveShares(room *Room, current ClientInfo) error {
+	v4, v6, err := r.config.TurnIPProvider.Get()
 	if err != nil {
 		return err
 	}
@@ -53,7 +139,7 @@ func (e *Join) Execute(rooms *Rooms, current ClientInfo) error {
 		if current.ID == user.ID || !user.Streaming {
 			continue
 		}
-		room.newSession(user.ID, current.ID, rooms, v4, v6)
+		room.newSession(user.ID, current.ID, r, v4, v6)
 	}
 
 	return nil
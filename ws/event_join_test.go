@@ -0,0 +1,161 @@
+package ws
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoin_guests(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    bool
+		password string
+		invite   bool
+		err      string
+	}{
+		{name: "guests disabled", err: "you need to login"},
+		{name: "guests disabled with invite", invite: true, err: "you need to login"},
+		{name: "room without password", allow: true},
+		{name: "password room", allow: true, password: "pw", err: "guests need an invite to join this room"},
+		{name: "password room with invite", allow: true, password: "pw", invite: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms := newTestRooms(config.Config{
+				AuthMode:             config.AuthModeAll,
+				AllowGuestJoin:       test.allow,
+				RoomPasswordsEnabled: true,
+				InviteExpiry:         time.Hour,
+				Secret:               []byte("secret"),
+			})
+			owner := newTestClient("alice")
+			require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN, Password: test.password}).Execute(rooms, owner))
+
+			join := &Join{ID: "room"}
+			if test.invite {
+				join.Invite = rooms.signInvite(getRoom(rooms, "room"), time.Now().Add(time.Hour))
+			}
+			guest := newTestClient("")
+			guest.QueryName = "Visitor"
+			err := join.Execute(rooms, guest)
+
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				assert.NotContains(t, getRoom(rooms, "room").Users, guest.ID)
+				return
+			}
+			require.NoError(t, err)
+			user := getRoom(rooms, "room").Users[guest.ID]
+			require.NotNil(t, user)
+			assert.True(t, user.Guest)
+			assert.False(t, user.Owner)
+			assert.Equal(t, "Visitor", user.Name)
+		})
+	}
+}
+
+func TestCreate_guestsCannotCreate(t *testing.T) {
+	rooms := newTestRooms(config.Config{AuthMode: config.AuthModeAll, AllowGuestJoin: true})
+	assert.EqualError(t, (&Create{ID: "room", Mode: ConnectionTURN}).Execute(rooms, newTestClient("")), "you need to login")
+}
+
+func TestCreate_guests(t *testing.T) {
+	rooms := newTestRooms(config.Config{AuthMode: config.AuthModeAll, AllowGuestCreate: true})
+	guest := newTestClient("")
+	assert.EqualError(t, (&Create{ID: "room", Mode: ConnectionTURN, UserName: "<b>"}).Execute(rooms, guest), `the name must not contain '<'`)
+
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN, UserName: " Visitor "}).Execute(rooms, guest))
+	owner := getRoom(rooms, "room").Users[guest.ID]
+	assert.True(t, owner.Guest)
+	assert.True(t, owner.Owner)
+	assert.Equal(t, "Visitor", owner.Name)
+}
+
+func TestJoin_guestNames(t *testing.T) {
+	rooms := newTestRooms(config.Config{AuthMode: config.AuthModeAll, AllowGuestJoin: true})
+	owner := newTestClient("bob")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN}).Execute(rooms, owner))
+
+	for _, name := range []string{"   ", "bo\nb", "<b>", strings.Repeat("x", 33)} {
+		var err *Error
+		require.ErrorAs(t, (&Join{ID: "room", UserName: name}).Execute(rooms, newTestClient("")), &err, name)
+		assert.Equal(t, CodeInvalidName, err.Code, name)
+	}
+	for _, name := range []string{"<b>", strings.Repeat("x", 33)} {
+		fromQuery := newTestClient("")
+		fromQuery.QueryName = name
+		var err *Error
+		require.ErrorAs(t, (&Join{ID: "room"}).Execute(rooms, fromQuery), &err, name)
+		assert.Equal(t, CodeInvalidName, err.Code, name)
+	}
+
+	require.NoError(t, (&Join{ID: "room", UserName: "bob"}).Execute(rooms, newTestClient("")))
+	require.NoError(t, (&Join{ID: "room", UserName: "bob"}).Execute(rooms, newTestClient("")))
+	names := []string{}
+	for _, user := range getRoom(rooms, "room").Users {
+		names = append(names, user.Name)
+	}
+	assert.ElementsMatch(t, []string{"bob", "bob (2)", "bob (3)"}, names)
+
+	messages := drain(owner)
+	info := messages[len(messages)-1].(outgoing.Room)
+	require.Len(t, info.Users, 3)
+	for _, user := range info.Users {
+		assert.Equal(t, user.ID != owner.ID, user.Guest, user.Name)
+	}
+}
+
+func TestJoin_admin(t *testing.T) {
+	rooms := newTestRooms(config.Config{RoomPasswordsEnabled: true})
+	owner := newTestClient("alice")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionLocal, Password: "pw", WaitingRoom: true}).Execute(rooms, owner))
+
+	admin := newTestClient("root")
+	admin.Admin = true
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, admin))
+	assert.Contains(t, getRoom(rooms, "room").Users, admin.ID, "admins skip the password and the waiting room")
+	assert.Empty(t, rooms.waiting)
+}
+
+func TestCreate_networks(t *testing.T) {
+	rooms := newTestRooms(config.Config{RoomCreateAllowedNetworks: []string{"10.0.0.0/8"}})
+	var err *Error
+	require.ErrorAs(t, (&Create{ID: "room", Mode: ConnectionLocal}).Execute(rooms, newTestClient("bob")), &err)
+	assert.Equal(t, CodeNotPermitted, err.Code)
+
+	office := newTestClient("alice")
+	office.Addr = net.ParseIP("10.0.0.5")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionLocal}).Execute(rooms, office))
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, newTestClient("bob")), "joining isn't restricted")
+}
+
+func TestJoin_namesWithoutGuests(t *testing.T) {
+	rooms := newTestRooms(config.Config{AuthMode: config.AuthModeNone})
+	owner := newTestClient("bob")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN}).Execute(rooms, owner))
+
+	anonymous := newTestClient("")
+	anonymous.QueryName = "<script>"
+	var err *Error
+	require.ErrorAs(t, (&Join{ID: "room"}).Execute(rooms, anonymous), &err, "the query name is validated in every auth mode")
+	assert.Equal(t, CodeInvalidName, err.Code)
+
+	anonymous.QueryName = " Visitor "
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, anonymous))
+	anonymous.RoomID = "room"
+	assert.Equal(t, "Visitor", getRoom(rooms, "room").Users[anonymous.ID].Name)
+
+	require.ErrorAs(t, (&Name{UserName: strings.Repeat("x", 33)}).Execute(rooms, anonymous), &err)
+	assert.Equal(t, CodeInvalidName, err.Code)
+	require.NoError(t, (&Name{UserName: "Guest"}).Execute(rooms, anonymous))
+	assert.Equal(t, "Guest", getRoom(rooms, "room").Users[anonymous.ID].Name)
+	owner.RoomID = "room"
+	require.NoError(t, (&Name{UserName: "<bob>"}).Execute(rooms, owner), "logged in users keep choosing their name")
+}
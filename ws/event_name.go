@@ -1,9 +1,5 @@
 package ws
 
-import (
-	"fmt"
-)
-
 func init() {
 	register("name", func() Event {
 		return &Name{}
@@ -16,15 +12,22 @@ type Name struct {
 
 func (e *Name) Execute(rooms *Rooms, current ClientInfo) error {
 	if current.RoomID == "" {
-		return fmt.Errorf("not in a room")
+		return errNotInRoom()
 	}
 
-	room, ok := rooms.Rooms[current.RoomID]
+	room, ok := rooms.store.GetRoom(current.RoomID)
 	if !ok {
-		return fmt.Errorf("room with id %s does not exist", current.RoomID)
+		return errRoomNotFound(current.RoomID)
 	}
 
-	room.Users[current.ID].Name = e.UserName
+	name := e.UserName
+	if !current.Authenticated {
+		var err error
+		if name, err = guestName(name); err != nil {
+			return newError(CodeInvalidName, room.ID, "%s", err)
+		}
+	}
+	room.Users[current.ID].Name = name
 
 	room.notifyInfoChanged()
 	return nil
@@ -0,0 +1,70 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+// A member may refresh the credentials of credentialRefreshLimit sessions per credentialRefreshWindow. Every session
+// needs at most one refresh per TTL, the limit only allows refreshing all sessions of a user at once.
+const (
+	credentialRefreshLimit  = 10
+	credentialRefreshWindow = time.Minute
+)
+
+func init() {
+	register("refresh_credentials", func() Event {
+		return &RefreshCredentials{}
+	})
+}
+
+// RefreshCredentials requests new TURN credentials for a session, when the current ones are about to expire. The new
+// credentials are sent to the host and the client of the session.
+type RefreshCredentials struct {
+	ID xid.ID `json:"id"`
+}
+
+func (e *RefreshCredentials) Execute(rooms *Rooms, current ClientInfo) error {
+	if current.RoomID == "" {
+		return errNotInRoom()
+	}
+
+	room, ok := rooms.store.GetRoom(current.RoomID)
+	if !ok {
+		return errRoomNotFound(current.RoomID)
+	}
+	if room.Mode != ConnectionTURN {
+		return newError(CodeProtocolError, room.ID, "room %s doesn't use TURN", room.ID)
+	}
+
+	session, ok := room.Sessions[e.ID]
+	if !ok {
+		log.Debug().Str("id", e.ID.String()).Msg("unknown session")
+		return nil
+	}
+	if session.Host != current.ID && session.Client != current.ID {
+		return newError(CodeNotAuthorized, room.ID, "permission denied for session %s", e.ID)
+	}
+
+	user := room.Users[current.ID]
+	now := time.Now()
+	if now.Sub(user.refreshWindowStart) >= credentialRefreshWindow {
+		user.refreshWindowStart = now
+		user.refreshCount = 0
+	}
+	if user.refreshCount >= credentialRefreshLimit {
+		current.send(errorMessage(newError(CodeRateLimited, room.ID, "too many credential refreshes, try again later")))
+		return nil
+	}
+	user.refreshCount++
+
+	v4, v6, err := rooms.config.TurnIPProvider.Get()
+	if err != nil {
+		return err
+	}
+	room.logEvent(RoomEventSignaling, user, "refresh_credentials")
+	rooms.rotateSession(room, e.ID, session, v4, v6)
+	return nil
+}
@@ -0,0 +1,81 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSharingRoom(t *testing.T, mode ConnectionMode) (*Rooms, ClientInfo, ClientInfo, xid.ID) {
+	t.Helper()
+	rooms := newTestRooms(config.Config{})
+	host := newTestClient("host")
+	require.NoError(t, (&Create{ID: "room", Mode: mode}).Execute(rooms, host))
+	host.RoomID = "room"
+	viewer := newTestClient("viewer")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, viewer))
+	viewer.RoomID = "room"
+	require.NoError(t, (&StartShare{}).Execute(rooms, host))
+
+	var sid xid.ID
+	for id := range getRoom(rooms, "room").Sessions {
+		sid = id
+	}
+	drain(host)
+	drain(viewer)
+	return rooms, host, viewer, sid
+}
+
+func TestRefreshCredentials(t *testing.T) {
+	rooms, host, viewer, sid := newSharingRoom(t, ConnectionTURN)
+	session := getRoom(rooms, "room").Sessions[sid]
+	oldClient := session.ClientCredential
+
+	require.NoError(t, (&RefreshCredentials{ID: sid}).Execute(rooms, viewer))
+
+	assert.NotEqual(t, oldClient, session.ClientCredential)
+	update := lastICEServersUpdate(t, viewer)
+	assert.Equal(t, sid, update.ID)
+	assert.Equal(t, session.ClientCredential, update.ICEServers[0].Username)
+	assert.Equal(t, int64(3600), update.TTL)
+	assert.Equal(t, session.HostCredential, lastICEServersUpdate(t, host).ICEServers[0].Username)
+}
+
+func TestRefreshCredentials_notInSession(t *testing.T) {
+	rooms, _, _, sid := newSharingRoom(t, ConnectionTURN)
+	other := newTestClient("other")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, other))
+	other.RoomID = "room"
+
+	assert.EqualError(t, (&RefreshCredentials{ID: sid}).Execute(rooms, other), "permission denied for session "+sid.String())
+}
+
+func TestRefreshCredentials_withoutTURN(t *testing.T) {
+	rooms, _, viewer, sid := newSharingRoom(t, ConnectionSTUN)
+
+	assert.EqualError(t, (&RefreshCredentials{ID: sid}).Execute(rooms, viewer), "room room doesn't use TURN")
+}
+
+func TestRefreshCredentials_rateLimited(t *testing.T) {
+	rooms, _, viewer, sid := newSharingRoom(t, ConnectionTURN)
+
+	for i := 0; i < credentialRefreshLimit; i++ {
+		require.NoError(t, (&RefreshCredentials{ID: sid}).Execute(rooms, viewer))
+	}
+	drain(viewer)
+	credential := getRoom(rooms, "room").Sessions[sid].ClientCredential
+
+	require.NoError(t, (&RefreshCredentials{ID: sid}).Execute(rooms, viewer))
+
+	assert.Equal(t, credential, getRoom(rooms, "room").Sessions[sid].ClientCredential)
+	assert.Equal(t, []outgoing.Message{outgoing.Error{
+		Code:    string(CodeRateLimited),
+		Message: "too many credential refreshes, try again later",
+		Room:    "room",
+	}}, drain(viewer))
+	assert.Empty(t, viewer.Close)
+}
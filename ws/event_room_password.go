@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"github.com/screego/server/auth"
+	"github.com/screego/server/ws/outgoing"
+)
+
+const maxRoomPasswordAttempts = 3
+
+func init() {
+	register("room_password", func() Event {
+		return &RoomPassword{}
+	})
+}
+
+type RoomPassword struct {
+	Password string `json:"password"`
+}
+
+func (e *RoomPassword) Execute(rooms *Rooms, current ClientInfo) error {
+	if current.RoomID != "" {
+		return newError(CodeProtocolError, current.RoomID, "cannot join room, you are already in one")
+	}
+
+	join, ok := rooms.pendingJoins[current.ID]
+	if !ok {
+		return newError(CodeProtocolError, "", "no room join is waiting for a password")
+	}
+
+	room, ok := rooms.store.GetRoom(join.ID)
+	if !ok {
+		delete(rooms.pendingJoins, current.ID)
+		return errRoomNotFound(join.ID)
+	}
+
+	return rooms.checkRoomPassword(room, current, e.Password, func(hash []byte) error {
+		if rooms.pendingJoins[current.ID] != join {
+			return nil
+		}
+		delete(rooms.pendingJoins, current.ID)
+		join.verifiedHash = hash
+		return join.Execute(rooms, current)
+	})
+}
+
+// checkRoomPassword verifies the password of a protected room outside of the event loop, because bcrypt would stall
+// all rooms. correct is executed in the event loop with the verified hash. Failed attempts are counted per connection,
+// after maxRoomPasswordAttempts failures an error is returned which closes the connection.
+func (r *Rooms) checkRoomPassword(room *Room, current ClientInfo, password string, correct func(hash []byte) error) error {
+	roomID, hash := room.ID, room.PasswordHash
+	var ok bool
+	return r.offload(current, func() {
+		ok = auth.VerifyPassword(string(hash), []byte(password))
+	}, func() error {
+		if ok {
+			return correct(hash)
+		}
+
+		r.passwordAttempts[current.ID]++
+		remaining := maxRoomPasswordAttempts - r.passwordAttempts[current.ID]
+		if remaining <= 0 {
+			return newError(CodeRateLimited, roomID, "too many incorrect room passwords")
+		}
+
+		current.send(outgoing.RoomPasswordIncorrect{ID: roomID, RemainingAttempts: remaining})
+		current.send(errorMessage(newError(CodeWrongPassword, roomID, "incorrect room password")))
+		return nil
+	})
+}
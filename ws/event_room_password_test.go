@@ -0,0 +1,143 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type typedMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// readMessage reads messages until one of the type arrives.
+func readMessage(t *testing.T, conn *websocket.Conn, typ string) json.RawMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	for {
+		var msg typedMessage
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg.Type == typ {
+			return msg.Payload
+		}
+	}
+}
+
+// passwordRoom starts the rooms with a room protected by the password "secret" and connects another client.
+func passwordRoom(t *testing.T) (*Rooms, *websocket.Conn) {
+	t.Helper()
+	rooms, url := startTestServer(t, config.Config{
+		RoomPasswordsEnabled: true,
+		PasswordHash:         auth.HashBcrypt,
+		BcryptCost:           bcrypt.MinCost,
+	})
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {url}})
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+		return conn
+	}
+
+	owner := dial()
+	require.NoError(t, owner.WriteMessage(websocket.TextMessage, []byte(`{"type":"create","payload":{"id":"room","mode":"local","password":"secret"}}`)))
+	readMessage(t, owner, "room")
+
+	return rooms, dial()
+}
+
+func TestRoomPassword_correct(t *testing.T) {
+	rooms, conn := passwordRoom(t)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"join","payload":{"id":"room"}}`)))
+	readMessage(t, conn, "room_password_required")
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"room_password","payload":{"password":"secret"}}`)))
+	readMessage(t, conn, "room")
+
+	assert.Equal(t, Stats{Connections: 2, Rooms: 1, Members: 2}, currentStats(t, rooms))
+}
+
+func TestRoomPassword_wrong(t *testing.T) {
+	rooms, conn := passwordRoom(t)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"join","payload":{"id":"room","password":"wrong"}}`)))
+	var incorrect struct {
+		ID                string `json:"id"`
+		RemainingAttempts int    `json:"remainingAttempts"`
+	}
+	require.NoError(t, json.Unmarshal(readMessage(t, conn, "room_password_incorrect"), &incorrect))
+	assert.Equal(t, "room", incorrect.ID)
+	assert.Equal(t, maxRoomPasswordAttempts-1, incorrect.RemainingAttempts)
+
+	var errMsg struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(readMessage(t, conn, "error"), &errMsg))
+	assert.Equal(t, string(CodeWrongPassword), errMsg.Code)
+	assert.Equal(t, Stats{Connections: 2, Rooms: 1, Members: 1}, currentStats(t, rooms))
+}
+
+func TestRoomPassword_tooManyAttempts(t *testing.T) {
+	_, conn := passwordRoom(t)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"join","payload":{"id":"room"}}`)))
+	readMessage(t, conn, "room_password_required")
+	for i := 1; i < maxRoomPasswordAttempts; i++ {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"room_password","payload":{"password":"wrong"}}`)))
+		readMessage(t, conn, "room_password_incorrect")
+	}
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"room_password","payload":{"password":"wrong"}}`)))
+
+	err := readUntilClose(t, conn, time.Second)
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseCodeRejected, closeErr.Code)
+}
+
+func TestOffloadAsync_doesNotBlockEventLoop(t *testing.T) {
+	rooms := NewRooms(&fakeTurnServer{}, &auth.Users{}, NewMemoryRoomStore(), config.Config{})
+	go rooms.Start()
+	t.Cleanup(func() {
+		_ = rooms.Stop(context.Background())
+	})
+	client := newTestClient("")
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	var offloadErr, secondErr error
+	require.NoError(t, rooms.do(func() {
+		offloadErr = rooms.offload(client, func() {
+			<-release
+		}, func() error {
+			close(done)
+			return nil
+		})
+		secondErr = rooms.offload(client, func() {}, func() error {
+			return nil
+		})
+	}))
+	require.NoError(t, offloadErr)
+	require.Error(t, secondErr, "one work per connection")
+	assert.Equal(t, CodeRateLimited, secondErr.(*Error).Code)
+
+	// the event loop answers while the work is running.
+	require.NoError(t, rooms.do(func() {}))
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the result wasn't passed to the event loop")
+	}
+}
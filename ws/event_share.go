@@ -1,9 +1,5 @@
 package ws
 
-import (
-	"fmt"
-)
-
 func init() {
 	register("share", func() Event {
 		return &StartShare{}
@@ -14,15 +10,26 @@ type StartShare struct{}
 
 func (e *StartShare) Execute(rooms *Rooms, current ClientInfo) error {
 	if current.RoomID == "" {
-		return fmt.Errorf("not in a room")
+		return errNotInRoom()
 	}
 
-	room, ok := rooms.Rooms[current.RoomID]
+	room, ok := rooms.store.GetRoom(current.RoomID)
 	if !ok {
-		return fmt.Errorf("room with id %s does not exist", current.RoomID)
+		return errRoomNotFound(current.RoomID)
+	}
+
+	if current.Observer {
+		return newError(CodeNotAuthorized, room.ID, "observers cannot share their screen")
+	}
+	if rooms.config.RequireAuthToShare && !current.Authenticated {
+		// the user may keep watching.
+		current.send(errorMessage(newError(CodeNotAuthorized, room.ID, "you need to login to share your screen")))
+		return nil
 	}
 
 	room.Users[current.ID].Streaming = true
+	room.logEvent(RoomEventShareStart, room.Users[current.ID], "")
+	rooms.webhook(WebhookScreenshareStart, room.ID, room.Users[current.ID])
 
 	v4, v6, err := rooms.config.TurnIPProvider.Get()
 	if err != nil {
@@ -0,0 +1,41 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartShare_requireAuthToShare(t *testing.T) {
+	rooms := newTestRooms(config.Config{AuthMode: config.AuthModeNone, RequireAuthToShare: true})
+
+	anonymous := newTestClient("")
+	assert.EqualError(t, (&Create{ID: "other", Mode: ConnectionTURN}).Execute(rooms, anonymous), "you need to login")
+
+	owner := newTestClient("alice")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN}).Execute(rooms, owner))
+	owner.RoomID = "room"
+	viewer := newTestClient("")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, viewer))
+	viewer.RoomID = "room"
+	drain(viewer)
+
+	require.NoError(t, (&StartShare{}).Execute(rooms, viewer))
+	assert.Equal(t, []outgoing.Message{outgoing.Error{Code: string(CodeNotAuthorized), Message: "you need to login to share your screen", Room: "room"}}, drain(viewer))
+	assert.False(t, getRoom(rooms, "room").Users[viewer.ID].Streaming)
+	assert.Empty(t, viewer.Close, "anonymous users keep watching")
+
+	require.NoError(t, (&StartShare{}).Execute(rooms, owner))
+	var session *outgoing.ClientSession
+	for _, msg := range drain(viewer) {
+		if clientSession, ok := msg.(outgoing.ClientSession); ok {
+			session = &clientSession
+		}
+	}
+	require.NotNil(t, session, "anonymous viewers receive the share")
+	require.Len(t, session.ICEServers, 1)
+	assert.NotEmpty(t, session.ICEServers[0].Credential)
+}
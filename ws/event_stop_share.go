@@ -2,7 +2,6 @@ package ws
 
 import (
 	"bytes"
-	"fmt"
 
 	"github.com/screego/server/ws/outgoing"
 )
@@ -17,25 +16,36 @@ type StopShare struct{}
 
 func (e *StopShare) Execute(rooms *Rooms, current ClientInfo) error {
 	if current.RoomID == "" {
-		return fmt.Errorf("not in a room")
+		return errNotInRoom()
 	}
 
-	room, ok := rooms.Rooms[current.RoomID]
+	room, ok := rooms.store.GetRoom(current.RoomID)
 	if !ok {
-		return fmt.Errorf("room with id %s does not exist", current.RoomID)
+		return errRoomNotFound(current.RoomID)
 	}
 
-	room.Users[current.ID].Streaming = false
-	for id, session := range room.Sessions {
-		if bytes.Equal(session.Host.Bytes(), current.ID.Bytes()) {
-			client, ok := room.Users[session.Client]
+	room.stopShare(rooms, room.Users[current.ID], false)
+	return nil
+}
+
+// stopShare ends all sessions the user hosts. The host is told about the ended sessions too, if the share was
+// stopped by someone else.
+func (r *Room) stopShare(rooms *Rooms, user *User, notifyHost bool) {
+	user.Streaming = false
+	r.logEvent(RoomEventShareStop, user, "")
+	rooms.webhook(WebhookScreenshareStop, r.ID, user)
+	for id, session := range r.Sessions {
+		if bytes.Equal(session.Host.Bytes(), user.ID.Bytes()) {
+			client, ok := r.Users[session.Client]
 			if ok {
-				client.Write <- outgoing.EndShare(id)
+				client.send(outgoing.EndShare(id))
 			}
-			room.closeSession(rooms, id)
+			if notifyHost {
+				user.send(outgoing.EndShare(id))
+			}
+			r.closeSession(rooms, id)
 		}
 	}
 
-	room.notifyInfoChanged()
-	return nil
+	r.notifyInfoChanged()
 }
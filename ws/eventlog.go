@@ -0,0 +1,107 @@
+package ws
+
+import (
+	"errors"
+	"time"
+)
+
+// Types of the room event log.
+const (
+	RoomEventJoin             = "join"
+	RoomEventLeave            = "leave"
+	RoomEventShareStart       = "share_start"
+	RoomEventShareStop        = "share_stop"
+	RoomEventShareStopRequest = "share_stop_request"
+	RoomEventShareForceStop   = "share_force_stop"
+	RoomEventSignaling        = "signaling"
+	RoomEventError            = "error"
+	RoomEventDisconnect       = "disconnect"
+	RoomEventReconnect        = "reconnect"
+	RoomEventExtend           = "extend"
+	RoomEventHostChange       = "host_change"
+)
+
+// RoomEvent is an entry of the room event log. Signaling messages are only recorded with their type, never with their
+// payload.
+type RoomEvent struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	User   string    `json:"user,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// eventLog is a ring buffer of the latest events of a room. A nil eventLog records nothing.
+type eventLog struct {
+	events []RoomEvent
+	next   int
+	full   bool
+}
+
+func newEventLog(size int) *eventLog {
+	if size <= 0 {
+		return nil
+	}
+	return &eventLog{events: make([]RoomEvent, size)}
+}
+
+func (l *eventLog) add(typ, user, detail string) {
+	if l == nil {
+		return
+	}
+	l.events[l.next] = RoomEvent{Time: time.Now(), Type: typ, User: user, Detail: detail}
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// list returns the events from the oldest to the newest.
+func (l *eventLog) list() []RoomEvent {
+	if l == nil {
+		return []RoomEvent{}
+	}
+	if !l.full {
+		return append([]RoomEvent{}, l.events[:l.next]...)
+	}
+	return append(append([]RoomEvent{}, l.events[l.next:]...), l.events[:l.next]...)
+}
+
+// RoomEvents returns the event log of a room.
+func (r *Rooms) RoomEvents(roomID string) ([]RoomEvent, error) {
+	var events []RoomEvent
+	var err error
+	if doErr := r.do(func() {
+		room, ok := r.store.GetRoom(roomID)
+		if !ok {
+			err = ErrRoomNotFound
+			return
+		}
+		events = room.events.list()
+	}); doErr != nil {
+		return nil, doErr
+	}
+	return events, err
+}
+
+func (r *Room) logEvent(typ string, user *User, detail string) {
+	name := ""
+	if user != nil {
+		name = user.Name
+	}
+	r.events.add(typ, name, detail)
+}
+
+// logError records the error of an event in the room it belongs to. Errors of users that aren't members, like a
+// failed join, are recorded too.
+func (r *Rooms) logError(current ClientInfo, err error) {
+	roomID := current.RoomID
+	var roomErr *Error
+	if roomID == "" && errors.As(err, &roomErr) {
+		roomID = roomErr.Room
+	}
+	room, ok := r.store.GetRoom(roomID)
+	if !ok {
+		return
+	}
+	room.logEvent(RoomEventError, room.Users[current.ID], err.Error())
+}
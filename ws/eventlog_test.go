@@ -0,0 +1,62 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventLog_ringBuffer(t *testing.T) {
+	log := newEventLog(3)
+	for _, user := range []string{"a", "b", "c", "d", "e"} {
+		log.add(RoomEventJoin, user, "")
+	}
+
+	var users []string
+	for _, event := range log.list() {
+		users = append(users, event.User)
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, users)
+}
+
+func TestEventLog_disabled(t *testing.T) {
+	log := newEventLog(0)
+	assert.Nil(t, log)
+	log.add(RoomEventJoin, "a", "")
+	assert.Empty(t, log.list())
+}
+
+func TestRoomEvents(t *testing.T) {
+	rooms := newTestRooms(config.Config{RoomEventLogSize: 10})
+	owner := newTestClient("alice")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+
+	viewer := newTestClient("bob")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, viewer))
+	viewer.RoomID = "room"
+	require.NoError(t, (&StartShare{}).Execute(rooms, owner))
+	require.Len(t, getRoom(rooms, "room").Sessions, 1)
+	var sid xid.ID
+	for id := range getRoom(rooms, "room").Sessions {
+		sid = id
+	}
+	require.NoError(t, (&HostOffer{SID: sid}).Execute(rooms, owner))
+	rooms.logError(viewer, (&HostICE{SID: sid}).Execute(rooms, viewer))
+	require.NoError(t, (&Disconnected{}).Execute(rooms, viewer))
+
+	var got [][]string
+	for _, event := range getRoom(rooms, "room").events.list() {
+		got = append(got, []string{event.Type, event.User, event.Detail})
+	}
+	assert.Equal(t, [][]string{
+		{RoomEventJoin, "alice", ""},
+		{RoomEventJoin, "bob", ""},
+		{RoomEventShareStart, "alice", ""},
+		{RoomEventSignaling, "alice", "hostoffer"},
+		{RoomEventError, "bob", "permission denied for session " + sid.String()},
+		{RoomEventLeave, "bob", ""},
+	}, got)
+}
@@ -0,0 +1,120 @@
+package ws
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/ws/outgoing"
+)
+
+// expiryCheckInterval is how often the rooms are checked for SCREEGO_ROOM_LIFETIME, it is the precision of the
+// expiry warnings.
+const expiryCheckInterval = time.Second
+
+func init() {
+	register("extend_room", func() Event {
+		return &ExtendRoom{}
+	})
+}
+
+// sortedWarnings returns the expiry warning thresholds, the earliest warning first.
+func sortedWarnings(warnings []time.Duration) []time.Duration {
+	sorted := append([]time.Duration{}, warnings...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] > sorted[j]
+	})
+	return sorted
+}
+
+// sweepRooms closes expired rooms and warns the members of rooms that are about to expire. It must be called inside
+// the rooms event loop.
+func (r *Rooms) sweepRooms() {
+	now := r.now()
+	for _, room := range r.store.ListRooms() {
+		if room.expiresAt.IsZero() {
+			continue
+		}
+		remaining := room.expiresAt.Sub(now)
+		if remaining <= 0 {
+			r.expireRoom(room)
+			continue
+		}
+
+		// every threshold is only warned about once, even if multiple thresholds have passed since the last check.
+		warned := r.warnedThresholds(remaining)
+		if warned <= room.expiryWarned {
+			continue
+		}
+		room.expiryWarned = warned
+		msg := outgoing.RoomExpiring{Room: room.ID, ExpiresAt: room.expiresAt, Remaining: int64(remaining.Seconds())}
+		for _, user := range room.Users {
+			user.send(msg)
+		}
+	}
+}
+
+func (r *Rooms) expireRoom(room *Room) {
+	for _, user := range room.Users {
+		room.logEvent(RoomEventDisconnect, user, CloseRoomExpired)
+		user.reject(newError(CodeRoomExpired, room.ID, CloseRoomExpired))
+	}
+	r.closeRoom(room.ID, audit.ReasonExpired)
+}
+
+// warnedThresholds returns how many expiry warnings have already passed with the remaining lifetime.
+func (r *Rooms) warnedThresholds(remaining time.Duration) int {
+	warned := 0
+	for warned < len(r.expiryWarnings) && remaining <= r.expiryWarnings[warned] {
+		warned++
+	}
+	return warned
+}
+
+// ExtendRoom restarts the lifetime of the room, it cannot be extended beyond SCREEGO_ROOM_MAX_LIFETIME since the
+// creation.
+type ExtendRoom struct {
+	Room string `json:"room"`
+}
+
+func (e *ExtendRoom) Execute(rooms *Rooms, current ClientInfo) error {
+	if current.RoomID == "" || current.RoomID != e.Room {
+		return newError(CodeProtocolError, e.Room, "not in room %s", e.Room)
+	}
+	room, ok := rooms.store.GetRoom(e.Room)
+	if !ok {
+		return errRoomNotFound(e.Room)
+	}
+	user, ok := room.Users[current.ID]
+	if !ok || !user.Owner {
+		return newError(CodeNotAuthorized, e.Room, "only the owner can extend the room")
+	}
+	if room.expiresAt.IsZero() {
+		current.send(errorMessage(newError(CodeFeatureDisabled, e.Room, "rooms don't expire on this instance")))
+		return nil
+	}
+
+	now := rooms.now()
+	expiresAt := now.Add(rooms.config.RoomLifetime)
+	if rooms.config.RoomMaxLifetime > 0 {
+		if limit := room.createdAt.Add(rooms.config.RoomMaxLifetime); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	if !expiresAt.After(room.expiresAt) {
+		current.send(errorMessage(newError(CodeLimitReached, e.Room, "the room has reached its maximum lifetime")))
+		return nil
+	}
+
+	room.expiresAt = expiresAt
+	room.expiryWarned = rooms.warnedThresholds(expiresAt.Sub(now))
+	if err := rooms.store.UpdateRoom(room); err != nil {
+		log.Warn().Err(err).Str("room", room.ID).Msg("Could not update room")
+	}
+	room.logEvent(RoomEventExtend, user, expiresAt.Format(time.RFC3339))
+	for _, member := range room.Users {
+		member.send(outgoing.RoomExtended{Room: room.ID, ExpiresAt: expiresAt})
+	}
+	return nil
+}
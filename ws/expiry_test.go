@@ -0,0 +1,151 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newExpiringRoom(t *testing.T, maxLifetime time.Duration) (*Rooms, *fakeClock, ClientInfo, ClientInfo) {
+	t.Helper()
+	rooms := newTestRooms(config.Config{
+		RoomLifetime:       30 * time.Minute,
+		RoomMaxLifetime:    maxLifetime,
+		RoomExpiryWarnings: []time.Duration{time.Minute, 5 * time.Minute},
+	})
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	rooms.now = clock.Now
+
+	owner := newTestClient("alice")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	bob := newTestClient("")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, bob))
+	bob.RoomID = "room"
+	drain(owner)
+	drain(bob)
+	return rooms, clock, owner, bob
+}
+
+func expiryMessages(client ClientInfo) []outgoing.Message {
+	var result []outgoing.Message
+	for _, msg := range drain(client) {
+		switch msg.(type) {
+		case outgoing.RoomExpiring, outgoing.RoomExtended:
+			result = append(result, msg)
+		}
+	}
+	return result
+}
+
+func TestSweepRooms_warnings(t *testing.T) {
+	rooms, clock, owner, bob := newExpiringRoom(t, 0)
+	expiresAt := clock.now.Add(30 * time.Minute)
+
+	clock.advance(24 * time.Minute)
+	rooms.sweepRooms()
+	assert.Empty(t, expiryMessages(bob))
+
+	clock.advance(time.Minute)
+	rooms.sweepRooms()
+	expected := []outgoing.Message{outgoing.RoomExpiring{Room: "room", ExpiresAt: expiresAt, Remaining: 300}}
+	assert.Equal(t, expected, expiryMessages(bob))
+	assert.Equal(t, expected, expiryMessages(owner))
+
+	clock.advance(time.Minute)
+	rooms.sweepRooms()
+	rooms.sweepRooms()
+	assert.Empty(t, expiryMessages(bob), "every warning is only sent once")
+
+	clock.advance(3*time.Minute + 30*time.Second)
+	rooms.sweepRooms()
+	assert.Equal(t, []outgoing.Message{outgoing.RoomExpiring{Room: "room", ExpiresAt: expiresAt, Remaining: 30}}, expiryMessages(bob))
+
+	clock.advance(30 * time.Second)
+	rooms.sweepRooms()
+	assert.NotContains(t, roomIDs(rooms), "room")
+	assert.Equal(t, closeFrame{Code: CloseCodeRoomClosed, Reason: CloseRoomExpired}, <-bob.Close)
+	assert.Equal(t, closeFrame{Code: CloseCodeRoomClosed, Reason: CloseRoomExpired}, <-owner.Close)
+}
+
+func TestSweepRooms_skippedWarningsAreSentOnce(t *testing.T) {
+	rooms, clock, _, bob := newExpiringRoom(t, 0)
+
+	clock.advance(29*time.Minute + 30*time.Second)
+	rooms.sweepRooms()
+
+	messages := expiryMessages(bob)
+	require.Len(t, messages, 1)
+	assert.Equal(t, int64(30), messages[0].(outgoing.RoomExpiring).Remaining)
+}
+
+func TestExtendRoom(t *testing.T) {
+	rooms, clock, owner, bob := newExpiringRoom(t, 0)
+
+	clock.advance(26 * time.Minute)
+	rooms.sweepRooms()
+	require.Len(t, expiryMessages(bob), 1)
+	drain(owner)
+
+	require.NoError(t, (&ExtendRoom{Room: "room"}).Execute(rooms, owner))
+	expiresAt := clock.now.Add(30 * time.Minute)
+	assert.Equal(t, []outgoing.Message{outgoing.RoomExtended{Room: "room", ExpiresAt: expiresAt}}, expiryMessages(bob))
+	assert.Equal(t, []outgoing.Message{outgoing.RoomExtended{Room: "room", ExpiresAt: expiresAt}}, expiryMessages(owner))
+
+	rooms.sweepRooms()
+	assert.Empty(t, expiryMessages(bob))
+
+	clock.advance(25 * time.Minute)
+	rooms.sweepRooms()
+	assert.Equal(t, []outgoing.Message{outgoing.RoomExpiring{Room: "room", ExpiresAt: expiresAt, Remaining: 300}}, expiryMessages(bob), "the warnings are sent again after an extension")
+}
+
+func TestExtendRoom_maxLifetime(t *testing.T) {
+	rooms, clock, owner, bob := newExpiringRoom(t, 40*time.Minute)
+	limit := clock.now.Add(40 * time.Minute)
+
+	clock.advance(26 * time.Minute)
+	rooms.sweepRooms()
+	drain(owner)
+	drain(bob)
+
+	require.NoError(t, (&ExtendRoom{Room: "room"}).Execute(rooms, owner))
+	assert.Equal(t, []outgoing.Message{outgoing.RoomExtended{Room: "room", ExpiresAt: limit}}, expiryMessages(owner))
+	drain(bob)
+
+	clock.advance(9 * time.Minute)
+	rooms.sweepRooms()
+	assert.Equal(t, []outgoing.Message{outgoing.RoomExpiring{Room: "room", ExpiresAt: limit, Remaining: 300}}, expiryMessages(bob))
+	drain(owner)
+
+	require.NoError(t, (&ExtendRoom{Room: "room"}).Execute(rooms, owner))
+	assert.Equal(t, []outgoing.Message{outgoing.Error{Code: string(CodeLimitReached), Message: "the room has reached its maximum lifetime", Room: "room"}}, drain(owner))
+	assert.Empty(t, owner.Close)
+}
+
+func TestExtendRoom_notAllowed(t *testing.T) {
+	rooms, _, _, bob := newExpiringRoom(t, 0)
+	assert.EqualError(t, (&ExtendRoom{Room: "room"}).Execute(rooms, bob), "only the owner can extend the room")
+
+	rooms = newTestRooms(config.Config{})
+	owner := newTestClient("alice")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	drain(owner)
+	require.NoError(t, (&ExtendRoom{Room: "room"}).Execute(rooms, owner))
+	assert.Equal(t, []outgoing.Message{outgoing.Error{Code: string(CodeFeatureDisabled), Message: "rooms don't expire on this instance", Room: "room"}}, drain(owner))
+}
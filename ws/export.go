@@ -0,0 +1,38 @@
+package ws
+
+import (
+	"sort"
+	"time"
+)
+
+// UserRoom is an open room of a user for the data export, closed rooms are only in the audit log.
+type UserRoom struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Owner is true if the user created the room.
+	Owner bool `json:"owner"`
+	// Connections is the amount of connections of the user in the room.
+	Connections int `json:"connections"`
+}
+
+// UserRooms returns the open rooms that the logged in user created or is connected to, sorted by id.
+func (r *Rooms) UserRooms(user string) ([]UserRoom, error) {
+	list := []UserRoom{}
+	err := r.do(func() {
+		for _, room := range r.store.ListRooms() {
+			userRoom := UserRoom{ID: room.ID, CreatedAt: room.createdAt, Owner: room.CreatedBy == user}
+			for id := range room.Users {
+				if client, ok := r.clients[id]; ok && client.Authenticated && client.AuthenticatedUser == user {
+					userRoom.Connections++
+				}
+			}
+			if userRoom.Owner || userRoom.Connections > 0 {
+				list = append(list, userRoom)
+			}
+		}
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list, err
+}
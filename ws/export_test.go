@@ -0,0 +1,39 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRooms(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	alice := newTestClient("alice")
+	bob := newTestClient("bob")
+	aliceAtBob := newTestClient("alice")
+	rooms.do(func() {
+		require.NoError(t, createRoom(t, rooms, &alice, "alice-room"))
+		require.NoError(t, createRoom(t, rooms, &bob, "bob-room"))
+		require.NoError(t, (&Join{ID: "bob-room"}).Execute(rooms, aliceAtBob))
+		for _, client := range []ClientInfo{alice, bob, aliceAtBob} {
+			rooms.clients[client.ID] = client
+		}
+	})
+
+	list, err := rooms.UserRooms("alice")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "alice-room", list[0].ID)
+	assert.True(t, list[0].Owner)
+	assert.Equal(t, 1, list[0].Connections)
+	assert.Equal(t, "bob-room", list[1].ID)
+	assert.False(t, list[1].Owner)
+	assert.Equal(t, 1, list[1].Connections)
+	list, err = rooms.UserRooms("carol")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -0,0 +1,105 @@
+package ws
+
+import (
+	"sync"
+)
+
+const (
+	// feedHistorySize is how many events are kept for subscribers that resume with the id of their last event.
+	feedHistorySize = 100
+	// feedBufferSize is how many events may be queued for a subscriber, slower subscribers are disconnected and may
+	// resume.
+	feedBufferSize = 100
+)
+
+// FeedEvent is a room event with a sequential id. Ids start at 1 and are reset on restart.
+type FeedEvent struct {
+	ID uint64
+	WebhookEvent
+}
+
+// feed distributes the room events, the same events that are sent to webhooks, to all subscribers. It is safe for
+// concurrent use, publishing never blocks the rooms event loop.
+type feed struct {
+	lock        sync.Mutex
+	lastID      uint64
+	history     []FeedEvent
+	subscribers map[chan FeedEvent]struct{}
+	closed      bool
+}
+
+func newFeed() *feed {
+	return &feed{subscribers: map[chan FeedEvent]struct{}{}}
+}
+
+func (f *feed) publish(e WebhookEvent) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		return
+	}
+
+	f.lastID++
+	event := FeedEvent{ID: f.lastID, WebhookEvent: e}
+	f.history = append(f.history, event)
+	if len(f.history) > feedHistorySize {
+		f.history = f.history[len(f.history)-feedHistorySize:]
+	}
+
+	for subscriber := range f.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			delete(f.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// subscribe returns the kept events after lastID and a channel with all following events. The channel is closed when
+// the subscriber is too slow or the feed is closed. A lastID of 0 skips the kept events, an unknown lastID, e.g. from
+// before a restart, returns all of them.
+func (f *feed) subscribe(lastID uint64) ([]FeedEvent, <-chan FeedEvent, func()) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var missed []FeedEvent
+	if lastID > 0 {
+		for _, event := range f.history {
+			if event.ID > lastID || lastID > f.lastID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	subscriber := make(chan FeedEvent, feedBufferSize)
+	if f.closed {
+		close(subscriber)
+		return missed, subscriber, func() {}
+	}
+	f.subscribers[subscriber] = struct{}{}
+	return missed, subscriber, func() {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		if _, ok := f.subscribers[subscriber]; ok {
+			delete(f.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// close closes all subscribers, the events that are already queued are still delivered.
+func (f *feed) close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = true
+	for subscriber := range f.subscribers {
+		delete(f.subscribers, subscriber)
+		close(subscriber)
+	}
+}
+
+// SubscribeEvents subscribes to the room events, see feed.subscribe. The returned function ends the subscription.
+func (r *Rooms) SubscribeEvents(lastID uint64) ([]FeedEvent, <-chan FeedEvent, func()) {
+	return r.feed.subscribe(lastID)
+}
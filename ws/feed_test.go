@@ -0,0 +1,87 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func feedIDs(events []FeedEvent) []uint64 {
+	ids := []uint64{}
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestFeed(t *testing.T) {
+	f := newFeed()
+	f.publish(WebhookEvent{Event: WebhookRoomCreated, Room: "room"})
+	f.publish(WebhookEvent{Event: WebhookUserJoined, Room: "room"})
+
+	missed, events, cancel := f.subscribe(0)
+	defer cancel()
+	assert.Empty(t, missed, "new subscribers only get new events")
+
+	f.publish(WebhookEvent{Event: WebhookRoomClosed, Room: "room"})
+	event := <-events
+	assert.Equal(t, uint64(3), event.ID)
+	assert.Equal(t, WebhookRoomClosed, event.Event)
+
+	missed, _, cancelResume := f.subscribe(1)
+	defer cancelResume()
+	assert.Equal(t, []uint64{2, 3}, feedIDs(missed))
+
+	missed, _, cancelUnknown := f.subscribe(10)
+	defer cancelUnknown()
+	assert.Equal(t, []uint64{1, 2, 3}, feedIDs(missed), "ids from before a restart get all kept events")
+}
+
+func TestFeed_history(t *testing.T) {
+	f := newFeed()
+	for i := 0; i < feedHistorySize+10; i++ {
+		f.publish(WebhookEvent{Event: WebhookUserJoined, Room: "room"})
+	}
+
+	missed, _, cancel := f.subscribe(1)
+	defer cancel()
+	require.Len(t, missed, feedHistorySize)
+	assert.Equal(t, uint64(11), missed[0].ID)
+}
+
+func TestFeed_slowSubscriber(t *testing.T) {
+	f := newFeed()
+	_, events, cancel := f.subscribe(0)
+	defer cancel()
+
+	for i := 0; i < feedBufferSize+1; i++ {
+		f.publish(WebhookEvent{Event: WebhookUserJoined, Room: "room"})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	assert.Equal(t, feedBufferSize, received, "the queued events are delivered before the channel is closed")
+	assert.Empty(t, f.subscribers)
+}
+
+func TestFeed_close(t *testing.T) {
+	f := newFeed()
+	_, events, cancel := f.subscribe(0)
+	defer cancel()
+	f.publish(WebhookEvent{Event: WebhookRoomClosed, Room: "room"})
+	f.close()
+
+	event, ok := <-events
+	require.True(t, ok)
+	assert.Equal(t, WebhookRoomClosed, event.Event)
+	_, ok = <-events
+	assert.False(t, ok)
+
+	_, events, cancel = f.subscribe(0)
+	defer cancel()
+	_, ok = <-events
+	assert.False(t, ok, "subscriptions after close end immediately")
+}
@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxGuestNameLength is the maximum length of guest display names in characters.
+const maxGuestNameLength = 32
+
+// guestName validates the display name a user without login chose and returns it without surrounding spaces. It may contain
+// letters, numbers, spaces and -_.'() so that it can't be mistaken for a flag in the participant list.
+func guestName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("the name must not be empty")
+	}
+	if utf8.RuneCountInString(name) > maxGuestNameLength {
+		return "", fmt.Errorf("the name must not be longer than %d characters", maxGuestNameLength)
+	}
+	for _, c := range name {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != ' ' && !strings.ContainsRune("-_.'()", c) {
+			return "", fmt.Errorf("the name must not contain %q", c)
+		}
+	}
+	return name, nil
+}
+
+// uniqueName appends a number to the name if another user of the room has it already, e.g. bob (2).
+func (r *Room) uniqueName(name string) string {
+	taken := map[string]bool{}
+	for _, user := range r.Users {
+		taken[user.Name] = true
+	}
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return unique
+}
@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrRoomNotFound  = errors.New("room not found")
+	ErrNotRoomOwner  = errors.New("you are not the owner of this room")
+	ErrInvalidInvite = errors.New("invalid invite")
+	ErrExpiredInvite = errors.New("invite expired")
+	ErrRevokeInvite  = errors.New("the invite could not be revoked")
+)
+
+// CreateInvite creates a signed invite token for the room. Only the logged in user that created the room may create
+// invites.
+func (r *Rooms) CreateInvite(roomID, user string) (string, time.Time, error) {
+	var token string
+	var err error
+	expires := time.Now().Add(r.config.InviteExpiry)
+	if doErr := r.do(func() {
+		if err = r.checkRoomOwner(roomID, user); err != nil {
+			return
+		}
+		room, _ := r.store.GetRoom(roomID)
+		token = r.signInvite(room, expires)
+	}); doErr != nil {
+		return "", time.Time{}, doErr
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// RevokeInvite invalidates an invite token before it expires. The revocation is kept in the room store, so that it
+// applies to all instances that share it and survives restarts.
+func (r *Rooms) RevokeInvite(roomID, user, token string) error {
+	var err error
+	if doErr := r.do(func() {
+		if err = r.checkRoomOwner(roomID, user); err != nil {
+			return
+		}
+		room, _ := r.store.GetRoom(roomID)
+		var expires time.Time
+		if expires, err = r.verifyInvite(room, token); err != nil {
+			return
+		}
+		if storeErr := r.store.RevokeInvite(inviteHash(token), expires); storeErr != nil {
+			log.Error().Err(storeErr).Str("room", roomID).Msg("Could not revoke the invite")
+			err = ErrRevokeInvite
+		}
+	}); doErr != nil {
+		return doErr
+	}
+	return err
+}
+
+// ValidateInvite returns the room id of a valid invite token.
+func (r *Rooms) ValidateInvite(token string) (string, error) {
+	roomID, _, _, _, err := parseInvite(token)
+	if err != nil {
+		return "", err
+	}
+
+	if doErr := r.do(func() {
+		room, ok := r.store.GetRoom(roomID)
+		if !ok {
+			err = ErrInvalidInvite
+			return
+		}
+		if _, err = r.verifyInvite(room, token); err == nil && r.inviteRevoked(token) {
+			err = ErrInvalidInvite
+		}
+	}); doErr != nil {
+		return "", doErr
+	}
+	return roomID, err
+}
+
+// validInvite checks an invite token for the given room, it must be called inside the rooms event loop.
+func (r *Rooms) validInvite(token string, room *Room) bool {
+	_, err := r.verifyInvite(room, token)
+	return err == nil && !r.inviteRevoked(token)
+}
+
+func (r *Rooms) checkRoomOwner(roomID, user string) error {
+	room, ok := r.store.GetRoom(roomID)
+	if !ok {
+		return ErrRoomNotFound
+	}
+	if room.CreatedBy == "" || room.CreatedBy != user {
+		return ErrNotRoomOwner
+	}
+	return nil
+}
+
+// signInvite binds the token to the creation time of the room, so that the invites of a closed room aren't valid for
+// a new room with the same id.
+func (r *Rooms) signInvite(room *Room, expires time.Time) string {
+	payload := room.ID + "|" + strconv.FormatInt(expires.Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(r.inviteMAC(room, payload))
+}
+
+// verifyInvite checks the signature of the token for the room and returns its expiry, it must be called inside the
+// rooms event loop.
+func (r *Rooms) verifyInvite(room *Room, token string) (time.Time, error) {
+	roomID, expires, payload, mac, err := parseInvite(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if roomID != room.ID || !hmac.Equal(mac, r.inviteMAC(room, payload)) {
+		return time.Time{}, ErrInvalidInvite
+	}
+	return expires, nil
+}
+
+// inviteRevoked fails closed, an invite isn't accepted if the store cannot be read.
+func (r *Rooms) inviteRevoked(token string) bool {
+	revoked, err := r.store.InviteRevoked(inviteHash(token))
+	if err != nil {
+		log.Error().Err(err).Msg("Could not read the revoked invites")
+		return true
+	}
+	return revoked
+}
+
+// parseInvite returns the room id, the expiry, the signed payload and the signature of the token, the signature isn't
+// verified.
+func parseInvite(token string) (string, time.Time, string, []byte, error) {
+	encodedPayload, encodedMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", time.Time{}, "", nil, ErrInvalidInvite
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", time.Time{}, "", nil, ErrInvalidInvite
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(encodedMAC)
+	if err != nil {
+		return "", time.Time{}, "", nil, ErrInvalidInvite
+	}
+
+	sep := strings.LastIndex(string(payload), "|")
+	if sep == -1 {
+		return "", time.Time{}, "", nil, ErrInvalidInvite
+	}
+	unix, err := strconv.ParseInt(string(payload[sep+1:]), 10, 64)
+	if err != nil {
+		return "", time.Time{}, "", nil, ErrInvalidInvite
+	}
+	expires := time.Unix(unix, 0)
+	if expires.Before(time.Now()) {
+		return "", time.Time{}, "", nil, ErrExpiredInvite
+	}
+	return string(payload[:sep]), expires, string(payload), mac, nil
+}
+
+func (r *Rooms) inviteMAC(room *Room, payload string) []byte {
+	mac := hmac.New(sha256.New, r.config.Secret)
+	_, _ = mac.Write([]byte(payload + "|" + strconv.FormatInt(room.createdAt.UnixNano(), 10)))
+	return mac.Sum(nil)
+}
+
+// inviteHash identifies a revoked invite in the store, the token itself isn't stored.
+func inviteHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
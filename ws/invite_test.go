@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvite(t *testing.T) {
+	rooms := newTestRooms(config.Config{Secret: []byte("secret"), InviteExpiry: time.Hour})
+	go rooms.Start()
+
+	owner := newTestClient("alice")
+	rooms.do(func() {
+		require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	})
+
+	_, _, err := rooms.CreateInvite("room", "bob")
+	assert.Equal(t, ErrNotRoomOwner, err)
+	_, _, err = rooms.CreateInvite("unknown", "alice")
+	assert.Equal(t, ErrRoomNotFound, err)
+
+	token, expires, err := rooms.CreateInvite("room", "alice")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+
+	roomID, err := rooms.ValidateInvite(token)
+	require.NoError(t, err)
+	assert.Equal(t, "room", roomID)
+
+	_, err = rooms.ValidateInvite(token + "x")
+	assert.Equal(t, ErrInvalidInvite, err)
+	var expired string
+	rooms.do(func() {
+		expired = rooms.signInvite(getRoom(rooms, "room"), time.Now().Add(-time.Minute))
+	})
+	_, err = rooms.ValidateInvite(expired)
+	assert.Equal(t, ErrExpiredInvite, err)
+
+	assert.Equal(t, ErrNotRoomOwner, rooms.RevokeInvite("room", "bob", token))
+	require.NoError(t, rooms.RevokeInvite("room", "alice", token))
+	_, err = rooms.ValidateInvite(token)
+	assert.Equal(t, ErrInvalidInvite, err)
+}
+
+func TestInvite_closedRoom(t *testing.T) {
+	rooms := newTestRooms(config.Config{Secret: []byte("secret"), InviteExpiry: time.Hour})
+	go rooms.Start()
+
+	owner := newTestClient("alice")
+	rooms.do(func() {
+		require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	})
+	token, _, err := rooms.CreateInvite("room", "alice")
+	require.NoError(t, err)
+
+	rooms.do(func() {
+		rooms.closeRoom("room", "closed")
+		owner = newTestClient("alice")
+		require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	})
+	_, err = rooms.ValidateInvite(token)
+	assert.Equal(t, ErrInvalidInvite, err, "the invite of the closed room isn't valid for the new room")
+}
+
+func TestInvite_revocationsAreShared(t *testing.T) {
+	persisted := store.NewMemoryStore()
+	conf := config.Config{Secret: []byte("secret"), InviteExpiry: time.Hour}
+	first, second := newTestRooms(conf), newTestRooms(conf)
+	first.store, second.store = newPersistentRoomStore(persisted), newPersistentRoomStore(persisted)
+	go first.Start()
+
+	owner := newTestClient("alice")
+	var token string
+	first.do(func() {
+		require.NoError(t, createRoom(t, first, &owner, "room"))
+		token = first.signInvite(getRoom(first, "room"), time.Now().Add(time.Hour))
+	})
+	require.NoError(t, first.RevokeInvite("room", "alice", token))
+
+	revoked, err := second.store.InviteRevoked(inviteHash(token))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
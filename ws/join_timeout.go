@@ -0,0 +1,37 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+)
+
+// startJoinTimer closes the connection, if the client doesn't create or join a room within SCREEGO_JOIN_TIMEOUT.
+func (r *Rooms) startJoinTimer(current ClientInfo) {
+	if r.config.JoinTimeout <= 0 {
+		return
+	}
+	r.joinTimers[current.ID] = time.AfterFunc(r.config.JoinTimeout, func() {
+		r.post(ClientMessage{Info: current, Incoming: &joinTimeout{}})
+	})
+}
+
+// stopJoinTimer cancels the join timeout of the connection, it must be called when the client is added to a room.
+func (r *Rooms) stopJoinTimer(id xid.ID) {
+	if timer, ok := r.joinTimers[id]; ok {
+		timer.Stop()
+		delete(r.joinTimers, id)
+	}
+}
+
+type joinTimeout struct{}
+
+func (e *joinTimeout) Execute(rooms *Rooms, current ClientInfo) error {
+	// the timer may fire while the join is processed.
+	if _, ok := rooms.joinTimers[current.ID]; !ok {
+		return nil
+	}
+	delete(rooms.joinTimers, current.ID)
+	current.reject(newError(CodeJoinTimeout, "", CloseJoinTimeout))
+	return nil
+}
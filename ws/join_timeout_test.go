@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinTimeout(t *testing.T) {
+	rooms, conn := dialTestServer(t, config.Config{JoinTimeout: 50 * time.Millisecond})
+
+	err := readUntilClose(t, conn, time.Second)
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, err, &closeErr)
+	assert.Equal(t, CloseCodeJoinTimeout, closeErr.Code)
+	assert.Equal(t, CloseJoinTimeout, closeErr.Text)
+	assert.Eventually(t, func() bool {
+		stats, err := rooms.Stats()
+		return err == nil && stats.Connections == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestJoinTimeout_cancelledByJoin(t *testing.T) {
+	rooms, conn := dialTestServer(t, config.Config{JoinTimeout: 100 * time.Millisecond})
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"create","payload":{"id":"room","mode":"local"}}`)))
+
+	err := readUntilClose(t, conn, 300*time.Millisecond)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr, "members of a room stay connected")
+	assert.True(t, netErr.Timeout())
+	assert.Equal(t, Stats{Connections: 1, Rooms: 1, Members: 1}, currentStats(t, rooms))
+}
+
+func TestJoinTimeout_disabled(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	client := newTestClient("")
+	rooms.do(func() {
+		require.NoError(t, (&Connected{}).Execute(rooms, client))
+		assert.Empty(t, rooms.joinTimers)
+	})
+}
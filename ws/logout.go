@@ -0,0 +1,78 @@
+package ws
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/ws/outgoing"
+)
+
+// CloseSessions closes the connections of the user that were authenticated with a login session, it is called after
+// the sessions of the user were revoked. It returns the amount of closed connections.
+func (r *Rooms) CloseSessions(user string) (int, error) {
+	closed := 0
+	err := r.do(func() {
+		for _, client := range r.clients {
+			if client.Authenticated && client.AuthenticatedUser == user && client.SessionID != "" {
+				closeConnection(client.Close, CloseCodeLoggedOut, CloseLoggedOut)
+				closed++
+			}
+		}
+	})
+	return closed, err
+}
+
+// CloseAllSessions closes the connections of all users that were authenticated with a login session, it is called
+// after all jwt sessions were revoked. Other instances keep their connections.
+func (r *Rooms) CloseAllSessions() (int, error) {
+	closed := 0
+	err := r.do(func() {
+		for _, client := range r.clients {
+			if client.Authenticated && client.SessionID != "" {
+				closeConnection(client.Close, CloseCodeLoggedOut, CloseLoggedOut)
+				closed++
+			}
+		}
+	})
+	return closed, err
+}
+
+// DeletedUser is what DeleteUser removed of a user.
+type DeletedUser struct {
+	// Connections is the amount of closed connections.
+	Connections int `json:"connections"`
+	// Rooms is the amount of rooms that the user created or was connected to.
+	Rooms int `json:"rooms"`
+}
+
+// DeleteUser closes all connections of the user after sending you_were_deleted, they leave their rooms like on a
+// disconnect. The user is removed as creator of its rooms, also in the persistent store.
+func (r *Rooms) DeleteUser(user string) (DeletedUser, error) {
+	deleted := DeletedUser{}
+	err := r.do(func() {
+		for _, room := range r.store.ListRooms() {
+			member := room.CreatedBy == user
+			for id := range room.Users {
+				if client, ok := r.clients[id]; ok && client.Authenticated && client.AuthenticatedUser == user {
+					member = true
+				}
+			}
+			if !member {
+				continue
+			}
+			deleted.Rooms++
+			if room.CreatedBy == user {
+				room.CreatedBy = ""
+				if err := r.store.UpdateRoom(room); err != nil {
+					log.Error().Err(err).Str("room", room.ID).Msg("Could not remove the creator of the room")
+				}
+			}
+		}
+		for _, client := range r.clients {
+			if client.Authenticated && client.AuthenticatedUser == user {
+				client.send(outgoing.YouWereDeleted{})
+				closeConnection(client.Close, CloseCodeDeleted, CloseDeleted)
+				deleted.Connections++
+			}
+		}
+	})
+	return deleted, err
+}
@@ -0,0 +1,73 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseSessions(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	first := newTestClient("alice")
+	first.SessionID = "first"
+	second := newTestClient("alice")
+	second.SessionID = "second"
+	// a user of a trusted proxy header without session cookie.
+	proxied := newTestClient("alice")
+	other := newTestClient("bob")
+	other.SessionID = "other"
+	rooms.do(func() {
+		for _, client := range []ClientInfo{first, second, proxied, other} {
+			rooms.clients[client.ID] = client
+		}
+	})
+
+	closed, err := rooms.CloseSessions("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 2, closed)
+	assert.Equal(t, closeFrame{Code: CloseCodeLoggedOut, Reason: CloseLoggedOut}, <-first.Close)
+	assert.Equal(t, closeFrame{Code: CloseCodeLoggedOut, Reason: CloseLoggedOut}, <-second.Close)
+	assert.Empty(t, proxied.Close)
+	assert.Empty(t, other.Close)
+}
+
+func TestDeleteUser(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	alice := newTestClient("alice")
+	bob := newTestClient("bob")
+	aliceAtBob := newTestClient("alice")
+	rooms.do(func() {
+		require.NoError(t, createRoom(t, rooms, &alice, "alice-room"))
+		require.NoError(t, createRoom(t, rooms, &bob, "bob-room"))
+		require.NoError(t, (&Join{ID: "bob-room"}).Execute(rooms, aliceAtBob))
+		for _, client := range []ClientInfo{alice, bob, aliceAtBob} {
+			rooms.clients[client.ID] = client
+		}
+		drain(alice)
+		drain(aliceAtBob)
+		drain(bob)
+	})
+
+	deleted, err := rooms.DeleteUser("alice")
+	require.NoError(t, err)
+	assert.Equal(t, DeletedUser{Connections: 2, Rooms: 2}, deleted)
+	for _, client := range []ClientInfo{alice, aliceAtBob} {
+		assert.Equal(t, []outgoing.Message{outgoing.YouWereDeleted{}}, drain(client))
+		assert.Equal(t, closeFrame{Code: CloseCodeDeleted, Reason: CloseDeleted}, <-client.Close)
+	}
+	assert.Empty(t, bob.Close)
+	rooms.do(func() {
+		assert.Empty(t, getRoom(rooms, "alice-room").CreatedBy)
+		assert.Equal(t, "bob", getRoom(rooms, "bob-room").CreatedBy)
+	})
+	deleted, err = rooms.DeleteUser("carol")
+	require.NoError(t, err)
+	assert.Equal(t, DeletedUser{}, deleted)
+}
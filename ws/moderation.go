@@ -0,0 +1,179 @@
+package ws
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/ws/outgoing"
+)
+
+var ErrBanNotFound = errors.New("ban not found")
+
+func init() {
+	register("kick_user", func() Event {
+		return &KickUser{}
+	})
+	register("ban_user", func() Event {
+		return &BanUser{}
+	})
+}
+
+// Ban blocks a user from rejoining a room. A joining user is blocked if either the name or the ip matches.
+type Ban struct {
+	User    string    `json:"user"`
+	IP      string    `json:"ip"`
+	Expires time.Time `json:"expires"`
+}
+
+type KickUser struct {
+	Room string `json:"room"`
+	ID   xid.ID `json:"id"`
+}
+
+func (e *KickUser) Execute(rooms *Rooms, current ClientInfo) error {
+	room, target, err := rooms.moderationTarget(current, e.Room, e.ID)
+	if err != nil || target == nil {
+		return err
+	}
+
+	rooms.auditLog(audit.Kick, current, room.Users[current.ID].Name, target.Name, room.ID)
+	room.logEvent(RoomEventDisconnect, target, CloseKicked)
+	target.send(outgoing.YouWereKicked{Room: room.ID})
+	rooms.kick(room, target)
+	return nil
+}
+
+type BanUser struct {
+	Room string `json:"room"`
+	ID   xid.ID `json:"id"`
+	// Duration in seconds.
+	Duration int64 `json:"duration"`
+}
+
+func (e *BanUser) Execute(rooms *Rooms, current ClientInfo) error {
+	if e.Duration <= 0 {
+		return newError(CodeProtocolError, e.Room, "ban duration must be positive")
+	}
+	room, target, err := rooms.moderationTarget(current, e.Room, e.ID)
+	if err != nil || target == nil {
+		return err
+	}
+
+	expires := time.Now().Add(time.Duration(e.Duration) * time.Second)
+	if rooms.bans[room.ID] == nil {
+		rooms.bans[room.ID] = map[string]*Ban{}
+	}
+	rooms.bans[room.ID][target.Name] = &Ban{User: target.Name, IP: target.Addr.String(), Expires: expires}
+
+	rooms.auditLog(audit.Ban, current, room.Users[current.ID].Name, target.Name, room.ID)
+	room.logEvent(RoomEventDisconnect, target, "Banned")
+	target.send(outgoing.YouWereKicked{Room: room.ID, Banned: true, Until: &expires})
+	rooms.kick(room, target)
+	return nil
+}
+
+func (r *Rooms) kick(room *Room, target *User) {
+	closeConnection(target.Close, CloseCodeKicked, CloseKicked)
+	if !target.disconnectedAt.IsZero() {
+		// the connection is already lost, the user would otherwise stay until the reconnect timeout.
+		r.leave(room, target)
+	}
+}
+
+// moderationTarget returns the user that should be kicked, if the current user is an owner of the room or an admin.
+func (r *Rooms) moderationTarget(current ClientInfo, roomID string, id xid.ID) (*Room, *User, error) {
+	if current.RoomID == "" || current.RoomID != roomID {
+		return nil, nil, newError(CodeProtocolError, roomID, "not in room %s", roomID)
+	}
+
+	room, ok := r.store.GetRoom(roomID)
+	if !ok {
+		return nil, nil, errRoomNotFound(roomID)
+	}
+
+	if user, ok := room.Users[current.ID]; !ok || (!user.Owner && !current.Admin) {
+		return nil, nil, newError(CodeNotAuthorized, roomID, "only the owner can kick or ban users")
+	}
+	if current.ID == id {
+		return nil, nil, newError(CodeProtocolError, roomID, "you cannot kick yourself")
+	}
+
+	// the user may already be gone.
+	return room, room.Users[id], nil
+}
+
+// banned checks if the name or ip is banned from the room, it must be called inside the rooms event loop.
+func (r *Rooms) banned(roomID, name string, addr net.IP) bool {
+	r.removeExpiredBans(roomID)
+	for _, ban := range r.bans[roomID] {
+		if ban.User == name || ban.IP == addr.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRoomModerator allows admins and the logged in user that created the room, admins also manage the rooms of
+// guests.
+func (r *Rooms) checkRoomModerator(roomID, user string, admin bool) error {
+	if !admin {
+		return r.checkRoomOwner(roomID, user)
+	}
+	if _, ok := r.store.GetRoom(roomID); !ok {
+		return ErrRoomNotFound
+	}
+	return nil
+}
+
+func (r *Rooms) removeExpiredBans(roomID string) {
+	now := time.Now()
+	for user, ban := range r.bans[roomID] {
+		if ban.Expires.Before(now) {
+			delete(r.bans[roomID], user)
+		}
+	}
+}
+
+// Bans returns the active bans of the room. Only admins and the logged in user that created the room may list them.
+func (r *Rooms) Bans(roomID, user string, admin bool) ([]Ban, error) {
+	var bans []Ban
+	var err error
+	if doErr := r.do(func() {
+		if err = r.checkRoomModerator(roomID, user, admin); err != nil {
+			return
+		}
+		r.removeExpiredBans(roomID)
+		bans = []Ban{}
+		for _, ban := range r.bans[roomID] {
+			bans = append(bans, *ban)
+		}
+	}); doErr != nil {
+		return nil, doErr
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].User < bans[j].User
+	})
+	return bans, err
+}
+
+// Unban removes the ban of the user from the room, like Bans only admins and the creator of the room may do it.
+func (r *Rooms) Unban(roomID, user string, admin bool, banned string) error {
+	var err error
+	if doErr := r.do(func() {
+		if err = r.checkRoomModerator(roomID, user, admin); err != nil {
+			return
+		}
+		if _, ok := r.bans[roomID][banned]; !ok {
+			err = ErrBanNotFound
+			return
+		}
+		delete(r.bans[roomID], banned)
+	}); doErr != nil {
+		return doErr
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"net"
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newModeratedRoom(t *testing.T) (*Rooms, ClientInfo, ClientInfo) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	owner := newTestClient("alice")
+	bob := newTestClient("bob")
+	bob.Addr = net.ParseIP("10.0.0.2")
+	rooms.do(func() {
+		require.NoError(t, createRoom(t, rooms, &owner, "room"))
+		require.NoError(t, (&Join{ID: "room"}).Execute(rooms, bob))
+	})
+	drain(owner)
+	drain(bob)
+	bob.RoomID = "room"
+	return rooms, owner, bob
+}
+
+func TestKickUser(t *testing.T) {
+	rooms, owner, bob := newModeratedRoom(t)
+
+	rooms.do(func() {
+		assert.EqualError(t, (&KickUser{Room: "room", ID: owner.ID}).Execute(rooms, bob), "only the owner can kick or ban users")
+		assert.EqualError(t, (&KickUser{Room: "other", ID: bob.ID}).Execute(rooms, owner), "not in room other")
+		require.NoError(t, (&KickUser{Room: "room", ID: bob.ID}).Execute(rooms, owner))
+	})
+
+	assert.Equal(t, []outgoing.Message{outgoing.YouWereKicked{Room: "room"}}, drain(bob))
+	assert.Equal(t, closeFrame{Code: CloseCodeKicked, Reason: CloseKicked}, <-bob.Close)
+
+	rejoin := newTestClient("bob")
+	rooms.do(func() {
+		assert.NoError(t, (&Join{ID: "room"}).Execute(rooms, rejoin))
+	})
+}
+
+func TestBanUser(t *testing.T) {
+	rooms, owner, bob := newModeratedRoom(t)
+
+	rooms.do(func() {
+		assert.EqualError(t, (&BanUser{Room: "room", ID: bob.ID}).Execute(rooms, owner), "ban duration must be positive")
+		require.NoError(t, (&BanUser{Room: "room", ID: bob.ID, Duration: 60}).Execute(rooms, owner))
+	})
+	messages := drain(bob)
+	require.Len(t, messages, 1)
+	assert.True(t, messages[0].(outgoing.YouWereKicked).Banned)
+	assert.Equal(t, closeFrame{Code: CloseCodeKicked, Reason: CloseKicked}, <-bob.Close)
+
+	sameName := newTestClient("bob")
+	sameIP := newTestClient("")
+	sameIP.Addr = bob.Addr
+	other := newTestClient("carol")
+	rooms.do(func() {
+		assert.EqualError(t, (&Join{ID: "room"}).Execute(rooms, sameName), "you are banned from this room")
+		assert.EqualError(t, (&Join{ID: "room"}).Execute(rooms, sameIP), "you are banned from this room")
+		assert.NoError(t, (&Join{ID: "room"}).Execute(rooms, other))
+	})
+
+	_, err := rooms.Bans("room", "carol", false)
+	assert.Equal(t, ErrNotRoomOwner, err)
+	_, err = rooms.Bans("unknown", "carol", true)
+	assert.Equal(t, ErrRoomNotFound, err)
+	bans, err := rooms.Bans("room", "carol", true)
+	require.NoError(t, err, "admins manage the bans of all rooms")
+	require.Len(t, bans, 1)
+	bans, err = rooms.Bans("room", "alice", false)
+	require.NoError(t, err)
+	require.Len(t, bans, 1)
+	assert.Equal(t, "bob", bans[0].User)
+	assert.Equal(t, "10.0.0.2", bans[0].IP)
+
+	assert.Equal(t, ErrNotRoomOwner, rooms.Unban("room", "carol", false, "bob"))
+	assert.Equal(t, ErrBanNotFound, rooms.Unban("room", "alice", false, "carol"))
+	require.NoError(t, rooms.Unban("room", "alice", false, "bob"))
+	rooms.do(func() {
+		assert.NoError(t, (&Join{ID: "room"}).Execute(rooms, sameName))
+	})
+}
+
+func TestKickUser_admin(t *testing.T) {
+	rooms, owner, bob := newModeratedRoom(t)
+
+	admin := newTestClient("root")
+	admin.Admin = true
+	rooms.do(func() {
+		require.NoError(t, (&Join{ID: "room"}).Execute(rooms, admin))
+	})
+	admin.RoomID = "room"
+
+	rooms.do(func() {
+		require.NoError(t, (&KickUser{Room: "room", ID: bob.ID}).Execute(rooms, admin))
+	})
+	assert.Equal(t, closeFrame{Code: CloseCodeKicked, Reason: CloseKicked}, <-bob.Close)
+	drain(owner)
+}
@@ -0,0 +1,40 @@
+package ws
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// isObserver returns whether the request authenticates with the observer token. Observers are services like
+// recorders, they receive the screen shares of a room without being visible to its members.
+func (r *Rooms) isObserver(req *http.Request) bool {
+	if r.config.ObserverToken == "" {
+		return false
+	}
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.config.ObserverToken)) == 1
+}
+
+// addObserver adds an observer to the room. Observers bypass passwords, bans and the waiting room.
+func (r *Rooms) addObserver(room *Room, current ClientInfo, name string) error {
+	if name == "" {
+		name = "observer"
+	}
+	return r.addUser(room, current, name, false)
+}
+
+// members returns the count of users in the room that aren't observers.
+func (r *Room) members() int {
+	count := 0
+	for _, user := range r.Users {
+		if !user.Observer {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newObserverRoom(t *testing.T) (*Rooms, ClientInfo, ClientInfo) {
+	t.Helper()
+	rooms := newTestRooms(config.Config{RoomPasswordsEnabled: true, ObserverToken: "token"})
+	owner := newTestClient("alice")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionLocal, Password: "pw", WaitingRoom: true}).Execute(rooms, owner))
+	owner.RoomID = "room"
+
+	observer := newTestClient("")
+	observer.Observer = true
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, observer))
+	observer.RoomID = "room"
+	return rooms, owner, observer
+}
+
+func TestObserver_joinsWithoutApproval(t *testing.T) {
+	rooms, owner, observer := newObserverRoom(t)
+
+	require.Contains(t, getRoom(rooms, "room").Users, observer.ID)
+	assert.True(t, getRoom(rooms, "room").Users[observer.ID].Observer)
+	assert.Empty(t, rooms.waiting)
+
+	getRoom(rooms, "room").notifyInfoChanged()
+	ownerMessages := drain(owner)
+	ownerRoom := ownerMessages[len(ownerMessages)-1].(outgoing.Room)
+	assert.Len(t, ownerRoom.Users, 1, "observers are hidden from members")
+
+	observerMessages := drain(observer)
+	observerRoom := observerMessages[len(observerMessages)-1].(outgoing.Room)
+	assert.Len(t, observerRoom.Users, 2)
+}
+
+func TestObserver_receivesShares(t *testing.T) {
+	rooms, owner, observer := newObserverRoom(t)
+	drain(observer)
+
+	require.NoError(t, (&StartShare{}).Execute(rooms, owner))
+
+	var sessions []outgoing.ClientSession
+	for _, msg := range drain(observer) {
+		if session, ok := msg.(outgoing.ClientSession); ok {
+			sessions = append(sessions, session)
+		}
+	}
+	require.Len(t, sessions, 1)
+	assert.Equal(t, owner.ID, sessions[0].Peer)
+}
+
+func TestObserver_cannotShareOrCreate(t *testing.T) {
+	rooms, _, observer := newObserverRoom(t)
+
+	assert.EqualError(t, (&StartShare{}).Execute(rooms, observer), "observers cannot share their screen")
+
+	other := newTestClient("")
+	other.Observer = true
+	assert.EqualError(t, (&Create{ID: "other", Mode: ConnectionLocal}).Execute(rooms, other), "observers cannot create rooms")
+}
+
+func TestObserver_roomClosesWithoutMembers(t *testing.T) {
+	rooms, owner, observer := newObserverRoom(t)
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+
+	assert.NotContains(t, roomIDs(rooms), "room")
+	assert.Equal(t, closeFrame{Code: CloseCodeRoomClosed, Reason: CloseRoomClosed}, <-observer.Close)
+}
+
+func TestIsObserver(t *testing.T) {
+	rooms := newTestRooms(config.Config{ObserverToken: "token"})
+	for header, expected := range map[string]bool{
+		"Bearer token": true,
+		"Bearer wrong": false,
+		"token":        false,
+		"":             false,
+	} {
+		req := httptest.NewRequest("GET", "/stream", nil)
+		req.Header.Set("Authorization", header)
+		assert.Equal(t, expected, rooms.isObserver(req), header)
+	}
+
+	req := httptest.NewRequest("GET", "/stream", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	assert.False(t, newTestRooms(config.Config{}).isObserver(req), "observers are disabled without token")
+}
@@ -0,0 +1,36 @@
+package ws
+
+// offloaded carries the result of work that was done outside of the event loop back into it.
+type offloaded struct {
+	done func() error
+}
+
+func (e *offloaded) Execute(rooms *Rooms, current ClientInfo) error {
+	if !rooms.offloading[current.ID] {
+		// the connection was closed while the work was running.
+		return nil
+	}
+	delete(rooms.offloading, current.ID)
+	return e.done()
+}
+
+// offloadAsync runs work in its own goroutine, so that slow work like password hashing doesn't stall all rooms. done
+// is executed in the event loop afterwards, its error is handled like the error of an event of the connection. Each
+// connection may only offload one work at a time.
+func (r *Rooms) offloadAsync(current ClientInfo, work func(), done func() error) error {
+	if r.offloading[current.ID] {
+		return newError(CodeRateLimited, current.RoomID, "a password is still being checked")
+	}
+	r.offloading[current.ID] = true
+	go func() {
+		work()
+		r.post(ClientMessage{Info: current, Incoming: &offloaded{done: done}})
+	}()
+	return nil
+}
+
+// offloadSync runs work and done directly, it is used by tests which execute events without the event loop.
+func offloadSync(current ClientInfo, work func(), done func() error) error {
+	work()
+	return done()
+}
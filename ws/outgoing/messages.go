@@ -2,6 +2,7 @@ package outgoing
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/rs/xid"
 )
@@ -22,6 +23,8 @@ type User struct {
 	Streaming bool   `json:"streaming"`
 	You       bool   `json:"you"`
 	Owner     bool   `json:"owner"`
+	Guest     bool   `json:"guest,omitempty"`
+	Idle      bool   `json:"idle,omitempty"`
 }
 
 func (Room) Type() string {
@@ -48,6 +51,17 @@ func (ClientSession) Type() string {
 	return "clientsession"
 }
 
+type ICEServersUpdate struct {
+	ID         xid.ID      `json:"id"`
+	ICEServers []ICEServer `json:"iceServers"`
+	// TTL in seconds of the credentials, 0 if they don't expire.
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+func (ICEServersUpdate) Type() string {
+	return "ice_servers_update"
+}
+
 type ICEServer struct {
 	URLs       []string `json:"urls"`
 	Credential string   `json:"credential"`
@@ -83,12 +97,177 @@ func (HostOffer) Type() string {
 	return "hostoffer"
 }
 
+// E2EKeyExchange is the public key of an application layer key exchange between the peers of a session.
+type E2EKeyExchange struct {
+	SID xid.ID `json:"sid"`
+	// PublicKey is the base64 encoded Diffie-Hellman public key.
+	PublicKey string `json:"publicKey"`
+}
+
+func (E2EKeyExchange) Type() string {
+	return "e2e_key_exchange"
+}
+
 type EndShare xid.ID
 
 func (EndShare) Type() string {
 	return "endshare"
 }
 
+type RoomPasswordRequired struct {
+	ID string `json:"id"`
+}
+
+func (RoomPasswordRequired) Type() string {
+	return "room_password_required"
+}
+
+type RoomPasswordIncorrect struct {
+	ID                string `json:"id"`
+	RemainingAttempts int    `json:"remainingAttempts"`
+}
+
+func (RoomPasswordIncorrect) Type() string {
+	return "room_password_incorrect"
+}
+
+type RoomJoined struct {
+	ID             string `json:"id"`
+	ReconnectToken string `json:"reconnectToken"`
+}
+
+func (RoomJoined) Type() string {
+	return "room_joined"
+}
+
+type StopShareRequested struct {
+	Room string `json:"room"`
+	By   xid.ID `json:"by"`
+}
+
+func (StopShareRequested) Type() string {
+	return "stop_share_requested"
+}
+
+type ShareStopped struct {
+	Room string `json:"room"`
+	By   xid.ID `json:"by"`
+}
+
+func (ShareStopped) Type() string {
+	return "share_stopped"
+}
+
+type RoomExpiring struct {
+	Room      string    `json:"room"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Remaining seconds until the room is closed.
+	Remaining int64 `json:"remaining"`
+}
+
+func (RoomExpiring) Type() string {
+	return "room_expiring"
+}
+
+type RoomExtended struct {
+	Room      string    `json:"room"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (RoomExtended) Type() string {
+	return "room_extended"
+}
+
+type WaitingForApproval struct{}
+
+func (WaitingForApproval) Type() string {
+	return "waiting_for_approval"
+}
+
+type UserWaiting struct {
+	ID   xid.ID `json:"id"`
+	Name string `json:"name"`
+}
+
+func (UserWaiting) Type() string {
+	return "user_waiting"
+}
+
+type YouWereKicked struct {
+	Room   string     `json:"room"`
+	Banned bool       `json:"banned"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+func (YouWereKicked) Type() string {
+	return "you_were_kicked"
+}
+
+type HostChanged struct {
+	Room string `json:"room"`
+	ID   xid.ID `json:"id"`
+	Name string `json:"name"`
+}
+
+func (HostChanged) Type() string {
+	return "host_changed"
+}
+
+type YouWereDeleted struct{}
+
+func (YouWereDeleted) Type() string {
+	return "you_were_deleted"
+}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+	Room    string `json:"room,omitempty"`
+}
+
+func (Error) Type() string {
+	return "error"
+}
+
+type ProtocolVersion struct {
+	Version int `json:"version"`
+}
+
+func (ProtocolVersion) Type() string {
+	return "protocol_version"
+}
+
+type Ping struct{}
+
+func (Ping) Type() string {
+	return "ping"
+}
+
+type UserIdle struct {
+	ID xid.ID `json:"id"`
+}
+
+func (UserIdle) Type() string {
+	return "user_idle"
+}
+
+type UserActive struct {
+	ID xid.ID `json:"id"`
+}
+
+func (UserActive) Type() string {
+	return "user_active"
+}
+
+type Broadcast struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+func (Broadcast) Type() string {
+	return "broadcast"
+}
+
 type ConnectionMode string
 
 const (
@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/screego/server/ws/outgoing"
+)
+
+func init() {
+	register("pong", func() Event {
+		return &Pong{}
+	})
+}
+
+// pingUsers sends a ping to every room member, members that don't answer in time are marked idle by checkPresence.
+func (r *Rooms) pingUsers() {
+	now := time.Now()
+	for _, room := range r.store.ListRooms() {
+		for _, user := range room.Users {
+			if user.Observer || !user.disconnectedAt.IsZero() || !answersPings(user.Protocol) {
+				continue
+			}
+			if !user.awaitingPong {
+				user.awaitingPong = true
+				user.pingSent = now
+			}
+			user.send(outgoing.Ping{})
+		}
+	}
+}
+
+func (r *Rooms) checkPresence() {
+	now := time.Now()
+	for _, room := range r.store.ListRooms() {
+		for _, user := range room.Users {
+			if !user.awaitingPong || now.Sub(user.pingSent) < r.config.PresenceTimeout {
+				continue
+			}
+			if !user.Idle {
+				user.Idle = true
+				user.idleSince = now
+				room.notifyOthers(user, outgoing.UserIdle{ID: user.ID})
+			}
+			if r.config.IdleDisconnect > 0 && now.Sub(user.idleSince) >= r.config.IdleDisconnect {
+				room.logEvent(RoomEventDisconnect, user, CloseIdle)
+				user.reject(newError(CodeIdle, room.ID, CloseIdle))
+			}
+		}
+	}
+}
+
+func (r *Room) notifyOthers(current *User, msg outgoing.Message) {
+	for _, user := range r.Users {
+		if user != current {
+			user.send(msg)
+		}
+	}
+}
+
+type Pong struct{}
+
+func (e *Pong) Execute(rooms *Rooms, current ClientInfo) error {
+	room, ok := rooms.store.GetRoom(current.RoomID)
+	if !ok {
+		// the user may have left the room after the ping.
+		return nil
+	}
+	user, ok := room.Users[current.ID]
+	if !ok {
+		return nil
+	}
+
+	user.awaitingPong = false
+	if user.Idle {
+		user.Idle = false
+		room.notifyOthers(user, outgoing.UserActive{ID: user.ID})
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresence(t *testing.T) {
+	rooms := newTestRooms(config.Config{PresenceTimeout: time.Second, IdleDisconnect: time.Minute})
+	owner := newTestClient("alice")
+	bob := newTestClient("")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, bob))
+	bob.RoomID = "room"
+	drain(owner)
+	drain(bob)
+
+	rooms.pingUsers()
+	assert.Equal(t, []outgoing.Message{outgoing.Ping{}}, drain(owner))
+	assert.Equal(t, []outgoing.Message{outgoing.Ping{}}, drain(bob))
+	require.NoError(t, (&Pong{}).Execute(rooms, owner))
+
+	rooms.checkPresence()
+	assert.Empty(t, drain(owner), "the timeout has not passed yet")
+
+	user := getRoom(rooms, "room").Users[bob.ID]
+	user.pingSent = time.Now().Add(-2 * time.Second)
+	rooms.checkPresence()
+	assert.True(t, user.Idle)
+	assert.Equal(t, []outgoing.Message{outgoing.UserIdle{ID: bob.ID}}, drain(owner))
+	assert.Empty(t, drain(bob))
+
+	require.NoError(t, (&Pong{}).Execute(rooms, bob))
+	assert.False(t, user.Idle)
+	assert.Equal(t, []outgoing.Message{outgoing.UserActive{ID: bob.ID}}, drain(owner))
+
+	rooms.pingUsers()
+	drain(owner)
+	drain(bob)
+	user.pingSent = time.Now().Add(-2 * time.Second)
+	rooms.checkPresence()
+	assert.Empty(t, bob.Close)
+	user.idleSince = time.Now().Add(-time.Hour)
+	rooms.checkPresence()
+	assert.Equal(t, closeFrame{Code: CloseCodeIdle, Reason: CloseIdle}, <-bob.Close)
+}
@@ -30,4 +30,40 @@ var (
 		Name: "screego_session_closed_total",
 		Help: "The total number of sessions closed",
 	})
+	roomJoinTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "screego_room_join_total",
+		Help: "The total number of users that entered a room, including the owners",
+	})
+	roomLeaveTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "screego_room_leave_total",
+		Help: "The total number of users that left a room, including the ones of closed rooms",
+	})
+	wsUpgradeFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "screego_ws_upgrade_failures_total",
+		Help: "The total number of failed websocket upgrades",
+	})
+	activeRooms = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "screego_active_rooms",
+		Help: "The number of open rooms",
+	})
+	connectedUsers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "screego_connected_users",
+		Help: "The number of websocket connections",
+	})
 )
+
+// reportGauges updates the gauges with the changes since the last call. It must be called inside the rooms event
+// loop, the changes of multiple Rooms add up.
+func (r *Rooms) reportGauges() {
+	rooms, users := len(r.store.ListRooms()), len(r.clients)
+	activeRooms.Add(float64(rooms - r.reportedRooms))
+	connectedUsers.Add(float64(users - r.reportedUsers))
+	r.reportedRooms, r.reportedUsers = rooms, users
+}
+
+// resetGauges removes the rooms and users of these Rooms from the gauges when the event loop ends.
+func (r *Rooms) resetGauges() {
+	activeRooms.Sub(float64(r.reportedRooms))
+	connectedUsers.Sub(float64(r.reportedUsers))
+	r.reportedRooms, r.reportedUsers = 0, 0
+}
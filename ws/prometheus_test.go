@@ -0,0 +1,72 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func metricValue(t *testing.T, collector prometheus.Metric) float64 {
+	t.Helper()
+	metric := &dto.Metric{}
+	require.NoError(t, collector.Write(metric))
+	if metric.Gauge != nil {
+		return metric.Gauge.GetValue()
+	}
+	return metric.Counter.GetValue()
+}
+
+func TestGauges(t *testing.T) {
+	// the rooms of other tests may still be in the gauges.
+	baseRooms, baseUsers := metricValue(t, activeRooms), metricValue(t, connectedUsers)
+	baseJoins, baseLeaves := metricValue(t, roomJoinTotal), metricValue(t, roomLeaveTotal)
+	gauges := func(rooms *Rooms) []float64 {
+		// the gauges are updated after the call of the previous do.
+		rooms.do(func() {})
+		return []float64{metricValue(t, activeRooms) - baseRooms, metricValue(t, connectedUsers) - baseUsers}
+	}
+
+	rooms := newTestRooms(config.Config{})
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- rooms.Start()
+	}()
+
+	alice, bob, carol := newTestClient("alice"), newTestClient("bob"), newTestClient("carol")
+	rooms.do(func() {
+		for _, client := range []ClientInfo{alice, bob, carol} {
+			require.NoError(t, (&Connected{}).Execute(rooms, client))
+		}
+		require.NoError(t, createRoom(t, rooms, &alice, "first"))
+		require.NoError(t, createRoom(t, rooms, &bob, "second"))
+		require.NoError(t, (&Join{ID: "first"}).Execute(rooms, carol))
+	})
+	assert.Equal(t, []float64{2, 3}, gauges(rooms))
+	assert.Equal(t, float64(3), metricValue(t, roomJoinTotal)-baseJoins)
+
+	rooms.do(func() {
+		require.NoError(t, (&Disconnected{}).Execute(rooms, bob))
+	})
+	assert.Equal(t, []float64{1, 2}, gauges(rooms))
+	assert.Equal(t, float64(1), metricValue(t, roomLeaveTotal)-baseLeaves)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		// the shutdown waits for the disconnects of the remaining clients.
+		for _, client := range []ClientInfo{alice, carol} {
+			<-client.Close
+			rooms.Incoming <- ClientMessage{Info: client, Incoming: &Disconnected{}}
+		}
+	}()
+	require.NoError(t, rooms.Stop(ctx))
+	require.NoError(t, <-stopped)
+	assert.Equal(t, baseRooms, metricValue(t, activeRooms))
+	assert.Equal(t, baseUsers, metricValue(t, connectedUsers))
+}
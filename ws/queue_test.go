@@ -0,0 +1,57 @@
+package ws
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowClientDoesNotBlockRoom(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	newClient := func(name string) ClientInfo {
+		client := newTestClient(name)
+		client.Write = make(chan outgoing.Message, 4)
+		return client
+	}
+	owner := newClient("owner")
+	slow := newClient("slow")
+	fast := newClient("fast")
+
+	// the closure runs on the event loop, the errors are checked on the test goroutine.
+	var errs []error
+	require.NoError(t, rooms.do(func() {
+		errs = append(errs, createRoom(t, rooms, &owner, "room"))
+		errs = append(errs, (&Join{ID: "room"}).Execute(rooms, slow))
+		errs = append(errs, (&Join{ID: "room"}).Execute(rooms, fast))
+	}))
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	drain(owner)
+	drain(fast)
+
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("renamed %d", i)
+		rooms.Incoming <- ClientMessage{Info: owner, Incoming: &Name{UserName: name}}
+
+		for _, client := range []ClientInfo{owner, fast} {
+			select {
+			case msg := <-client.Write:
+				require.Equal(t, name, msg.(outgoing.Room).Users[0].Name)
+			case <-time.After(time.Second):
+				t.Fatalf("room broadcast %d was blocked by the slow client", i)
+			}
+		}
+	}
+
+	assert.Equal(t, closeFrame{Code: CloseCodeTooSlow, Reason: CloseTooSlow}, <-slow.Close)
+	assert.Empty(t, owner.Close)
+	assert.Empty(t, fast.Close)
+}
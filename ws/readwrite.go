@@ -2,7 +2,6 @@ package ws
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 
@@ -28,17 +27,14 @@ func ToTypedOutgoing(outgoing outgoing.Message) (Typed, error) {
 func ReadTypedIncoming(r io.Reader) (Event, error) {
 	typed := Typed{}
 	if err := json.NewDecoder(r).Decode(&typed); err != nil {
-		return nil, fmt.Errorf("%s e", err)
+		return nil, fmt.Errorf("%w e", err)
 	}
 
-	create, ok := provider[typed.Type]
-
-	if !ok {
-		return nil, errors.New("cannot handle " + typed.Type)
+	payload, err := newEvent(typed.Type)
+	if err != nil {
+		return nil, err
 	}
 
-	payload := create()
-
 	if err := json.Unmarshal(typed.Payload, payload); err != nil {
 		return nil, fmt.Errorf("incoming payload %s", err)
 	}
@@ -0,0 +1,180 @@
+package ws
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/ws/outgoing"
+)
+
+// reconnectGracePeriod is how long a user whose connection was lost stays in the room, so the client can reconnect
+// without the other members noticing.
+const reconnectGracePeriod = 30 * time.Second
+
+func init() {
+	register("reconnect", func() Event {
+		return &Reconnect{}
+	})
+}
+
+func newReconnectKey() []byte {
+	key := make([]byte, 32)
+	if _, err := crand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func newReconnectNonce() string {
+	nonce := make([]byte, 16)
+	if _, err := crand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(nonce)
+}
+
+// issueReconnectToken sends the user a token to restore the membership after a lost connection. Every token replaces
+// the previous one of the user.
+func (r *Rooms) issueReconnectToken(room *Room, user *User) {
+	if user.Observer || !supportsReconnect(user.Protocol) {
+		return
+	}
+	user.reconnectNonce = newReconnectNonce()
+	token := strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(room.ID)),
+		user.ID.String(),
+		user.reconnectNonce,
+		hex.EncodeToString(r.reconnectMAC(room.ID, user.ID, user.reconnectNonce)),
+	}, ".")
+	user.send(outgoing.RoomJoined{ID: room.ID, ReconnectToken: token})
+}
+
+func (r *Rooms) reconnectMAC(roomID string, userID xid.ID, nonce string) []byte {
+	mac := hmac.New(sha256.New, r.reconnectKey)
+	mac.Write([]byte(roomID + userID.String() + nonce))
+	return mac.Sum(nil)
+}
+
+// reconnectTarget returns the room and the disconnected user of a valid reconnect token.
+func (r *Rooms) reconnectTarget(token string) (*Room, *User, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return nil, nil, false
+	}
+	roomID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, false
+	}
+	userID, err := xid.FromString(parts[1])
+	if err != nil {
+		return nil, nil, false
+	}
+	mac, err := hex.DecodeString(parts[3])
+	if err != nil || !hmac.Equal(mac, r.reconnectMAC(string(roomID), userID, parts[2])) {
+		return nil, nil, false
+	}
+
+	room, ok := r.store.GetRoom(string(roomID))
+	if !ok {
+		return nil, nil, false
+	}
+	user, ok := room.Users[userID]
+	if !ok || user.reconnectNonce != parts[2] || user.disconnectedAt.IsZero() ||
+		time.Since(user.disconnectedAt) > reconnectGracePeriod {
+		return nil, nil, false
+	}
+	return room, user, true
+}
+
+// holdForReconnect keeps the user in the room after the connection was lost. The sessions of the user are ended, as
+// they cannot survive a new connection, the user leaves the room if the client doesn't reconnect in time.
+func (r *Rooms) holdForReconnect(room *Room, user *User) {
+	user.disconnectedAt = time.Now()
+	user.awaitingPong = false
+	room.logEvent(RoomEventDisconnect, user, "connection lost")
+	if user.Streaming {
+		user.Streaming = false
+		room.logEvent(RoomEventShareStop, user, "")
+		r.webhook(WebhookScreenshareStop, room.ID, user)
+	}
+	room.endSessionsOf(r, user)
+	room.notifyInfoChanged()
+
+	info := ClientInfo{ID: user.ID, RoomID: room.ID}
+	timeout := &reconnectTimeout{nonce: user.reconnectNonce}
+	time.AfterFunc(reconnectGracePeriod, func() {
+		r.post(ClientMessage{Info: info, Incoming: timeout})
+	})
+}
+
+type reconnectTimeout struct {
+	nonce string
+}
+
+func (e *reconnectTimeout) Execute(rooms *Rooms, current ClientInfo) error {
+	room, ok := rooms.store.GetRoom(current.RoomID)
+	if !ok {
+		return nil
+	}
+	user, ok := room.Users[current.ID]
+	if !ok || user.reconnectNonce != e.nonce || user.disconnectedAt.IsZero() {
+		// the user has reconnected or already left.
+		return nil
+	}
+	rooms.leave(room, user)
+	return nil
+}
+
+// Reconnect restores the room membership of a user whose connection was lost.
+type Reconnect struct {
+	Token string `json:"token"`
+}
+
+func (e *Reconnect) Execute(rooms *Rooms, current ClientInfo) error {
+	if current.RoomID != "" {
+		return newError(CodeProtocolError, current.RoomID, "cannot reconnect, you are already in a room")
+	}
+	if _, waiting := rooms.waiting[current.ID]; waiting {
+		return newError(CodeProtocolError, "", "cannot reconnect, you are waiting for approval")
+	}
+
+	room, user, ok := rooms.reconnectTarget(e.Token)
+	if !ok {
+		// the client falls back to a normal join.
+		current.send(errorMessage(newError(CodeReconnectFailed, "", "the reconnect token is invalid or expired")))
+		return nil
+	}
+
+	if err := rooms.store.RemoveUser(room.ID, user.ID); err != nil {
+		return err
+	}
+	user.ID = current.ID
+	user.Addr = current.Addr
+	user.Protocol = current.Protocol
+	user.Write = current.Write
+	user.Close = current.Close
+	user.Idle = false
+	user.disconnectedAt = time.Time{}
+	if err := rooms.store.AddUser(room.ID, user); err != nil {
+		return err
+	}
+	rooms.stopJoinTimer(current.ID)
+
+	room.logEvent(RoomEventReconnect, user, "")
+	room.notifyInfoChanged()
+	rooms.issueReconnectToken(room, user)
+	if user.Owner {
+		for id, waiting := range rooms.waiting {
+			if waiting.RoomID == room.ID {
+				user.send(outgoing.UserWaiting{ID: id, Name: waiting.Name})
+			}
+		}
+	}
+	return rooms.receiveShares(room, current)
+}
@@ -0,0 +1,155 @@
+package ws
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reconnectToken(t *testing.T, client ClientInfo) string {
+	t.Helper()
+	var token string
+	for _, msg := range drain(client) {
+		if joined, ok := msg.(outgoing.RoomJoined); ok {
+			token = joined.ReconnectToken
+		}
+	}
+	require.NotEmpty(t, token)
+	return token
+}
+
+func newReconnectRoom(t *testing.T) (*Rooms, ClientInfo, ClientInfo, string) {
+	t.Helper()
+	rooms := newTestRooms(config.Config{})
+	owner := newTestClient("alice")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	drain(owner)
+
+	bob := newTestClient("")
+	require.NoError(t, (&Join{ID: "room", UserName: "bob"}).Execute(rooms, bob))
+	bob.RoomID = "room"
+	token := reconnectToken(t, bob)
+	drain(owner)
+
+	require.NoError(t, (&Disconnected{lost: true}).Execute(rooms, bob))
+	return rooms, owner, bob, token
+}
+
+func TestReconnect(t *testing.T) {
+	rooms, owner, bob, token := newReconnectRoom(t)
+	require.Contains(t, getRoom(rooms, "room").Users, bob.ID, "lost users are kept in the room")
+	drain(owner)
+
+	reconnected := newTestClient("")
+	require.NoError(t, (&Reconnect{Token: token}).Execute(rooms, reconnected))
+
+	room := getRoom(rooms, "room")
+	assert.NotContains(t, room.Users, bob.ID)
+	require.Contains(t, room.Users, reconnected.ID)
+	assert.Equal(t, "bob", room.Users[reconnected.ID].Name)
+
+	messages := drain(reconnected)
+	require.Len(t, messages, 2)
+	assert.IsType(t, outgoing.Room{}, messages[0])
+	assert.IsType(t, outgoing.RoomJoined{}, messages[1])
+	assert.NotEqual(t, token, messages[1].(outgoing.RoomJoined).ReconnectToken)
+
+	for _, msg := range drain(owner) {
+		assert.IsType(t, outgoing.Room{}, msg, "other members only see the updated room")
+	}
+
+	other := newTestClient("")
+	require.NoError(t, (&Reconnect{Token: token}).Execute(rooms, other))
+	assert.Equal(t, []outgoing.Message{outgoing.Error{Code: string(CodeReconnectFailed), Message: "the reconnect token is invalid or expired"}}, drain(other))
+}
+
+func TestReconnect_invalidToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token func(token string, rooms *Rooms, bob ClientInfo) string
+	}{
+		{
+			name:  "malformed",
+			token: func(string, *Rooms, ClientInfo) string { return "token" },
+		},
+		{
+			name: "wrong signature",
+			token: func(token string, rooms *Rooms, bob ClientInfo) string {
+				return token[:len(token)-2] + "00"
+			},
+		},
+		{
+			name: "expired",
+			token: func(token string, rooms *Rooms, bob ClientInfo) string {
+				getRoom(rooms, "room").Users[bob.ID].disconnectedAt = time.Now().Add(-reconnectGracePeriod - time.Second)
+				return token
+			},
+		},
+		{
+			name: "other key",
+			token: func(token string, rooms *Rooms, bob ClientInfo) string {
+				rooms.reconnectKey = newReconnectKey()
+				return token
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms, _, bob, token := newReconnectRoom(t)
+
+			client := newTestClient("")
+			require.NoError(t, (&Reconnect{Token: test.token(token, rooms, bob)}).Execute(rooms, client))
+
+			assert.Equal(t, []outgoing.Message{outgoing.Error{Code: string(CodeReconnectFailed), Message: "the reconnect token is invalid or expired"}}, drain(client))
+			assert.Empty(t, client.Close, "the client may fall back to a normal join")
+			require.NoError(t, (&Join{ID: "room"}).Execute(rooms, client))
+			assert.Contains(t, getRoom(rooms, "room").Users, client.ID)
+		})
+	}
+}
+
+func TestReconnect_timeout(t *testing.T) {
+	rooms, _, bob, _ := newReconnectRoom(t)
+	nonce := getRoom(rooms, "room").Users[bob.ID].reconnectNonce
+
+	require.NoError(t, (&reconnectTimeout{nonce: nonce}).Execute(rooms, ClientInfo{ID: bob.ID, RoomID: "room"}))
+
+	assert.NotContains(t, getRoom(rooms, "room").Users, bob.ID)
+}
+
+func TestReconnect_kickLostUser(t *testing.T) {
+	rooms, owner, bob, token := newReconnectRoom(t)
+
+	require.NoError(t, (&KickUser{Room: "room", ID: bob.ID}).Execute(rooms, owner))
+
+	assert.NotContains(t, getRoom(rooms, "room").Users, bob.ID)
+	client := newTestClient("")
+	require.NoError(t, (&Reconnect{Token: token}).Execute(rooms, client))
+	assert.NotContains(t, getRoom(rooms, "room").Users, client.ID)
+}
+
+func TestReconnect_closedConnectionLeavesImmediately(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	owner := newTestClient("alice")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	bob := newTestClient("")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, bob))
+	bob.RoomID = "room"
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, bob))
+
+	assert.NotContains(t, getRoom(rooms, "room").Users, bob.ID)
+}
+
+func TestConnectionLost(t *testing.T) {
+	assert.True(t, connectionLost(errors.New("i/o timeout")))
+	assert.True(t, connectionLost(&websocket.CloseError{Code: websocket.CloseAbnormalClosure}))
+	assert.False(t, connectionLost(&websocket.CloseError{Code: websocket.CloseGoingAway}))
+	assert.False(t, connectionLost(&websocket.CloseError{Code: websocket.CloseNormalClosure}))
+}
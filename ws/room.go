@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"sort"
+	"time"
 
 	"github.com/rs/xid"
 	"github.com/screego/server/config"
@@ -22,23 +23,51 @@ type Room struct {
 	ID                string
 	CloseOnOwnerLeave bool
 	Mode              ConnectionMode
+	WaitingRoom       bool
+	PasswordHash      []byte
+	CreatedBy         string
+	ownerKey          string
+	events            *eventLog
+	createdAt         time.Time
+	expiresAt         time.Time
+	expiryWarned      int
 	Users             map[xid.ID]*User
 	Sessions          map[xid.ID]*RoomSession
 }
 
 const (
-	CloseOwnerLeft = "Owner Left"
-	CloseDone      = "Read End"
+	CloseOwnerLeft       = "Owner Left"
+	CloseDone            = "Read End"
+	CloseRoomClosed      = "Room Closed"
+	CloseRejected        = "Rejected"
+	CloseApprovalTimeout = "Approval Timeout"
+	CloseTooSlow         = "Too Slow"
+	CloseKicked          = "Kicked"
+	CloseServerShutdown  = "Server Shutdown"
+	CloseIdle            = "Idle"
+	CloseRoomExpired     = "Room Expired"
+	CloseJoinTimeout     = "Join Timeout"
+	CloseLoggedOut       = "Logged Out"
+	CloseDeleted         = "User Deleted"
 )
 
 func (r *Room) newSession(host, client xid.ID, rooms *Rooms, v4, v6 net.IP) {
 	id := xid.New()
-	r.Sessions[id] = &RoomSession{
+	session := &RoomSession{
 		Host:   host,
 		Client: client,
 	}
+	r.Sessions[id] = session
 	sessionCreatedTotal.Inc()
 
+	iceHost, iceClient := r.iceServers(rooms, id, session, v4, v6)
+	r.Users[host].send(outgoing.HostSession{Peer: client, ID: id, ICEServers: iceHost})
+	r.Users[client].send(outgoing.ClientSession{Peer: host, ID: id, ICEServers: iceClient})
+}
+
+// iceServers creates the ice servers for the host and the client of a session. In TURN mode new credentials are
+// minted for the current credential generation of the session.
+func (r *Room) iceServers(rooms *Rooms, id xid.ID, session *RoomSession, v4, v6 net.IP) ([]outgoing.ICEServer, []outgoing.ICEServer) {
 	iceHost := []outgoing.ICEServer{}
 	iceClient := []outgoing.ICEServer{}
 	switch r.Mode {
@@ -47,24 +76,48 @@ func (r *Room) newSession(host, client xid.ID, rooms *Rooms, v4, v6 net.IP) {
 		iceHost = []outgoing.ICEServer{{URLs: rooms.addresses("stun", v4, v6, false)}}
 		iceClient = []outgoing.ICEServer{{URLs: rooms.addresses("stun", v4, v6, false)}}
 	case ConnectionTURN:
-		hostName, hostPW := rooms.turnServer.Credentials(id.String()+"host", r.Users[host].Addr)
-		clientName, clientPW := rooms.turnServer.Credentials(id.String()+"client", r.Users[client].Addr)
-		iceHost = []outgoing.ICEServer{{
-			URLs:       rooms.addresses("turn", v4, v6, true),
-			Credential: hostPW,
-			Username:   hostName,
-		}}
-		iceClient = []outgoing.ICEServer{{
-			URLs:       rooms.addresses("turn", v4, v6, true),
-			Credential: clientPW,
-			Username:   clientName,
-		}}
+		iceHost, session.HostCredential = rooms.turnICEServers(r.ID, turnCredentialID(id, "host", session.Generation), r.Users[session.Host].Addr, v4, v6)
+		iceClient, session.ClientCredential = rooms.turnICEServers(r.ID, turnCredentialID(id, "client", session.Generation), r.Users[session.Client].Addr, v4, v6)
+	}
+	return iceHost, iceClient
+}
+
+// turnICEServers returns the TURN server with new credentials and their username. Users in SCREEGO_TURN_LAN_NETWORKS
+// only get the STUN server and no credentials, they don't need a relay to reach the server.
+func (r *Rooms) turnICEServers(roomID, credentialID string, addr, v4, v6 net.IP) ([]outgoing.ICEServer, string) {
+	if r.inLAN(addr) {
+		return []outgoing.ICEServer{{URLs: r.addresses("stun", v4, v6, false)}}, ""
+	}
+	username, password := r.turnServer.Credentials(roomID, credentialID, addr)
+	return []outgoing.ICEServer{{URLs: r.addresses("turn", v4, v6, true), Credential: password, Username: username}}, username
+}
+
+func (r *Rooms) inLAN(addr net.IP) bool {
+	for _, network := range r.lanNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func turnCredentialID(id xid.ID, role string, generation int) string {
+	if generation == 0 {
+		return id.String() + role
 	}
-	r.Users[host].Write <- outgoing.HostSession{Peer: client, ID: id, ICEServers: iceHost}
-	r.Users[client].Write <- outgoing.ClientSession{Peer: host, ID: id, ICEServers: iceClient}
+	return fmt.Sprintf("%s%s-%d", id.String(), role, generation)
 }
 
+// addresses of the STUN/TURN server, SCREEGO_EXTERNAL_HOST replaces the ips of the internal TURN server.
 func (r *Rooms) addresses(prefix string, v4, v6 net.IP, tcp bool) (result []string) {
+	if r.config.ExternalHost != "" && !r.config.TurnExternal {
+		host := net.JoinHostPort(r.config.ExternalHostname(), r.config.TurnPort)
+		result = append(result, fmt.Sprintf("%s:%s", prefix, host))
+		if tcp {
+			result = append(result, fmt.Sprintf("%s:%s?transport=tcp", prefix, host))
+		}
+		return
+	}
 	if v4 != nil {
 		result = append(result, fmt.Sprintf("%s:%s:%s", prefix, v4.String(), r.config.TurnPort))
 		if tcp {
@@ -81,9 +134,8 @@ func (r *Rooms) addresses(prefix string, v4, v6 net.IP, tcp bool) (result []stri
 }
 
 func (r *Room) closeSession(rooms *Rooms, id xid.ID) {
-	if r.Mode == ConnectionTURN {
-		rooms.turnServer.Disallow(id.String() + "host")
-		rooms.turnServer.Disallow(id.String() + "client")
+	if session, ok := r.Sessions[id]; ok && r.Mode == ConnectionTURN {
+		rooms.disallowTURNCredentials(session.HostCredential, session.ClientCredential)
 	}
 	delete(r.Sessions, id)
 	sessionClosedTotal.Inc()
@@ -92,18 +144,28 @@ func (r *Room) closeSession(rooms *Rooms, id xid.ID) {
 type RoomSession struct {
 	Host   xid.ID
 	Client xid.ID
+
+	Generation int
+	// HostCredential and ClientCredential are the TURN usernames of the current generation.
+	HostCredential   string
+	ClientCredential string
 }
 
 func (r *Room) notifyInfoChanged() {
 	for _, current := range r.Users {
 		users := []outgoing.User{}
 		for _, user := range r.Users {
+			if user.Observer && !current.Observer {
+				continue
+			}
 			users = append(users, outgoing.User{
 				ID:        user.ID,
 				Name:      user.Name,
 				Streaming: user.Streaming,
 				You:       current == user,
 				Owner:     user.Owner,
+				Guest:     user.Guest,
+				Idle:      user.Idle,
 			})
 		}
 
@@ -122,10 +184,10 @@ func (r *Room) notifyInfoChanged() {
 			return left.Name < right.Name
 		})
 
-		current.Write <- outgoing.Room{
+		current.send(outgoing.Room{
 			ID:    r.ID,
 			Users: users,
-		}
+		})
 	}
 }
 
@@ -135,6 +197,26 @@ type User struct {
 	Name      string
 	Streaming bool
 	Owner     bool
+	Guest     bool
+	Observer  bool
+	Idle      bool
+	Protocol  int
 	Write     chan<- outgoing.Message
-	Close     chan<- string
+	Close     chan<- closeFrame
+
+	awaitingPong bool
+	pingSent     time.Time
+	idleSince    time.Time
+	// joinedAt is when the user joined, the user that is longest in the room becomes the next owner.
+	joinedAt time.Time
+
+	reconnectNonce string
+	disconnectedAt time.Time
+
+	refreshWindowStart time.Time
+	refreshCount       int
+}
+
+func (u *User) send(msg outgoing.Message) {
+	queue(u.Write, u.Close, msg)
 }
@@ -1,31 +1,70 @@
 package ws
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rs/xid"
 	"github.com/rs/zerolog/log"
+	"github.com/screego/server/audit"
 	"github.com/screego/server/auth"
 	"github.com/screego/server/config"
 	"github.com/screego/server/turn"
 	"github.com/screego/server/util"
 )
 
-func NewRooms(tServer turn.Server, users *auth.Users, conf config.Config) *Rooms {
-	return &Rooms{
-		Rooms:      map[string]*Room{},
-		Incoming:   make(chan ClientMessage),
-		turnServer: tServer,
-		users:      users,
-		config:     conf,
-		r:          rand.New(rand.NewSource(time.Now().Unix())),
+// NewRooms creates the rooms, they are kept in store.
+func NewRooms(tServer turn.Server, users *auth.Users, store RoomStore, conf config.Config) *Rooms {
+	var hooks *webhooks
+	if conf.WebhookURL != "" {
+		hooks = newWebhooks(conf.WebhookURL, conf.WebhookSecret, conf.WebhookTimeout)
+	}
+	var lanNetworks []*net.IPNet
+	for _, cidr := range conf.TurnLANNetworks {
+		// the networks are validated by the config.
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			lanNetworks = append(lanNetworks, network)
+		}
+	}
+	createNetworks, _ := auth.NewNetworks(conf.RoomCreateAllowedNetworks, conf.RoomCreateDeniedNetworks)
+	rooms := &Rooms{
+		webhooks:         hooks,
+		lanNetworks:      lanNetworks,
+		createNetworks:   createNetworks,
+		feed:             newFeed(),
+		store:            store,
+		Incoming:         make(chan ClientMessage),
+		pendingJoins:     map[xid.ID]*Join{},
+		passwordAttempts: map[xid.ID]int{},
+		offloading:       map[xid.ID]bool{},
+		roomsByOwner:     map[string]int{},
+		sessionsByUser:   map[string]int{},
+		waiting:          map[xid.ID]*waitingUser{},
+		bans:             map[string]map[string]*Ban{},
+		clients:          map[xid.ID]ClientInfo{},
+		joinTimers:       map[xid.ID]*time.Timer{},
+		reconnectKey:     newReconnectKey(),
+		expiryWarnings:   sortedWarnings(conf.RoomExpiryWarnings),
+		now:              time.Now,
+		stop:             make(chan struct{}),
+		stopped:          make(chan struct{}),
+		turnServer:       tServer,
+		users:            users,
+		config:           conf,
+		r:                rand.New(rand.NewSource(time.Now().Unix())),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
+			Subprotocols:    []string{MsgPackProtocol},
+			// clients without permessage-deflate support get uncompressed messages.
+			EnableCompression: conf.WSCompression,
 			CheckOrigin: func(r *http.Request) bool {
 				origin := r.Header.Get("origin")
 				u, err := url.Parse(origin)
@@ -39,16 +78,46 @@ func NewRooms(tServer turn.Server, users *auth.Users, conf config.Config) *Rooms
 			},
 		},
 	}
+	rooms.offload = rooms.offloadAsync
+	return rooms
 }
 
 type Rooms struct {
-	turnServer turn.Server
-	Rooms      map[string]*Room
-	Incoming   chan ClientMessage
-	upgrader   websocket.Upgrader
-	users      *auth.Users
-	config     config.Config
-	r          *rand.Rand
+	turnServer       turn.Server
+	store            RoomStore
+	Incoming         chan ClientMessage
+	Audit            *audit.Log
+	upgrader         websocket.Upgrader
+	users            *auth.Users
+	config           config.Config
+	r                *rand.Rand
+	pendingJoins     map[xid.ID]*Join
+	passwordAttempts map[xid.ID]int
+	offloading       map[xid.ID]bool
+	roomsByOwner     map[string]int
+	sessionsByUser   map[string]int
+	waiting          map[xid.ID]*waitingUser
+	bans             map[string]map[string]*Ban
+	clients          map[xid.ID]ClientInfo
+	joinTimers       map[xid.ID]*time.Timer
+	stop             chan struct{}
+	stopped          chan struct{}
+	stopping         bool
+	// notReady is set by NotReady, it is read outside of the event loop.
+	notReady       int32
+	webhooks       *webhooks
+	feed           *feed
+	shared         *sharedEvents
+	lanNetworks    []*net.IPNet
+	createNetworks *auth.Networks
+	reconnectKey   []byte
+	expiryWarnings []time.Duration
+	now            func() time.Time
+	// offload runs slow work outside of the event loop, it is offloadAsync except in tests.
+	offload func(current ClientInfo, work func(), done func() error) error
+	// reportedRooms and reportedUsers are the values of these Rooms in the gauges.
+	reportedRooms int
+	reportedUsers int
 }
 
 func (r *Rooms) RandUserName() string {
@@ -63,37 +132,202 @@ func (r *Rooms) Upgrade(w http.ResponseWriter, req *http.Request) {
 	conn, err := r.upgrader.Upgrade(w, req, nil)
 	if err != nil {
 		log.Debug().Err(err).Msg("Websocket upgrade")
+		wsUpgradeFailuresTotal.Inc()
 		w.WriteHeader(400)
 		_, _ = w.Write([]byte(fmt.Sprintf("Upgrade failed %s", err)))
 		return
 	}
 
 	user, loggedIn := r.users.CurrentUser(req)
-	c := newClient(conn, req, r.Incoming, user, loggedIn, r.config.TrustProxyHeaders)
+	c := newClient(conn, req, r.Incoming, user, loggedIn, r.config)
+	c.info.Observer = r.isObserver(req)
+	c.info.Admin = r.users.CurrentRole(req) == auth.RoleAdmin
+	c.info.FileUser = r.users.FileUser(req)
+	if loggedIn {
+		c.info.SessionID = r.users.SessionID(req)
+	}
+	if !r.post(ClientMessage{Info: c.info, Incoming: &Connected{}}) {
+		// the server is shutting down.
+		message := websocket.FormatCloseMessage(CloseCodeShutdown, CloseServerShutdown)
+		_ = conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(writeWait))
+		_ = conn.Close()
+		return
+	}
 
-	go c.startReading(time.Second * 20)
-	go c.startWriteHandler(time.Second * 5)
+	go c.startReading()
+	go c.startWriteHandler()
 }
 
-func (r *Rooms) Start() {
+// Start runs the rooms event loop until Stop was called and all clients are disconnected.
+func (r *Rooms) Start() (err error) {
+	defer close(r.stopped)
+	// the room closed events of the shutdown are still delivered to the feed subscribers.
+	defer r.feed.close()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("rooms event loop panicked: %v", p)
+		}
+	}()
+
+	var rotate <-chan time.Time
+	if r.config.TurnCredentialRotationInterval > 0 {
+		ticker := time.NewTicker(r.config.TurnCredentialRotationInterval)
+		defer ticker.Stop()
+		rotate = ticker.C
+	}
+
+	var presence, presenceCheck <-chan time.Time
+	if r.config.PresenceInterval > 0 {
+		ticker := time.NewTicker(r.config.PresenceInterval)
+		defer ticker.Stop()
+		presence = ticker.C
+	}
+
+	var expiry <-chan time.Time
+	if r.config.RoomLifetime > 0 {
+		ticker := time.NewTicker(expiryCheckInterval)
+		defer ticker.Stop()
+		expiry = ticker.C
+	}
+
+	defer r.resetGauges()
+	stop := r.stop
 	for {
-		msg := <-r.Incoming
-		if err := msg.Incoming.Execute(r, msg.Info); err != nil {
-			msg.Info.Close <- err.Error()
+		select {
+		case msg := <-r.Incoming:
+			if err := msg.Incoming.Execute(r, msg.Info); err != nil {
+				r.logError(msg.Info, err)
+				msg.Info.reject(err)
+			}
+		case <-rotate:
+			r.rotateTURNCredentials()
+		case <-presence:
+			r.pingUsers()
+			presenceCheck = time.After(r.config.PresenceTimeout)
+		case <-expiry:
+			r.sweepRooms()
+		case <-presenceCheck:
+			presenceCheck = nil
+			r.checkPresence()
+		case <-stop:
+			stop = nil
+			r.stopping = true
+			r.closeAll()
+		}
+		r.reportGauges()
+
+		if r.stopping && len(r.clients) == 0 {
+			return nil
 		}
 	}
 }
 
-func (r *Rooms) closeRoom(roomID string) {
-	room, ok := r.Rooms[roomID]
+// NotReady marks the rooms as shutting down for the readiness probe, they keep working until Stop is called.
+func (r *Rooms) NotReady() {
+	atomic.StoreInt32(&r.notReady, 1)
+}
+
+// Ready returns whether the rooms accept new clients, it is false once the shutdown started.
+func (r *Rooms) Ready() bool {
+	select {
+	case <-r.stopped:
+		return false
+	default:
+		return atomic.LoadInt32(&r.notReady) == 0
+	}
+}
+
+// Stop closes all clients and rooms and waits until the event loop has processed the disconnects of all clients or
+// the context expires. It may be called more than once, later calls wait for the same shutdown.
+func (r *Rooms) Stop(ctx context.Context) error {
+	select {
+	case r.stop <- struct{}{}:
+	case <-r.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-r.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Rooms) closeAll() {
+	for _, client := range r.clients {
+		client.reject(newError(CodeServerShutdown, "", CloseServerShutdown))
+	}
+	for _, room := range r.store.ListRooms() {
+		r.closeRoom(room.ID, audit.ReasonShutdown)
+	}
+}
+
+// loginRequired returns whether the auth mode requires a login for rooms with the given connection mode.
+func (r *Rooms) loginRequired(mode ConnectionMode) bool {
+	switch r.config.AuthMode {
+	case config.AuthModeAll:
+		return true
+	case config.AuthModeTurn:
+		return mode != ConnectionSTUN && mode != ConnectionLocal
+	default:
+		return false
+	}
+}
+
+// ownerKey identifies the owner of a room for the per user room limit. Guests are identified by their ip, so that they
+// cannot bypass the limit by reconnecting.
+func ownerKey(current ClientInfo) string {
+	if current.Authenticated {
+		return "user:" + current.AuthenticatedUser
+	}
+	return "guest:" + current.Addr.String()
+}
+
+// closeRoom removes the room, reason is one of the audit.Reason* constants of room_close. It is the only place where
+// rooms are removed, every teardown of a room must go through it.
+func (r *Rooms) closeRoom(roomID, reason string) {
+	room, ok := r.store.GetRoom(roomID)
 	if !ok {
 		return
 	}
+	r.Audit.Write(audit.Entry{EventType: audit.RoomClose, ActorUsername: room.CreatedBy, RoomID: roomID, Reason: reason})
+	r.roomsByOwner[room.ownerKey]--
+	if r.roomsByOwner[room.ownerKey] <= 0 {
+		delete(r.roomsByOwner, room.ownerKey)
+	}
+	r.rejectWaiting(roomID, newError(CodeRoomClosed, roomID, CloseRoomClosed))
+	delete(r.bans, roomID)
 	usersLeftTotal.Add(float64(len(room.Users)))
+	roomLeaveTotal.Add(float64(len(room.Users)))
 	for id := range room.Sessions {
 		room.closeSession(r, id)
 	}
+	if room.Mode == ConnectionTURN {
+		r.turnServer.DisallowRoom(roomID)
+	}
 
-	delete(r.Rooms, roomID)
+	if room.events != nil {
+		log.Debug().Str("room", roomID).Interface("events", room.events.list()).Msg("Room event log")
+	}
+
+	if err := r.store.DeleteRoom(roomID); err != nil {
+		log.Warn().Err(err).Str("room", roomID).Msg("Could not delete room")
+	}
 	roomsClosedTotal.Inc()
+	r.webhook(WebhookRoomClosed, roomID, nil)
+}
+
+// auditLog writes an audit log entry for an action of the current connection.
+func (r *Rooms) auditLog(event audit.Event, current ClientInfo, actor, target, roomID string) {
+	r.Audit.Write(audit.Entry{
+		EventType:      event,
+		ActorUsername:  actor,
+		TargetUsername: target,
+		RoomID:         roomID,
+		SourceIP:       current.Addr.String(),
+		SessionID:      current.ID.String(),
+	})
 }
@@ -0,0 +1,259 @@
+package ws
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/audit"
+	"github.com/screego/server/auth"
+	"github.com/screego/server/config"
+	"github.com/screego/server/config/ipdns"
+	"github.com/screego/server/turn"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type fakeTurnServer struct {
+	lock            sync.Mutex
+	disallowed      []string
+	disallowedRooms []string
+}
+
+func (s *fakeTurnServer) Credentials(room, id string, addr net.IP) (string, string) {
+	return id, "password"
+}
+
+func (s *fakeTurnServer) DisallowRoom(room string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.disallowedRooms = append(s.disallowedRooms, room)
+}
+
+func (s *fakeTurnServer) Disallow(username string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.disallowed = append(s.disallowed, username)
+}
+
+func (s *fakeTurnServer) TTL() time.Duration {
+	return time.Hour
+}
+
+func (s *fakeTurnServer) Stop(ctx context.Context) error {
+	return nil
+}
+
+func (s *fakeTurnServer) Stats() turn.Stats {
+	return turn.Stats{}
+}
+
+func (s *fakeTurnServer) Disallowed() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string{}, s.disallowed...)
+}
+
+func newTestRooms(conf config.Config) *Rooms {
+	if conf.AuthMode == "" {
+		conf.AuthMode = config.AuthModeNone
+	}
+	if conf.PasswordHash == "" {
+		conf.PasswordHash = auth.HashBcrypt
+		conf.BcryptCost = bcrypt.MinCost
+	}
+	conf.TurnIPProvider = &ipdns.Static{V4: net.ParseIP("127.0.0.1")}
+	rooms := NewRooms(&fakeTurnServer{}, &auth.Users{}, NewMemoryRoomStore(), conf)
+	rooms.offload = offloadSync
+	return rooms
+}
+
+// getRoom returns the room or nil if it doesn't exist, it must be called inside the event loop.
+func getRoom(rooms *Rooms, id string) *Room {
+	room, _ := rooms.store.GetRoom(id)
+	return room
+}
+
+func roomIDs(rooms *Rooms) []string {
+	ids := []string{}
+	for _, room := range rooms.store.ListRooms() {
+		ids = append(ids, room.ID)
+	}
+	return ids
+}
+
+func newTestClient(user string) ClientInfo {
+	return ClientInfo{
+		ID:                xid.New(),
+		Authenticated:     user != "",
+		AuthenticatedUser: user,
+		Write:             make(chan outgoing.Message, 100),
+		Close:             make(chan closeFrame, 10),
+		Addr:              net.ParseIP("127.0.0.1"),
+		Protocol:          CurrentProtocol,
+	}
+}
+
+func createRoom(t *testing.T, rooms *Rooms, client *ClientInfo, id string) error {
+	t.Helper()
+	err := (&Create{ID: id, Mode: ConnectionLocal}).Execute(rooms, *client)
+	if err == nil {
+		client.RoomID = id
+	}
+	return err
+}
+
+func TestMaxRoomsPerUser(t *testing.T) {
+	rooms := newTestRooms(config.Config{MaxRoomsPerUser: 2})
+
+	first := newTestClient("alice")
+	second := newTestClient("alice")
+	third := newTestClient("alice")
+	require.NoError(t, createRoom(t, rooms, &first, "one"))
+	require.NoError(t, createRoom(t, rooms, &second, "two"))
+	assert.EqualError(t, createRoom(t, rooms, &third, "three"), "you have reached the maximum of 2 rooms")
+
+	other := newTestClient("bob")
+	assert.NoError(t, createRoom(t, rooms, &other, "bobs"))
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, first))
+	assert.NoError(t, createRoom(t, rooms, &third, "three"))
+}
+
+func TestCreate_permission(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users")
+	hash, err := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte("alice:"+string(hash)+"::create_rooms=true\nbob:"+string(hash)+"\n"), 0o600))
+	users, err := auth.ReadPasswordsFile(path, []byte("secret"), 0)
+	require.NoError(t, err)
+	users.RestrictRoomCreation = true
+	rooms := newTestRooms(config.Config{})
+	rooms.users = users
+
+	alice, bob, guest := newTestClient("alice"), newTestClient("bob"), newTestClient("")
+	alice.FileUser, bob.FileUser = "alice", "bob"
+	var codeErr *Error
+	require.ErrorAs(t, createRoom(t, rooms, &bob, "bobs"), &codeErr)
+	assert.Equal(t, CodeNotPermitted, codeErr.Code)
+	require.ErrorAs(t, createRoom(t, rooms, &guest, "guests"), &codeErr)
+	assert.Equal(t, CodeNotPermitted, codeErr.Code)
+
+	require.NoError(t, createRoom(t, rooms, &alice, "room"))
+	assert.NoError(t, (&Create{ID: "room", Mode: ConnectionLocal, JoinIfExist: true}).Execute(rooms, bob), "users may still join")
+	assert.NoError(t, (&Join{ID: "room"}).Execute(rooms, guest))
+}
+
+func TestMaxSessionsPerUser(t *testing.T) {
+	rooms := newTestRooms(config.Config{MaxSessionsPerUser: 2})
+
+	first := newTestClient("alice")
+	second := newTestClient("alice")
+	third := newTestClient("alice")
+	require.NoError(t, (&Connected{}).Execute(rooms, first))
+	require.NoError(t, (&Connected{}).Execute(rooms, second))
+	assert.EqualError(t, (&Connected{}).Execute(rooms, third), "user alice has reached the maximum of 2 sessions")
+	require.NoError(t, (&Disconnected{}).Execute(rooms, third))
+
+	assert.NoError(t, (&Connected{}).Execute(rooms, newTestClient("bob")))
+	assert.NoError(t, (&Connected{}).Execute(rooms, newTestClient("")))
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, first))
+	fourth := newTestClient("alice")
+	assert.NoError(t, (&Connected{}).Execute(rooms, fourth))
+	assert.Error(t, (&Connected{}).Execute(rooms, newTestClient("alice")))
+}
+
+func TestMaxRoomsPerUser_guestsLimitedByIP(t *testing.T) {
+	rooms := newTestRooms(config.Config{MaxRoomsPerUser: 1})
+
+	guest := newTestClient("")
+	require.NoError(t, createRoom(t, rooms, &guest, "one"))
+	assert.Equal(t, 1, rooms.roomsByOwner[ownerKey(guest)])
+
+	reconnected := newTestClient("")
+	var codeErr *Error
+	require.ErrorAs(t, createRoom(t, rooms, &reconnected, "two"), &codeErr, "a new connection of the same ip")
+	assert.Equal(t, CodeLimitReached, codeErr.Code)
+
+	other := newTestClient("")
+	other.Addr = net.ParseIP("10.0.0.9")
+	assert.NoError(t, createRoom(t, rooms, &other, "two"))
+}
+
+func TestOwnedRoomsReleasedOnTeardown(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(rooms *Rooms, owner, viewer ClientInfo)
+	}{
+		{
+			name: "owner leaves",
+			close: func(rooms *Rooms, owner, viewer ClientInfo) {
+				getRoom(rooms, "room").CloseOnOwnerLeave = true
+				_ = (&Disconnected{}).Execute(rooms, owner)
+			},
+		},
+		{
+			name: "last member leaves",
+			close: func(rooms *Rooms, owner, viewer ClientInfo) {
+				_ = (&Disconnected{}).Execute(rooms, owner)
+				require.Contains(t, roomIDs(rooms), "room")
+				_ = (&Disconnected{}).Execute(rooms, viewer)
+			},
+		},
+		{
+			name: "closed directly",
+			close: func(rooms *Rooms, owner, viewer ClientInfo) {
+				rooms.closeRoom("room", audit.ReasonEmpty)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms := newTestRooms(config.Config{MaxRoomsPerUser: 1})
+			owner := newTestClient("alice")
+			require.NoError(t, createRoom(t, rooms, &owner, "room"))
+			viewer := newTestClient("")
+			require.NoError(t, (&Join{ID: "room"}).Execute(rooms, viewer))
+			viewer.RoomID = "room"
+
+			test.close(rooms, owner, viewer)
+
+			assert.NotContains(t, roomIDs(rooms), "room")
+			assert.Empty(t, rooms.roomsByOwner)
+			again := newTestClient("alice")
+			assert.NoError(t, createRoom(t, rooms, &again, "other"))
+		})
+	}
+}
+
+func TestCloseRoom_disallowsTURNCredentials(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	turnServer := rooms.turnServer.(*fakeTurnServer)
+	require.NoError(t, (&Create{ID: "turn", Mode: ConnectionTURN}).Execute(rooms, newTestClient("alice")))
+	require.NoError(t, (&Create{ID: "local", Mode: ConnectionLocal}).Execute(rooms, newTestClient("bob")))
+
+	rooms.closeRoom("turn", audit.ReasonEmpty)
+	rooms.closeRoom("local", audit.ReasonEmpty)
+	assert.Equal(t, []string{"turn"}, turnServer.disallowedRooms)
+}
+
+func TestAddresses_externalHost(t *testing.T) {
+	v4, v6 := net.ParseIP("203.0.113.1"), net.ParseIP("2001:db8::1")
+
+	rooms := newTestRooms(config.Config{TurnPort: "3478"})
+	assert.Equal(t, []string{"stun:203.0.113.1:3478", "stun:[2001:db8::1]:3478"}, rooms.addresses("stun", v4, v6, false))
+
+	rooms = newTestRooms(config.Config{TurnPort: "3478", ExternalHost: "screego.example.org:8443"})
+	assert.Equal(t, []string{"turn:screego.example.org:3478", "turn:screego.example.org:3478?transport=tcp"}, rooms.addresses("turn", v4, v6, true))
+
+	rooms = newTestRooms(config.Config{TurnPort: "3478", ExternalHost: "screego.example.org", TurnExternal: true})
+	assert.Equal(t, []string{"stun:203.0.113.1:3478"}, rooms.addresses("stun", v4, nil, false), "an external TURN server has its own address")
+}
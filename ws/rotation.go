@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"net"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+	"github.com/screego/server/ws/outgoing"
+)
+
+// rotateTURNCredentials replaces the TURN credentials of all sessions in TURN rooms. The old credentials are revoked
+// after the configured overlap, so allocations created with them can be refreshed by the clients in the meantime.
+func (r *Rooms) rotateTURNCredentials() {
+	v4, v6, err := r.config.TurnIPProvider.Get()
+	if err != nil {
+		// error is already logged by .Get()
+		return
+	}
+
+	rotated := 0
+	for _, room := range r.store.ListRooms() {
+		if room.Mode != ConnectionTURN {
+			continue
+		}
+		for id, session := range room.Sessions {
+			r.rotateSession(room, id, session, v4, v6)
+			rotated++
+		}
+	}
+	log.Debug().Int("sessions", rotated).Msg("TURN credentials rotated")
+}
+
+// rotateSession sends new TURN credentials to the host and the client of the session and revokes the old ones.
+func (r *Rooms) rotateSession(room *Room, id xid.ID, session *RoomSession, v4, v6 net.IP) {
+	oldHost, oldClient := session.HostCredential, session.ClientCredential
+	session.Generation++
+	iceHost, iceClient := room.iceServers(r, id, session, v4, v6)
+	ttl := int64(r.turnServer.TTL() / time.Second)
+	room.Users[session.Host].send(outgoing.ICEServersUpdate{ID: id, ICEServers: iceHost, TTL: ttl})
+	room.Users[session.Client].send(outgoing.ICEServersUpdate{ID: id, ICEServers: iceClient, TTL: ttl})
+	r.revokeTURNCredentials(oldHost, oldClient)
+}
+
+func (r *Rooms) revokeTURNCredentials(usernames ...string) {
+	time.AfterFunc(r.config.TurnCredentialRotationOverlap, func() {
+		r.disallowTURNCredentials(usernames...)
+	})
+}
+
+// disallowTURNCredentials revokes the credentials, users in SCREEGO_TURN_LAN_NETWORKS have none.
+func (r *Rooms) disallowTURNCredentials(usernames ...string) {
+	for _, username := range usernames {
+		if username != "" {
+			r.turnServer.Disallow(username)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateTURNCredentials(t *testing.T) {
+	rooms := newTestRooms(config.Config{TurnCredentialRotationOverlap: 50 * time.Millisecond})
+	turnServer := rooms.turnServer.(*fakeTurnServer)
+
+	host := newTestClient("host")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN}).Execute(rooms, host))
+	host.RoomID = "room"
+	viewer := newTestClient("viewer")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, viewer))
+	viewer.RoomID = "room"
+	require.NoError(t, (&StartShare{}).Execute(rooms, host))
+
+	var session *RoomSession
+	for _, s := range getRoom(rooms, "room").Sessions {
+		session = s
+	}
+	require.NotNil(t, session)
+	oldHost, oldClient := session.HostCredential, session.ClientCredential
+
+	rooms.rotateTURNCredentials()
+
+	assert.NotEqual(t, oldHost, session.HostCredential)
+	assert.NotEqual(t, oldClient, session.ClientCredential)
+	assert.Equal(t, session.HostCredential, lastICEServersUpdate(t, host).ICEServers[0].Username)
+	assert.Equal(t, session.ClientCredential, lastICEServersUpdate(t, viewer).ICEServers[0].Username)
+	assert.Empty(t, turnServer.Disallowed())
+
+	assert.Eventually(t, func() bool {
+		return assert.ObjectsAreEqual([]string{oldHost, oldClient}, turnServer.Disallowed())
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRotateTURNCredentials_lanNetworks(t *testing.T) {
+	rooms := newTestRooms(config.Config{TurnLANNetworks: []string{"127.0.0.0/8"}, TurnCredentialRotationOverlap: time.Millisecond})
+	turnServer := rooms.turnServer.(*fakeTurnServer)
+
+	host := newTestClient("host")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN}).Execute(rooms, host))
+	host.RoomID = "room"
+	viewer := newTestClient("viewer")
+	viewer.Addr = net.ParseIP("10.0.0.2")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, viewer))
+	viewer.RoomID = "room"
+	require.NoError(t, (&StartShare{}).Execute(rooms, host))
+
+	rooms.rotateTURNCredentials()
+
+	hostServers := lastICEServersUpdate(t, host).ICEServers
+	require.Len(t, hostServers, 1)
+	assert.Contains(t, hostServers[0].URLs[0], "stun:")
+	assert.Empty(t, hostServers[0].Username)
+	assert.Empty(t, hostServers[0].Credential)
+
+	viewerServers := lastICEServersUpdate(t, viewer).ICEServers
+	require.Len(t, viewerServers, 1)
+	assert.Contains(t, viewerServers[0].URLs[0], "turn:")
+	assert.NotEmpty(t, viewerServers[0].Username)
+
+	assert.Eventually(t, func() bool {
+		disallowed := turnServer.Disallowed()
+		return len(disallowed) == 1 && disallowed[0] != ""
+	}, time.Second, 10*time.Millisecond)
+}
+
+func lastICEServersUpdate(t *testing.T, client ClientInfo) outgoing.ICEServersUpdate {
+	t.Helper()
+	var update *outgoing.ICEServersUpdate
+	for len(client.Write) > 0 {
+		if msg, ok := (<-client.Write).(outgoing.ICEServersUpdate); ok {
+			update = &msg
+		}
+	}
+	require.NotNil(t, update)
+	return *update
+}
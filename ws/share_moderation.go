@@ -0,0 +1,73 @@
+package ws
+
+import (
+	"github.com/rs/xid"
+	"github.com/screego/server/ws/outgoing"
+)
+
+func init() {
+	register("request_stop_share", func() Event {
+		return &RequestStopShare{}
+	})
+	register("force_stop_share", func() Event {
+		return &ForceStopShare{}
+	})
+}
+
+// RequestStopShare asks a streaming user to stop sharing. The request is advisory, the target decides.
+type RequestStopShare struct {
+	Room string `json:"room"`
+	ID   xid.ID `json:"id"`
+}
+
+func (e *RequestStopShare) Execute(rooms *Rooms, current ClientInfo) error {
+	room, target, err := rooms.shareTarget(current, e.Room, e.ID)
+	if err != nil || target == nil {
+		return err
+	}
+
+	room.logEvent(RoomEventShareStopRequest, target, "")
+	target.send(outgoing.StopShareRequested{Room: room.ID, By: current.ID})
+	return nil
+}
+
+// ForceStopShare ends the share of a user, regardless of the client.
+type ForceStopShare struct {
+	Room string `json:"room"`
+	ID   xid.ID `json:"id"`
+}
+
+func (e *ForceStopShare) Execute(rooms *Rooms, current ClientInfo) error {
+	room, target, err := rooms.shareTarget(current, e.Room, e.ID)
+	if err != nil || target == nil {
+		return err
+	}
+
+	room.logEvent(RoomEventShareForceStop, target, "")
+	target.send(outgoing.ShareStopped{Room: room.ID, By: current.ID})
+	room.stopShare(rooms, target, true)
+	return nil
+}
+
+// shareTarget returns the streaming user whose share should be stopped, if the current user is an owner of the room.
+// The target is nil if the user is gone or doesn't share anymore.
+func (r *Rooms) shareTarget(current ClientInfo, roomID string, id xid.ID) (*Room, *User, error) {
+	if current.RoomID == "" || current.RoomID != roomID {
+		return nil, nil, newError(CodeProtocolError, roomID, "not in room %s", roomID)
+	}
+
+	room, ok := r.store.GetRoom(roomID)
+	if !ok {
+		return nil, nil, errRoomNotFound(roomID)
+	}
+
+	if user, ok := room.Users[current.ID]; !ok || !user.Owner {
+		return nil, nil, newError(CodeNotAuthorized, roomID, "only the owner can stop the shares of other users")
+	}
+
+	target, ok := room.Users[id]
+	if !ok || !target.Streaming {
+		return room, nil, nil
+	}
+	return room, target, nil
+}
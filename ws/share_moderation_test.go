@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func roomEventTypes(room *Room) []string {
+	var types []string
+	for _, event := range room.events.list() {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestRequestStopShare(t *testing.T) {
+	rooms, owner, viewer, _ := newSharingRoom(t, ConnectionLocal)
+	require.NoError(t, (&StartShare{}).Execute(rooms, viewer))
+	drain(viewer)
+
+	require.NoError(t, (&RequestStopShare{Room: "room", ID: viewer.ID}).Execute(rooms, owner))
+
+	assert.Equal(t, []outgoing.Message{outgoing.StopShareRequested{Room: "room", By: owner.ID}}, drain(viewer))
+	assert.True(t, getRoom(rooms, "room").Users[viewer.ID].Streaming, "the request is advisory")
+
+	assert.EqualError(t, (&RequestStopShare{Room: "room", ID: owner.ID}).Execute(rooms, viewer),
+		"only the owner can stop the shares of other users")
+}
+
+func TestForceStopShare(t *testing.T) {
+	rooms, owner, viewer, sid := newSharingRoom(t, ConnectionLocal)
+	room := getRoom(rooms, "room")
+	room.events = newEventLog(10)
+	require.NoError(t, (&StartShare{}).Execute(rooms, viewer))
+	drain(owner)
+	drain(viewer)
+	var viewerSession xid.ID
+	for id, session := range room.Sessions {
+		if session.Host == viewer.ID {
+			viewerSession = id
+		}
+	}
+
+	require.NoError(t, (&ForceStopShare{Room: "room", ID: viewer.ID}).Execute(rooms, owner))
+
+	assert.False(t, room.Users[viewer.ID].Streaming)
+	require.Len(t, room.Sessions, 1)
+	assert.Contains(t, room.Sessions, sid, "only the sessions of the target are closed")
+
+	viewerMessages := drain(viewer)
+	require.Len(t, viewerMessages, 3)
+	assert.Equal(t, outgoing.ShareStopped{Room: "room", By: owner.ID}, viewerMessages[0])
+	assert.Equal(t, outgoing.EndShare(viewerSession), viewerMessages[1])
+	assert.IsType(t, outgoing.Room{}, viewerMessages[2])
+
+	ownerMessages := drain(owner)
+	require.Len(t, ownerMessages, 2)
+	assert.Equal(t, outgoing.EndShare(viewerSession), ownerMessages[0])
+	assert.IsType(t, outgoing.Room{}, ownerMessages[1])
+
+	assert.Equal(t, []string{RoomEventShareStart, RoomEventShareForceStop, RoomEventShareStop}, roomEventTypes(room))
+}
+
+func TestForceStopShare_targetDisconnected(t *testing.T) {
+	rooms, owner, viewer, _ := newSharingRoom(t, ConnectionLocal)
+	require.NoError(t, (&StartShare{}).Execute(rooms, viewer))
+	require.NoError(t, (&RequestStopShare{Room: "room", ID: viewer.ID}).Execute(rooms, owner))
+	require.NoError(t, (&Disconnected{}).Execute(rooms, viewer))
+	drain(owner)
+
+	require.NoError(t, (&ForceStopShare{Room: "room", ID: viewer.ID}).Execute(rooms, owner))
+
+	assert.Empty(t, drain(owner))
+	assert.NotContains(t, getRoom(rooms, "room").Users, viewer.ID)
+	for _, session := range getRoom(rooms, "room").Sessions {
+		assert.NotEqual(t, viewer.ID, session.Host)
+	}
+}
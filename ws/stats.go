@@ -0,0 +1,83 @@
+package ws
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats is a snapshot of the rooms state for debugging.
+type Stats struct {
+	Connections int `json:"connections"`
+	Rooms       int `json:"rooms"`
+	Members     int `json:"members"`
+}
+
+// Stats returns the current connection, room and member count.
+func (r *Rooms) Stats() (Stats, error) {
+	var stats Stats
+	err := r.do(func() {
+		stats.Connections = len(r.clients)
+		rooms := r.store.ListRooms()
+		stats.Rooms = len(rooms)
+		for _, room := range rooms {
+			stats.Members += len(room.Users)
+		}
+	})
+	return stats, err
+}
+
+// RoomSummary describes a room for administrators.
+type RoomSummary struct {
+	ID                string         `json:"id"`
+	Mode              ConnectionMode `json:"mode"`
+	Users             int            `json:"users"`
+	Streaming         int            `json:"streaming"`
+	PasswordProtected bool           `json:"passwordProtected"`
+	WaitingRoom       bool           `json:"waitingRoom"`
+	CreatedBy         string         `json:"createdBy,omitempty"`
+	CreatedAt         time.Time      `json:"createdAt"`
+	ExpiresAt         *time.Time     `json:"expiresAt,omitempty"`
+}
+
+// RoomIDs returns the ids of the open rooms of this instance.
+func (r *Rooms) RoomIDs() ([]string, error) {
+	ids := []string{}
+	err := r.do(func() {
+		for _, room := range r.store.ListRooms() {
+			ids = append(ids, room.ID)
+		}
+	})
+	return ids, err
+}
+
+// RoomList returns all rooms sorted by id.
+func (r *Rooms) RoomList() ([]RoomSummary, error) {
+	list := []RoomSummary{}
+	err := r.do(func() {
+		for _, room := range r.store.ListRooms() {
+			summary := RoomSummary{
+				ID:                room.ID,
+				Mode:              room.Mode,
+				Users:             len(room.Users),
+				PasswordProtected: len(room.PasswordHash) > 0,
+				WaitingRoom:       room.WaitingRoom,
+				CreatedBy:         room.CreatedBy,
+				CreatedAt:         room.createdAt,
+			}
+			for _, user := range room.Users {
+				if user.Streaming {
+					summary.Streaming++
+				}
+			}
+			if !room.expiresAt.IsZero() {
+				expiresAt := room.expiresAt
+				summary.ExpiresAt = &expiresAt
+			}
+			list = append(list, summary)
+		}
+	})
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list, err
+}
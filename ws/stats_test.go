@@ -0,0 +1,38 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	owner := newTestClient("alice")
+	member := newTestClient("")
+	idle := newTestClient("")
+	rooms.do(func() {
+		for _, client := range []ClientInfo{owner, member, idle} {
+			require.NoError(t, (&Connected{}).Execute(rooms, client))
+		}
+		require.NoError(t, createRoom(t, rooms, &owner, "room"))
+		require.NoError(t, (&Join{ID: "room"}).Execute(rooms, member))
+	})
+	assert.Equal(t, Stats{Connections: 3, Rooms: 1, Members: 2}, currentStats(t, rooms))
+
+	rooms.do(func() {
+		require.NoError(t, (&Disconnected{}).Execute(rooms, idle))
+	})
+	assert.Equal(t, Stats{Connections: 2, Rooms: 1, Members: 2}, currentStats(t, rooms))
+}
+
+func currentStats(t *testing.T, rooms *Rooms) Stats {
+	t.Helper()
+	stats, err := rooms.Stats()
+	require.NoError(t, err)
+	return stats
+}
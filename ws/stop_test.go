@@ -0,0 +1,85 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStop(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	started := make(chan error, 1)
+	go func() {
+		started <- rooms.Start()
+	}()
+
+	owner := newTestClient("alice")
+	idle := newTestClient("")
+	rooms.do(func() {
+		require.NoError(t, (&Connected{}).Execute(rooms, owner))
+		require.NoError(t, (&Connected{}).Execute(rooms, idle))
+		require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	})
+	drain(owner)
+	drain(idle)
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- rooms.Stop(context.Background())
+	}()
+
+	for _, client := range []ClientInfo{owner, idle} {
+		assert.Equal(t, closeFrame{Code: CloseCodeShutdown, Reason: CloseServerShutdown}, <-client.Close)
+		assert.Equal(t, []outgoing.Message{outgoing.Error{Code: string(CodeServerShutdown), Message: CloseServerShutdown}}, drain(client))
+	}
+	rooms.do(func() {
+		assert.Empty(t, roomIDs(rooms))
+	})
+	assert.Empty(t, stopped, "stop must wait until all clients are disconnected")
+
+	rooms.Incoming <- ClientMessage{Info: owner, Incoming: &Disconnected{}}
+	rooms.Incoming <- ClientMessage{Info: idle, Incoming: &Disconnected{}}
+	assert.NoError(t, <-stopped)
+	assert.NoError(t, <-started)
+	assert.NoError(t, rooms.Stop(context.Background()))
+}
+
+func TestStop_timeout(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	rooms.do(func() {
+		require.NoError(t, (&Connected{}).Execute(rooms, newTestClient("")))
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, rooms.Stop(ctx))
+}
+
+func TestStop_concurrent(t *testing.T) {
+	rooms := newTestRooms(config.Config{})
+	go rooms.Start()
+
+	client := newTestClient("")
+	rooms.do(func() {
+		require.NoError(t, (&Connected{}).Execute(rooms, client))
+	})
+
+	stopped := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			stopped <- rooms.Stop(context.Background())
+		}()
+	}
+	assert.Equal(t, closeFrame{Code: websocket.CloseGoingAway, Reason: CloseServerShutdown}, <-client.Close)
+	rooms.Incoming <- ClientMessage{Info: client, Incoming: &Disconnected{}}
+	assert.NoError(t, <-stopped)
+	assert.NoError(t, <-stopped)
+}
@@ -0,0 +1,185 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/store"
+)
+
+var errRoomExists = errors.New("room already exists")
+
+// RoomStore holds the rooms of this instance. It is only used inside the rooms event loop, implementations don't need
+// to be safe for concurrent use.
+type RoomStore interface {
+	// CreateRoom adds the room, it fails if a room with the same id exists.
+	CreateRoom(room *Room) error
+	GetRoom(id string) (*Room, bool)
+	// UpdateRoom is called after the settings or the expiry of the room were changed.
+	UpdateRoom(room *Room) error
+	DeleteRoom(id string) error
+	ListRooms() []*Room
+	AddUser(roomID string, user *User) error
+	RemoveUser(roomID string, id xid.ID) error
+	// RevokeInvite keeps the hash of a revoked invite token until the token expires.
+	RevokeInvite(hash string, expires time.Time) error
+	InviteRevoked(hash string) (bool, error)
+}
+
+// MemoryRoomStore keeps the rooms in a map, they are lost on restart.
+type MemoryRoomStore struct {
+	rooms map[string]*Room
+	// invites keeps the revoked invites, the users and their connections aren't stored there.
+	invites *store.MemoryStore
+}
+
+// NewMemoryRoomStore returns an empty store.
+func NewMemoryRoomStore() *MemoryRoomStore {
+	return &MemoryRoomStore{rooms: map[string]*Room{}, invites: store.NewMemoryStore()}
+}
+
+func (m *MemoryRoomStore) CreateRoom(room *Room) error {
+	if _, ok := m.rooms[room.ID]; ok {
+		return errRoomExists
+	}
+	m.rooms[room.ID] = room
+	return nil
+}
+
+func (m *MemoryRoomStore) GetRoom(id string) (*Room, bool) {
+	room, ok := m.rooms[id]
+	return room, ok
+}
+
+func (m *MemoryRoomStore) UpdateRoom(room *Room) error {
+	if _, ok := m.rooms[room.ID]; !ok {
+		return store.ErrNotFound
+	}
+	m.rooms[room.ID] = room
+	return nil
+}
+
+func (m *MemoryRoomStore) DeleteRoom(id string) error {
+	delete(m.rooms, id)
+	return nil
+}
+
+func (m *MemoryRoomStore) ListRooms() []*Room {
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+func (m *MemoryRoomStore) AddUser(roomID string, user *User) error {
+	room, ok := m.rooms[roomID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	room.Users[user.ID] = user
+	return nil
+}
+
+func (m *MemoryRoomStore) RemoveUser(roomID string, id xid.ID) error {
+	if room, ok := m.rooms[roomID]; ok {
+		delete(room.Users, id)
+	}
+	return nil
+}
+
+func (m *MemoryRoomStore) RevokeInvite(hash string, expires time.Time) error {
+	return m.invites.RevokeInvite(hash, expires)
+}
+
+func (m *MemoryRoomStore) InviteRevoked(hash string) (bool, error) {
+	return m.invites.InviteRevoked(hash)
+}
+
+// persistentRoomStore additionally saves the metadata of the rooms in a store.Store. The users and their connections
+// only exist on the instance they are connected to and stay in memory.
+type persistentRoomStore struct {
+	*MemoryRoomStore
+	store store.Store
+}
+
+func newPersistentRoomStore(s store.Store) *persistentRoomStore {
+	return &persistentRoomStore{MemoryRoomStore: NewMemoryRoomStore(), store: s}
+}
+
+// RedisRoomStore saves the metadata of the rooms in Redis (SCREEGO_REDIS_URL), so that they are visible outside of the
+// instance. Rooms of other instances aren't loaded, Redis may contain stale rooms of crashed instances until they
+// expire or the data retention deletes them.
+type RedisRoomStore struct {
+	*persistentRoomStore
+}
+
+// NewRedisRoomStore returns an empty store that saves the rooms in s.
+func NewRedisRoomStore(s *store.RedisStore) *RedisRoomStore {
+	return &RedisRoomStore{persistentRoomStore: newPersistentRoomStore(s)}
+}
+
+// SQLiteRoomStore saves the metadata of the rooms in the database of SCREEGO_DB_DRIVER. The database belongs to a
+// single instance, the rooms of a previous run lost their connections and are deleted when the store is created.
+type SQLiteRoomStore struct {
+	*persistentRoomStore
+}
+
+// NewSQLiteRoomStore returns an empty store that saves the rooms in s.
+func NewSQLiteRoomStore(s *store.SQLiteStore) (*SQLiteRoomStore, error) {
+	if _, err := s.DeleteRoomsBefore(time.Now(), nil); err != nil {
+		return nil, fmt.Errorf("could not delete the rooms of the previous run: %w", err)
+	}
+	return &SQLiteRoomStore{persistentRoomStore: newPersistentRoomStore(s)}, nil
+}
+
+func (p *persistentRoomStore) CreateRoom(room *Room) error {
+	if err := p.MemoryRoomStore.CreateRoom(room); err != nil {
+		return err
+	}
+	if err := p.store.SaveRoom(storedRoom(room)); err != nil {
+		_ = p.MemoryRoomStore.DeleteRoom(room.ID)
+		return fmt.Errorf("could not save room: %w", err)
+	}
+	return nil
+}
+
+func (p *persistentRoomStore) UpdateRoom(room *Room) error {
+	if err := p.MemoryRoomStore.UpdateRoom(room); err != nil {
+		return err
+	}
+	return p.store.SaveRoom(storedRoom(room))
+}
+
+func (p *persistentRoomStore) DeleteRoom(id string) error {
+	// only rooms of this instance are removed from the store.
+	if _, ok := p.MemoryRoomStore.GetRoom(id); !ok {
+		return nil
+	}
+	_ = p.MemoryRoomStore.DeleteRoom(id)
+	return p.store.DeleteRoom(id)
+}
+
+// RevokeInvite stores the revocation in the store, so that the instances sharing it reject the token.
+func (p *persistentRoomStore) RevokeInvite(hash string, expires time.Time) error {
+	return p.store.RevokeInvite(hash, expires)
+}
+
+func (p *persistentRoomStore) InviteRevoked(hash string) (bool, error) {
+	return p.store.InviteRevoked(hash)
+}
+
+func storedRoom(room *Room) store.Room {
+	return store.Room{
+		ID:                room.ID,
+		Mode:              string(room.Mode),
+		CreatedBy:         room.CreatedBy,
+		CloseOnOwnerLeave: room.CloseOnOwnerLeave,
+		WaitingRoom:       room.WaitingRoom,
+		PasswordHash:      room.PasswordHash,
+		Created:           room.createdAt,
+		Expires:           room.expiresAt,
+	}
+}
@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/config"
+	"github.com/screego/server/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRoomStore(t *testing.T) {
+	rooms := NewMemoryRoomStore()
+	room := &Room{ID: "room", Users: map[xid.ID]*User{}}
+	require.NoError(t, rooms.CreateRoom(room))
+	assert.ErrorIs(t, rooms.CreateRoom(&Room{ID: "room"}), errRoomExists)
+
+	user := &User{ID: newTestClient("bob").ID, Name: "bob"}
+	require.NoError(t, rooms.AddUser("room", user))
+	got, ok := rooms.GetRoom("room")
+	require.True(t, ok)
+	assert.Same(t, user, got.Users[user.ID])
+	assert.ErrorIs(t, rooms.AddUser("other", user), store.ErrNotFound)
+
+	require.NoError(t, rooms.RemoveUser("room", user.ID))
+	assert.Empty(t, got.Users)
+	assert.ErrorIs(t, rooms.UpdateRoom(&Room{ID: "other"}), store.ErrNotFound)
+
+	require.NoError(t, rooms.DeleteRoom("room"))
+	_, ok = rooms.GetRoom("room")
+	assert.False(t, ok)
+	assert.Empty(t, rooms.ListRooms())
+}
+
+func TestPersistentRoomStore(t *testing.T) {
+	persisted := store.NewMemoryStore()
+	rooms := newTestRooms(config.Config{RoomLifetime: time.Hour})
+	rooms.store = newPersistentRoomStore(persisted)
+
+	owner := newTestClient("alice")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionSTUN, CloseOnOwnerLeave: true}).Execute(rooms, owner))
+	owner.RoomID = "room"
+
+	room, err := persisted.GetRoom("room")
+	require.NoError(t, err)
+	assert.Equal(t, "stun", room.Mode)
+	assert.Equal(t, "alice", room.CreatedBy)
+	assert.True(t, room.CloseOnOwnerLeave)
+	assert.False(t, room.Expires.IsZero())
+
+	require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+	_, err = persisted.GetRoom("room")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestSQLiteRoomStore(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "screego.db")
+	persisted, err := store.OpenSQLite(dsn)
+	require.NoError(t, err)
+	defer persisted.Close()
+	require.NoError(t, persisted.SaveRoom(store.Room{ID: "stale", Created: time.Now().Add(-time.Minute)}))
+
+	roomStore, err := NewSQLiteRoomStore(persisted)
+	require.NoError(t, err)
+	_, err = persisted.GetRoom("stale")
+	assert.ErrorIs(t, err, store.ErrNotFound, "the rooms of the previous run are deleted")
+
+	rooms := newTestRooms(config.Config{})
+	rooms.store = roomStore
+	owner := newTestClient("alice")
+	require.NoError(t, (&Create{ID: "room", Mode: ConnectionTURN}).Execute(rooms, owner))
+	room, err := persisted.GetRoom("room")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", room.CreatedBy)
+}
+
+func TestRetention_sqliteKeepsOpenRooms(t *testing.T) {
+	persisted, err := store.OpenSQLite(filepath.Join(t.TempDir(), "screego.db"))
+	require.NoError(t, err)
+	defer persisted.Close()
+	roomStore, err := NewSQLiteRoomStore(persisted)
+	require.NoError(t, err)
+
+	rooms := newTestRooms(config.Config{})
+	rooms.store = roomStore
+	created := time.Now().Add(-31 * 24 * time.Hour)
+	rooms.now = func() time.Time { return created }
+	go rooms.Start()
+	defer rooms.Stop(context.Background())
+
+	var createErr error
+	require.NoError(t, rooms.do(func() {
+		createErr = (&Create{ID: "open", Mode: ConnectionTURN}).Execute(rooms, newTestClient("alice"))
+	}))
+	require.NoError(t, createErr)
+	require.NoError(t, persisted.SaveRoom(store.Room{ID: "stale", Created: created}))
+	require.NoError(t, persisted.SaveSession(store.Session{Key: "expired", Created: created, Expires: created.Add(time.Hour)}))
+
+	retention := store.Retention{Store: persisted, MaxAge: 30 * 24 * time.Hour, LiveRooms: rooms.RoomIDs}
+	require.NoError(t, retention.Run(time.Now()))
+
+	_, err = persisted.GetRoom("stale")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = persisted.GetSession("expired")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	room, err := persisted.GetRoom("open")
+	require.NoError(t, err, "the rooms of ws.Rooms are open and kept")
+	assert.True(t, created.Equal(room.Created))
+}
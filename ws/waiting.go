@@ -0,0 +1,135 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/screego/server/ws/outgoing"
+)
+
+func init() {
+	register("admit_user", func() Event {
+		return &AdmitUser{}
+	})
+	register("reject_user", func() Event {
+		return &RejectUser{}
+	})
+}
+
+// waitingUser is a user that joined a room with waiting room and has not been admitted by the owner yet.
+type waitingUser struct {
+	RoomID string
+	Name   string
+	Guest  bool
+	Info   ClientInfo
+}
+
+func (r *Rooms) wait(room *Room, current ClientInfo, name string, guest bool) error {
+	owners := room.owners()
+	if len(owners) == 0 {
+		return newError(CodeNotAuthorized, room.ID, "the owner of room %s is not present", room.ID)
+	}
+
+	r.waiting[current.ID] = &waitingUser{RoomID: room.ID, Name: name, Guest: guest, Info: current}
+	// the waiting room has its own timeout.
+	r.stopJoinTimer(current.ID)
+	current.send(outgoing.WaitingForApproval{})
+	for _, owner := range owners {
+		owner.send(outgoing.UserWaiting{ID: current.ID, Name: name})
+	}
+
+	if timeout := r.config.WaitingRoomTimeout; timeout > 0 {
+		time.AfterFunc(timeout, func() {
+			r.post(ClientMessage{Info: current, Incoming: &waitingTimeout{}})
+		})
+	}
+	return nil
+}
+
+// rejectWaiting closes the connections of all users waiting for the room.
+func (r *Rooms) rejectWaiting(roomID string, err *Error) {
+	for id, waiting := range r.waiting {
+		if waiting.RoomID == roomID {
+			delete(r.waiting, id)
+			waiting.Info.reject(err)
+		}
+	}
+}
+
+func (r *Room) owners() []*User {
+	var owners []*User
+	for _, user := range r.Users {
+		if user.Owner {
+			owners = append(owners, user)
+		}
+	}
+	return owners
+}
+
+// waitingUserOfOwner returns the waiting user, if the current user is an owner of the room the user waits for.
+func (r *Rooms) waitingUserOfOwner(current ClientInfo, id xid.ID) (*waitingUser, error) {
+	if current.RoomID == "" {
+		return nil, errNotInRoom()
+	}
+
+	room, ok := r.store.GetRoom(current.RoomID)
+	if !ok {
+		return nil, errRoomNotFound(current.RoomID)
+	}
+
+	if user, ok := room.Users[current.ID]; !ok || !user.Owner {
+		return nil, newError(CodeNotAuthorized, current.RoomID, "only the owner can admit or reject users")
+	}
+
+	waiting, ok := r.waiting[id]
+	if !ok || waiting.RoomID != room.ID {
+		return nil, nil
+	}
+	return waiting, nil
+}
+
+type AdmitUser struct {
+	ID xid.ID `json:"id"`
+}
+
+func (e *AdmitUser) Execute(rooms *Rooms, current ClientInfo) error {
+	waiting, err := rooms.waitingUserOfOwner(current, e.ID)
+	if err != nil || waiting == nil {
+		// the user may already be admitted, rejected or gone.
+		return err
+	}
+
+	delete(rooms.waiting, e.ID)
+	room, ok := rooms.store.GetRoom(waiting.RoomID)
+	if !ok {
+		return errRoomNotFound(waiting.RoomID)
+	}
+	return rooms.addUser(room, waiting.Info, waiting.Name, waiting.Guest)
+}
+
+type RejectUser struct {
+	ID xid.ID `json:"id"`
+}
+
+func (e *RejectUser) Execute(rooms *Rooms, current ClientInfo) error {
+	waiting, err := rooms.waitingUserOfOwner(current, e.ID)
+	if err != nil || waiting == nil {
+		return err
+	}
+
+	delete(rooms.waiting, e.ID)
+	waiting.Info.reject(newError(CodeRejected, waiting.RoomID, CloseRejected))
+	return nil
+}
+
+type waitingTimeout struct{}
+
+func (e *waitingTimeout) Execute(rooms *Rooms, current ClientInfo) error {
+	waiting, ok := rooms.waiting[current.ID]
+	if !ok {
+		return nil
+	}
+	delete(rooms.waiting, current.ID)
+	current.reject(newError(CodeApprovalTimeout, waiting.RoomID, CloseApprovalTimeout))
+	return nil
+}
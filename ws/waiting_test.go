@@ -0,0 +1,137 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/screego/server/config"
+	"github.com/screego/server/ws/outgoing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWaitingRoom(t *testing.T) (*Rooms, ClientInfo, ClientInfo) {
+	t.Helper()
+	rooms := newTestRooms(config.Config{})
+	owner := newTestClient("alice")
+	require.NoError(t, createRoomWith(t, rooms, &owner, &Create{ID: "room", Mode: ConnectionLocal, WaitingRoom: true}))
+	drain(owner)
+
+	joiner := newTestClient("bob")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, joiner))
+	return rooms, owner, joiner
+}
+
+func createRoomWith(t *testing.T, rooms *Rooms, client *ClientInfo, create *Create) error {
+	t.Helper()
+	err := create.Execute(rooms, *client)
+	if err == nil {
+		client.RoomID = create.ID
+	}
+	return err
+}
+
+func drain(client ClientInfo) []outgoing.Message {
+	var messages []outgoing.Message
+	for len(client.Write) > 0 {
+		messages = append(messages, <-client.Write)
+	}
+	return messages
+}
+
+func TestWaitingRoom_pending(t *testing.T) {
+	rooms, owner, joiner := newWaitingRoom(t)
+
+	assert.Equal(t, []outgoing.Message{outgoing.WaitingForApproval{}}, drain(joiner))
+	assert.Equal(t, []outgoing.Message{outgoing.UserWaiting{ID: joiner.ID, Name: "bob"}}, drain(owner))
+	assert.NotContains(t, getRoom(rooms, "room").Users, joiner.ID)
+	assert.Error(t, (&Join{ID: "room"}).Execute(rooms, joiner))
+}
+
+func TestWaitingRoom_admit(t *testing.T) {
+	rooms, owner, joiner := newWaitingRoom(t)
+
+	require.NoError(t, (&AdmitUser{ID: joiner.ID}).Execute(rooms, owner))
+
+	assert.Contains(t, getRoom(rooms, "room").Users, joiner.ID)
+	assert.Empty(t, rooms.waiting)
+	messages := drain(joiner)
+	require.Len(t, messages, 3)
+	assert.IsType(t, outgoing.Room{}, messages[1])
+	assert.IsType(t, outgoing.RoomJoined{}, messages[2])
+}
+
+func TestWaitingRoom_deniedUserLearnsNothing(t *testing.T) {
+	rooms, owner, joiner := newWaitingRoom(t)
+
+	member := newTestClient("carol")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, member))
+	require.NoError(t, (&AdmitUser{ID: member.ID}).Execute(rooms, owner))
+	member.RoomID = "room"
+	require.NoError(t, (&StartShare{}).Execute(rooms, owner))
+	require.NoError(t, (&Name{UserName: "dave"}).Execute(rooms, member))
+	require.NoError(t, (&RejectUser{ID: joiner.ID}).Execute(rooms, owner))
+
+	assert.Equal(t, []outgoing.Message{
+		outgoing.WaitingForApproval{},
+		outgoing.Error{Code: string(CodeRejected), Message: CloseRejected, Room: "room"},
+	}, drain(joiner))
+}
+
+func TestWaitingRoom_onlyOwnerMayAdmit(t *testing.T) {
+	rooms, owner, joiner := newWaitingRoom(t)
+	require.NoError(t, (&AdmitUser{ID: joiner.ID}).Execute(rooms, owner))
+	joiner.RoomID = "room"
+
+	other := newTestClient("carol")
+	require.NoError(t, (&Join{ID: "room"}).Execute(rooms, other))
+
+	assert.EqualError(t, (&AdmitUser{ID: other.ID}).Execute(rooms, joiner), "only the owner can admit or reject users")
+	assert.Contains(t, rooms.waiting, other.ID)
+}
+
+func TestWaitingRoom_rejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		reject func(rooms *Rooms, owner, joiner ClientInfo)
+		code   int
+		reason string
+	}{
+		{
+			name: "by owner",
+			reject: func(rooms *Rooms, owner, joiner ClientInfo) {
+				require.NoError(t, (&RejectUser{ID: joiner.ID}).Execute(rooms, owner))
+			},
+			code:   CloseCodeRejected,
+			reason: CloseRejected,
+		},
+		{
+			name: "owner leaves",
+			reject: func(rooms *Rooms, owner, joiner ClientInfo) {
+				require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+			},
+			code:   CloseCodeRoomClosed,
+			reason: CloseOwnerLeft,
+		},
+		{
+			name: "timeout",
+			reject: func(rooms *Rooms, owner, joiner ClientInfo) {
+				require.NoError(t, (&waitingTimeout{}).Execute(rooms, joiner))
+			},
+			code:   CloseCodeRejected,
+			reason: CloseApprovalTimeout,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rooms, owner, joiner := newWaitingRoom(t)
+
+			test.reject(rooms, owner, joiner)
+
+			assert.Empty(t, rooms.waiting)
+			assert.Equal(t, closeFrame{Code: test.code, Reason: test.reason}, <-joiner.Close)
+			if room, ok := rooms.store.GetRoom("room"); ok {
+				assert.NotContains(t, room.Users, joiner.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	WebhookRoomCreated      = "room.created"
+	WebhookRoomClosed       = "room.closed"
+	WebhookUserJoined       = "user.joined"
+	WebhookUserLeft         = "user.left"
+	WebhookScreenshareStart = "user.screenshare.started"
+	WebhookScreenshareStop  = "user.screenshare.stopped"
+
+	webhookRetries   = 3
+	webhookQueueSize = 100
+)
+
+// webhookBackoff is the delay before the first retry, it doubles with every retry.
+var webhookBackoff = time.Second
+
+// WebhookEvent is posted as json to SCREEGO_WEBHOOK_URL.
+type WebhookEvent struct {
+	Event     string       `json:"event"`
+	Timestamp time.Time    `json:"timestamp"`
+	Room      string       `json:"room"`
+	User      *WebhookUser `json:"user,omitempty"`
+}
+
+type WebhookUser struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// webhooks delivers events in order in its own goroutine, so slow receivers don't block the rooms event loop.
+type webhooks struct {
+	url    string
+	secret []byte
+	client *http.Client
+	queue  chan WebhookEvent
+}
+
+func newWebhooks(url, secret string, timeout time.Duration) *webhooks {
+	w := &webhooks{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: timeout},
+		queue:  make(chan WebhookEvent, webhookQueueSize),
+	}
+	go w.run()
+	return w
+}
+
+// webhook publishes an event to the event feed and the other instances and queues it for delivery, if a webhook is
+// configured.
+func (r *Rooms) webhook(event, roomID string, user *User) {
+	e := WebhookEvent{Event: event, Timestamp: time.Now(), Room: roomID}
+	if user != nil {
+		e.User = &WebhookUser{ID: user.ID.String(), Name: user.Name}
+	}
+	r.feed.publish(e)
+	if r.shared != nil {
+		r.shared.publish(e)
+	}
+	if r.webhooks == nil {
+		return
+	}
+
+	select {
+	case r.webhooks.queue <- e:
+	default:
+		log.Warn().Str("event", event).Str("room", roomID).Msg("Webhook queue is full, dropping event")
+	}
+}
+
+func (w *webhooks) run() {
+	for event := range w.queue {
+		body, err := json.Marshal(event)
+		if err != nil {
+			log.Error().Err(err).Msg("Webhook encode")
+			continue
+		}
+
+		backoff := webhookBackoff
+		for attempt := 0; ; attempt++ {
+			err = w.deliver(body)
+			if err == nil || attempt == webhookRetries {
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		if err != nil {
+			log.Warn().Err(err).Str("event", event.Event).Str("room", event.Room).Msg("Webhook delivery failed")
+		}
+	}
+}
+
+func (w *webhooks) deliver(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Screego-Signature", "sha256="+w.sign(body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *webhooks) sign(body []byte) string {
+	mac := hmac.New(sha256.New, w.secret)
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package ws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/screego/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooks(t *testing.T) {
+	old := webhookBackoff
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = old }()
+
+	received := make(chan WebhookEvent, 20)
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), r.Header.Get("X-Screego-Signature"))
+
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var event WebhookEvent
+		require.NoError(t, json.Unmarshal(body, &event))
+		received <- event
+	}))
+	defer server.Close()
+
+	rooms := newTestRooms(config.Config{WebhookURL: server.URL, WebhookSecret: "secret", WebhookTimeout: time.Second})
+	owner := newTestClient("alice")
+	bob := newTestClient("")
+	require.NoError(t, createRoom(t, rooms, &owner, "room"))
+	require.NoError(t, (&Join{ID: "room", UserName: "bob"}).Execute(rooms, bob))
+	bob.RoomID = "room"
+	require.NoError(t, (&StartShare{}).Execute(rooms, bob))
+	require.NoError(t, (&StopShare{}).Execute(rooms, bob))
+	require.NoError(t, (&Disconnected{}).Execute(rooms, bob))
+	require.NoError(t, (&Disconnected{}).Execute(rooms, owner))
+
+	expected := []struct{ event, user string }{
+		{WebhookRoomCreated, ""},
+		{WebhookUserJoined, "alice"},
+		{WebhookUserJoined, "bob"},
+		{WebhookScreenshareStart, "bob"},
+		{WebhookScreenshareStop, "bob"},
+		{WebhookUserLeft, "bob"},
+		{WebhookUserLeft, "alice"},
+		{WebhookRoomClosed, ""},
+	}
+	for _, e := range expected {
+		select {
+		case event := <-received:
+			assert.Equal(t, e.event, event.Event)
+			assert.Equal(t, "room", event.Room)
+			if e.user == "" {
+				assert.Nil(t, event.User)
+			} else if assert.NotNil(t, event.User, e.event) {
+				assert.Equal(t, e.user, event.User.Name)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("webhook %s was not delivered", e.event)
+		}
+	}
+}